@@ -0,0 +1,200 @@
+package dh
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// Стандартные MODP-группы: p — безопасное простое (p = 2q + 1, q простое), g = 2
+// порождает подгруппу порядка q. Группа передаётся в приглашении по имени,
+// поэтому собеседникам не нужно генерировать и пересылать собственное простое.
+const (
+	GroupMODP2048  = "modp2048"
+	GroupMODP3072  = "modp3072"
+	GroupMODP4096  = "modp4096"
+	GroupFFDHE2048 = "ffdhe2048"
+	GroupFFDHE3072 = "ffdhe3072"
+	GroupFFDHE4096 = "ffdhe4096"
+
+	DefaultGroup = GroupFFDHE2048
+)
+
+var (
+	ErrUnknownGroup     = errors.New("unknown DH group")
+	ErrInvalidPublicKey = errors.New("invalid DH public key")
+)
+
+type Group struct {
+	Name string
+	P    *big.Int
+	G    *big.Int
+	// Q — порядок подгруппы, порождённой G: (P - 1) / 2.
+	Q *big.Int
+}
+
+var groups = map[string]*Group{}
+
+func init() {
+	registerGroup(GroupMODP2048, modp2048Hex)
+	registerGroup(GroupMODP3072, modp3072Hex)
+	registerGroup(GroupMODP4096, modp4096Hex)
+	registerGroup(GroupFFDHE2048, ffdhe2048Hex)
+	registerGroup(GroupFFDHE3072, ffdhe3072Hex)
+	registerGroup(GroupFFDHE4096, ffdhe4096Hex)
+}
+
+func registerGroup(name, primeHex string) {
+	p, ok := new(big.Int).SetString(primeHex, 16)
+	if !ok {
+		panic("dh: invalid prime for group " + name)
+	}
+	q := new(big.Int).Rsh(p, 1)
+	groups[name] = &Group{Name: name, P: p, G: big.NewInt(2), Q: q}
+}
+
+// LookupGroup ищет группу по имени без учёта регистра.
+func LookupGroup(name string) (*Group, error) {
+	g, ok := groups[strings.ToLower(name)]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownGroup, name)
+	}
+	return g, nil
+}
+
+// GeneratePrivateKey возвращает случайный показатель из [2, q-1].
+func (g *Group) GeneratePrivateKey() (*big.Int, error) {
+	limit := new(big.Int).Sub(g.Q, big.NewInt(2))
+	x, err := rand.Int(rand.Reader, limit)
+	if err != nil {
+		return nil, err
+	}
+	return x.Add(x, big.NewInt(2)), nil
+}
+
+func (g *Group) PublicKey(privateKey *big.Int) *big.Int {
+	return GeneratePublicKey(g.G, privateKey, g.P)
+}
+
+// ValidatePublicKey отбрасывает ключи вне [2, p-2] и ключи не из подгруппы порядка q:
+// иначе собеседник может навязать общий ключ из маленькой подгруппы.
+func (g *Group) ValidatePublicKey(y *big.Int) error {
+	if y == nil {
+		return ErrInvalidPublicKey
+	}
+	upper := new(big.Int).Sub(g.P, big.NewInt(2))
+	if y.Cmp(big.NewInt(2)) < 0 || y.Cmp(upper) > 0 {
+		return fmt.Errorf("%w: out of range", ErrInvalidPublicKey)
+	}
+	if new(big.Int).Exp(y, g.Q, g.P).Cmp(big.NewInt(1)) != 0 {
+		return fmt.Errorf("%w: not in the prime-order subgroup", ErrInvalidPublicKey)
+	}
+	return nil
+}
+
+// GenerateKey и SharedSecret работают с ключами в виде big-endian байтов длины p,
+// как схемы ECDH, — чтобы храповик мог использовать любую из них.
+func (g *Group) GenerateKey() (privateKey, publicKey []byte, err error) {
+	x, err := g.GeneratePrivateKey()
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not generate private key: %w", err)
+	}
+	return SharedSecretBytes(x, g.P), SharedSecretBytes(g.PublicKey(x), g.P), nil
+}
+
+func (g *Group) SharedSecret(privateKey, peerPublicKey []byte) ([]byte, error) {
+	y := new(big.Int).SetBytes(peerPublicKey)
+	if err := g.ValidatePublicKey(y); err != nil {
+		return nil, err
+	}
+	x := new(big.Int).SetBytes(privateKey)
+	return SharedSecretBytes(GenerateSharedKey(x, y, g.P), g.P), nil
+}
+
+const (
+	// RFC 3526, группа 14
+	modp2048Hex = "FFFFFFFFFFFFFFFFC90FDAA22168C234C4C6628B80DC1CD129024E088A67CC74" +
+		"020BBEA63B139B22514A08798E3404DDEF9519B3CD3A431B302B0A6DF25F1437" +
+		"4FE1356D6D51C245E485B576625E7EC6F44C42E9A637ED6B0BFF5CB6F406B7ED" +
+		"EE386BFB5A899FA5AE9F24117C4B1FE649286651ECE45B3DC2007CB8A163BF05" +
+		"98DA48361C55D39A69163FA8FD24CF5F83655D23DCA3AD961C62F356208552BB" +
+		"9ED529077096966D670C354E4ABC9804F1746C08CA18217C32905E462E36CE3B" +
+		"E39E772C180E86039B2783A2EC07A28FB5C55DF06F4C52C9DE2BCBF695581718" +
+		"3995497CEA956AE515D2261898FA051015728E5A8AACAA68FFFFFFFFFFFFFFFF"
+
+	// RFC 3526, группа 15
+	modp3072Hex = "FFFFFFFFFFFFFFFFC90FDAA22168C234C4C6628B80DC1CD129024E088A67CC74" +
+		"020BBEA63B139B22514A08798E3404DDEF9519B3CD3A431B302B0A6DF25F1437" +
+		"4FE1356D6D51C245E485B576625E7EC6F44C42E9A637ED6B0BFF5CB6F406B7ED" +
+		"EE386BFB5A899FA5AE9F24117C4B1FE649286651ECE45B3DC2007CB8A163BF05" +
+		"98DA48361C55D39A69163FA8FD24CF5F83655D23DCA3AD961C62F356208552BB" +
+		"9ED529077096966D670C354E4ABC9804F1746C08CA18217C32905E462E36CE3B" +
+		"E39E772C180E86039B2783A2EC07A28FB5C55DF06F4C52C9DE2BCBF695581718" +
+		"3995497CEA956AE515D2261898FA051015728E5A8AAAC42DAD33170D04507A33" +
+		"A85521ABDF1CBA64ECFB850458DBEF0A8AEA71575D060C7DB3970F85A6E1E4C7" +
+		"ABF5AE8CDB0933D71E8C94E04A25619DCEE3D2261AD2EE6BF12FFA06D98A0864" +
+		"D87602733EC86A64521F2B18177B200CBBE117577A615D6C770988C0BAD946E2" +
+		"08E24FA074E5AB3143DB5BFCE0FD108E4B82D120A93AD2CAFFFFFFFFFFFFFFFF"
+
+	// RFC 3526, группа 16
+	modp4096Hex = "FFFFFFFFFFFFFFFFC90FDAA22168C234C4C6628B80DC1CD129024E088A67CC74" +
+		"020BBEA63B139B22514A08798E3404DDEF9519B3CD3A431B302B0A6DF25F1437" +
+		"4FE1356D6D51C245E485B576625E7EC6F44C42E9A637ED6B0BFF5CB6F406B7ED" +
+		"EE386BFB5A899FA5AE9F24117C4B1FE649286651ECE45B3DC2007CB8A163BF05" +
+		"98DA48361C55D39A69163FA8FD24CF5F83655D23DCA3AD961C62F356208552BB" +
+		"9ED529077096966D670C354E4ABC9804F1746C08CA18217C32905E462E36CE3B" +
+		"E39E772C180E86039B2783A2EC07A28FB5C55DF06F4C52C9DE2BCBF695581718" +
+		"3995497CEA956AE515D2261898FA051015728E5A8AAAC42DAD33170D04507A33" +
+		"A85521ABDF1CBA64ECFB850458DBEF0A8AEA71575D060C7DB3970F85A6E1E4C7" +
+		"ABF5AE8CDB0933D71E8C94E04A25619DCEE3D2261AD2EE6BF12FFA06D98A0864" +
+		"D87602733EC86A64521F2B18177B200CBBE117577A615D6C770988C0BAD946E2" +
+		"08E24FA074E5AB3143DB5BFCE0FD108E4B82D120A92108011A723C12A787E6D7" +
+		"88719A10BDBA5B2699C327186AF4E23C1A946834B6150BDA2583E9CA2AD44CE8" +
+		"DBBBC2DB04DE8EF92E8EFC141FBECAA6287C59474E6BC05D99B2964FA090C3A2" +
+		"233BA186515BE7ED1F612970CEE2D7AFB81BDD762170481CD0069127D5B05AA9" +
+		"93B4EA988D8FDDC186FFB7DC90A6C08F4DF435C934063199FFFFFFFFFFFFFFFF"
+
+	// RFC 7919
+	ffdhe2048Hex = "FFFFFFFFFFFFFFFFADF85458A2BB4A9AAFDC5620273D3CF1D8B9C583CE2D3695" +
+		"A9E13641146433FBCC939DCE249B3EF97D2FE363630C75D8F681B202AEC4617A" +
+		"D3DF1ED5D5FD65612433F51F5F066ED0856365553DED1AF3B557135E7F57C935" +
+		"984F0C70E0E68B77E2A689DAF3EFE8721DF158A136ADE73530ACCA4F483A797A" +
+		"BC0AB182B324FB61D108A94BB2C8E3FBB96ADAB760D7F4681D4F42A3DE394DF4" +
+		"AE56EDE76372BB190B07A7C8EE0A6D709E02FCE1CDF7E2ECC03404CD28342F61" +
+		"9172FE9CE98583FF8E4F1232EEF28183C3FE3B1B4C6FAD733BB5FCBC2EC22005" +
+		"C58EF1837D1683B2C6F34A26C1B2EFFA886B423861285C97FFFFFFFFFFFFFFFF"
+
+	// RFC 7919
+	ffdhe3072Hex = "FFFFFFFFFFFFFFFFADF85458A2BB4A9AAFDC5620273D3CF1D8B9C583CE2D3695" +
+		"A9E13641146433FBCC939DCE249B3EF97D2FE363630C75D8F681B202AEC4617A" +
+		"D3DF1ED5D5FD65612433F51F5F066ED0856365553DED1AF3B557135E7F57C935" +
+		"984F0C70E0E68B77E2A689DAF3EFE8721DF158A136ADE73530ACCA4F483A797A" +
+		"BC0AB182B324FB61D108A94BB2C8E3FBB96ADAB760D7F4681D4F42A3DE394DF4" +
+		"AE56EDE76372BB190B07A7C8EE0A6D709E02FCE1CDF7E2ECC03404CD28342F61" +
+		"9172FE9CE98583FF8E4F1232EEF28183C3FE3B1B4C6FAD733BB5FCBC2EC22005" +
+		"C58EF1837D1683B2C6F34A26C1B2EFFA886B4238611FCFDCDE355B3B6519035B" +
+		"BC34F4DEF99C023861B46FC9D6E6C9077AD91D2691F7F7EE598CB0FAC186D91C" +
+		"AEFE130985139270B4130C93BC437944F4FD4452E2D74DD364F2E21E71F54BFF" +
+		"5CAE82AB9C9DF69EE86D2BC522363A0DABC521979B0DEADA1DBF9A42D5C4484E" +
+		"0ABCD06BFA53DDEF3C1B20EE3FD59D7C25E41D2B66C62E37FFFFFFFFFFFFFFFF"
+
+	// RFC 7919
+	ffdhe4096Hex = "FFFFFFFFFFFFFFFFADF85458A2BB4A9AAFDC5620273D3CF1D8B9C583CE2D3695" +
+		"A9E13641146433FBCC939DCE249B3EF97D2FE363630C75D8F681B202AEC4617A" +
+		"D3DF1ED5D5FD65612433F51F5F066ED0856365553DED1AF3B557135E7F57C935" +
+		"984F0C70E0E68B77E2A689DAF3EFE8721DF158A136ADE73530ACCA4F483A797A" +
+		"BC0AB182B324FB61D108A94BB2C8E3FBB96ADAB760D7F4681D4F42A3DE394DF4" +
+		"AE56EDE76372BB190B07A7C8EE0A6D709E02FCE1CDF7E2ECC03404CD28342F61" +
+		"9172FE9CE98583FF8E4F1232EEF28183C3FE3B1B4C6FAD733BB5FCBC2EC22005" +
+		"C58EF1837D1683B2C6F34A26C1B2EFFA886B4238611FCFDCDE355B3B6519035B" +
+		"BC34F4DEF99C023861B46FC9D6E6C9077AD91D2691F7F7EE598CB0FAC186D91C" +
+		"AEFE130985139270B4130C93BC437944F4FD4452E2D74DD364F2E21E71F54BFF" +
+		"5CAE82AB9C9DF69EE86D2BC522363A0DABC521979B0DEADA1DBF9A42D5C4484E" +
+		"0ABCD06BFA53DDEF3C1B20EE3FD59D7C25E41D2B669E1EF16E6F52C3164DF4FB" +
+		"7930E9E4E58857B6AC7D5F42D69F6D187763CF1D5503400487F55BA57E31CC7A" +
+		"7135C886EFB4318AED6A1E012D9E6832A907600A918130C46DC778F971AD0038" +
+		"092999A333CB8B7A1A1DB93D7140003C2A4ECEA9F98D0ACC0A8291CDCEC97DCF" +
+		"8EC9B55A7F88A46B4DB5A851F44182E1C68A007E5E655F6AFFFFFFFFFFFFFFFF"
+)
@@ -0,0 +1,130 @@
+package dh
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"testing"
+)
+
+// Простые RFC 3526 строятся из цифр π, RFC 7919 — из цифр e; у всех старшие
+// и младшие 64 бита единичные.
+func TestLookupGroupReturnsRFCPrimes(t *testing.T) {
+	tests := []struct {
+		name   string
+		bits   int
+		digits string
+	}{
+		{GroupMODP2048, 2048, "C90FDAA22168C234"},
+		{GroupMODP3072, 3072, "C90FDAA22168C234"},
+		{GroupMODP4096, 4096, "C90FDAA22168C234"},
+		{GroupFFDHE2048, 2048, "ADF85458A2BB4A9A"},
+		{GroupFFDHE3072, 3072, "ADF85458A2BB4A9A"},
+		{GroupFFDHE4096, 4096, "ADF85458A2BB4A9A"},
+	}
+	if len(groups) != len(tests) {
+		t.Fatalf("%d groups registered, want %d", len(groups), len(tests))
+	}
+
+	ones := new(big.Int).SetUint64(^uint64(0))
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g, err := LookupGroup(strings.ToUpper(tt.name))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if g.P.BitLen() != tt.bits {
+				t.Fatalf("prime has %d bits, want %d", g.P.BitLen(), tt.bits)
+			}
+			hexP := fmt.Sprintf("%X", g.P)
+			if !strings.HasPrefix(hexP, "FFFFFFFFFFFFFFFF"+tt.digits) {
+				t.Fatalf("prime starts with %s", hexP[:32])
+			}
+			if new(big.Int).And(g.P, ones).Cmp(ones) != 0 {
+				t.Fatal("low 64 bits of the prime are not all ones")
+			}
+			if g.G.Cmp(big.NewInt(2)) != 0 {
+				t.Fatalf("generator = %v", g.G)
+			}
+			// Безопасное простое: p = 2q + 1, оба простые
+			if new(big.Int).Add(new(big.Int).Lsh(g.Q, 1), big.NewInt(1)).Cmp(g.P) != 0 {
+				t.Fatal("q != (p-1)/2")
+			}
+			if !g.P.ProbablyPrime(1) || !g.Q.ProbablyPrime(1) {
+				t.Fatal("p is not a safe prime")
+			}
+		})
+	}
+
+	if _, err := LookupGroup("modp1024"); !errors.Is(err, ErrUnknownGroup) {
+		t.Fatalf("unknown group: got %v", err)
+	}
+}
+
+func TestValidatePublicKey(t *testing.T) {
+	for name, g := range groups {
+		t.Run(name, func(t *testing.T) {
+			one := big.NewInt(1)
+			pMinus1 := new(big.Int).Sub(g.P, one)
+			x, err := g.GeneratePrivateKey()
+			if err != nil {
+				t.Fatal(err)
+			}
+			gx := g.PublicKey(x)
+
+			rejected := map[string]*big.Int{
+				"nil":      nil,
+				"negative": big.NewInt(-2),
+				"0":        big.NewInt(0),
+				"1":        one,
+				"p-1":      pMinus1,
+				"p":        g.P,
+				"p+2":      new(big.Int).Add(g.P, big.NewInt(2)),
+				// -g^x имеет порядок 2q: вне подгруппы порядка q
+				"-g^x": new(big.Int).Sub(g.P, gx),
+			}
+			for label, y := range rejected {
+				if err := g.ValidatePublicKey(y); !errors.Is(err, ErrInvalidPublicKey) {
+					t.Errorf("%s: got %v, want %v", label, err, ErrInvalidPublicKey)
+				}
+			}
+
+			for label, y := range map[string]*big.Int{"g": g.G, "g^x": gx} {
+				if err := g.ValidatePublicKey(y); err != nil {
+					t.Errorf("%s: %v", label, err)
+				}
+			}
+		})
+	}
+}
+
+// Ключ из подгруппы порядка 2 сводит общий секрет к 1 или p-1: SharedSecret его не принимает.
+func TestSharedSecretRejectsSmallSubgroup(t *testing.T) {
+	g, _ := LookupGroup(DefaultGroup)
+	privateKey, publicKey, err := g.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	peerPrivate, peerPublic, err := g.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	secret, err := g.SharedSecret(privateKey, peerPublic)
+	if err != nil {
+		t.Fatal(err)
+	}
+	peerSecret, err := g.SharedSecret(peerPrivate, publicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(secret, peerSecret) {
+		t.Fatal("shared secrets differ")
+	}
+
+	pMinus1 := new(big.Int).Sub(g.P, big.NewInt(1))
+	if _, err = g.SharedSecret(privateKey, pMinus1.Bytes()); !errors.Is(err, ErrInvalidPublicKey) {
+		t.Fatalf("p-1: got %v", err)
+	}
+}
@@ -0,0 +1,155 @@
+package dh
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"golang.org/x/crypto/hkdf"
+	"io"
+	"math/big"
+	"sort"
+)
+
+// Ключи комнаты выводятся из общего секрета DH через HKDF-SHA256:
+//
+//	PRK = HKDF-Extract(salt, secret)
+//	key = HKDF-Expand(PRK, label || 0x00 || transcript, length)
+//
+// Для ключа шифрования, ключа MAC и затравки IV используются разные метки,
+// поэтому знание одного из них ничего не говорит о других. Транскрипт содержит
+// параметры комнаты: если участники видят разные параметры, ключи не совпадут.
+const (
+	kdfSalt = "CryptoMessenger DH key schedule v1"
+
+	labelEncryptionKey = "encryption key"
+	labelMACKey        = "mac key"
+	labelIVSeed        = "iv seed"
+)
+
+var ErrEmptySecret = errors.New("shared secret is empty")
+
+// Transcript — параметры комнаты, которые входят в вывод ключей.
+type Transcript struct {
+	RoomID string
+	// KeyAgreement — схема согласования. Классический DH ("" или "DH") не кодируется,
+	// чтобы ключи комнат, созданных до выбора схемы, не изменились.
+	KeyAgreement string
+	Algorithm    string
+	Mode         string
+	Padding      string
+	Params       map[string]int
+	KeyLength    int
+	Prime        *big.Int
+	G            *big.Int
+	IV           []byte
+	RandomDelta  []byte
+	// Epoch — номер ключа комнаты после смен ключа. Нулевая эпоха не кодируется,
+	// чтобы ключи комнат, созданных до появления смены ключа, не изменились.
+	Epoch int
+}
+
+// Bytes кодирует транскрипт однозначно: каждое поле с префиксом длины, параметры по имени.
+func (t Transcript) Bytes() []byte {
+	var buf []byte
+	field := func(b []byte) {
+		buf = binary.BigEndian.AppendUint32(buf, uint32(len(b)))
+		buf = append(buf, b...)
+	}
+	number := func(n int) {
+		buf = binary.BigEndian.AppendUint64(buf, uint64(n))
+	}
+	bigInt := func(n *big.Int) {
+		if n == nil {
+			field(nil)
+			return
+		}
+		field(n.Bytes())
+	}
+
+	field([]byte(t.RoomID))
+	if t.KeyAgreement != "" && t.KeyAgreement != "DH" {
+		field([]byte(t.KeyAgreement))
+	}
+	field([]byte(t.Algorithm))
+	field([]byte(t.Mode))
+	field([]byte(t.Padding))
+
+	names := make([]string, 0, len(t.Params))
+	for name := range t.Params {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	number(len(names))
+	for _, name := range names {
+		field([]byte(name))
+		number(t.Params[name])
+	}
+
+	number(t.KeyLength)
+	bigInt(t.Prime)
+	bigInt(t.G)
+	field(t.IV)
+	field(t.RandomDelta)
+	if t.Epoch != 0 {
+		number(t.Epoch)
+	}
+
+	return buf
+}
+
+// KeyLengths — длины выводимых ключей в байтах.
+type KeyLengths struct {
+	Encryption int
+	MAC        int
+	IVSeed     int
+}
+
+type RoomKeys struct {
+	EncryptionKey []byte
+	MACKey        []byte
+	IVSeed        []byte
+}
+
+// SharedSecretBytes возвращает общий ключ в виде big-endian строки длины p,
+// чтобы ведущие нули не зависели от конкретного значения.
+func SharedSecretBytes(sharedKey, prime *big.Int) []byte {
+	return sharedKey.FillBytes(make([]byte, (prime.BitLen()+7)/8))
+}
+
+// DeriveRoomKeys выводит ключ шифрования, ключ MAC и затравку IV из общего секрета.
+func DeriveRoomKeys(secret []byte, transcript Transcript, lengths KeyLengths) (*RoomKeys, error) {
+	if len(secret) == 0 {
+		return nil, ErrEmptySecret
+	}
+
+	prk := hkdf.Extract(sha256.New, secret, []byte(kdfSalt))
+	info := transcript.Bytes()
+
+	expand := func(label string, length int) ([]byte, error) {
+		if length < 0 {
+			return nil, fmt.Errorf("invalid %s length: %d", label, length)
+		}
+		out := make([]byte, length)
+		r := hkdf.Expand(sha256.New, prk, append(append([]byte(label), 0), info...))
+		if _, err := io.ReadFull(r, out); err != nil {
+			return nil, fmt.Errorf("derive %s: %w", label, err)
+		}
+		return out, nil
+	}
+
+	var (
+		keys RoomKeys
+		err  error
+	)
+	if keys.EncryptionKey, err = expand(labelEncryptionKey, lengths.Encryption); err != nil {
+		return nil, err
+	}
+	if keys.MACKey, err = expand(labelMACKey, lengths.MAC); err != nil {
+		return nil, err
+	}
+	if keys.IVSeed, err = expand(labelIVSeed, lengths.IVSeed); err != nil {
+		return nil, err
+	}
+	return &keys, nil
+}
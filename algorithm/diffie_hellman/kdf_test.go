@@ -0,0 +1,144 @@
+package dh
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"golang.org/x/crypto/hkdf"
+	"io"
+	"math/big"
+	"testing"
+)
+
+func sampleTranscript() Transcript {
+	return Transcript{
+		RoomID:      "r1",
+		Algorithm:   "RC6",
+		Mode:        "CBC",
+		Padding:     "PKCS7",
+		Params:      map[string]int{"w": 32, "r": 20},
+		KeyLength:   32,
+		Prime:       big.NewInt(23),
+		G:           big.NewInt(5),
+		IV:          []byte{1, 2},
+		RandomDelta: []byte{3},
+	}
+}
+
+// Транскрипт комнат DH должен совпадать с кодировкой до появления KeyAgreement,
+// иначе у существующих комнат изменятся ключи.
+func TestTranscriptKeepsLegacyDHEncoding(t *testing.T) {
+	const legacy = "09207cbe0123a2d4b8beef3c8765e2f9f70e03a9ecd9e6655e5eb7e386085e75"
+
+	for _, keyAgreement := range []string{"", "DH"} {
+		transcript := sampleTranscript()
+		transcript.KeyAgreement = keyAgreement
+		sum := sha256.Sum256(transcript.Bytes())
+		if got := hex.EncodeToString(sum[:]); got != legacy {
+			t.Errorf("key agreement %q: transcript hash %s, want %s", keyAgreement, got, legacy)
+		}
+	}
+
+	ecdh := sampleTranscript()
+	ecdh.KeyAgreement = "X25519"
+	if bytes.Equal(ecdh.Bytes(), sampleTranscript().Bytes()) {
+		t.Error("ECDH transcript must differ from the DH one")
+	}
+}
+
+// Каждый ключ — HKDF-Expand со своей меткой: label || 0x00 || транскрипт (RFC 5869).
+func TestDeriveRoomKeysLabels(t *testing.T) {
+	secret := bytes.Repeat([]byte{0x0b}, 32)
+	transcript := sampleTranscript()
+	lengths := KeyLengths{Encryption: 24, MAC: 32, IVSeed: 16}
+
+	keys, err := DeriveRoomKeys(secret, transcript, lengths)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	prk := hkdf.Extract(sha256.New, secret, []byte(kdfSalt))
+	tests := []struct {
+		label string
+		got   []byte
+		size  int
+	}{
+		{labelEncryptionKey, keys.EncryptionKey, lengths.Encryption},
+		{labelMACKey, keys.MACKey, lengths.MAC},
+		{labelIVSeed, keys.IVSeed, lengths.IVSeed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.label, func(t *testing.T) {
+			want := make([]byte, tt.size)
+			info := append(append([]byte(tt.label), 0), transcript.Bytes()...)
+			if _, err := io.ReadFull(hkdf.Expand(sha256.New, prk, info), want); err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(tt.got, want) {
+				t.Fatalf("got %x, want %x", tt.got, want)
+			}
+		})
+	}
+
+	// Разные метки дают независимые ключи даже при одинаковой длине
+	equal, err := DeriveRoomKeys(secret, transcript, KeyLengths{Encryption: 32, MAC: 32, IVSeed: 32})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(equal.EncryptionKey, equal.MACKey) || bytes.Equal(equal.MACKey, equal.IVSeed) ||
+		bytes.Equal(equal.EncryptionKey, equal.IVSeed) {
+		t.Fatal("keys with different labels coincide")
+	}
+	if !bytes.HasPrefix(equal.EncryptionKey, keys.EncryptionKey) {
+		t.Fatal("key depends on the length of the other keys")
+	}
+}
+
+// Ключи меняются при изменении любого параметра комнаты.
+func TestDeriveRoomKeysBindsTranscript(t *testing.T) {
+	secret := bytes.Repeat([]byte{0x0b}, 32)
+	lengths := KeyLengths{Encryption: 32, MAC: 32, IVSeed: 16}
+	base, err := DeriveRoomKeys(secret, sampleTranscript(), lengths)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		change func(*Transcript)
+	}{
+		{"room", func(tr *Transcript) { tr.RoomID = "r2" }},
+		{"key agreement", func(tr *Transcript) { tr.KeyAgreement = "X25519" }},
+		{"algorithm", func(tr *Transcript) { tr.Algorithm = "RC5" }},
+		{"mode", func(tr *Transcript) { tr.Mode = "CTR" }},
+		{"padding", func(tr *Transcript) { tr.Padding = "Zeros" }},
+		{"rounds", func(tr *Transcript) { tr.Params = map[string]int{"w": 32, "r": 12} }},
+		{"key length", func(tr *Transcript) { tr.KeyLength = 16 }},
+		{"prime", func(tr *Transcript) { tr.Prime = big.NewInt(47) }},
+		{"generator", func(tr *Transcript) { tr.G = big.NewInt(2) }},
+		{"IV", func(tr *Transcript) { tr.IV = []byte{1, 3} }},
+		{"random delta", func(tr *Transcript) { tr.RandomDelta = []byte{4} }},
+		{"epoch", func(tr *Transcript) { tr.Epoch = 1 }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			transcript := sampleTranscript()
+			tt.change(&transcript)
+			keys, err := DeriveRoomKeys(secret, transcript, lengths)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if bytes.Equal(keys.EncryptionKey, base.EncryptionKey) || bytes.Equal(keys.MACKey, base.MACKey) ||
+				bytes.Equal(keys.IVSeed, base.IVSeed) {
+				t.Fatal("keys do not depend on the changed parameter")
+			}
+		})
+	}
+}
+
+func TestDeriveRoomKeysRejectsEmptySecret(t *testing.T) {
+	if _, err := DeriveRoomKeys(nil, sampleTranscript(), KeyLengths{Encryption: 16}); !errors.Is(err, ErrEmptySecret) {
+		t.Fatalf("got %v, want %v", err, ErrEmptySecret)
+	}
+}
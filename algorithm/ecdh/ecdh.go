@@ -0,0 +1,87 @@
+package ecdh
+
+import (
+	cryptoecdh "crypto/ecdh"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Согласование ключа на эллиптических кривых — альтернатива классическому DH:
+// открытый ключ X25519 занимает 32 байта вместо 256 байт для 2048-битной группы.
+const (
+	SchemeX25519 = "X25519"
+	SchemeP256   = "P-256"
+)
+
+var (
+	ErrUnknownScheme    = errors.New("unknown key agreement scheme")
+	ErrInvalidPublicKey = errors.New("invalid ECDH public key")
+)
+
+type Scheme struct {
+	Name  string
+	curve cryptoecdh.Curve
+}
+
+var schemes = []*Scheme{
+	{Name: SchemeX25519, curve: cryptoecdh.X25519()},
+	{Name: SchemeP256, curve: cryptoecdh.P256()},
+}
+
+// LookupScheme ищет схему по имени без учёта регистра.
+func LookupScheme(name string) (*Scheme, error) {
+	for _, s := range schemes {
+		if strings.EqualFold(s.Name, name) {
+			return s, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %q", ErrUnknownScheme, name)
+}
+
+// Schemes возвращает имена схем в порядке предпочтения — для списков в UI.
+func Schemes() []string {
+	names := make([]string, 0, len(schemes))
+	for _, s := range schemes {
+		names = append(names, s.Name)
+	}
+	return names
+}
+
+// GenerateKey возвращает закрытый и открытый ключи в кодировке кривой
+// (для P-256 открытый ключ — несжатая точка).
+func (s *Scheme) GenerateKey() (privateKey, publicKey []byte, err error) {
+	key, err := s.curve.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not generate %s key: %w", s.Name, err)
+	}
+	return key.Bytes(), key.PublicKey().Bytes(), nil
+}
+
+// ValidatePublicKey проверяет длину ключа и, для P-256, что точка лежит на кривой.
+func (s *Scheme) ValidatePublicKey(publicKey []byte) error {
+	if _, err := s.curve.NewPublicKey(publicKey); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidPublicKey, err)
+	}
+	return nil
+}
+
+// SharedSecret вычисляет общий секрет. Для X25519 ключи малого порядка,
+// дающие нулевой секрет, отвергаются.
+func (s *Scheme) SharedSecret(privateKey, peerPublicKey []byte) ([]byte, error) {
+	private, err := s.curve.NewPrivateKey(privateKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s private key: %w", s.Name, err)
+	}
+	public, err := s.curve.NewPublicKey(peerPublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidPublicKey, err)
+	}
+
+	secret, err := private.ECDH(public)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidPublicKey, err)
+	}
+	return secret, nil
+}
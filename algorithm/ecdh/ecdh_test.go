@@ -0,0 +1,127 @@
+package ecdh_test
+
+import (
+	"CryptoMessenger/algorithm/ecdh"
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"testing"
+)
+
+func TestSharedSecretAgrees(t *testing.T) {
+	for _, name := range ecdh.Schemes() {
+		t.Run(name, func(t *testing.T) {
+			s, err := ecdh.LookupScheme(name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			alicePrivate, alicePublic, err := s.GenerateKey()
+			if err != nil {
+				t.Fatal(err)
+			}
+			bobPrivate, bobPublic, err := s.GenerateKey()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err = s.ValidatePublicKey(alicePublic); err != nil {
+				t.Fatal(err)
+			}
+
+			alice, err := s.SharedSecret(alicePrivate, bobPublic)
+			if err != nil {
+				t.Fatal(err)
+			}
+			bob, err := s.SharedSecret(bobPrivate, alicePublic)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(alice) != 32 || !bytes.Equal(alice, bob) {
+				t.Fatalf("alice %x, bob %x", alice, bob)
+			}
+		})
+	}
+}
+
+func TestLookupScheme(t *testing.T) {
+	for name, want := range map[string]string{"x25519": ecdh.SchemeX25519, "p-256": ecdh.SchemeP256} {
+		s, err := ecdh.LookupScheme(name)
+		if err != nil || s.Name != want {
+			t.Fatalf("%s: got %v, %v", name, s, err)
+		}
+	}
+	if _, err := ecdh.LookupScheme("P-384"); !errors.Is(err, ecdh.ErrUnknownScheme) {
+		t.Fatalf("got %v, want %v", err, ecdh.ErrUnknownScheme)
+	}
+}
+
+// Точки малого порядка X25519 (список из libsodium) дают нулевой секрет.
+func TestX25519RejectsLowOrderPoints(t *testing.T) {
+	lowOrder := []string{
+		"0000000000000000000000000000000000000000000000000000000000000000",
+		"0100000000000000000000000000000000000000000000000000000000000000",
+		"e0eb7a7c3b41b8ae1656e3faf19fc46ada098deb9c32b1fd866205165f49b800",
+		"5f9c95bca3508c24b1d0b1559c83ef5b04445cc4581c8e86d8224eddd09f1157",
+		"ecffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff7f",
+		"edffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff7f",
+		"eeffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff7f",
+	}
+
+	s, _ := ecdh.LookupScheme(ecdh.SchemeX25519)
+	privateKey, _, err := s.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, point := range lowOrder {
+		publicKey, _ := hex.DecodeString(point)
+		if _, err = s.SharedSecret(privateKey, publicKey); !errors.Is(err, ecdh.ErrInvalidPublicKey) {
+			t.Errorf("%s: got %v, want %v", point, err, ecdh.ErrInvalidPublicKey)
+		}
+	}
+}
+
+func TestRejectsMalformedPublicKeys(t *testing.T) {
+	x25519, _ := ecdh.LookupScheme(ecdh.SchemeX25519)
+	p256, _ := ecdh.LookupScheme(ecdh.SchemeP256)
+
+	_, x25519Public, err := x25519.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, p256Public, err := p256.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	offCurve := make([]byte, 65)
+	offCurve[0], offCurve[32], offCurve[64] = 4, 1, 1
+
+	tests := []struct {
+		name      string
+		scheme    *ecdh.Scheme
+		publicKey []byte
+	}{
+		{"X25519 empty", x25519, nil},
+		{"X25519 short", x25519, x25519Public[:31]},
+		{"X25519 long", x25519, append(bytes.Clone(x25519Public), 0)},
+		{"P-256 empty", p256, nil},
+		{"P-256 infinity", p256, []byte{0}},
+		{"P-256 compressed", p256, append([]byte{2}, p256Public[1:33]...)},
+		{"P-256 short", p256, p256Public[:64]},
+		{"P-256 off curve", p256, offCurve},
+		{"P-256 zero point", p256, append([]byte{4}, make([]byte, 64)...)},
+		{"X25519 key for P-256", p256, x25519Public},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.scheme.ValidatePublicKey(tt.publicKey); !errors.Is(err, ecdh.ErrInvalidPublicKey) {
+				t.Fatalf("ValidatePublicKey: got %v, want %v", err, ecdh.ErrInvalidPublicKey)
+			}
+			privateKey, _, err := tt.scheme.GenerateKey()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err = tt.scheme.SharedSecret(privateKey, tt.publicKey); !errors.Is(err, ecdh.ErrInvalidPublicKey) {
+				t.Fatalf("SharedSecret: got %v, want %v", err, ecdh.ErrInvalidPublicKey)
+			}
+		})
+	}
+}
@@ -0,0 +1,95 @@
+package fingerprint
+
+import (
+	"crypto/sha512"
+	"encoding/binary"
+	"fmt"
+	"github.com/skip2/go-qrcode"
+	"image"
+	"strings"
+)
+
+// Номер безопасности строится как в Signal: для каждого участника открытый ключ,
+// имя и ID комнаты многократно хешируются SHA-512, из первых 30 байт получается
+// 30 цифр. Обе половины сортируются по имени участника, поэтому у собеседников
+// номер одинаковый. Если сервер подменил ключ, номера на двух устройствах разойдутся.
+const (
+	version    = 0
+	iterations = 5200
+
+	digitsPerChunk = 5
+	chunksPerParty = 6
+	bytesPerChunk  = 5
+
+	// SafetyNumberLength — число цифр в номере безопасности.
+	SafetyNumberLength = 2 * chunksPerParty * digitsPerChunk
+
+	qrPrefix = "CMSN1:"
+)
+
+type Party struct {
+	Name      string
+	PublicKey []byte
+}
+
+// SafetyNumber возвращает 60 цифр без разделителей.
+func SafetyNumber(roomID string, a, b Party) string {
+	first, second := a, b
+	if second.Name < first.Name {
+		first, second = second, first
+	}
+	return partyDigits(roomID, first) + partyDigits(roomID, second)
+}
+
+func partyDigits(roomID string, p Party) string {
+	var input []byte
+	input = binary.BigEndian.AppendUint16(input, version)
+	for _, field := range [][]byte{p.PublicKey, []byte(p.Name), []byte(roomID)} {
+		input = binary.BigEndian.AppendUint32(input, uint32(len(field)))
+		input = append(input, field...)
+	}
+
+	hash := input
+	for i := 0; i < iterations; i++ {
+		h := sha512.New()
+		h.Write(hash)
+		h.Write(p.PublicKey)
+		hash = h.Sum(nil)
+	}
+
+	var sb strings.Builder
+	for i := 0; i < chunksPerParty; i++ {
+		chunk := hash[i*bytesPerChunk : (i+1)*bytesPerChunk]
+		var v uint64
+		for _, b := range chunk {
+			v = v<<8 | uint64(b)
+		}
+		fmt.Fprintf(&sb, "%05d", v%100000)
+	}
+	return sb.String()
+}
+
+// Format разбивает номер на группы по 5 цифр, по 4 группы в строке.
+func Format(number string) string {
+	var sb strings.Builder
+	for i := 0; i < len(number); i += digitsPerChunk {
+		if i > 0 {
+			if (i/digitsPerChunk)%4 == 0 {
+				sb.WriteByte('\n')
+			} else {
+				sb.WriteByte(' ')
+			}
+		}
+		sb.WriteString(number[i:min(i+digitsPerChunk, len(number))])
+	}
+	return sb.String()
+}
+
+// QRCode кодирует номер и ID комнаты в QR-код размером size×size пикселей.
+func QRCode(roomID, number string, size int) (image.Image, error) {
+	qr, err := qrcode.New(qrPrefix+roomID+":"+number, qrcode.Medium)
+	if err != nil {
+		return nil, fmt.Errorf("could not build QR code: %w", err)
+	}
+	return qr.Image(size), nil
+}
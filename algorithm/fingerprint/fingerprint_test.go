@@ -0,0 +1,79 @@
+package fingerprint_test
+
+import (
+	"CryptoMessenger/algorithm/fingerprint"
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func parties() (fingerprint.Party, fingerprint.Party) {
+	return fingerprint.Party{Name: "alice", PublicKey: bytes.Repeat([]byte{1}, 32)},
+		fingerprint.Party{Name: "bob", PublicKey: bytes.Repeat([]byte{2}, 32)}
+}
+
+// Номер должен совпадать у всех клиентов, поэтому его формат закреплён.
+func TestSafetyNumberIsStable(t *testing.T) {
+	const want = "554518370305324285679486993303081700867139371917652929447204"
+
+	alice, bob := parties()
+	got := fingerprint.SafetyNumber("room", alice, bob)
+	if got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+	if len(got) != fingerprint.SafetyNumberLength || strings.Trim(got, "0123456789") != "" {
+		t.Fatalf("%q is not a %d-digit number", got, fingerprint.SafetyNumberLength)
+	}
+}
+
+func TestSafetyNumberIsSymmetric(t *testing.T) {
+	alice, bob := parties()
+	if fingerprint.SafetyNumber("room", alice, bob) != fingerprint.SafetyNumber("room", bob, alice) {
+		t.Fatal("safety number depends on the order of the parties")
+	}
+}
+
+func TestSafetyNumberChangesWithKeys(t *testing.T) {
+	alice, bob := parties()
+	base := fingerprint.SafetyNumber("room", alice, bob)
+
+	otherKey := func(p fingerprint.Party) fingerprint.Party {
+		p.PublicKey = bytes.Clone(p.PublicKey)
+		p.PublicKey[len(p.PublicKey)-1] ^= 0x01
+		return p
+	}
+	tests := []struct {
+		name   string
+		number string
+	}{
+		{"first party key", fingerprint.SafetyNumber("room", otherKey(alice), bob)},
+		{"second party key", fingerprint.SafetyNumber("room", alice, otherKey(bob))},
+		{"swapped keys", fingerprint.SafetyNumber("room",
+			fingerprint.Party{Name: alice.Name, PublicKey: bob.PublicKey},
+			fingerprint.Party{Name: bob.Name, PublicKey: alice.PublicKey})},
+		{"room", fingerprint.SafetyNumber("other room", alice, bob)},
+	}
+	for _, tt := range tests {
+		if tt.number == base {
+			t.Errorf("%s changed, safety number did not", tt.name)
+		}
+	}
+
+	// Меняется только половина номера того участника, чей ключ изменился
+	half := fingerprint.SafetyNumberLength / 2
+	changed := fingerprint.SafetyNumber("room", otherKey(alice), bob)
+	if changed[:half] == base[:half] || changed[half:] != base[half:] {
+		t.Fatalf("alice's key change: %s -> %s", base, changed)
+	}
+}
+
+func TestFormat(t *testing.T) {
+	alice, bob := parties()
+	got := fingerprint.Format(fingerprint.SafetyNumber("room", alice, bob))
+	want := "55451 83703 05324 28567\n" +
+		"94869 93303 08170 08671\n" +
+		"39371 91765 29294 47204"
+	if got != want {
+		t.Fatalf("got\n%s\nwant\n%s", got, want)
+	}
+}
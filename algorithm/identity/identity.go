@@ -0,0 +1,77 @@
+package identity
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// Долговременный ключ пользователя Ed25519. Им подписываются приглашения, ответы
+// на них и предключи, поэтому сервер не может подменить открытый ключ DH/ECDH собеседника.
+const (
+	InvitationContext = "CryptoMessenger invitation v1"
+	ReactionContext   = "CryptoMessenger invitation reaction v1"
+	RekeyContext      = "CryptoMessenger rekey v1"
+	PrekeyContext     = "CryptoMessenger signed prekey v1"
+)
+
+var (
+	ErrInvalidPublicKey = errors.New("invalid identity public key")
+	ErrInvalidSignature = errors.New("invalid identity signature")
+)
+
+// GenerateKey создаёт новую пару ключей идентичности.
+func GenerateKey() (ed25519.PublicKey, ed25519.PrivateKey, error) {
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not generate identity key: %w", err)
+	}
+	return publicKey, privateKey, nil
+}
+
+func ParsePublicKey(key []byte) (ed25519.PublicKey, error) {
+	if len(key) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("%w: length %d", ErrInvalidPublicKey, len(key))
+	}
+	return ed25519.PublicKey(key), nil
+}
+
+func ParsePrivateKey(key []byte) (ed25519.PrivateKey, error) {
+	if len(key) != ed25519.PrivateKeySize {
+		return nil, fmt.Errorf("invalid identity private key length %d", len(key))
+	}
+	return ed25519.PrivateKey(key), nil
+}
+
+// Message кодирует подписываемые поля однозначно: метка контекста и каждое поле
+// с префиксом длины, чтобы подпись приглашения нельзя было выдать за подпись ответа.
+func Message(context string, fields ...string) []byte {
+	var msg []byte
+	for _, field := range append([]string{context}, fields...) {
+		msg = binary.BigEndian.AppendUint32(msg, uint32(len(field)))
+		msg = append(msg, field...)
+	}
+	return msg
+}
+
+func Sign(privateKey ed25519.PrivateKey, context string, fields ...string) []byte {
+	return ed25519.Sign(privateKey, Message(context, fields...))
+}
+
+func Verify(publicKey ed25519.PublicKey, signature []byte, context string, fields ...string) error {
+	if len(publicKey) != ed25519.PublicKeySize {
+		return ErrInvalidPublicKey
+	}
+	if !ed25519.Verify(publicKey, Message(context, fields...), signature) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// PrekeyFields — подписываемые поля предключа; их сверяют и сервер при загрузке,
+// и отправитель, получивший предключ.
+func PrekeyFields(owner, keyID, keyAgreement, dhGroup, publicKey string) []string {
+	return []string{owner, keyID, keyAgreement, dhGroup, publicKey}
+}
@@ -0,0 +1,85 @@
+package identity_test
+
+import (
+	"CryptoMessenger/algorithm/identity"
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestVerify(t *testing.T) {
+	publicKey, privateKey, err := identity.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherPublic, _, err := identity.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	fields := []string{"alice", "bob", "room", "X25519", "public key"}
+	signature := identity.Sign(privateKey, identity.InvitationContext, fields...)
+
+	if err = identity.Verify(publicKey, signature, identity.InvitationContext, fields...); err != nil {
+		t.Fatal(err)
+	}
+
+	// Подпись одного вида сообщений нельзя выдать за другой
+	for _, context := range []string{identity.ReactionContext, identity.RekeyContext, identity.PrekeyContext, ""} {
+		if err = identity.Verify(publicKey, signature, context, fields...); !errors.Is(err, identity.ErrInvalidSignature) {
+			t.Errorf("context %q: got %v", context, err)
+		}
+	}
+
+	for i := range fields {
+		altered := append([]string(nil), fields...)
+		altered[i] += "x"
+		if err = identity.Verify(publicKey, signature, identity.InvitationContext, altered...); !errors.Is(err, identity.ErrInvalidSignature) {
+			t.Errorf("field %d altered: got %v", i, err)
+		}
+	}
+
+	// Префикс длины не даёт перенести байты между соседними полями
+	shifted := append([]string(nil), fields...)
+	shifted[0], shifted[1] = "alicebo", "b"
+	tests := map[string][]string{
+		"shifted boundary": shifted,
+		"field missing":    fields[:len(fields)-1],
+		"field added":      append(append([]string(nil), fields...), ""),
+	}
+	for name, altered := range tests {
+		if err = identity.Verify(publicKey, signature, identity.InvitationContext, altered...); !errors.Is(err, identity.ErrInvalidSignature) {
+			t.Errorf("%s: got %v", name, err)
+		}
+	}
+
+	if err = identity.Verify(otherPublic, signature, identity.InvitationContext, fields...); !errors.Is(err, identity.ErrInvalidSignature) {
+		t.Errorf("other key: got %v", err)
+	}
+	tampered := bytes.Clone(signature)
+	tampered[0] ^= 0x01
+	if err = identity.Verify(publicKey, tampered, identity.InvitationContext, fields...); !errors.Is(err, identity.ErrInvalidSignature) {
+		t.Errorf("signature altered: got %v", err)
+	}
+	if err = identity.Verify(publicKey[:31], signature, identity.InvitationContext, fields...); !errors.Is(err, identity.ErrInvalidPublicKey) {
+		t.Errorf("short public key: got %v", err)
+	}
+}
+
+func TestParseKeys(t *testing.T) {
+	publicKey, privateKey, err := identity.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = identity.ParsePublicKey(publicKey); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = identity.ParsePrivateKey(privateKey); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = identity.ParsePublicKey(publicKey[:31]); !errors.Is(err, identity.ErrInvalidPublicKey) {
+		t.Fatalf("short public key: got %v", err)
+	}
+	if _, err = identity.ParsePrivateKey(privateKey[:32]); err == nil {
+		t.Fatal("short private key was accepted")
+	}
+}
@@ -0,0 +1,279 @@
+package ratchet
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"golang.org/x/crypto/hkdf"
+	"io"
+	"strconv"
+)
+
+// Double Ratchet по спецификации Signal. Пакет только ведёт ключи: каждое сообщение
+// получает свой ключ, а шифрует его вызывающая сторона (CipherContext). Шаг DH
+// выполняется при каждой смене говорящего, поэтому утечка текущего состояния не
+// раскрывает ни прошлые сообщения, ни сообщения после следующего шага DH.
+const (
+	// MaxSkip — сколько ключей пропущенных сообщений можно вывести за один раз.
+	MaxSkip = 1000
+	// maxStoredSkipped ограничивает общее число хранимых ключей пропущенных сообщений.
+	maxStoredSkipped = 2000
+
+	KeySize = 32
+
+	rootInfo = "CryptoMessenger ratchet root v1"
+)
+
+var (
+	ErrTooManySkipped = errors.New("too many skipped messages")
+	ErrNoSendingChain = errors.New("ratchet has no sending chain")
+	ErrEmptySecret    = errors.New("ratchet shared secret is empty")
+	ErrReplayed       = errors.New("message key was already used")
+)
+
+// DH — схема согласования для шагов храповика: группа dh.Group или схема ecdh.Scheme.
+type DH interface {
+	GenerateKey() (privateKey, publicKey []byte, err error)
+	SharedSecret(privateKey, peerPublicKey []byte) ([]byte, error)
+}
+
+// Header передаётся вместе с сообщением открыто и входит в его дополнительные данные.
+type Header struct {
+	PublicKey []byte
+	// PreviousChainLength — число сообщений в предыдущей цепочке отправки.
+	PreviousChainLength uint32
+	MessageNumber       uint32
+}
+
+func (h Header) Bytes() []byte {
+	buf := binary.BigEndian.AppendUint32(nil, uint32(len(h.PublicKey)))
+	buf = append(buf, h.PublicKey...)
+	buf = binary.BigEndian.AppendUint32(buf, h.PreviousChainLength)
+	return binary.BigEndian.AppendUint32(buf, h.MessageNumber)
+}
+
+// State — состояние храповика одной стороны. Поля экспортированы для сохранения в JSON.
+type State struct {
+	SelfPrivateKey []byte `json:"self_private_key"`
+	SelfPublicKey  []byte `json:"self_public_key"`
+	RemoteKey      []byte `json:"remote_key,omitempty"`
+
+	RootKey      []byte `json:"root_key"`
+	SendingChain []byte `json:"sending_chain,omitempty"`
+	ReceiveChain []byte `json:"receive_chain,omitempty"`
+
+	SendingNumber       uint32 `json:"sending_number"`
+	ReceiveNumber       uint32 `json:"receive_number"`
+	PreviousChainLength uint32 `json:"previous_chain_length"`
+
+	// Skipped — ключи пропущенных сообщений по "hex(открытый ключ):номер".
+	Skipped map[string][]byte `json:"skipped,omitempty"`
+	// SkippedOrder — порядок появления ключей, чтобы выбрасывать самые старые.
+	SkippedOrder []string `json:"skipped_order,omitempty"`
+}
+
+// NewInitiator создаёт состояние стороны, которая начинает первой: её первый ключ
+// храповика — ключ комнаты, а цепочка отправки выводится сразу.
+func NewInitiator(dh DH, secret, privateKey, publicKey, remotePublicKey []byte) (*State, error) {
+	if len(secret) == 0 {
+		return nil, ErrEmptySecret
+	}
+	s := &State{
+		SelfPrivateKey: clone(privateKey),
+		SelfPublicKey:  clone(publicKey),
+		RemoteKey:      clone(remotePublicKey),
+	}
+	dhOut, err := dh.SharedSecret(privateKey, remotePublicKey)
+	if err != nil {
+		return nil, err
+	}
+	s.RootKey, s.SendingChain = kdfRoot(secret, dhOut)
+	return s, nil
+}
+
+// NewResponder создаёт состояние второй стороны. Она сразу принимает ключ инициатора
+// и делает шаг DH, поэтому тоже может писать, не дожидаясь первого сообщения.
+func NewResponder(dh DH, secret, privateKey, publicKey, remotePublicKey []byte) (*State, error) {
+	if len(secret) == 0 {
+		return nil, ErrEmptySecret
+	}
+	s := &State{
+		SelfPrivateKey: clone(privateKey),
+		SelfPublicKey:  clone(publicKey),
+		RootKey:        clone(secret),
+	}
+	if err := s.dhRatchet(dh, remotePublicKey); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Encrypt возвращает заголовок и ключ следующего отправляемого сообщения.
+func (s *State) Encrypt() (Header, []byte, error) {
+	if s.SendingChain == nil {
+		return Header{}, nil, ErrNoSendingChain
+	}
+	var messageKey []byte
+	s.SendingChain, messageKey = kdfChain(s.SendingChain)
+	h := Header{
+		PublicKey:           clone(s.SelfPublicKey),
+		PreviousChainLength: s.PreviousChainLength,
+		MessageNumber:       s.SendingNumber,
+	}
+	s.SendingNumber++
+	return h, messageKey, nil
+}
+
+// Decrypt возвращает ключ полученного сообщения. При ошибке состояние не меняется,
+// но ключ может и не подойти к сообщению, поэтому вызывать его нужно на копии (Clone)
+// и сохранять копию только после успешной проверки.
+func (s *State) Decrypt(dh DH, h Header) ([]byte, error) {
+	next := s.Clone()
+	messageKey, err := next.decrypt(dh, h)
+	if err != nil {
+		return nil, err
+	}
+	*s = *next
+	return messageKey, nil
+}
+
+func (s *State) decrypt(dh DH, h Header) ([]byte, error) {
+	if key, ok := s.Skipped[skippedID(h.PublicKey, h.MessageNumber)]; ok {
+		s.removeSkipped(skippedID(h.PublicKey, h.MessageNumber))
+		return key, nil
+	}
+	// Ключ этого номера уже выдан и не сохранён среди пропущенных: сообщение повторное
+	if bytes.Equal(h.PublicKey, s.RemoteKey) && h.MessageNumber < s.ReceiveNumber {
+		return nil, fmt.Errorf("%w: %d", ErrReplayed, h.MessageNumber)
+	}
+
+	if !bytes.Equal(h.PublicKey, s.RemoteKey) {
+		if err := s.skipMessageKeys(h.PreviousChainLength); err != nil {
+			return nil, err
+		}
+		if err := s.dhRatchet(dh, h.PublicKey); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := s.skipMessageKeys(h.MessageNumber); err != nil {
+		return nil, err
+	}
+	var messageKey []byte
+	s.ReceiveChain, messageKey = kdfChain(s.ReceiveChain)
+	s.ReceiveNumber++
+	return messageKey, nil
+}
+
+func (s *State) Clone() *State {
+	c := *s
+	c.SelfPrivateKey = clone(s.SelfPrivateKey)
+	c.SelfPublicKey = clone(s.SelfPublicKey)
+	c.RemoteKey = clone(s.RemoteKey)
+	c.RootKey = clone(s.RootKey)
+	c.SendingChain = clone(s.SendingChain)
+	c.ReceiveChain = clone(s.ReceiveChain)
+	c.SkippedOrder = append([]string(nil), s.SkippedOrder...)
+	c.Skipped = make(map[string][]byte, len(s.Skipped))
+	for id, key := range s.Skipped {
+		c.Skipped[id] = clone(key)
+	}
+	return &c
+}
+
+func (s *State) dhRatchet(dh DH, remotePublicKey []byte) error {
+	dhOut, err := dh.SharedSecret(s.SelfPrivateKey, remotePublicKey)
+	if err != nil {
+		return err
+	}
+
+	s.PreviousChainLength = s.SendingNumber
+	s.SendingNumber = 0
+	s.ReceiveNumber = 0
+	s.RemoteKey = clone(remotePublicKey)
+	s.RootKey, s.ReceiveChain = kdfRoot(s.RootKey, dhOut)
+
+	if s.SelfPrivateKey, s.SelfPublicKey, err = dh.GenerateKey(); err != nil {
+		return err
+	}
+	if dhOut, err = dh.SharedSecret(s.SelfPrivateKey, s.RemoteKey); err != nil {
+		return err
+	}
+	s.RootKey, s.SendingChain = kdfRoot(s.RootKey, dhOut)
+	return nil
+}
+
+func (s *State) skipMessageKeys(until uint32) error {
+	if s.ReceiveChain == nil {
+		return nil
+	}
+	if until > s.ReceiveNumber && until-s.ReceiveNumber > MaxSkip {
+		return fmt.Errorf("%w: %d", ErrTooManySkipped, until-s.ReceiveNumber)
+	}
+	for s.ReceiveNumber < until {
+		var messageKey []byte
+		s.ReceiveChain, messageKey = kdfChain(s.ReceiveChain)
+		s.addSkipped(skippedID(s.RemoteKey, s.ReceiveNumber), messageKey)
+		s.ReceiveNumber++
+	}
+	return nil
+}
+
+func (s *State) addSkipped(id string, key []byte) {
+	if s.Skipped == nil {
+		s.Skipped = map[string][]byte{}
+	}
+	s.Skipped[id] = key
+	s.SkippedOrder = append(s.SkippedOrder, id)
+	for len(s.SkippedOrder) > maxStoredSkipped {
+		delete(s.Skipped, s.SkippedOrder[0])
+		s.SkippedOrder = s.SkippedOrder[1:]
+	}
+}
+
+func (s *State) removeSkipped(id string) {
+	delete(s.Skipped, id)
+	for i, v := range s.SkippedOrder {
+		if v == id {
+			s.SkippedOrder = append(s.SkippedOrder[:i], s.SkippedOrder[i+1:]...)
+			break
+		}
+	}
+}
+
+func skippedID(publicKey []byte, n uint32) string {
+	return hex.EncodeToString(publicKey) + ":" + strconv.FormatUint(uint64(n), 10)
+}
+
+// kdfRoot — KDF_RK: HKDF-SHA256 с корневым ключом в роли соли.
+func kdfRoot(rootKey, dhOut []byte) (newRootKey, chainKey []byte) {
+	out := make([]byte, 2*KeySize)
+	r := hkdf.New(sha256.New, dhOut, rootKey, []byte(rootInfo))
+	if _, err := io.ReadFull(r, out); err != nil {
+		// HKDF-SHA256 выдаёт до 8160 байт, 64 байта прочитаются всегда
+		panic(err)
+	}
+	return out[:KeySize], out[KeySize:]
+}
+
+// kdfChain — KDF_CK: HMAC-SHA256 с константами 0x01 (ключ сообщения) и 0x02 (следующий ключ цепочки).
+func kdfChain(chainKey []byte) (nextChainKey, messageKey []byte) {
+	mac := hmac.New(sha256.New, chainKey)
+	mac.Write([]byte{0x01})
+	messageKey = mac.Sum(nil)
+
+	mac = hmac.New(sha256.New, chainKey)
+	mac.Write([]byte{0x02})
+	return mac.Sum(nil), messageKey
+}
+
+func clone(b []byte) []byte {
+	if b == nil {
+		return nil
+	}
+	return append([]byte(nil), b...)
+}
@@ -0,0 +1,216 @@
+package ratchet_test
+
+import (
+	"CryptoMessenger/algorithm/ecdh"
+	"CryptoMessenger/algorithm/ratchet"
+	"bytes"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+type message struct {
+	header ratchet.Header
+	key    []byte
+}
+
+// newPair создаёт состояния инициатора и ответчика с общим секретом на X25519.
+func newPair(t *testing.T) (ratchet.DH, *ratchet.State, *ratchet.State) {
+	t.Helper()
+	scheme, err := ecdh.LookupScheme(ecdh.SchemeX25519)
+	if err != nil {
+		t.Fatal(err)
+	}
+	alicePrivate, alicePublic, err := scheme.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	bobPrivate, bobPublic, err := scheme.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	secret := make([]byte, ratchet.KeySize)
+	if _, err = rand.Read(secret); err != nil {
+		t.Fatal(err)
+	}
+
+	alice, err := ratchet.NewInitiator(scheme, secret, alicePrivate, alicePublic, bobPublic)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bob, err := ratchet.NewResponder(scheme, secret, bobPrivate, bobPublic, alicePublic)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return scheme, alice, bob
+}
+
+func send(t *testing.T, s *ratchet.State, n int) []message {
+	t.Helper()
+	messages := make([]message, n)
+	for i := range messages {
+		header, key, err := s.Encrypt()
+		if err != nil {
+			t.Fatal(err)
+		}
+		messages[i] = message{header, key}
+	}
+	return messages
+}
+
+func receive(t *testing.T, dh ratchet.DH, s *ratchet.State, m message) {
+	t.Helper()
+	key, err := s.Decrypt(dh, m.header)
+	if err != nil {
+		t.Fatalf("message %d: %v", m.header.MessageNumber, err)
+	}
+	if !bytes.Equal(key, m.key) {
+		t.Fatalf("message %d: key differs from the sender's", m.header.MessageNumber)
+	}
+}
+
+// snapshot — состояние в том виде, в каком оно сохраняется в хранилище ключей.
+func snapshot(t *testing.T, s *ratchet.State) string {
+	t.Helper()
+	out, err := json.Marshal(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(out)
+}
+
+func TestDecryptOutOfOrder(t *testing.T) {
+	tests := []struct {
+		name  string
+		sent  int
+		order []int
+	}{
+		{"in order", 3, []int{0, 1, 2}},
+		{"reversed", 3, []int{2, 1, 0}},
+		{"shuffled", 5, []int{3, 0, 4, 2, 1}},
+		{"lost messages", 6, []int{5, 1}},
+		{"up to MaxSkip", ratchet.MaxSkip + 1, []int{ratchet.MaxSkip, 0}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dh, alice, bob := newPair(t)
+			messages := send(t, alice, tt.sent)
+			for _, i := range tt.order {
+				receive(t, dh, bob, messages[i])
+			}
+		})
+	}
+}
+
+// Сообщение прошлой цепочки приходит после шага DH: его ключ берётся из пропущенных.
+func TestDecryptSkippedAcrossDHStep(t *testing.T) {
+	dh, alice, bob := newPair(t)
+
+	first := send(t, alice, 2)
+	receive(t, dh, bob, first[0])
+
+	receive(t, dh, alice, send(t, bob, 1)[0])
+
+	second := send(t, alice, 1)
+	if second[0].header.PreviousChainLength != 2 {
+		t.Fatalf("previous chain length = %d", second[0].header.PreviousChainLength)
+	}
+	receive(t, dh, bob, second[0])
+	receive(t, dh, bob, first[1])
+}
+
+func TestDecryptRejectsWithoutChangingState(t *testing.T) {
+	tests := []struct {
+		name    string
+		header  func(t *testing.T, dh ratchet.DH, alice, bob *ratchet.State) ratchet.Header
+		wantErr error
+	}{
+		{
+			name: "replayed message",
+			header: func(t *testing.T, dh ratchet.DH, alice, bob *ratchet.State) ratchet.Header {
+				m := send(t, alice, 1)[0]
+				receive(t, dh, bob, m)
+				return m.header
+			},
+			wantErr: ratchet.ErrReplayed,
+		},
+		{
+			name: "replayed skipped message",
+			header: func(t *testing.T, dh ratchet.DH, alice, bob *ratchet.State) ratchet.Header {
+				messages := send(t, alice, 2)
+				receive(t, dh, bob, messages[1])
+				receive(t, dh, bob, messages[0])
+				return messages[0].header
+			},
+			wantErr: ratchet.ErrReplayed,
+		},
+		{
+			name: "more than MaxSkip in the current chain",
+			header: func(t *testing.T, dh ratchet.DH, alice, bob *ratchet.State) ratchet.Header {
+				m := send(t, alice, 1)[0]
+				m.header.MessageNumber = ratchet.MaxSkip + 1
+				return m.header
+			},
+			wantErr: ratchet.ErrTooManySkipped,
+		},
+		{
+			name: "more than MaxSkip after a DH step",
+			header: func(t *testing.T, dh ratchet.DH, alice, bob *ratchet.State) ratchet.Header {
+				_, publicKey, err := dh.GenerateKey()
+				if err != nil {
+					t.Fatal(err)
+				}
+				return ratchet.Header{PublicKey: publicKey, MessageNumber: ratchet.MaxSkip + 1}
+			},
+			wantErr: ratchet.ErrTooManySkipped,
+		},
+		{
+			name: "more than MaxSkip in the previous chain",
+			header: func(t *testing.T, dh ratchet.DH, alice, bob *ratchet.State) ratchet.Header {
+				receive(t, dh, bob, send(t, alice, 1)[0])
+				_, publicKey, err := dh.GenerateKey()
+				if err != nil {
+					t.Fatal(err)
+				}
+				return ratchet.Header{PublicKey: publicKey, PreviousChainLength: ratchet.MaxSkip + 2}
+			},
+			wantErr: ratchet.ErrTooManySkipped,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dh, alice, bob := newPair(t)
+			header := tt.header(t, dh, alice, bob)
+
+			before := snapshot(t, bob)
+			if _, err := bob.Decrypt(dh, header); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("got %v, want %v", err, tt.wantErr)
+			}
+			if snapshot(t, bob) != before {
+				t.Fatal("state changed after a failed decrypt")
+			}
+
+			// После отказа следующие сообщения по-прежнему расшифровываются
+			receive(t, dh, bob, send(t, alice, 1)[0])
+		})
+	}
+}
+
+// Ключ, который не подошёл к сообщению, не должен сдвигать сохранённое состояние:
+// вызывающая сторона работает с копией и отбрасывает её.
+func TestCloneIsIndependent(t *testing.T) {
+	dh, alice, bob := newPair(t)
+	messages := send(t, alice, 3)
+	receive(t, dh, bob, messages[2])
+
+	before := snapshot(t, bob)
+	next := bob.Clone()
+	receive(t, dh, next, messages[0])
+	receive(t, dh, next, messages[1])
+	if snapshot(t, bob) != before {
+		t.Fatal("decrypting on a clone changed the original state")
+	}
+
+	receive(t, dh, bob, messages[0])
+}
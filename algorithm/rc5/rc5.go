@@ -118,7 +118,7 @@ func (r *RC5) MyBytesToWords(key []byte) []uint {
 func (r *RC5) MyExpTable(S []uint, L []uint) []uint {
 	T := 2 * (r.rounds + 1)
 	u := r.wordSize / 8
-	c := max(r.b, 1) / u
+	c := (max(r.b, 1) + u - 1) / u
 	k := 3 * T
 	if c > T {
 		k = 3 * c
@@ -164,15 +164,39 @@ func (r *RC5) Rotl(k uint, c uint) uint {
 }
 
 func (r *RC5) Encrypt(block []byte) ([]byte, error) {
+	out := make([]byte, len(block))
+	if err := r.EncryptBlock(out, block); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (r *RC5) Decrypt(block []byte) ([]byte, error) {
+	out := make([]byte, len(block))
+	if err := r.DecryptBlock(out, block); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (r *RC5) checkBlock(dst, src []byte) (int, error) {
 	wordBytes := int(r.wordSize / 8)
-	if len(block) != 2*wordBytes {
-		return nil, fmt.Errorf("block must be %d bytes", 2*wordBytes)
+	if len(src) != 2*wordBytes || len(dst) < 2*wordBytes {
+		return 0, fmt.Errorf("block must be %d bytes", 2*wordBytes)
+	}
+	return wordBytes, nil
+}
+
+func (r *RC5) EncryptBlock(dst, src []byte) error {
+	wordBytes, err := r.checkBlock(dst, src)
+	if err != nil {
+		return err
 	}
 
 	mask := uint((1 << r.wordSize) - 1)
 
-	A := MyBytesToUint(block[:wordBytes])
-	B := MyBytesToUint(block[wordBytes:])
+	A := MyBytesToUint(src[:wordBytes])
+	B := MyBytesToUint(src[wordBytes:])
 
 	A = (A + r.s[0]) & mask
 	B = (B + r.s[1]) & mask
@@ -182,23 +206,21 @@ func (r *RC5) Encrypt(block []byte) ([]byte, error) {
 		B = (r.Rotl(B^A, A) + r.s[2*i+1]) & mask
 	}
 
-	out := make([]byte, 0, 2*wordBytes)
-	out = append(out, MyUintToBytes(A, wordBytes)...)
-	out = append(out, MyUintToBytes(B, wordBytes)...)
-
-	return out, nil
+	putUint(dst[:wordBytes], A)
+	putUint(dst[wordBytes:2*wordBytes], B)
+	return nil
 }
 
-func (r *RC5) Decrypt(block []byte) ([]byte, error) {
-	wordBytes := int(r.wordSize / 8)
-	if len(block) != 2*wordBytes {
-		return nil, fmt.Errorf("block must be %d bytes", 2*wordBytes)
+func (r *RC5) DecryptBlock(dst, src []byte) error {
+	wordBytes, err := r.checkBlock(dst, src)
+	if err != nil {
+		return err
 	}
 
 	mask := uint((1 << r.wordSize) - 1)
 
-	A := MyBytesToUint(block[:wordBytes])
-	B := MyBytesToUint(block[wordBytes:])
+	A := MyBytesToUint(src[:wordBytes])
+	B := MyBytesToUint(src[wordBytes:])
 
 	for i := r.rounds; i >= 1; i-- {
 		B = r.Rotr((B-r.s[2*i+1])&mask, A) ^ A
@@ -208,11 +230,9 @@ func (r *RC5) Decrypt(block []byte) ([]byte, error) {
 	B = (B - r.s[1]) & mask
 	A = (A - r.s[0]) & mask
 
-	out := make([]byte, 0, 2*wordBytes)
-	out = append(out, MyUintToBytes(A, wordBytes)...)
-	out = append(out, MyUintToBytes(B, wordBytes)...)
-
-	return out, nil
+	putUint(dst[:wordBytes], A)
+	putUint(dst[wordBytes:2*wordBytes], B)
+	return nil
 }
 
 func MyBytesToUint(b []byte) uint {
@@ -225,11 +245,16 @@ func MyBytesToUint(b []byte) uint {
 
 func MyUintToBytes(x uint, size int) []byte {
 	b := make([]byte, size)
-	for i := 0; i < size; i++ {
+	putUint(b, x)
+	return b
+}
+
+// putUint записывает младшие len(b) байт x в порядке little-endian.
+func putUint(b []byte, x uint) {
+	for i := range b {
 		b[i] = byte(x & 0xff)
 		x >>= 8
 	}
-	return b
 }
 
 func (r *RC5) wordMask() uint {
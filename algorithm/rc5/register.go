@@ -0,0 +1,22 @@
+package rc5
+
+import "CryptoMessenger/algorithm/symmetric"
+
+func init() {
+	symmetric.RegisterCipher(symmetric.CipherInfo{
+		Name:    "RC5",
+		ID:      symmetric.AlgorithmRC5,
+		KeySize: symmetric.KeySizeRange{Min: 1, Max: 255, Step: 1},
+		Params: []symmetric.ParamSpec{
+			{Name: "w", Default: 64, Allowed: []int{16, 32, 64}},
+			{Name: "r", Default: 12, Min: 0, Max: 255},
+		},
+		// Блок RC5 состоит из двух слов по w бит
+		BlockSize: func(params symmetric.Params) int {
+			return 2 * params["w"] / 8
+		},
+		New: func(key []byte, params symmetric.Params) (symmetric.CipherScheme, error) {
+			return NewRC5(uint(params["w"]), uint(params["r"]), uint(len(key)), key)
+		},
+	})
+}
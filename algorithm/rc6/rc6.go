@@ -6,20 +6,25 @@ import (
 )
 
 var (
+	p16 uint = 0xb7e1
+	q16 uint = 0x9e37
+
 	p32 uint = 0xb7e15163
 	q32 uint = 0x9e3779b9
-)
 
-const (
-	w         = 32
-	wordBytes = w / 8
-	r         = 20
-	t         = 2 * (r + 2)
+	p64 uint = 0xb7e151628aed2a6b
+	q64 uint = 0x9e3779b97f4a7c15
 )
 
+// RC6 реализует RC6-w/r/b: слово w бит, r раундов, ключ b байт. Блок — четыре слова.
 type RC6 struct {
-	key []byte
-	S   []uint
+	key      []byte
+	S        []uint
+	wordSize uint
+	rounds   uint
+	b        uint
+	lgw      uint
+	mask     uint
 }
 
 func (c *RC6) EncryptAsync(data []byte) (<-chan []byte, <-chan error) {
@@ -32,45 +37,83 @@ func (c *RC6) DecryptAsync(data []byte) (<-chan []byte, <-chan error) {
 	panic("implement me")
 }
 
-func NewRC6(key []byte) (*RC6, error) {
-	b := len(key)
-	if b != 16 && b != 24 && b != 32 {
-		return nil, fmt.Errorf("invalid key size %d", b)
+func NewRC6(w, r, b uint, key []byte) (*RC6, error) {
+	var lgw uint
+	switch w {
+	case 16:
+		lgw = 4
+	case 32:
+		lgw = 5
+	case 64:
+		lgw = 6
+	default:
+		return nil, fmt.Errorf("w must be either 16 or 32 or 64")
+	}
+	if r > 255 {
+		return nil, fmt.Errorf("r must be between 0 and 255")
+	}
+	if b > 255 {
+		return nil, fmt.Errorf("b must be between 0 and 255")
+	}
+	if len(key) != int(b) {
+		return nil, fmt.Errorf("key must be equal to length of key")
+	}
+
+	c := &RC6{
+		key:      key,
+		wordSize: w,
+		rounds:   r,
+		b:        b,
+		lgw:      lgw,
+		mask:     ^uint(0) >> (64 - w),
 	}
-	c := &RC6{key: key}
 	if err := c.GenerateS(); err != nil {
 		return nil, err
 	}
 	return c, nil
 }
 
+// BlockSize возвращает размер блока в байтах: четыре слова по w бит.
+func (c *RC6) BlockSize() int {
+	return 4 * c.wordBytes()
+}
+
 func (c *RC6) SetKey(key []byte) error {
 	if !bytes.Equal(c.key, key) {
+		if len(key) > 255 {
+			return fmt.Errorf("key must be between 0 and 255")
+		}
 		c.key = key
+		c.b = uint(len(key))
 		return c.GenerateS()
 	}
 	return nil
 }
 
 func (c *RC6) GenerateS() error {
-	mask := uint((1 << w) - 1)
+	p, q := c.selectMagicConstants()
+	t := int(2*c.rounds + 4)
+
 	S := make([]uint, t)
-	S[0] = p32 & mask
+	S[0] = p & c.mask
 	for i := 1; i < t; i++ {
-		S[i] = (S[i-1] + q32) & mask
+		S[i] = (S[i-1] + q) & c.mask
 	}
+
+	u := c.wordBytes()
 	b := len(c.key)
-	Lw := (b + wordBytes - 1) / wordBytes
+	Lw := max((b+u-1)/u, 1)
 	L := make([]uint, Lw)
 	for i := b - 1; i >= 0; i-- {
-		L[i/wordBytes] = ((L[i/wordBytes] << 8) + uint(c.key[i])) & mask
+		L[i/u] = ((L[i/u] << 8) + uint(c.key[i])) & c.mask
 	}
+
 	A, B := uint(0), uint(0)
 	i, j := 0, 0
 	for k := 0; k < 3*max(Lw, t); k++ {
-		A = rotl((S[i]+A+B)&mask, 3)
+		A = c.rotl((S[i]+A+B)&c.mask, 3)
 		S[i] = A
-		B = rotl((L[j]+A+B)&mask, (A+B)&(w-1))
+		B = c.rotl((L[j]+A+B)&c.mask, A+B)
 		L[j] = B
 		i = (i + 1) % t
 		j = (j + 1) % Lw
@@ -79,78 +122,111 @@ func (c *RC6) GenerateS() error {
 	return nil
 }
 
+func (c *RC6) selectMagicConstants() (uint, uint) {
+	switch c.wordSize {
+	case 16:
+		return p16, q16
+	case 64:
+		return p64, q64
+	default:
+		return p32, q32
+	}
+}
+
 func (c *RC6) Encrypt(block []byte) ([]byte, error) {
-	if len(block) != 4*wordBytes {
-		return nil, fmt.Errorf("invalid block size %d", len(block))
+	out := make([]byte, len(block))
+	if err := c.EncryptBlock(out, block); err != nil {
+		return nil, err
 	}
+	return out, nil
+}
 
-	mask := uint((1 << w) - 1)
+func (c *RC6) Decrypt(block []byte) ([]byte, error) {
+	out := make([]byte, len(block))
+	if err := c.DecryptBlock(out, block); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
 
-	A := BytesToUint(block[0:4])
-	B := BytesToUint(block[4:8])
-	C := BytesToUint(block[8:12])
-	D := BytesToUint(block[12:16])
+func (c *RC6) checkBlock(dst, src []byte) error {
+	if len(src) != c.BlockSize() || len(dst) < c.BlockSize() {
+		return fmt.Errorf("invalid block size %d", len(src))
+	}
+	return nil
+}
 
-	B = (B + c.S[0]) & mask
-	D = (D + c.S[1]) & mask
+func (c *RC6) EncryptBlock(dst, src []byte) error {
+	if err := c.checkBlock(dst, src); err != nil {
+		return err
+	}
 
-	for i := 1; i <= r; i++ {
-		tv := rotl((B*(2*B+1))&mask, 5)
-		uv := rotl((D*(2*D+1))&mask, 5)
+	u := c.wordBytes()
+	A := BytesToUint(src[0:u])
+	B := BytesToUint(src[u : 2*u])
+	C := BytesToUint(src[2*u : 3*u])
+	D := BytesToUint(src[3*u : 4*u])
 
-		A = (rotl(A^tv, uv&(w-1)) + c.S[2*i]) & mask
-		C = (rotl(C^uv, tv&(w-1)) + c.S[2*i+1]) & mask
+	B = (B + c.S[0]) & c.mask
+	D = (D + c.S[1]) & c.mask
 
-		A, B, C, D = B, C, D, A
-	}
-	A = (A + c.S[2*r+2]) & mask
-	C = (C + c.S[2*r+3]) & mask
+	for i := uint(1); i <= c.rounds; i++ {
+		tv := c.rotl((B*(2*B+1))&c.mask, c.lgw)
+		uv := c.rotl((D*(2*D+1))&c.mask, c.lgw)
 
-	out := make([]byte, 4*wordBytes)
+		A = (c.rotl(A^tv, uv) + c.S[2*i]) & c.mask
+		C = (c.rotl(C^uv, tv) + c.S[2*i+1]) & c.mask
 
-	copy(out[0:4], UintToBytes(A))
-	copy(out[4:8], UintToBytes(B))
-	copy(out[8:12], UintToBytes(C))
-	copy(out[12:16], UintToBytes(D))
+		A, B, C, D = B, C, D, A
+	}
+	A = (A + c.S[2*c.rounds+2]) & c.mask
+	C = (C + c.S[2*c.rounds+3]) & c.mask
 
-	return out, nil
+	c.join(dst, A, B, C, D)
+	return nil
 }
 
-func (c *RC6) Decrypt(block []byte) ([]byte, error) {
-	if len(block) != 4*wordBytes {
-		return nil, fmt.Errorf("invalid block size %d", len(block))
+func (c *RC6) DecryptBlock(dst, src []byte) error {
+	if err := c.checkBlock(dst, src); err != nil {
+		return err
 	}
 
-	mask := uint((1 << w) - 1)
-
-	A := BytesToUint(block[0:4])
-	B := BytesToUint(block[4:8])
-	C := BytesToUint(block[8:12])
-	D := BytesToUint(block[12:16])
+	u := c.wordBytes()
+	A := BytesToUint(src[0:u])
+	B := BytesToUint(src[u : 2*u])
+	C := BytesToUint(src[2*u : 3*u])
+	D := BytesToUint(src[3*u : 4*u])
 
-	C = (C - c.S[2*r+3]) & mask
-	A = (A - c.S[2*r+2]) & mask
+	C = (C - c.S[2*c.rounds+3]) & c.mask
+	A = (A - c.S[2*c.rounds+2]) & c.mask
 
-	for i := r; i >= 1; i-- {
+	for i := c.rounds; i >= 1; i-- {
 		A, B, C, D = D, A, B, C
 
-		uv := rotl((D*(2*D+1))&mask, 5)
-		tv := rotl((B*(2*B+1))&mask, 5)
+		uv := c.rotl((D*(2*D+1))&c.mask, c.lgw)
+		tv := c.rotl((B*(2*B+1))&c.mask, c.lgw)
 
-		C = rotr((C-c.S[2*i+1])&mask, tv&(w-1)) ^ uv
-		A = rotr((A-c.S[2*i])&mask, uv&(w-1)) ^ tv
+		C = c.rotr((C-c.S[2*i+1])&c.mask, tv) ^ uv
+		A = c.rotr((A-c.S[2*i])&c.mask, uv) ^ tv
 	}
-	D = (D - c.S[1]) & mask
-	B = (B - c.S[0]) & mask
+	D = (D - c.S[1]) & c.mask
+	B = (B - c.S[0]) & c.mask
 
-	out := make([]byte, 4*wordBytes)
+	c.join(dst, A, B, C, D)
+	return nil
+}
 
-	copy(out[0:4], UintToBytes(A))
-	copy(out[4:8], UintToBytes(B))
-	copy(out[8:12], UintToBytes(C))
-	copy(out[12:16], UintToBytes(D))
+// join записывает четыре слова блока в dst.
+func (c *RC6) join(dst []byte, A, B, C, D uint) {
+	u := c.wordBytes()
+	putUint(dst[0:u], A)
+	putUint(dst[u:2*u], B)
+	putUint(dst[2*u:3*u], C)
+	putUint(dst[3*u:4*u], D)
+}
 
-	return out, nil
+func (c *RC6) wordBytes() int {
+	return int(c.wordSize / 8)
 }
 
 func BytesToUint(b []byte) uint {
@@ -161,21 +237,26 @@ func BytesToUint(b []byte) uint {
 	return v
 }
 
-func UintToBytes(x uint) []byte {
-	b := make([]byte, 4)
-	for i := 0; i < 4; i++ {
+func UintToBytes(x uint, size int) []byte {
+	b := make([]byte, size)
+	putUint(b, x)
+	return b
+}
+
+func putUint(b []byte, x uint) {
+	for i := range b {
 		b[i] = byte(x)
 		x >>= 8
 	}
-	return b
 }
 
-func rotl(x, y uint) uint {
-	s := y & (w - 1)
-	return (x<<s | x>>(w-s)) & ((1 << w) - 1)
+// rotl и rotr берут из y только младшие lg w бит, как требует RC6.
+func (c *RC6) rotl(x, y uint) uint {
+	s := y & (c.wordSize - 1)
+	return (x<<s | x>>(c.wordSize-s)) & c.mask
 }
 
-func rotr(x, y uint) uint {
-	s := y & (w - 1)
-	return (x>>s | x<<(w-s)) & ((1 << w) - 1)
+func (c *RC6) rotr(x, y uint) uint {
+	s := y & (c.wordSize - 1)
+	return (x>>s | x<<(c.wordSize-s)) & c.mask
 }
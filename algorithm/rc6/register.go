@@ -0,0 +1,22 @@
+package rc6
+
+import "CryptoMessenger/algorithm/symmetric"
+
+func init() {
+	symmetric.RegisterCipher(symmetric.CipherInfo{
+		Name:    "RC6",
+		ID:      symmetric.AlgorithmRC6,
+		KeySize: symmetric.KeySizeRange{Min: 0, Max: 255, Step: 1},
+		Params: []symmetric.ParamSpec{
+			{Name: "w", Default: 32, Allowed: []int{16, 32, 64}},
+			{Name: "r", Default: 20, Min: 0, Max: 255},
+		},
+		// Блок RC6 состоит из четырёх слов по w бит
+		BlockSize: func(params symmetric.Params) int {
+			return 4 * params["w"] / 8
+		},
+		New: func(key []byte, params symmetric.Params) (symmetric.CipherScheme, error) {
+			return NewRC6(uint(params["w"]), uint(params["r"]), uint(len(key)), key)
+		},
+	})
+}
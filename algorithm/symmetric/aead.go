@@ -0,0 +1,335 @@
+package symmetric
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"sort"
+)
+
+const (
+	TagSize    = sha256.Size
+	MACKeySize = sha256.Size
+
+	macKeyLabel     = "CryptoMessenger encrypt-then-MAC key"
+	macLabelMessage = "message"
+	macLabelFile    = "file"
+)
+
+var ErrAuthenticationFailed = errors.New("message authentication failed")
+
+// AEADContext добавляет к CipherContext аутентификацию по схеме encrypt-then-MAC:
+// к шифртексту дописывается HMAC-SHA256 по IV, параметрам режима и самому шифртексту.
+type AEADContext struct {
+	cipher *CipherContext
+	macKey []byte
+}
+
+func NewAEADContext(cipher *CipherContext) (*AEADContext, error) {
+	if cipher == nil {
+		return nil, errors.New("cipher context is not initialized")
+	}
+	if len(cipher.key) == 0 {
+		return nil, errors.New("cipher key cannot be empty")
+	}
+
+	return &AEADContext{
+		cipher: cipher,
+		macKey: deriveMACKey(cipher.key),
+	}, nil
+}
+
+// NewAEADContextWithMACKey использует отдельно выведенный ключ MAC вместо производного от ключа шифра.
+func NewAEADContextWithMACKey(cipher *CipherContext, macKey []byte) (*AEADContext, error) {
+	if cipher == nil {
+		return nil, errors.New("cipher context is not initialized")
+	}
+	if len(macKey) < MACKeySize {
+		return nil, fmt.Errorf("mac key must be at least %d bytes", MACKeySize)
+	}
+
+	return &AEADContext{
+		cipher: cipher,
+		macKey: macKey,
+	}, nil
+}
+
+// Encrypt шифрует и аутентифицирует data; additionalData не шифруется, но входит в тег.
+// В режиме GCM используется его собственный тег вместо HMAC.
+func (a *AEADContext) Encrypt(data, additionalData []byte, chunkIndex, totalChunks int) ([]byte, error) {
+	if a.cipher.mode == GCM {
+		return a.cipher.EncryptGCM(data, additionalData)
+	}
+
+	encrypted, err := a.cipher.Encrypt(data, chunkIndex, totalChunks)
+	if err != nil {
+		return nil, err
+	}
+
+	mac := a.newMAC(macLabelMessage, chunkIndex, totalChunks)
+	writeField(mac, additionalData)
+	mac.Write(encrypted)
+
+	return mac.Sum(encrypted), nil
+}
+
+func (a *AEADContext) Decrypt(data, additionalData []byte, chunkIndex, totalChunks int) ([]byte, error) {
+	if a.cipher.mode == GCM {
+		return a.cipher.DecryptGCM(data, additionalData)
+	}
+
+	if len(data) <= TagSize {
+		return nil, ErrAuthenticationFailed
+	}
+
+	encrypted, tag := data[:len(data)-TagSize], data[len(data)-TagSize:]
+
+	mac := a.newMAC(macLabelMessage, chunkIndex, totalChunks)
+	writeField(mac, additionalData)
+	mac.Write(encrypted)
+	if !hmac.Equal(mac.Sum(nil), tag) {
+		return nil, ErrAuthenticationFailed
+	}
+
+	return a.cipher.Decrypt(encrypted, chunkIndex, totalChunks)
+}
+
+// EncryptFile шифрует файл в контейнер и дописывает в его конец тег,
+// вычисленный по additionalData, заголовку и всему шифртексту.
+func (a *AEADContext) EncryptFile(ctx context.Context, inputPath, outputPath string, additionalData []byte, progress func(done, total int)) error {
+	inputFile, fileSize, err := openInputFile(inputPath)
+	if err != nil {
+		return err
+	}
+	defer inputFile.Close()
+
+	outputFile, err := createOutputFile(outputPath)
+	if err != nil {
+		return err
+	}
+	defer outputFile.Close()
+
+	mac := a.newFileMAC(additionalData)
+
+	if err = a.cipher.writeContainer(ctx, inputFile, fileSize, io.MultiWriter(outputFile, mac), true, progress); err != nil {
+		if ctx.Err() != nil {
+			_ = os.Remove(outputPath)
+		}
+		return err
+	}
+
+	if _, err = outputFile.Write(mac.Sum(nil)); err != nil {
+		return fmt.Errorf("write tag: %w", err)
+	}
+
+	return nil
+}
+
+// DecryptFile проверяет тег до расшифрования, поэтому изменённый файл не попадает в outputPath.
+func (a *AEADContext) DecryptFile(inputPath, outputPath string, additionalData []byte, progress func(done, total int)) error {
+	return a.decryptFile(inputPath, additionalData, func() (io.WriteCloser, error) {
+		return createOutputFile(outputPath)
+	}, progress)
+}
+
+// DecryptFileTo — DecryptFile с записью в w; до проверки тега в w ничего не пишется.
+func (a *AEADContext) DecryptFileTo(inputPath string, additionalData []byte, w io.Writer, progress func(done, total int)) error {
+	return a.decryptFile(inputPath, additionalData, func() (io.WriteCloser, error) {
+		return nopWriteCloser{w}, nil
+	}, progress)
+}
+
+func (a *AEADContext) decryptFile(inputPath string, additionalData []byte, openOutput func() (io.WriteCloser, error), progress func(done, total int)) error {
+	inputFile, fileSize, err := openInputFile(inputPath)
+	if err != nil {
+		return err
+	}
+	defer inputFile.Close()
+
+	header, rawHeader, err := ReadContainerHeader(inputFile)
+	if errors.Is(err, ErrNotContainer) {
+		return a.decryptLegacyFile(inputFile, fileSize, additionalData, openOutput, progress)
+	}
+	if errors.Is(err, ErrCorruptedContainer) || errors.Is(err, ErrTruncatedStream) {
+		return ErrAuthenticationFailed
+	}
+	if err != nil {
+		return err
+	}
+
+	if !header.HasMAC {
+		return ErrAuthenticationFailed
+	}
+	if err = a.cipher.checkContainerHeader(header); err != nil {
+		return err
+	}
+
+	bodySize := a.cipher.containerBodySize(header)
+	if fileSize != int64(len(rawHeader))+bodySize+TagSize {
+		return ErrAuthenticationFailed
+	}
+
+	mac := a.newFileMAC(additionalData)
+	mac.Write(rawHeader)
+	if err = a.verifyTag(mac, inputFile, bodySize); err != nil {
+		return err
+	}
+
+	if _, err = inputFile.Seek(int64(len(rawHeader)), io.SeekStart); err != nil {
+		return fmt.Errorf("seek error: %w", err)
+	}
+
+	outputFile, err := openOutput()
+	if err != nil {
+		return err
+	}
+	defer outputFile.Close()
+
+	return a.cipher.readContainerBody(inputFile, header, rawHeader, outputFile, progress)
+}
+
+// decryptLegacyFile расшифровывает файлы, записанные до появления заголовка контейнера.
+func (a *AEADContext) decryptLegacyFile(inputFile *os.File, fileSize int64, additionalData []byte, openOutput func() (io.WriteCloser, error), progress func(done, total int)) error {
+	if fileSize <= TagSize {
+		return ErrAuthenticationFailed
+	}
+	encryptedSize := fileSize - TagSize
+
+	if _, err := inputFile.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("seek error: %w", err)
+	}
+	if err := a.verifyTag(a.newFileMAC(additionalData), inputFile, encryptedSize); err != nil {
+		return err
+	}
+	if _, err := inputFile.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("seek error: %w", err)
+	}
+
+	outputFile, err := openOutput()
+	if err != nil {
+		return err
+	}
+	defer outputFile.Close()
+
+	return a.cipher.decryptStream(io.LimitReader(inputFile, encryptedSize), encryptedSize, outputFile, progress)
+}
+
+type nopWriteCloser struct{ io.Writer }
+
+func (nopWriteCloser) Close() error { return nil }
+
+// verifyTag дописывает в mac следующие size байт r и сравнивает результат с тегом, идущим за ними.
+func (a *AEADContext) verifyTag(mac hash.Hash, r io.Reader, size int64) error {
+	if _, err := io.Copy(mac, io.LimitReader(r, size)); err != nil {
+		return fmt.Errorf("read error: %w", err)
+	}
+
+	tag := make([]byte, TagSize)
+	if _, err := io.ReadFull(r, tag); err != nil {
+		return ErrAuthenticationFailed
+	}
+	if !hmac.Equal(mac.Sum(nil), tag) {
+		return ErrAuthenticationFailed
+	}
+	return nil
+}
+
+// NewEncryptWriter пишет в w контейнер для size байт открытого текста, как EncryptFile:
+// тег по additionalData, заголовку и шифртексту дописывается при Close.
+func (a *AEADContext) NewEncryptWriter(w io.Writer, size int64, additionalData []byte) (io.WriteCloser, error) {
+	mac := a.newFileMAC(additionalData)
+
+	encryptWriter, err := a.cipher.newContainerWriter(io.MultiWriter(w, mac), size, true)
+	if err != nil {
+		return nil, err
+	}
+
+	return &aeadWriter{encryptWriter: encryptWriter, w: w, mac: mac}, nil
+}
+
+// EncryptedSize возвращает длину результата NewEncryptWriter (и EncryptFile) для size байт.
+func (a *AEADContext) EncryptedSize(size int64) int64 {
+	return a.cipher.containerSize(size) + TagSize
+}
+
+type aeadWriter struct {
+	encryptWriter io.WriteCloser
+	w             io.Writer
+	mac           hash.Hash
+	closed        bool
+}
+
+func (a *aeadWriter) Write(p []byte) (int, error) {
+	return a.encryptWriter.Write(p)
+}
+
+func (a *aeadWriter) Close() error {
+	if a.closed {
+		return nil
+	}
+	a.closed = true
+
+	if err := a.encryptWriter.Close(); err != nil {
+		return err
+	}
+	if _, err := a.w.Write(a.mac.Sum(nil)); err != nil {
+		return fmt.Errorf("write tag: %w", err)
+	}
+	return nil
+}
+
+// newMAC возвращает HMAC, в который уже записаны IV и параметры режима,
+// чтобы подмена любого из них ломала проверку тега.
+func (a *AEADContext) newMAC(label string, chunkIndex, totalChunks int) hash.Hash {
+	mac := hmac.New(sha256.New, a.macKey)
+
+	writeField(mac, []byte(label))
+	writeField(mac, []byte{byte(a.cipher.mode), byte(a.cipher.padding)})
+	writeUint64(mac, uint64(a.cipher.blockSize))
+	writeField(mac, a.cipher.iv)
+
+	names := make([]string, 0, len(a.cipher.extraParams))
+	for name := range a.cipher.extraParams {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		writeField(mac, []byte(name))
+		writeField(mac, a.cipher.extraParams[name])
+	}
+
+	writeUint64(mac, uint64(chunkIndex))
+	writeUint64(mac, uint64(totalChunks))
+
+	return mac
+}
+
+// newFileMAC — newMAC для файла; additionalData связывает файл с сообщением, как в Encrypt.
+func (a *AEADContext) newFileMAC(additionalData []byte) hash.Hash {
+	mac := a.newMAC(macLabelFile, 0, 0)
+	writeField(mac, additionalData)
+	return mac
+}
+
+func deriveMACKey(key []byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(macKeyLabel))
+	return mac.Sum(nil)
+}
+
+func writeField(h hash.Hash, field []byte) {
+	writeUint64(h, uint64(len(field)))
+	h.Write(field)
+}
+
+func writeUint64(h hash.Hash, v uint64) {
+	var buf [8]byte
+	binary.BigEndian.PutUint64(buf[:], v)
+	h.Write(buf[:])
+}
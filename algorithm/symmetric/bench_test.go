@@ -0,0 +1,149 @@
+package symmetric_test
+
+import (
+	_ "CryptoMessenger/algorithm/rc5"
+	_ "CryptoMessenger/algorithm/rc6"
+	"CryptoMessenger/algorithm/symmetric"
+	"crypto/rand"
+	"testing"
+)
+
+// Пропускная способность режимов: go test -bench . -benchmem ./algorithm/symmetric
+
+const benchDataSize = 1 << 20
+
+func BenchmarkRC5ECB(b *testing.B)         { benchmarkSuite(b, "RC5", "ECB") }
+func BenchmarkRC5CBC(b *testing.B)         { benchmarkSuite(b, "RC5", "CBC") }
+func BenchmarkRC5PCBC(b *testing.B)        { benchmarkSuite(b, "RC5", "PCBC") }
+func BenchmarkRC5CFB(b *testing.B)         { benchmarkSuite(b, "RC5", "CFB") }
+func BenchmarkRC5OFB(b *testing.B)         { benchmarkSuite(b, "RC5", "OFB") }
+func BenchmarkRC5CTR(b *testing.B)         { benchmarkSuite(b, "RC5", "CTR") }
+func BenchmarkRC5RandomDelta(b *testing.B) { benchmarkSuite(b, "RC5", "RandomDelta") }
+func BenchmarkRC5GCM(b *testing.B)         { benchmarkSuite(b, "RC5", "GCM") }
+
+func BenchmarkRC6ECB(b *testing.B)         { benchmarkSuite(b, "RC6", "ECB") }
+func BenchmarkRC6CBC(b *testing.B)         { benchmarkSuite(b, "RC6", "CBC") }
+func BenchmarkRC6PCBC(b *testing.B)        { benchmarkSuite(b, "RC6", "PCBC") }
+func BenchmarkRC6CFB(b *testing.B)         { benchmarkSuite(b, "RC6", "CFB") }
+func BenchmarkRC6OFB(b *testing.B)         { benchmarkSuite(b, "RC6", "OFB") }
+func BenchmarkRC6CTR(b *testing.B)         { benchmarkSuite(b, "RC6", "CTR") }
+func BenchmarkRC6RandomDelta(b *testing.B) { benchmarkSuite(b, "RC6", "RandomDelta") }
+func BenchmarkRC6GCM(b *testing.B)         { benchmarkSuite(b, "RC6", "GCM") }
+
+func benchmarkSuite(b *testing.B, cipherName, modeName string) {
+	if err := symmetric.ValidateSuite(cipherName, modeName, "PKCS7", nil, 0); err != nil {
+		b.Skip(err)
+	}
+	cipherContext := newBenchContext(b, cipherName, modeName)
+	data := randomBytes(b, benchDataSize)
+
+	encrypted, err := cipherContext.Encrypt(data, 0, 1)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.Run("encrypt", func(b *testing.B) {
+		b.SetBytes(int64(len(data)))
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if _, err := cipherContext.Encrypt(data, 0, 1); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("decrypt", func(b *testing.B) {
+		b.SetBytes(int64(len(data)))
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if _, err := cipherContext.Decrypt(encrypted, 0, 1); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func newBenchContext(tb testing.TB, cipherName, modeName string) *symmetric.CipherContext {
+	tb.Helper()
+	cipherInfo, _ := symmetric.LookupCipher(cipherName)
+	mode, _ := symmetric.LookupMode(modeName)
+
+	key := randomBytes(tb, 32)
+	scheme, blockSize, err := cipherInfo.Create(key, nil)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	cipherContext, err := symmetric.NewCipherContext(key, scheme, mode.Mode, symmetric.PKCS7,
+		randomBytes(tb, blockSize), blockSize, "randomDelta", randomBytes(tb, blockSize))
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return cipherContext
+}
+
+func randomBytes(tb testing.TB, n int) []byte {
+	tb.Helper()
+	buf := make([]byte, n)
+	if _, err := rand.Read(buf); err != nil {
+		tb.Fatal(err)
+	}
+	return buf
+}
+
+// Блочный API не выделяет память, поэтому у режимов остаются только аллокации
+// под результат и буферы отрезков, а не по одной на блок.
+func BenchmarkRC5EncryptBlock(b *testing.B) { benchmarkBlock(b, "RC5") }
+func BenchmarkRC6EncryptBlock(b *testing.B) { benchmarkBlock(b, "RC6") }
+
+func benchmarkBlock(b *testing.B, cipherName string) {
+	cipherInfo, _ := symmetric.LookupCipher(cipherName)
+	scheme, blockSize, err := cipherInfo.Create(randomBytes(b, 32), nil)
+	if err != nil {
+		b.Fatal(err)
+	}
+	block := randomBytes(b, blockSize)
+
+	b.SetBytes(int64(blockSize))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if err := scheme.EncryptBlock(block, block); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestBlockAPIDoesNotAllocate(t *testing.T) {
+	for _, cipherName := range symmetric.CipherNames() {
+		cipherInfo, _ := symmetric.LookupCipher(cipherName)
+		scheme, blockSize, err := cipherInfo.Create(randomBytes(t, 32), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		block := randomBytes(t, blockSize)
+
+		allocs := testing.AllocsPerRun(100, func() {
+			_ = scheme.EncryptBlock(block, block)
+			_ = scheme.DecryptBlock(block, block)
+		})
+		if allocs != 0 {
+			t.Errorf("%s: %v allocations per block", cipherName, allocs)
+		}
+	}
+}
+
+func TestModesAllocatePerCallNotPerBlock(t *testing.T) {
+	for _, modeName := range []string{"ECB", "CBC", "PCBC", "CFB", "OFB", "CTR", "RandomDelta"} {
+		cipherContext := newBenchContext(t, "RC6", modeName)
+		cipherContext.SetWorkers(1)
+		data := randomBytes(t, 64<<10)
+
+		allocs := testing.AllocsPerRun(10, func() {
+			if _, err := cipherContext.Encrypt(data, 0, 1); err != nil {
+				t.Fatal(err)
+			}
+		})
+		// Блоков здесь 4096: аллокаций на блок быть не должно
+		if allocs > 16 {
+			t.Errorf("%s: %v allocations per call", modeName, allocs)
+		}
+	}
+}
@@ -0,0 +1,384 @@
+package symmetric
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+)
+
+// Зашифрованный файл начинается с заголовка, по которому его можно расшифровать,
+// зная только ключ (все целые числа big-endian):
+//
+//	magic "CMEF" | version | algorithm | mode | padding | blockSize u16 | flags |
+//	keyEpoch u32 | chunkSize u32 | originalSize u64 | wordSize u8 | rounds u8 | keyLength u8 |
+//	len(IV) u8 | IV | len(randomDelta) u8 | randomDelta | crc32 всего предыдущего
+//
+// В версии 1 не было полей wordSize, rounds и keyLength: такие файлы читаются,
+// но параметры шифра для них берутся из контекста.
+//
+// Затем идёт шифртекст потока без собственного заголовка (IV уже в заголовке файла),
+// а при флаге containerFlagMAC — HMAC-SHA256 по заголовку и шифртексту.
+// В режиме GCM заголовок входит в тег как дополнительные данные.
+const (
+	ContainerVersion = 2
+
+	containerFlagMAC = 1 << 0
+
+	containerPrefixSize  = 4 + 1
+	containerFixedSizeV1 = containerPrefixSize + 1 + 1 + 1 + 2 + 1 + 4 + 4 + 8
+	containerFixedSize   = containerFixedSizeV1 + 1 + 1 + 1
+)
+
+var containerMagic = []byte("CMEF")
+
+var (
+	ErrNotContainer       = errors.New("file has no container header")
+	ErrCorruptedContainer = errors.New("container header is corrupted")
+	ErrContainerMismatch  = errors.New("container was encrypted with different cipher parameters")
+)
+
+// ContainerHeader описывает параметры, с которыми зашифрован файл.
+type ContainerHeader struct {
+	Version   uint8
+	Algorithm AlgorithmID
+	Mode      CipherMode
+	Padding   PaddingMode
+	BlockSize int
+	// WordSize (в битах), Rounds и KeyLength (в байтах) — параметры шифра; 0 в заголовках версии 1.
+	WordSize     int
+	Rounds       int
+	KeyLength    int
+	KeyEpoch     uint32
+	ChunkSize    uint32
+	OriginalSize uint64
+	IV           []byte
+	RandomDelta  []byte
+	HasMAC       bool
+}
+
+func (h *ContainerHeader) MarshalBinary() ([]byte, error) {
+	if h.BlockSize <= 0 || h.BlockSize > 0xffff {
+		return nil, fmt.Errorf("invalid block size: %d", h.BlockSize)
+	}
+	if len(h.IV) > 0xff || len(h.RandomDelta) > 0xff {
+		return nil, errors.New("container IV or delta is too long")
+	}
+	if h.WordSize < 0 || h.WordSize > 0xff || h.Rounds < 0 || h.Rounds > 0xff || h.KeyLength < 0 || h.KeyLength > 0xff {
+		return nil, errors.New("container cipher parameters are out of range")
+	}
+
+	var flags byte
+	if h.HasMAC {
+		flags |= containerFlagMAC
+	}
+
+	buf := make([]byte, 0, containerFixedSize+2+len(h.IV)+len(h.RandomDelta)+4)
+	buf = append(buf, containerMagic...)
+	buf = append(buf, ContainerVersion, byte(h.Algorithm), byte(h.Mode), byte(h.Padding))
+	buf = binary.BigEndian.AppendUint16(buf, uint16(h.BlockSize))
+	buf = append(buf, flags)
+	buf = binary.BigEndian.AppendUint32(buf, h.KeyEpoch)
+	buf = binary.BigEndian.AppendUint32(buf, h.ChunkSize)
+	buf = binary.BigEndian.AppendUint64(buf, h.OriginalSize)
+	buf = append(buf, byte(h.WordSize), byte(h.Rounds), byte(h.KeyLength))
+	buf = append(buf, byte(len(h.IV)))
+	buf = append(buf, h.IV...)
+	buf = append(buf, byte(len(h.RandomDelta)))
+	buf = append(buf, h.RandomDelta...)
+	buf = binary.BigEndian.AppendUint32(buf, crc32.ChecksumIEEE(buf))
+
+	return buf, nil
+}
+
+// ReadContainerHeader читает заголовок из r и возвращает его вместе с исходными байтами,
+// которые нужны для проверки MAC. Если файл начинается не с magic, возвращается ErrNotContainer.
+func ReadContainerHeader(r io.Reader) (*ContainerHeader, []byte, error) {
+	raw := make([]byte, containerPrefixSize)
+	if _, err := io.ReadFull(r, raw); err != nil {
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil, nil, ErrNotContainer
+		}
+		return nil, nil, fmt.Errorf("read container header: %w", err)
+	}
+	if !bytes.Equal(raw[:len(containerMagic)], containerMagic) {
+		return nil, nil, ErrNotContainer
+	}
+
+	version := raw[4]
+	var fixedSize int
+	switch version {
+	case 1:
+		fixedSize = containerFixedSizeV1
+	case ContainerVersion:
+		fixedSize = containerFixedSize
+	default:
+		return nil, nil, fmt.Errorf("unsupported container version: %d", version)
+	}
+
+	rest := make([]byte, fixedSize-containerPrefixSize+1)
+	if _, err := io.ReadFull(r, rest); err != nil {
+		return nil, nil, ErrTruncatedStream
+	}
+	raw = append(raw, rest...)
+
+	ivLen := int(raw[fixedSize])
+	rest = make([]byte, ivLen+1)
+	if _, err := io.ReadFull(r, rest); err != nil {
+		return nil, nil, ErrTruncatedStream
+	}
+	raw = append(raw, rest...)
+
+	deltaLen := int(raw[len(raw)-1])
+	rest = make([]byte, deltaLen+crc32.Size)
+	if _, err := io.ReadFull(r, rest); err != nil {
+		return nil, nil, ErrTruncatedStream
+	}
+	raw = append(raw, rest...)
+
+	body, checksum := raw[:len(raw)-crc32.Size], raw[len(raw)-crc32.Size:]
+	if crc32.ChecksumIEEE(body) != binary.BigEndian.Uint32(checksum) {
+		return nil, nil, ErrCorruptedContainer
+	}
+
+	h := &ContainerHeader{
+		Version:      version,
+		Algorithm:    AlgorithmID(raw[5]),
+		Mode:         CipherMode(raw[6]),
+		Padding:      PaddingMode(raw[7]),
+		BlockSize:    int(binary.BigEndian.Uint16(raw[8:10])),
+		HasMAC:       raw[10]&containerFlagMAC != 0,
+		KeyEpoch:     binary.BigEndian.Uint32(raw[11:15]),
+		ChunkSize:    binary.BigEndian.Uint32(raw[15:19]),
+		OriginalSize: binary.BigEndian.Uint64(raw[19:27]),
+	}
+	if version >= 2 {
+		h.WordSize = int(raw[27])
+		h.Rounds = int(raw[28])
+		h.KeyLength = int(raw[29])
+	}
+
+	pos := fixedSize + 1
+	h.IV = append([]byte(nil), raw[pos:pos+ivLen]...)
+	pos += ivLen + 1
+	if deltaLen > 0 {
+		h.RandomDelta = append([]byte(nil), raw[pos:pos+deltaLen]...)
+	}
+
+	return h, raw, nil
+}
+
+// NewCipherContextFromHeader создаёт контекст с параметрами из заголовка файла,
+// так что для расшифрования достаточно ключа и шифра, соответствующего h.Algorithm.
+func NewCipherContextFromHeader(key []byte, cipher CipherScheme, h *ContainerHeader) (*CipherContext, error) {
+	var extra []interface{}
+	if h.RandomDelta != nil {
+		extra = []interface{}{"randomDelta", h.RandomDelta}
+	}
+
+	if h.KeyLength != 0 && len(key) != h.KeyLength {
+		return nil, ErrContainerMismatch
+	}
+
+	c, err := NewCipherContext(key, cipher, h.Mode, h.Padding, h.IV, h.BlockSize, extra...)
+	if err != nil {
+		return nil, err
+	}
+	c.algorithm = h.Algorithm
+	c.keyEpoch = h.KeyEpoch
+	c.wordSize = h.WordSize
+	c.rounds = h.Rounds
+
+	return c, nil
+}
+
+// OpenContainerContext создаёт шифр и контекст только по заголовку и ключу.
+// Для заголовков версии 1 используются параметры шифра по умолчанию.
+func OpenContainerContext(key []byte, h *ContainerHeader) (*CipherContext, error) {
+	cipherInfo, ok := LookupCipherByID(h.Algorithm)
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown algorithm %d", ErrUnsupportedSuite, h.Algorithm)
+	}
+	cipher, blockSize, err := cipherInfo.Create(key, RoomParams(h.WordSize, h.Rounds))
+	if err != nil {
+		return nil, err
+	}
+	if blockSize != h.BlockSize {
+		return nil, ErrContainerMismatch
+	}
+	return NewCipherContextFromHeader(key, cipher, h)
+}
+
+func (c *CipherContext) newContainerHeader(iv []byte, size int64, hasMAC bool) *ContainerHeader {
+	h := &ContainerHeader{
+		Version:      ContainerVersion,
+		Algorithm:    c.algorithm,
+		Mode:         c.mode,
+		Padding:      c.padding,
+		BlockSize:    c.blockSize,
+		WordSize:     c.wordSize,
+		Rounds:       c.rounds,
+		KeyLength:    len(c.key),
+		KeyEpoch:     c.keyEpoch,
+		ChunkSize:    uint32(c.blockSize * 1024),
+		OriginalSize: uint64(size),
+		IV:           iv,
+		HasMAC:       hasMAC,
+	}
+	if c.mode == RandomDelta {
+		h.RandomDelta = c.extraParams["randomDelta"]
+	}
+	return h
+}
+
+// checkContainerHeader сверяет заголовок с параметрами контекста: расшифровать файл
+// другим шифром или режимом всё равно не получится, лучше сразу сообщить об этом.
+func (c *CipherContext) checkContainerHeader(h *ContainerHeader) error {
+	if h.Mode != c.mode || h.Padding != c.padding || h.BlockSize != c.blockSize {
+		return ErrContainerMismatch
+	}
+	if h.Algorithm != UnknownAlgorithm && c.algorithm != UnknownAlgorithm && h.Algorithm != c.algorithm {
+		return ErrContainerMismatch
+	}
+	if h.Mode == RandomDelta && !bytes.Equal(h.RandomDelta, c.extraParams["randomDelta"]) {
+		return ErrContainerMismatch
+	}
+	// В заголовках версии 1 параметров нет, у контекста они могут быть не заданы
+	if h.WordSize != 0 && c.wordSize != 0 && h.WordSize != c.wordSize {
+		return ErrContainerMismatch
+	}
+	if h.Rounds != 0 && c.rounds != 0 && h.Rounds != c.rounds {
+		return ErrContainerMismatch
+	}
+	if h.KeyLength != 0 && h.KeyLength != len(c.key) {
+		return ErrContainerMismatch
+	}
+	return nil
+}
+
+// containerBodySize — ожидаемая длина шифртекста после заголовка, без MAC.
+func (c *CipherContext) containerBodySize(h *ContainerHeader) int64 {
+	return c.streamBodySize(int64(h.OriginalSize))
+}
+
+// containerSize — полная длина контейнера для size байт открытого текста, без MAC.
+func (c *CipherContext) containerSize(size int64) int64 {
+	headerSize := containerFixedSize + 1 + 1 + crc32.Size
+	if c.usesIV() {
+		headerSize += c.blockSize
+	}
+	if c.mode == GCM {
+		headerSize += GCMNonceSize
+	}
+	if c.mode == RandomDelta {
+		headerSize += len(c.extraParams["randomDelta"])
+	}
+	return int64(headerSize) + c.streamBodySize(size)
+}
+
+// containerWriter шифрует ровно size байт после уже записанного заголовка.
+type containerWriter struct {
+	*encryptWriter
+	size    int64
+	written int64
+}
+
+// newContainerWriter сразу пишет в w заголовок контейнера для size байт открытого текста.
+func (c *CipherContext) newContainerWriter(w io.Writer, size int64, hasMAC bool) (*containerWriter, error) {
+	if c.mode == GCM {
+		if err := checkGCMLength(size); err != nil {
+			return nil, err
+		}
+	}
+
+	iv, err := c.newStreamIV()
+	if err != nil {
+		return nil, err
+	}
+
+	header, err := c.newContainerHeader(iv, size, hasMAC).MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	if _, err = w.Write(header); err != nil {
+		return nil, fmt.Errorf("write error: %w", err)
+	}
+
+	encryptWriter, err := c.newEncryptWriter(w, iv, nil, header)
+	if err != nil {
+		return nil, err
+	}
+
+	return &containerWriter{encryptWriter: encryptWriter, size: size}, nil
+}
+
+func (cw *containerWriter) Write(p []byte) (int, error) {
+	if cw.written+int64(len(p)) > cw.size {
+		return 0, fmt.Errorf("container size %d exceeded", cw.size)
+	}
+	n, err := cw.encryptWriter.Write(p)
+	cw.written += int64(n)
+	return n, err
+}
+
+func (cw *containerWriter) Close() error {
+	if cw.written != cw.size {
+		return fmt.Errorf("container expects %d bytes, got %d", cw.size, cw.written)
+	}
+	return cw.encryptWriter.Close()
+}
+
+// writeContainer пишет в w заголовок и шифртекст r.
+func (c *CipherContext) writeContainer(ctx context.Context, r io.Reader, size int64, w io.Writer, hasMAC bool, progress func(done, total int)) error {
+	containerWriter, err := c.newContainerWriter(w, size, hasMAC)
+	if err != nil {
+		return err
+	}
+
+	if err = copyChunks(ctx, containerWriter, r, size, int64(c.blockSize*1024), progress); err != nil {
+		return err
+	}
+
+	if err = containerWriter.Close(); err != nil {
+		return fmt.Errorf("write error: %w", err)
+	}
+	return nil
+}
+
+// readContainerBody расшифровывает шифртекст после заголовка и проверяет,
+// что получилось ровно OriginalSize байт.
+func (c *CipherContext) readContainerBody(r io.Reader, h *ContainerHeader, rawHeader []byte, w io.Writer, progress func(done, total int)) error {
+	iv := h.IV
+	if len(iv) == 0 {
+		iv = nil
+	}
+
+	bodyReader, err := c.newBodyReader(io.LimitReader(r, c.containerBodySize(h)), iv, rawHeader)
+	if err != nil {
+		return err
+	}
+
+	counter := &countingWriter{w: w}
+	if err = copyChunks(context.Background(), counter, bodyReader, int64(h.OriginalSize), int64(c.blockSize*1024), progress); err != nil {
+		return err
+	}
+
+	if counter.n != int64(h.OriginalSize) {
+		return ErrTruncatedStream
+	}
+	return nil
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
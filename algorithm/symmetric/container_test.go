@@ -0,0 +1,317 @@
+package symmetric_test
+
+import (
+	"CryptoMessenger/algorithm/symmetric"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func noProgress(done, total int) {}
+
+// newParamContext создаёт контекст с параметрами шифра, отличными от значений по умолчанию.
+func newParamContext(t *testing.T, cipherName, modeName string, params symmetric.Params, keyLength int) (*symmetric.CipherContext, []byte) {
+	t.Helper()
+	cipherInfo, _ := symmetric.LookupCipher(cipherName)
+	mode, _ := symmetric.LookupMode(modeName)
+
+	key := randomBytes(t, keyLength)
+	scheme, blockSize, err := cipherInfo.Create(key, params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cipherContext, err := symmetric.NewCipherContext(key, scheme, mode.Mode, symmetric.PKCS7,
+		randomBytes(t, blockSize), blockSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	full, _ := cipherInfo.ResolveParams(params)
+	cipherContext.SetAlgorithm(cipherInfo.ID)
+	cipherContext.SetCipherParams(full["w"], full["r"])
+	return cipherContext, key
+}
+
+func encryptTempFile(t *testing.T, cipherContext *symmetric.CipherContext, plaintext []byte) string {
+	t.Helper()
+	dir := t.TempDir()
+	plainPath := filepath.Join(dir, "plain")
+	if err := os.WriteFile(plainPath, plaintext, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	encryptedPath := filepath.Join(dir, "encrypted")
+	if err := cipherContext.EncryptFile(context.Background(), plainPath, encryptedPath, noProgress); err != nil {
+		t.Fatal(err)
+	}
+	return encryptedPath
+}
+
+func readHeader(t *testing.T, path string) *symmetric.ContainerHeader {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	header, _, err := symmetric.ReadContainerHeader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return header
+}
+
+func TestContainerHeaderCarriesCipherParams(t *testing.T) {
+	tests := []struct {
+		cipher    string
+		mode      string
+		params    symmetric.Params
+		keyLength int
+	}{
+		{"RC5", "CBC", symmetric.Params{"w": 32, "r": 16}, 20},
+		{"RC5", "CTR", symmetric.Params{"w": 16, "r": 7}, 5},
+		{"RC6", "CFB", symmetric.Params{"w": 64, "r": 12}, 40},
+		{"RC6", "GCM", symmetric.Params{"w": 32, "r": 8}, 24},
+	}
+	for _, tt := range tests {
+		t.Run(tt.cipher+"/"+tt.mode, func(t *testing.T) {
+			cipherContext, key := newParamContext(t, tt.cipher, tt.mode, tt.params, tt.keyLength)
+			plaintext := randomBytes(t, 5000)
+			encryptedPath := encryptTempFile(t, cipherContext, plaintext)
+
+			header := readHeader(t, encryptedPath)
+			if header.Version != symmetric.ContainerVersion || header.WordSize != tt.params["w"] ||
+				header.Rounds != tt.params["r"] || header.KeyLength != tt.keyLength {
+				t.Fatalf("header = %+v", header)
+			}
+
+			// Для расшифрования достаточно заголовка и ключа
+			opened, err := symmetric.OpenContainerContext(key, header)
+			if err != nil {
+				t.Fatal(err)
+			}
+			decryptedPath := filepath.Join(t.TempDir(), "decrypted")
+			if err = opened.DecryptFile(encryptedPath, decryptedPath, noProgress); err != nil {
+				t.Fatal(err)
+			}
+			decrypted, _ := os.ReadFile(decryptedPath)
+			if !bytes.Equal(decrypted, plaintext) {
+				t.Fatal("decrypted file differs from the original")
+			}
+		})
+	}
+}
+
+func TestContainerRejectsOtherCipherParams(t *testing.T) {
+	cipherContext, key := newParamContext(t, "RC5", "CBC", symmetric.Params{"w": 64, "r": 16}, 16)
+	encryptedPath := encryptTempFile(t, cipherContext, randomBytes(t, 100))
+
+	// Тот же шифр, размер блока и ключ, но другое число раундов
+	other, _ := newParamContext(t, "RC5", "CBC", symmetric.Params{"w": 64, "r": 12}, 16)
+	err := other.DecryptFile(encryptedPath, filepath.Join(t.TempDir(), "out"), noProgress)
+	if !errors.Is(err, symmetric.ErrContainerMismatch) {
+		t.Fatalf("rounds mismatch: got %v", err)
+	}
+
+	header := readHeader(t, encryptedPath)
+	if _, err = symmetric.OpenContainerContext(key[:8], header); !errors.Is(err, symmetric.ErrContainerMismatch) {
+		t.Fatalf("key length mismatch: got %v", err)
+	}
+}
+
+func TestReadContainerHeaderVersion1(t *testing.T) {
+	iv := []byte("0123456789abcdef")
+
+	buf := []byte("CMEF")
+	buf = append(buf, 1, byte(symmetric.AlgorithmRC6), byte(symmetric.CBC), byte(symmetric.PKCS7))
+	buf = binary.BigEndian.AppendUint16(buf, 16)
+	buf = append(buf, 0)
+	buf = binary.BigEndian.AppendUint32(buf, 3)
+	buf = binary.BigEndian.AppendUint32(buf, 16*1024)
+	buf = binary.BigEndian.AppendUint64(buf, 42)
+	buf = append(buf, byte(len(iv)))
+	buf = append(buf, iv...)
+	buf = append(buf, 0)
+	buf = binary.BigEndian.AppendUint32(buf, crc32.ChecksumIEEE(buf))
+
+	header, raw, err := symmetric.ReadContainerHeader(bytes.NewReader(buf))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(raw, buf) {
+		t.Fatal("raw header differs from input")
+	}
+	if header.Version != 1 || header.BlockSize != 16 || header.KeyEpoch != 3 || header.OriginalSize != 42 ||
+		!bytes.Equal(header.IV, iv) || header.WordSize != 0 || header.Rounds != 0 || header.KeyLength != 0 {
+		t.Fatalf("header = %+v", header)
+	}
+}
+
+func TestContainerRejectsTruncatedOrCorrupted(t *testing.T) {
+	cipherContext, _ := newParamContext(t, "RC6", "CBC", nil, 16)
+	aead, err := symmetric.NewAEADContext(cipherContext)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	plainPath := filepath.Join(dir, "plain")
+	if err = os.WriteFile(plainPath, randomBytes(t, 3000), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	sealedPath := filepath.Join(dir, "sealed")
+	if err = aead.EncryptFile(context.Background(), plainPath, sealedPath, []byte("message"), noProgress); err != nil {
+		t.Fatal(err)
+	}
+	sealed, _ := os.ReadFile(sealedPath)
+	headerSize := len(readRawHeader(t, sealed))
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"header cut", sealed[:headerSize-3]},
+		{"header only", sealed[:headerSize]},
+		{"body cut", sealed[:headerSize+100]},
+		{"tag missing", sealed[:len(sealed)-symmetric.TagSize]},
+		{"last byte missing", sealed[:len(sealed)-1]},
+		{"extra byte", append(bytes.Clone(sealed), 0)},
+		{"header byte flipped", flipped(sealed, 12)},
+		{"header checksum flipped", flipped(sealed, headerSize-1)},
+		{"body byte flipped", flipped(sealed, headerSize+5)},
+		{"tag byte flipped", flipped(sealed, len(sealed)-1)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inputPath := filepath.Join(t.TempDir(), "input")
+			if err := os.WriteFile(inputPath, tt.data, 0o600); err != nil {
+				t.Fatal(err)
+			}
+			outputPath := filepath.Join(t.TempDir(), "output")
+			if err := aead.DecryptFile(inputPath, outputPath, []byte("message"), noProgress); !errors.Is(err, symmetric.ErrAuthenticationFailed) {
+				t.Fatalf("got %v, want %v", err, symmetric.ErrAuthenticationFailed)
+			}
+			if _, err := os.Stat(outputPath); !os.IsNotExist(err) {
+				t.Fatal("output was written for a rejected container")
+			}
+		})
+	}
+}
+
+// Тег файла зависит от дополнительных данных: шифртекст нельзя выдать за другое сообщение.
+func TestContainerBindsAdditionalData(t *testing.T) {
+	for _, modeName := range []string{"CBC", "GCM"} {
+		t.Run(modeName, func(t *testing.T) {
+			cipherContext, _ := newParamContext(t, "RC6", modeName, nil, 16)
+			aead, err := symmetric.NewAEADContext(cipherContext)
+			if err != nil {
+				t.Fatal(err)
+			}
+			plaintext := randomBytes(t, 1000)
+			aad := []byte("room\x00sender\x00file")
+
+			var sealed bytes.Buffer
+			encryptWriter, err := aead.NewEncryptWriter(&sealed, int64(len(plaintext)), aad)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err = encryptWriter.Write(plaintext); err != nil {
+				t.Fatal(err)
+			}
+			if err = encryptWriter.Close(); err != nil {
+				t.Fatal(err)
+			}
+			if int64(sealed.Len()) != aead.EncryptedSize(int64(len(plaintext))) {
+				t.Fatalf("sealed %d bytes, EncryptedSize = %d", sealed.Len(), aead.EncryptedSize(int64(len(plaintext))))
+			}
+			sealedPath := filepath.Join(t.TempDir(), "sealed")
+			if err = os.WriteFile(sealedPath, sealed.Bytes(), 0o600); err != nil {
+				t.Fatal(err)
+			}
+
+			var opened bytes.Buffer
+			if err = aead.DecryptFileTo(sealedPath, aad, &opened, noProgress); err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(opened.Bytes(), plaintext) {
+				t.Fatal("decrypted file differs from the plaintext")
+			}
+
+			for _, other := range [][]byte{nil, []byte("room\x00sender\x00other")} {
+				var out bytes.Buffer
+				if err = aead.DecryptFileTo(sealedPath, other, &out, noProgress); !errors.Is(err, symmetric.ErrAuthenticationFailed) {
+					t.Fatalf("additional data %q: got %v", other, err)
+				}
+				if out.Len() != 0 {
+					t.Fatal("output was written for a rejected file")
+				}
+			}
+		})
+	}
+}
+
+// Контейнер GCM больше 2^39-256 бит не создаётся: счётчик блоков пошёл бы по кругу.
+func TestGCMContainerRejectsOversizeInput(t *testing.T) {
+	cipherContext, _ := newParamContext(t, "RC6", "GCM", nil, 16)
+	aead, err := symmetric.NewAEADContext(cipherContext)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = aead.NewEncryptWriter(io.Discard, 1<<36, nil); !errors.Is(err, symmetric.ErrGCMTooLong) {
+		t.Fatalf("got %v, want %v", err, symmetric.ErrGCMTooLong)
+	}
+	if _, err = aead.NewEncryptWriter(io.Discard, 1<<36-32, nil); err != nil {
+		t.Fatalf("input at the limit: %v", err)
+	}
+}
+
+// Без MAC повреждение тела не обнаружить, но обрезанный файл и испорченный
+// заголовок всё равно отвергаются.
+func TestUnauthenticatedContainerRejectsTruncatedOrCorrupted(t *testing.T) {
+	cipherContext, _ := newParamContext(t, "RC6", "CBC", nil, 16)
+	encrypted, _ := os.ReadFile(encryptTempFile(t, cipherContext, randomBytes(t, 3000)))
+	headerSize := len(readRawHeader(t, encrypted))
+
+	tests := []struct {
+		name    string
+		data    []byte
+		wantErr error
+	}{
+		{"header cut", encrypted[:headerSize-3], symmetric.ErrTruncatedStream},
+		{"body cut", encrypted[:headerSize+100], symmetric.ErrTruncatedStream},
+		{"last byte missing", encrypted[:len(encrypted)-1], symmetric.ErrTruncatedStream},
+		{"extra byte", append(bytes.Clone(encrypted), 0), symmetric.ErrTruncatedStream},
+		{"header byte flipped", flipped(encrypted, 12), symmetric.ErrCorruptedContainer},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inputPath := filepath.Join(t.TempDir(), "input")
+			if err := os.WriteFile(inputPath, tt.data, 0o600); err != nil {
+				t.Fatal(err)
+			}
+			err := cipherContext.DecryptFile(inputPath, filepath.Join(t.TempDir(), "output"), noProgress)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("got %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func readRawHeader(t *testing.T, data []byte) []byte {
+	t.Helper()
+	_, raw, err := symmetric.ReadContainerHeader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return raw
+}
+
+func flipped(data []byte, i int) []byte {
+	out := bytes.Clone(data)
+	out[i] ^= 0x80
+	return out
+}
@@ -13,6 +13,11 @@ type CipherContext struct {
 	iv          []byte
 	extraParams map[string][]byte
 	blockSize   int
+	workers     int
+	algorithm   AlgorithmID
+	wordSize    int
+	rounds      int
+	keyEpoch    uint32
 }
 
 func NewCipherContext(
@@ -24,6 +29,10 @@ func NewCipherContext(
 	blockSize int,
 	extraParams ...interface{}) (*CipherContext, error) {
 
+	if blockSize <= 0 || blockSize > 255 {
+		return nil, fmt.Errorf("invalid block size: %d", blockSize)
+	}
+
 	cryptoContext := &CipherContext{
 		key:         key,
 		cipher:      cipher,
@@ -67,6 +76,22 @@ func (c *CipherContext) SetKey(key []byte) error {
 	return c.cipher.SetKey(key)
 }
 
+// SetAlgorithm задаёт идентификатор шифра, который пишется в заголовок файлов.
+func (c *CipherContext) SetAlgorithm(algorithm AlgorithmID) {
+	c.algorithm = algorithm
+}
+
+// SetCipherParams задаёт размер слова (в битах) и число раундов шифра, которые пишутся
+// в заголовок файлов вместе с длиной ключа.
+func (c *CipherContext) SetCipherParams(wordSize, rounds int) {
+	c.wordSize, c.rounds = wordSize, rounds
+}
+
+// SetKeyEpoch задаёт номер поколения ключа, который пишется в заголовок файлов.
+func (c *CipherContext) SetKeyEpoch(epoch uint32) {
+	c.keyEpoch = epoch
+}
+
 func (c *CipherContext) Encrypt(data []byte, chunkIndex, totalChunks int) ([]byte, error) {
 	if data == nil || len(data) == 0 {
 		return nil, fmt.Errorf("data cannot be empty")
@@ -76,30 +101,44 @@ func (c *CipherContext) Encrypt(data []byte, chunkIndex, totalChunks int) ([]byt
 		err error
 	)
 
-	if chunkIndex == totalChunks-1 {
+	if chunkIndex == totalChunks-1 && c.usesPadding() {
 		data, err = c.addPadding(data)
 		if err != nil {
 			return nil, fmt.Errorf("failed to add padding data: %w", err)
 		}
 	}
 
-	var encryptedData []byte
+	var (
+		encryptedData []byte
+		iv            []byte
+	)
+
+	cc := c
+	if c.usesIV() {
+		iv, err = c.newMessageIV()
+		if err != nil {
+			return nil, err
+		}
+		cc = c.withIV(iv)
+	}
 
 	switch c.mode {
 	case ECB:
-		encryptedData, err = c.EncryptECB(data)
+		encryptedData, err = cc.EncryptECB(data)
 	case CBC:
-		encryptedData, err = c.EncryptCBC(data)
+		encryptedData, err = cc.EncryptCBC(data)
 	case PCBC:
-		encryptedData, err = c.EncryptPCBC(data)
+		encryptedData, err = cc.EncryptPCBC(data)
 	case CFB:
-		encryptedData, err = c.EncryptCFB(data)
+		encryptedData, err = cc.EncryptCFB(data)
 	case OFB:
-		encryptedData, err = c.EncryptOFB(data)
+		encryptedData, err = cc.EncryptOFB(data)
 	case CTR:
-		encryptedData, err = c.EncryptCTR(data)
+		encryptedData, err = cc.EncryptCTR(data)
 	case RandomDelta:
-		encryptedData, err = c.EncryptRandomDelta(data)
+		encryptedData, err = cc.EncryptRandomDelta(data)
+	case GCM:
+		encryptedData, err = cc.EncryptGCM(data, nil)
 	default:
 		err = fmt.Errorf("unsupported cipher mode: %d", c.mode)
 	}
@@ -108,6 +147,9 @@ func (c *CipherContext) Encrypt(data []byte, chunkIndex, totalChunks int) ([]byt
 		return nil, fmt.Errorf("failed to encrypt data: %w", err)
 	}
 
+	if iv != nil {
+		return prependMessageIV(iv, encryptedData), nil
+	}
 	return encryptedData, nil
 }
 
@@ -121,21 +163,32 @@ func (c *CipherContext) Decrypt(data []byte, chunkIndex, totalChunks int) ([]byt
 		err           error
 	)
 
+	// Без заголовка с IV шифртекст считается старым и расшифровывается IV комнаты.
+	cc := c
+	if c.usesIV() {
+		if iv, body, ok := c.splitMessageIV(data); ok {
+			cc = c.withIV(iv)
+			data = body
+		}
+	}
+
 	switch c.mode {
 	case ECB:
-		decryptedData, err = c.DecryptECB(data)
+		decryptedData, err = cc.DecryptECB(data)
 	case CBC:
-		decryptedData, err = c.DecryptCBC(data)
+		decryptedData, err = cc.DecryptCBC(data)
 	case PCBC:
-		decryptedData, err = c.DecryptPCBC(data)
+		decryptedData, err = cc.DecryptPCBC(data)
 	case CFB:
-		decryptedData, err = c.DecryptCFB(data)
+		decryptedData, err = cc.DecryptCFB(data)
 	case OFB:
-		decryptedData, err = c.DecryptOFB(data)
+		decryptedData, err = cc.DecryptOFB(data)
 	case CTR:
-		decryptedData, err = c.DecryptCTR(data)
+		decryptedData, err = cc.DecryptCTR(data)
 	case RandomDelta:
-		decryptedData, err = c.DecryptRandomDelta(data)
+		decryptedData, err = cc.DecryptRandomDelta(data)
+	case GCM:
+		decryptedData, err = cc.DecryptGCM(data, nil)
 	default:
 		err = fmt.Errorf("unsupported cipher mode: %d", c.cipher)
 	}
@@ -144,7 +197,7 @@ func (c *CipherContext) Decrypt(data []byte, chunkIndex, totalChunks int) ([]byt
 		return nil, fmt.Errorf("failed to decrypt data: %w", err)
 	}
 
-	if chunkIndex == totalChunks-1 {
+	if chunkIndex == totalChunks-1 && c.usesPadding() {
 		decryptedData, err = c.removePadding(decryptedData)
 		if err != nil {
 			return nil, fmt.Errorf("failed to remove padding data: %w", err)
@@ -153,6 +206,11 @@ func (c *CipherContext) Decrypt(data []byte, chunkIndex, totalChunks int) ([]byt
 	return decryptedData, nil
 }
 
+// usesPadding сообщает, нужно ли дополнять последний блок: GCM работает как потоковый режим.
+func (c *CipherContext) usesPadding() bool {
+	return c.mode != GCM
+}
+
 func (c *CipherContext) EncryptAsync(data []byte, chunkIndex, totalChunks int) (<-chan []byte, <-chan error) {
 	resultChan := make(chan []byte, 1)
 	errorChan := make(chan error, 1)
@@ -2,6 +2,7 @@ package symmetric
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"os"
@@ -9,127 +10,143 @@ import (
 )
 
 func (c *CipherContext) EncryptFile(ctx context.Context, inputPath, outputPath string, progress func(done, total int)) error {
-	inputInfo, err := os.Stat(inputPath)
+	inputFile, fileSize, err := openInputFile(inputPath)
 	if err != nil {
-		return fmt.Errorf("failed to stat input file: %w", err)
+		return err
 	}
-	if inputInfo.IsDir() {
-		return fmt.Errorf("inputPath %s is a directory", inputPath)
+	defer inputFile.Close()
+
+	outputFile, err := createOutputFile(outputPath)
+	if err != nil {
+		return err
+	}
+	defer outputFile.Close()
+
+	if err = c.writeContainer(ctx, inputFile, fileSize, outputFile, false, progress); err != nil {
+		if ctx.Err() != nil {
+			_ = os.Remove(outputPath)
+		}
+		return err
 	}
 
-	fileSize := inputInfo.Size()
-	chunkSize := int64(c.blockSize * 1024)
-	totalChunks := int((fileSize + chunkSize - 1) / chunkSize)
+	return nil
+}
 
-	inputFile, err := os.Open(inputPath)
+func (c *CipherContext) DecryptFile(inputPath, outputPath string, progress func(done, total int)) error {
+	inputFile, fileSize, err := openInputFile(inputPath)
 	if err != nil {
-		return fmt.Errorf("cannot open input file: %w", err)
+		return err
 	}
 	defer inputFile.Close()
 
-	outputDir := filepath.Dir(outputPath)
-	if err := os.MkdirAll(outputDir, 0755); err != nil {
+	header, rawHeader, err := ReadContainerHeader(inputFile)
+	if errors.Is(err, ErrNotContainer) {
+		// Файл старого формата без заголовка — сплошной шифртекст потока
+		if _, err = inputFile.Seek(0, io.SeekStart); err != nil {
+			return fmt.Errorf("seek error: %w", err)
+		}
+
+		outputFile, err := createOutputFile(outputPath)
+		if err != nil {
+			return err
+		}
+		defer outputFile.Close()
+
+		return c.decryptStream(inputFile, fileSize, outputFile, progress)
+	}
+	if err != nil {
 		return err
 	}
 
-	outputFile, err := os.Create(outputPath)
+	if err = c.checkContainerHeader(header); err != nil {
+		return err
+	}
+
+	expectedSize := int64(len(rawHeader)) + c.containerBodySize(header)
+	if header.HasMAC {
+		expectedSize += TagSize
+	}
+	if fileSize != expectedSize {
+		return ErrTruncatedStream
+	}
+
+	outputFile, err := createOutputFile(outputPath)
 	if err != nil {
-		return fmt.Errorf("cannot open output file: %w", err)
+		return err
 	}
 	defer outputFile.Close()
 
+	return c.readContainerBody(inputFile, header, rawHeader, outputFile, progress)
+}
+
+func (c *CipherContext) decryptStream(r io.Reader, size int64, w io.Writer, progress func(done, total int)) error {
+	decryptReader, err := c.NewDecryptReader(r)
+	if err != nil {
+		return err
+	}
+
+	return copyChunks(context.Background(), w, decryptReader, size, int64(c.blockSize*1024), progress)
+}
+
+func copyChunks(ctx context.Context, w io.Writer, r io.Reader, size, chunkSize int64, progress func(done, total int)) error {
+	totalChunks := int((size + chunkSize - 1) / chunkSize)
+
 	buffer := make([]byte, chunkSize)
 	chunkIndex := 0
 
 	for {
 		select {
 		case <-ctx.Done():
-			_ = os.Remove(outputPath)
 			return ctx.Err()
 		default:
 		}
 
-		n, err := inputFile.Read(buffer)
-		if err != nil && err != io.EOF {
-			return fmt.Errorf("read error: %w", err)
-		}
-		if n == 0 {
-			break
-		}
+		n, err := io.ReadFull(r, buffer)
+		if n > 0 {
+			if _, werr := w.Write(buffer[:n]); werr != nil {
+				return fmt.Errorf("write error: %w", werr)
+			}
 
-		encrypted, err := c.Encrypt(buffer[:n], chunkIndex, totalChunks)
-		if err != nil {
-			return fmt.Errorf("encrypt chunk %d failed: %w", chunkIndex, err)
+			chunkIndex++
+			progress(min(chunkIndex, totalChunks), totalChunks)
 		}
 
-		if _, err := outputFile.Write(encrypted); err != nil {
-			return fmt.Errorf("write error: %w", err)
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("read error: %w", err)
 		}
-
-		chunkIndex++
-		progress(chunkIndex, totalChunks)
 	}
-
-	return nil
 }
 
-func (c *CipherContext) DecryptFile(inputPath, outputPath string, progress func(done, total int)) error {
+func openInputFile(inputPath string) (*os.File, int64, error) {
 	inputInfo, err := os.Stat(inputPath)
 	if err != nil {
-		return fmt.Errorf("failed to stat input file: %w", err)
+		return nil, 0, fmt.Errorf("failed to stat input file: %w", err)
 	}
 	if inputInfo.IsDir() {
-		return fmt.Errorf("inputPath %s is a directory", inputPath)
+		return nil, 0, fmt.Errorf("inputPath %s is a directory", inputPath)
 	}
 
-	// Вычисляем общее количество чанков
-	fileSize := inputInfo.Size()
-	chunkSize := int64(c.blockSize * 1024)
-	totalChunks := int((fileSize + chunkSize - 1) / chunkSize)
-
 	inputFile, err := os.Open(inputPath)
 	if err != nil {
-		return fmt.Errorf("cannot open input file: %w", err)
+		return nil, 0, fmt.Errorf("cannot open input file: %w", err)
 	}
-	defer inputFile.Close()
+	return inputFile, inputInfo.Size(), nil
+}
 
+func createOutputFile(outputPath string) (*os.File, error) {
 	outputDir := filepath.Dir(outputPath)
 	if err := os.MkdirAll(outputDir, 0755); err != nil {
-		return err
+		return nil, err
 	}
 
 	outputFile, err := os.Create(outputPath)
 	if err != nil {
-		return fmt.Errorf("cannot open output file: %w", err)
+		return nil, fmt.Errorf("cannot open output file: %w", err)
 	}
-	defer outputFile.Close()
-
-	buffer := make([]byte, chunkSize)
-	chunkIndex := 0
-
-	for {
-		n, err := inputFile.Read(buffer)
-		if err != nil && err != io.EOF {
-			return fmt.Errorf("read error: %w", err)
-		}
-		if n == 0 {
-			break
-		}
-
-		decrypted, err := c.Decrypt(buffer[:n], chunkIndex, totalChunks)
-		if err != nil {
-			return fmt.Errorf("decrypt chunk %d failed: %w", chunkIndex, err)
-		}
-
-		if _, err = outputFile.Write(decrypted); err != nil {
-			return fmt.Errorf("write error: %w", err)
-		}
-
-		chunkIndex++
-		progress(chunkIndex, totalChunks)
-	}
-
-	return nil
+	return outputFile, nil
 }
 
 func (c *CipherContext) EncryptFileAsync(ctx context.Context, inputPath, outputPath string, progress func(done, total int)) (<-chan struct{}, <-chan error) {
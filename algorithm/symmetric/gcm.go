@@ -0,0 +1,253 @@
+package symmetric
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+const (
+	gcmBlockSize = 16
+	GCMNonceSize = 12
+	GCMTagSize   = 16
+
+	// gcmMaxPlaintext — предел NIST SP 800-38D для одного nonce (2^39-256 бит):
+	// дальше 32-битный счётчик блоков пошёл бы по второму кругу.
+	gcmMaxPlaintext = 1<<36 - 32
+)
+
+var ErrGCMTooLong = errors.New("GCM plaintext exceeds 2^39-256 bits")
+
+// EncryptGCM шифрует data в режиме Galois/Counter со случайным nonce.
+// Результат имеет вид nonce || шифртекст || тег, additionalData только аутентифицируется.
+func (c *CipherContext) EncryptGCM(data, additionalData []byte) ([]byte, error) {
+	nonce, err := c.newGCMNonce()
+	if err != nil {
+		return nil, err
+	}
+
+	sealed, err := c.SealGCM(nonce, data, additionalData)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(nonce, sealed...), nil
+}
+
+func (c *CipherContext) DecryptGCM(data, additionalData []byte) ([]byte, error) {
+	if len(data) < GCMNonceSize+GCMTagSize {
+		return nil, ErrAuthenticationFailed
+	}
+	return c.OpenGCM(data[:GCMNonceSize], data[GCMNonceSize:], additionalData)
+}
+
+// SealGCM возвращает шифртекст с дописанным тегом для заданного nonce.
+// Один и тот же nonce нельзя использовать повторно с тем же ключом.
+func (c *CipherContext) SealGCM(nonce, plaintext, additionalData []byte) ([]byte, error) {
+	if err := checkGCMLength(int64(len(plaintext))); err != nil {
+		return nil, err
+	}
+
+	h, j0, err := c.gcmInit(nonce)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]byte, len(plaintext)+GCMTagSize)
+	if _, err = c.gctr(gcmInc32(j0), plaintext, out[:len(plaintext)]); err != nil {
+		return nil, err
+	}
+
+	tag, err := c.gcmTag(h, j0, additionalData, out[:len(plaintext)])
+	if err != nil {
+		return nil, err
+	}
+	copy(out[len(plaintext):], tag)
+
+	return out, nil
+}
+
+func (c *CipherContext) OpenGCM(nonce, ciphertext, additionalData []byte) ([]byte, error) {
+	if len(ciphertext) < GCMTagSize {
+		return nil, ErrAuthenticationFailed
+	}
+	if err := checkGCMLength(int64(len(ciphertext) - GCMTagSize)); err != nil {
+		return nil, err
+	}
+
+	h, j0, err := c.gcmInit(nonce)
+	if err != nil {
+		return nil, err
+	}
+
+	encrypted, tag := ciphertext[:len(ciphertext)-GCMTagSize], ciphertext[len(ciphertext)-GCMTagSize:]
+
+	expected, err := c.gcmTag(h, j0, additionalData, encrypted)
+	if err != nil {
+		return nil, err
+	}
+	if subtle.ConstantTimeCompare(expected, tag) != 1 {
+		return nil, ErrAuthenticationFailed
+	}
+
+	out := make([]byte, len(encrypted))
+	if _, err = c.gctr(gcmInc32(j0), encrypted, out); err != nil {
+		return nil, err
+	}
+
+	return out, nil
+}
+
+func checkGCMLength(size int64) error {
+	if size > gcmMaxPlaintext {
+		return ErrGCMTooLong
+	}
+	return nil
+}
+
+func (c *CipherContext) newGCMNonce() ([]byte, error) {
+	nonce := make([]byte, GCMNonceSize)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	return nonce, nil
+}
+
+// gcmInit вычисляет ключ хеширования H = E(0^128) и начальный счётчик J0 = nonce || 0^31 || 1.
+func (c *CipherContext) gcmInit(nonce []byte) ([]byte, []byte, error) {
+	if c.blockSize != gcmBlockSize {
+		return nil, nil, fmt.Errorf("GCM requires a %d-byte block cipher, got %d", gcmBlockSize, c.blockSize)
+	}
+	if len(nonce) != GCMNonceSize {
+		return nil, nil, fmt.Errorf("GCM nonce must be %d bytes", GCMNonceSize)
+	}
+
+	h := make([]byte, gcmBlockSize)
+	if err := c.cipher.EncryptBlock(h, h); err != nil {
+		return nil, nil, fmt.Errorf("cannot compute GHASH key: %w", err)
+	}
+
+	j0 := make([]byte, gcmBlockSize)
+	copy(j0, nonce)
+	j0[gcmBlockSize-1] = 1
+
+	return h, j0, nil
+}
+
+func (c *CipherContext) gcmTag(h, j0, additionalData, ciphertext []byte) ([]byte, error) {
+	g := newGHASH(h)
+	g.update(additionalData)
+	g.update(ciphertext)
+
+	return c.gcmFinish(g, j0, len(additionalData), len(ciphertext))
+}
+
+func (c *CipherContext) gcmFinish(g *ghashState, j0 []byte, additionalLen, ciphertextLen int) ([]byte, error) {
+	tag := make([]byte, gcmBlockSize)
+	if err := c.cipher.EncryptBlock(tag, j0); err != nil {
+		return nil, fmt.Errorf("cannot encrypt initial counter: %w", err)
+	}
+
+	xorInto(tag, tag, g.sum(additionalLen, ciphertextLen))
+	return tag, nil
+}
+
+// gctr шифрует in счётчиком counter и возвращает значение счётчика для следующего блока.
+// Сам counter не изменяется.
+func (c *CipherContext) gctr(counter, in, out []byte) ([]byte, error) {
+	counter = append([]byte(nil), counter...)
+	keystream := make([]byte, gcmBlockSize)
+
+	for pos := 0; pos < len(in); pos += gcmBlockSize {
+		if err := c.cipher.EncryptBlock(keystream, counter); err != nil {
+			return nil, fmt.Errorf("encryption failed at block %d, %w", pos/gcmBlockSize, err)
+		}
+
+		end := min(pos+gcmBlockSize, len(in))
+		xorInto(out[pos:end], in[pos:end], keystream)
+
+		gcmIncrement(counter)
+	}
+	return counter, nil
+}
+
+// ghashState накапливает GHASH_H(A || 0^v || C || 0^u || [len(A)]64 || [len(C)]64) из NIST SP 800-38D.
+// update дополняет неполный блок нулями, поэтому A и C должны подаваться целыми блоками,
+// кроме последнего фрагмента каждой из частей.
+type ghashState struct {
+	hHi, hLo uint64
+	yHi, yLo uint64
+}
+
+func newGHASH(h []byte) *ghashState {
+	return &ghashState{
+		hHi: binary.BigEndian.Uint64(h[:8]),
+		hLo: binary.BigEndian.Uint64(h[8:]),
+	}
+}
+
+func (g *ghashState) update(data []byte) {
+	for pos := 0; pos < len(data); pos += gcmBlockSize {
+		var block [gcmBlockSize]byte
+		copy(block[:], data[pos:min(pos+gcmBlockSize, len(data))])
+
+		g.yHi ^= binary.BigEndian.Uint64(block[:8])
+		g.yLo ^= binary.BigEndian.Uint64(block[8:])
+		g.yHi, g.yLo = gfMul(g.yHi, g.yLo, g.hHi, g.hLo)
+	}
+}
+
+func (g *ghashState) sum(additionalLen, ciphertextLen int) []byte {
+	var lengths [gcmBlockSize]byte
+	binary.BigEndian.PutUint64(lengths[:8], uint64(additionalLen)*8)
+	binary.BigEndian.PutUint64(lengths[8:], uint64(ciphertextLen)*8)
+	g.update(lengths[:])
+
+	out := make([]byte, gcmBlockSize)
+	binary.BigEndian.PutUint64(out[:8], g.yHi)
+	binary.BigEndian.PutUint64(out[8:], g.yLo)
+	return out
+}
+
+// gfMul умножает X на Y в GF(2^128) с многочленом x^128 + x^7 + x^2 + x + 1.
+func gfMul(xHi, xLo, yHi, yLo uint64) (uint64, uint64) {
+	var zHi, zLo uint64
+	vHi, vLo := yHi, yLo
+
+	for i := 0; i < 128; i++ {
+		var bit uint64
+		if i < 64 {
+			bit = (xHi >> (63 - i)) & 1
+		} else {
+			bit = (xLo >> (127 - i)) & 1
+		}
+		if bit == 1 {
+			zHi ^= vHi
+			zLo ^= vLo
+		}
+
+		lsb := vLo & 1
+		vLo = vLo>>1 | vHi<<63
+		vHi >>= 1
+		if lsb == 1 {
+			vHi ^= 0xe100000000000000
+		}
+	}
+
+	return zHi, zLo
+}
+
+func gcmInc32(counter []byte) []byte {
+	next := make([]byte, len(counter))
+	copy(next, counter)
+	gcmIncrement(next)
+	return next
+}
+
+// gcmIncrement увеличивает младшие 32 бита счётчика на месте.
+func gcmIncrement(counter []byte) {
+	n := binary.BigEndian.Uint32(counter[len(counter)-4:])
+	binary.BigEndian.PutUint32(counter[len(counter)-4:], n+1)
+}
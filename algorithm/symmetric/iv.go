@@ -0,0 +1,59 @@
+package symmetric
+
+import (
+	"crypto/rand"
+	"fmt"
+)
+
+// Каждый вызов Encrypt в режимах с IV берёт новый случайный IV и кладёт его перед шифртекстом:
+//
+//	[1 байт версии = messageIVVersion][IV, blockSize байт][шифртекст]
+//
+// Старые шифртексты без заголовка имеют длину, кратную blockSize, поэтому их
+// можно отличить и расшифровать IV комнаты.
+const (
+	messageIVVersion    = 0x01
+	messageIVHeaderSize = 1
+)
+
+func (c *CipherContext) usesIV() bool {
+	switch c.mode {
+	case CBC, PCBC, CFB, OFB, CTR, RandomDelta:
+		return true
+	default:
+		return false
+	}
+}
+
+func (c *CipherContext) newMessageIV() ([]byte, error) {
+	iv := make([]byte, c.blockSize)
+	if _, err := rand.Read(iv); err != nil {
+		return nil, fmt.Errorf("could not generate IV: %w", err)
+	}
+	return iv, nil
+}
+
+// withIV возвращает копию контекста, которая шифрует с заданным IV.
+func (c *CipherContext) withIV(iv []byte) *CipherContext {
+	cc := *c
+	cc.iv = iv
+	return &cc
+}
+
+func prependMessageIV(iv, data []byte) []byte {
+	out := make([]byte, 0, messageIVHeaderSize+len(iv)+len(data))
+	out = append(out, messageIVVersion)
+	out = append(out, iv...)
+	return append(out, data...)
+}
+
+func (c *CipherContext) splitMessageIV(data []byte) ([]byte, []byte, bool) {
+	headerSize := messageIVHeaderSize + c.blockSize
+	if len(data) < headerSize || data[0] != messageIVVersion {
+		return nil, nil, false
+	}
+	if (len(data)-headerSize)%c.blockSize != 0 {
+		return nil, nil, false
+	}
+	return data[messageIVHeaderSize:headerSize], data[headerSize:], true
+}
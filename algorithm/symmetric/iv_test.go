@@ -0,0 +1,88 @@
+package symmetric_test
+
+import (
+	"CryptoMessenger/algorithm/symmetric"
+	"bytes"
+	"testing"
+)
+
+var ivModes = []string{"CBC", "PCBC", "CFB", "OFB", "CTR", "RandomDelta"}
+
+// Каждый вызов Encrypt берёт новый IV, поэтому одинаковые сообщения не совпадают.
+func TestEncryptUsesFreshIV(t *testing.T) {
+	for _, modeName := range ivModes {
+		t.Run(modeName, func(t *testing.T) {
+			cipherContext := newBenchContext(t, "RC6", modeName)
+			plaintext := []byte("the same message twice")
+
+			first, err := cipherContext.Encrypt(plaintext, 0, 1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			second, err := cipherContext.Encrypt(plaintext, 0, 1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if bytes.Equal(first, second) {
+				t.Fatal("equal plaintexts gave equal ciphertexts")
+			}
+			if first[0] != 0x01 || len(first) != 1+16+32 {
+				t.Fatalf("unexpected message header: % x, %d bytes", first[:1], len(first))
+			}
+
+			for _, ciphertext := range [][]byte{first, second} {
+				decrypted, err := cipherContext.Decrypt(ciphertext, 0, 1)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !bytes.Equal(decrypted, plaintext) {
+					t.Fatalf("decrypt = %q", decrypted)
+				}
+			}
+		})
+	}
+}
+
+// Шифртексты получены прежней версией Encrypt, без IV в сообщении (RC6, PKCS7,
+// IV комнаты), и по-прежнему расшифровываются IV комнаты.
+func TestDecryptLegacyCiphertextWithRoomIV(t *testing.T) {
+	tests := []struct {
+		mode       string
+		ciphertext string
+	}{
+		{"CBC", "51e2fea3268b0311968b97b7dadb88b0fc95e50e6e36701b1185200268ec8c65"},
+		{"PCBC", "51e2fea3268b0311968b97b7dadb88b0e0054eceb702ec74eb00235fe72e801f"},
+		{"CFB", "4f20d6a336bcfc2cda42668f303c97df3018b1945b408116a25a83b23754c42a"},
+		{"OFB", "4f20d6a336bcfc2cda42668f303c97df536a00fdb4fc8630fde5d4249ebda323"},
+		{"CTR", "4f20d6a336bcfc2cda42668f303c97df9c71db669690744edb7ac2603b4cdb30"},
+		{"RandomDelta", "4f20d6a336bcfc2cda42668f303c97df082812d411d1a75dd99191783866a430"},
+	}
+
+	key := unhex(t, "000102030405060708090a0b0c0d0e0f")
+	roomIV := unhex(t, "f0e0d0c0b0a090807060504030201000")
+	delta := unhex(t, "0123456789abcdef0123456789abcdef")
+	cipherInfo, _ := symmetric.LookupCipher("RC6")
+
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			mode, _ := symmetric.LookupMode(tt.mode)
+			scheme, blockSize, err := cipherInfo.Create(key, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			cipherContext, err := symmetric.NewCipherContext(key, scheme, mode.Mode, symmetric.PKCS7, roomIV, blockSize,
+				"randomDelta", delta)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			decrypted, err := cipherContext.Decrypt(unhex(t, tt.ciphertext), 0, 1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(decrypted) != "sent before per-message IVs" {
+				t.Fatalf("decrypt = %q", decrypted)
+			}
+		})
+	}
+}
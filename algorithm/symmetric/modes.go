@@ -3,40 +3,27 @@ package symmetric
 import (
 	"errors"
 	"fmt"
-	"sync"
 )
 
 func (c *CipherContext) EncryptECB(data []byte) ([]byte, error) {
-	if len(data)&(c.blockSize-1) != 0 {
+	if len(data)%c.blockSize != 0 {
 		return nil, errors.New("block size must be a multiple of the block size")
 	}
 
 	numberOfBlocks := len(data) / c.blockSize
 	encrypted := make([]byte, len(data))
 
-	wg := &sync.WaitGroup{}
-	errChan := make(chan error, numberOfBlocks)
-
-	for i := 0; i < numberOfBlocks; i++ {
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
+	err := c.forEachSegment(numberOfBlocks, func(first, last int) error {
+		for i := first; i < last; i++ {
 			pos := i * c.blockSize
-			block := data[pos : pos+c.blockSize]
 
-			encryptedBlock, err := c.cipher.Encrypt(block)
-			if err != nil {
-				errChan <- fmt.Errorf("encryption failed at block %d: %w", i, err)
+			if err := c.cipher.EncryptBlock(encrypted[pos:pos+c.blockSize], data[pos:pos+c.blockSize]); err != nil {
+				return fmt.Errorf("encryption failed at block %d: %w", i, err)
 			}
-
-			copy(encrypted[pos:], encryptedBlock)
-		}()
-	}
-
-	wg.Wait()
-	close(errChan)
-
-	if err, ok := <-errChan; ok {
+		}
+		return nil
+	})
+	if err != nil {
 		return nil, err
 	}
 
@@ -44,36 +31,24 @@ func (c *CipherContext) EncryptECB(data []byte) ([]byte, error) {
 }
 
 func (c *CipherContext) DecryptECB(data []byte) ([]byte, error) {
-	if len(data)&(c.blockSize-1) != 0 {
+	if len(data)%c.blockSize != 0 {
 		return nil, errors.New("block size must be a multiple of the block size")
 	}
 
 	numberOfBlocks := len(data) / c.blockSize
 	decrypted := make([]byte, len(data))
 
-	wg := &sync.WaitGroup{}
-	errChan := make(chan error, numberOfBlocks)
-
-	for i := 0; i < numberOfBlocks; i++ {
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
+	err := c.forEachSegment(numberOfBlocks, func(first, last int) error {
+		for i := first; i < last; i++ {
 			pos := i * c.blockSize
-			block := data[pos : pos+c.blockSize]
 
-			decryptedBlock, err := c.cipher.Decrypt(block)
-			if err != nil {
-				errChan <- fmt.Errorf("decryption failed at block %d: %w", i, err)
+			if err := c.cipher.DecryptBlock(decrypted[pos:pos+c.blockSize], data[pos:pos+c.blockSize]); err != nil {
+				return fmt.Errorf("decryption failed at block %d: %w", i, err)
 			}
-
-			copy(decrypted[pos:], decryptedBlock)
-		}()
-	}
-
-	wg.Wait()
-	close(errChan)
-
-	if err, ok := <-errChan; ok {
+		}
+		return nil
+	})
+	if err != nil {
 		return nil, err
 	}
 
@@ -81,7 +56,7 @@ func (c *CipherContext) DecryptECB(data []byte) ([]byte, error) {
 }
 
 func (c *CipherContext) EncryptCBC(data []byte) ([]byte, error) {
-	if len(data)&(c.blockSize-1) != 0 {
+	if len(data)%c.blockSize != 0 {
 		return nil, errors.New("block size must be a multiple of the block size")
 	}
 	if len(c.iv) != c.blockSize {
@@ -89,28 +64,25 @@ func (c *CipherContext) EncryptCBC(data []byte) ([]byte, error) {
 	}
 
 	encrypted := make([]byte, len(data))
-	previousBlock := make([]byte, c.blockSize)
 	numberOfBlocks := len(data) / c.blockSize
-	copy(previousBlock, c.iv)
+	previousBlock := c.iv
 
 	for i := 0; i < numberOfBlocks; i++ {
 		pos := i * c.blockSize
-		currBlock := data[pos : pos+c.blockSize]
+		block := encrypted[pos : pos+c.blockSize]
 
-		xoredBlock := xorBlocks(currBlock, previousBlock)
-		encryptedBlock, err := c.cipher.Encrypt(xoredBlock)
-		if err != nil {
+		xorInto(block, data[pos:pos+c.blockSize], previousBlock)
+		if err := c.cipher.EncryptBlock(block, block); err != nil {
 			return nil, fmt.Errorf("encryption failed at block %d, %w", i, err)
 		}
 
-		copy(encrypted[pos:], encryptedBlock)
-		copy(previousBlock, encryptedBlock)
+		previousBlock = block
 	}
 	return encrypted, nil
 }
 
 func (c *CipherContext) DecryptCBC(data []byte) ([]byte, error) {
-	if len(data)&(c.blockSize-1) != 0 {
+	if len(data)%c.blockSize != 0 {
 		return nil, errors.New("block size must be a multiple of the block size")
 	}
 	if len(c.iv) != c.blockSize {
@@ -118,29 +90,35 @@ func (c *CipherContext) DecryptCBC(data []byte) ([]byte, error) {
 	}
 
 	decrypted := make([]byte, len(data))
-	previousBlock := make([]byte, c.blockSize)
 	numberOfBlocks := len(data) / c.blockSize
-	copy(previousBlock, c.iv)
 
-	for i := 0; i < numberOfBlocks; i++ {
-		pos := i * c.blockSize
-		currBlock := data[pos : pos+c.blockSize]
+	// P_i = D(C_i) ^ C_{i-1}: все блоки шифртекста известны, поэтому отрезки независимы
+	err := c.forEachSegment(numberOfBlocks, func(first, last int) error {
+		for i := first; i < last; i++ {
+			pos := i * c.blockSize
+			block := decrypted[pos : pos+c.blockSize]
 
-		decryptedBlock, err := c.cipher.Decrypt(currBlock)
-		if err != nil {
-			return nil, fmt.Errorf("decryption failed at block %d, %w", i, err)
-		}
+			if err := c.cipher.DecryptBlock(block, data[pos:pos+c.blockSize]); err != nil {
+				return fmt.Errorf("decryption failed at block %d, %w", i, err)
+			}
 
-		xoredBlock := xorBlocks(decryptedBlock, previousBlock)
-		copy(decrypted[pos:], xoredBlock)
-		copy(previousBlock, currBlock)
+			previousBlock := c.iv
+			if i > 0 {
+				previousBlock = data[pos-c.blockSize : pos]
+			}
+			xorInto(block, block, previousBlock)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
 
 	return decrypted, nil
 }
 
 func (c *CipherContext) EncryptPCBC(data []byte) ([]byte, error) {
-	if len(data)&(c.blockSize-1) != 0 {
+	if len(data)%c.blockSize != 0 {
 		return nil, errors.New("block size must be a multiple of the block size")
 	}
 	if len(c.iv) != c.blockSize {
@@ -150,32 +128,29 @@ func (c *CipherContext) EncryptPCBC(data []byte) ([]byte, error) {
 	encrypted := make([]byte, len(data))
 	numberOfBlocks := len(data) / c.blockSize
 	previousPlaintext := make([]byte, c.blockSize)
-	previousCiphertext := make([]byte, c.blockSize)
-	copy(previousCiphertext, c.iv)
+	previousCiphertext := c.iv
 
 	for i := 0; i < numberOfBlocks; i++ {
 		pos := i * c.blockSize
 		plaintextBlock := data[pos : pos+c.blockSize]
-		inputBlock := make([]byte, c.blockSize)
+		block := encrypted[pos : pos+c.blockSize]
 		for j := 0; j < c.blockSize; j++ {
-			inputBlock[j] = plaintextBlock[j] ^ previousPlaintext[j] ^ previousCiphertext[j]
+			block[j] = plaintextBlock[j] ^ previousPlaintext[j] ^ previousCiphertext[j]
 		}
 
-		encryptedBlock, err := c.cipher.Encrypt(inputBlock)
-		if err != nil {
+		if err := c.cipher.EncryptBlock(block, block); err != nil {
 			return nil, fmt.Errorf("encryption failed at block %d, %w", i, err)
 		}
 
-		copy(encrypted[pos:], encryptedBlock)
-		copy(previousPlaintext, plaintextBlock)
-		copy(previousCiphertext, encryptedBlock)
+		previousPlaintext = plaintextBlock
+		previousCiphertext = block
 	}
 
 	return encrypted, nil
 }
 
 func (c *CipherContext) DecryptPCBC(data []byte) ([]byte, error) {
-	if len(data)&(c.blockSize-1) != 0 {
+	if len(data)%c.blockSize != 0 {
 		return nil, errors.New("block size must be a multiple of the block size")
 	}
 	if len(c.iv) != c.blockSize {
@@ -185,31 +160,30 @@ func (c *CipherContext) DecryptPCBC(data []byte) ([]byte, error) {
 	decrypted := make([]byte, len(data))
 	numberOfBlocks := len(data) / c.blockSize
 	previousPlaintext := make([]byte, c.blockSize)
-	previousCiphertext := make([]byte, c.blockSize)
-	copy(previousCiphertext, c.iv)
+	previousCiphertext := c.iv
 
 	for i := 0; i < numberOfBlocks; i++ {
 		pos := i * c.blockSize
 		currBlock := data[pos : pos+c.blockSize]
+		block := decrypted[pos : pos+c.blockSize]
 
-		decryptedBlock, err := c.cipher.Decrypt(currBlock)
-		if err != nil {
+		if err := c.cipher.DecryptBlock(block, currBlock); err != nil {
 			return nil, fmt.Errorf("decryption failed at block %d, %w", i, err)
 		}
 
 		for j := 0; j < c.blockSize; j++ {
-			decrypted[pos+j] = decryptedBlock[j] ^ previousPlaintext[j] ^ previousCiphertext[j]
+			block[j] ^= previousPlaintext[j] ^ previousCiphertext[j]
 		}
 
-		copy(previousPlaintext, decrypted[pos:pos+c.blockSize])
-		copy(previousCiphertext, currBlock)
+		previousPlaintext = block
+		previousCiphertext = currBlock
 	}
 
 	return decrypted, nil
 }
 
 func (c *CipherContext) EncryptCFB(data []byte) ([]byte, error) {
-	if len(data)&(c.blockSize-1) != 0 {
+	if len(data)%c.blockSize != 0 {
 		return nil, errors.New("block size must be a multiple of the block size")
 	}
 	if len(c.iv) != c.blockSize {
@@ -217,27 +191,19 @@ func (c *CipherContext) EncryptCFB(data []byte) ([]byte, error) {
 	}
 
 	encrypted := make([]byte, len(data))
-	previousBlock := make([]byte, c.blockSize)
+	keystream := make([]byte, c.blockSize)
 	numberOfBlocks := len(data) / c.blockSize
-	copy(previousBlock, c.iv)
+	previousBlock := c.iv
 
 	for i := 0; i < numberOfBlocks; i++ {
 		pos := i * c.blockSize
-		encryptedIV, err := c.cipher.Encrypt(previousBlock)
-		if err != nil {
+		if err := c.cipher.EncryptBlock(keystream, previousBlock); err != nil {
 			return nil, fmt.Errorf("cannot encrypt IV at block %d, %w", i, err)
 		}
 
-		end := pos + c.blockSize
-		if end > len(data) {
-			end = len(data)
-		}
-
-		currBlock := data[pos:end]
-		xoredBlock := xorBlocks(currBlock, encryptedIV)
-
-		copy(encrypted[pos:], xoredBlock)
-		copy(previousBlock, xoredBlock)
+		block := encrypted[pos : pos+c.blockSize]
+		xorInto(block, data[pos:pos+c.blockSize], keystream)
+		previousBlock = block
 	}
 
 	return encrypted, nil
@@ -249,28 +215,29 @@ func (c *CipherContext) DecryptCFB(data []byte) ([]byte, error) {
 	}
 
 	decrypted := make([]byte, len(data))
-	previousBlock := make([]byte, c.blockSize)
 	numberOfBlocks := len(data) / c.blockSize
-	copy(previousBlock, c.iv)
-
-	for i := 0; i < numberOfBlocks; i++ {
-		pos := i * c.blockSize
 
-		outputBlock, err := c.cipher.Encrypt(previousBlock)
-		if err != nil {
-			return nil, fmt.Errorf("decryption failed at block %d, %w", i, err)
-		}
+	// P_i = C_i ^ E(C_{i-1}), как и в CBC, блоки расшифровываются независимо
+	err := c.forEachSegment(numberOfBlocks, func(first, last int) error {
+		keystream := make([]byte, c.blockSize)
+		for i := first; i < last; i++ {
+			pos := i * c.blockSize
 
-		end := pos + c.blockSize
-		if end > len(data) {
-			end = len(data)
-		}
+			previousBlock := c.iv
+			if i > 0 {
+				previousBlock = data[pos-c.blockSize : pos]
+			}
 
-		currBlock := data[pos:end]
-		xoredBlock := xorBlocks(currBlock, outputBlock)
+			if err := c.cipher.EncryptBlock(keystream, previousBlock); err != nil {
+				return fmt.Errorf("decryption failed at block %d, %w", i, err)
+			}
 
-		copy(decrypted[pos:], xoredBlock)
-		copy(previousBlock, currBlock)
+			xorInto(decrypted[pos:pos+c.blockSize], data[pos:pos+c.blockSize], keystream)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
 
 	return decrypted, nil
@@ -282,28 +249,18 @@ func (c *CipherContext) EncryptOFB(data []byte) ([]byte, error) {
 	}
 
 	encrypted := make([]byte, len(data))
-	previousBlock := make([]byte, c.blockSize)
+	keystream := make([]byte, c.blockSize)
 	numberOfBlocks := len(data) / c.blockSize
-	copy(previousBlock, c.iv)
+	copy(keystream, c.iv)
 
 	for i := 0; i < numberOfBlocks; i++ {
 		pos := i * c.blockSize
 
-		encryptedBlock, err := c.cipher.Encrypt(previousBlock)
-		if err != nil {
+		if err := c.cipher.EncryptBlock(keystream, keystream); err != nil {
 			return nil, fmt.Errorf("encryption failed at block %d, %w", i, err)
 		}
 
-		end := pos + c.blockSize
-		if end > len(data) {
-			end = len(data)
-		}
-
-		currBlock := data[pos:end]
-		xoredBlock := xorBlocks(currBlock, encryptedBlock)
-
-		copy(encrypted[pos:], xoredBlock)
-		copy(previousBlock, encryptedBlock)
+		xorInto(encrypted[pos:pos+c.blockSize], data[pos:pos+c.blockSize], keystream)
 	}
 
 	return encrypted, nil
@@ -319,49 +276,28 @@ func (c *CipherContext) EncryptCTR(data []byte) ([]byte, error) {
 	}
 
 	encrypted := make([]byte, len(data))
-	numberOfBlocks := len(data) / c.blockSize
+	numberOfBlocks := (len(data) + c.blockSize - 1) / c.blockSize
 
-	wg := &sync.WaitGroup{}
-	errCh := make(chan error, numberOfBlocks)
-
-	counter := make([]byte, c.blockSize)
-
-	copy(counter, c.iv)
-
-	for i := 0; i < numberOfBlocks; i++ {
-		wg.Add(1)
-
-		go func() {
-			defer wg.Done()
+	err := c.forEachSegment(numberOfBlocks, func(first, last int) error {
+		counter := make([]byte, c.blockSize)
+		keystream := make([]byte, c.blockSize)
+		copy(counter, c.iv)
+		incrementCounterBy(counter, first)
 
+		for i := first; i < last; i++ {
 			pos := i * c.blockSize
+			end := min(pos+c.blockSize, len(data))
 
-			localCounter := make([]byte, c.blockSize)
-			copy(localCounter, c.iv)
-			incrementCounterBy(localCounter, i)
-
-			encryptedCounter, err := c.cipher.Encrypt(localCounter)
-			if err != nil {
-				errCh <- fmt.Errorf("encryption failed at block %d, %w", i, err)
-				return
-			}
-
-			end := pos + c.blockSize
-			if end > len(data) {
-				end = len(data)
+			if err := c.cipher.EncryptBlock(keystream, counter); err != nil {
+				return fmt.Errorf("encryption failed at block %d, %w", i, err)
 			}
-			currBlock := data[pos:end]
 
-			xoredBlock := xorBlocks(currBlock, encryptedCounter)
-			copy(encrypted[pos:], xoredBlock)
-
-		}()
-	}
-
-	wg.Wait()
-	close(errCh)
-
-	if err, ok := <-errCh; ok {
+			xorInto(encrypted[pos:end], data[pos:end], keystream)
+			incrementCounterBy(counter, 1)
+		}
+		return nil
+	})
+	if err != nil {
 		return nil, err
 	}
 
@@ -389,40 +325,24 @@ func (c *CipherContext) EncryptRandomDelta(data []byte) ([]byte, error) {
 	numBlocks := (len(data) + blockSize - 1) / blockSize
 	encrypted := make([]byte, len(data))
 
-	wg := sync.WaitGroup{}
-	errCh := make(chan error, numBlocks)
-
-	for i := 0; i < numBlocks; i++ {
-		wg.Add(1)
-
-		go func() {
-			defer wg.Done()
+	err := c.forEachSegment(numBlocks, func(first, last int) error {
+		counter := computeCounter(c.iv, randomDelta, first)
+		keystream := make([]byte, blockSize)
 
+		for i := first; i < last; i++ {
 			pos := i * blockSize
-			end := pos + blockSize
-			if end > len(data) {
-				end = len(data)
-			}
-			block := data[pos:end]
-
-			counterI := computeCounter(c.iv, randomDelta, i)
+			end := min(pos+blockSize, len(data))
 
-			encryptedCounter, err := c.cipher.Encrypt(counterI)
-			if err != nil {
-				errCh <- fmt.Errorf("encryption failed at block %d: %w", i, err)
-				return
+			if err := c.cipher.EncryptBlock(keystream, counter); err != nil {
+				return fmt.Errorf("encryption failed at block %d: %w", i, err)
 			}
 
-			for j := 0; j < end-pos; j++ {
-				encrypted[pos+j] = block[j] ^ encryptedCounter[j]
-			}
-		}()
-	}
-
-	wg.Wait()
-	close(errCh)
-
-	if err, ok := <-errCh; ok {
+			xorInto(encrypted[pos:end], data[pos:end], keystream)
+			addInPlace(counter, randomDelta)
+		}
+		return nil
+	})
+	if err != nil {
 		return nil, err
 	}
 
@@ -433,14 +353,6 @@ func (c *CipherContext) DecryptRandomDelta(data []byte) ([]byte, error) {
 	return c.EncryptRandomDelta(data)
 }
 
-func xorBlocks(a, b []byte) []byte {
-	res := make([]byte, len(a))
-	for i := 0; i < len(a); i++ {
-		res[i] = a[i] ^ b[i]
-	}
-	return res
-}
-
 func computeCounter(iv, delta []byte, multiplier int) []byte {
 	result := make([]byte, len(iv))
 	carry := 0
@@ -11,11 +11,13 @@ func (c *CipherContext) addPadding(data []byte) ([]byte, error) {
 		return nil, errors.New("data cannot be empty")
 	}
 
-	paddingSize := c.blockSize - (len(data) & (int(c.blockSize) - 1))
-	if paddingSize == 0 {
-		paddingSize = c.blockSize
-	}
+	// Всегда от 1 до blockSize байт, чтобы набивку можно было снять однозначно
+	paddingSize := c.blockSize - len(data)%c.blockSize
+
+	return c.applyPadding(data, paddingSize)
+}
 
+func (c *CipherContext) applyPadding(data []byte, paddingSize int) ([]byte, error) {
 	switch c.padding {
 	case Zeros:
 		return ZerosPadding(data, paddingSize), nil
@@ -34,6 +36,11 @@ func (c *CipherContext) removePadding(data []byte) ([]byte, error) {
 	if data == nil || len(data) == 0 {
 		return nil, errors.New("data cannot be empty")
 	}
+	if c.padding != Zeros {
+		if paddingSize := int(data[len(data)-1]); paddingSize == 0 || paddingSize > c.blockSize {
+			return nil, errors.New("invalid padding size")
+		}
+	}
 	switch c.padding {
 	case Zeros:
 		return removeZerosPadding(data), nil
@@ -0,0 +1,76 @@
+package symmetric
+
+import (
+	"runtime"
+	"sync"
+)
+
+// minBlocksPerSegment — меньше этого числа блоков на горутину параллелить невыгодно.
+const minBlocksPerSegment = 256
+
+// SetWorkers задаёт число горутин для параллельных режимов (ECB, CTR, RandomDelta,
+// расшифрование CBC и CFB). Значение <= 0 означает GOMAXPROCS.
+func (c *CipherContext) SetWorkers(workers int) {
+	c.workers = workers
+}
+
+func (c *CipherContext) workerCount() int {
+	if c.workers > 0 {
+		return c.workers
+	}
+	return runtime.GOMAXPROCS(0)
+}
+
+// forEachSegment делит блоки [0, numberOfBlocks) на непрерывные отрезки
+// и обрабатывает их не более чем workerCount горутинами.
+// Возвращается первая ошибка, остальные отрезки дорабатывают до конца.
+func (c *CipherContext) forEachSegment(numberOfBlocks int, process func(first, last int) error) error {
+	if numberOfBlocks == 0 {
+		return nil
+	}
+
+	workers := min(c.workerCount(), (numberOfBlocks+minBlocksPerSegment-1)/minBlocksPerSegment)
+	if workers <= 1 {
+		return process(0, numberOfBlocks)
+	}
+
+	segment := (numberOfBlocks + workers - 1) / workers
+
+	var (
+		wg       sync.WaitGroup
+		once     sync.Once
+		firstErr error
+	)
+
+	for first := 0; first < numberOfBlocks; first += segment {
+		last := min(first+segment, numberOfBlocks)
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := process(first, last); err != nil {
+				once.Do(func() { firstErr = err })
+			}
+		}()
+	}
+
+	wg.Wait()
+	return firstErr
+}
+
+// xorInto записывает a ^ b в dst; длина определяется dst.
+func xorInto(dst, a, b []byte) {
+	for i := range dst {
+		dst[i] = a[i] ^ b[i]
+	}
+}
+
+// addInPlace прибавляет delta к counter как большое число big-endian.
+func addInPlace(counter, delta []byte) {
+	carry := 0
+	for i := len(counter) - 1; i >= 0; i-- {
+		sum := int(counter[i]) + int(delta[i]) + carry
+		counter[i] = byte(sum)
+		carry = sum >> 8
+	}
+}
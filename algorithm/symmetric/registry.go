@@ -0,0 +1,324 @@
+package symmetric
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// Реестр шифров, режимов и набивок. Шифры регистрируются из init() своих пакетов,
+// поэтому программа должна импортировать их (хотя бы через _), чтобы они появились в списке.
+
+var ErrUnsupportedSuite = errors.New("unsupported cipher suite")
+
+// Params — числовые параметры шифра, например размер слова и число раундов RC5.
+type Params map[string]int
+
+// RoomParams переводит параметры комнаты (размер слова w и число раундов r) в Params шифра.
+// Нулевое значение означает значение по умолчанию: в proto3 его нельзя отличить от незаполненного поля.
+func RoomParams(wordSize, rounds int) Params {
+	params := Params{}
+	if wordSize != 0 {
+		params["w"] = wordSize
+	}
+	if rounds != 0 {
+		params["r"] = rounds
+	}
+	return params
+}
+
+// ParamSpec описывает один параметр шифра: либо список допустимых значений, либо диапазон.
+type ParamSpec struct {
+	Name    string
+	Default int
+	Allowed []int
+	Min     int
+	Max     int
+}
+
+func (p ParamSpec) valid(value int) bool {
+	if len(p.Allowed) > 0 {
+		return slices.Contains(p.Allowed, value)
+	}
+	return value >= p.Min && value <= p.Max
+}
+
+// KeySizeRange — допустимые длины ключа в байтах: Min, Min+Step, ..., Max.
+type KeySizeRange struct {
+	Min  int
+	Max  int
+	Step int
+}
+
+func (r KeySizeRange) Valid(size int) bool {
+	if size < r.Min || size > r.Max {
+		return false
+	}
+	return r.Step <= 1 || (size-r.Min)%r.Step == 0
+}
+
+type CipherInfo struct {
+	Name    string
+	ID      AlgorithmID
+	KeySize KeySizeRange
+	Params  []ParamSpec
+
+	// BlockSize возвращает размер блока в байтах при заданных параметрах.
+	BlockSize func(params Params) int
+	New       func(key []byte, params Params) (CipherScheme, error)
+}
+
+// Create проверяет ключ и параметры, дополняет их значениями по умолчанию
+// и возвращает шифр вместе с его размером блока.
+func (info CipherInfo) Create(key []byte, params Params) (CipherScheme, int, error) {
+	if !info.KeySize.Valid(len(key)) {
+		return nil, 0, fmt.Errorf("%s: invalid key size %d", info.Name, len(key))
+	}
+
+	full, err := info.resolveParams(params)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	cipher, err := info.New(key, full)
+	if err != nil {
+		return nil, 0, fmt.Errorf("could not create %s block cipher: %w", info.Name, err)
+	}
+	return cipher, info.BlockSize(full), nil
+}
+
+// BlockSizeFor возвращает размер блока шифра при заданных параметрах.
+func (info CipherInfo) BlockSizeFor(params Params) (int, error) {
+	full, err := info.resolveParams(params)
+	if err != nil {
+		return 0, err
+	}
+	return info.BlockSize(full), nil
+}
+
+// Param возвращает описание параметра шифра по имени.
+func (info CipherInfo) Param(name string) (ParamSpec, bool) {
+	for _, spec := range info.Params {
+		if spec.Name == name {
+			return spec, true
+		}
+	}
+	return ParamSpec{}, false
+}
+
+// ResolveParams проверяет параметры и дополняет их значениями по умолчанию.
+func (info CipherInfo) ResolveParams(params Params) (Params, error) {
+	return info.resolveParams(params)
+}
+
+func (info CipherInfo) resolveParams(params Params) (Params, error) {
+	full := make(Params, len(info.Params))
+	for _, spec := range info.Params {
+		value, ok := params[spec.Name]
+		if !ok {
+			value = spec.Default
+		}
+		if !spec.valid(value) {
+			return nil, fmt.Errorf("%s: invalid value %d for parameter %s", info.Name, value, spec.Name)
+		}
+		full[spec.Name] = value
+	}
+	for name := range params {
+		if _, ok := full[name]; !ok {
+			return nil, fmt.Errorf("%s: unknown parameter %s", info.Name, name)
+		}
+	}
+	return full, nil
+}
+
+type ModeInfo struct {
+	Name string
+	Mode CipherMode
+	// RequiredBlockSize, если не 0, — единственный размер блока, с которым работает режим.
+	RequiredBlockSize int
+	// ExtraParams — имена дополнительных параметров контекста, нужных режиму.
+	ExtraParams []string
+}
+
+type PaddingInfo struct {
+	Name    string
+	Padding PaddingMode
+}
+
+var registry = struct {
+	sync.RWMutex
+	ciphers  []CipherInfo
+	modes    []ModeInfo
+	paddings []PaddingInfo
+}{}
+
+func RegisterCipher(info CipherInfo) {
+	registry.Lock()
+	defer registry.Unlock()
+
+	if info.Name == "" || info.New == nil || info.BlockSize == nil {
+		panic("symmetric: RegisterCipher requires name, constructor and block size")
+	}
+	for _, c := range registry.ciphers {
+		if strings.EqualFold(c.Name, info.Name) || c.ID == info.ID {
+			panic("symmetric: cipher registered twice: " + info.Name)
+		}
+	}
+	registry.ciphers = append(registry.ciphers, info)
+}
+
+func RegisterMode(info ModeInfo) {
+	registry.Lock()
+	defer registry.Unlock()
+
+	for _, m := range registry.modes {
+		if strings.EqualFold(m.Name, info.Name) || m.Mode == info.Mode {
+			panic("symmetric: mode registered twice: " + info.Name)
+		}
+	}
+	registry.modes = append(registry.modes, info)
+}
+
+func RegisterPadding(info PaddingInfo) {
+	registry.Lock()
+	defer registry.Unlock()
+
+	for _, p := range registry.paddings {
+		if strings.EqualFold(p.Name, info.Name) || p.Padding == info.Padding {
+			panic("symmetric: padding registered twice: " + info.Name)
+		}
+	}
+	registry.paddings = append(registry.paddings, info)
+}
+
+// LookupCipher ищет шифр по имени без учёта регистра.
+func LookupCipher(name string) (CipherInfo, bool) {
+	registry.RLock()
+	defer registry.RUnlock()
+
+	for _, c := range registry.ciphers {
+		if strings.EqualFold(c.Name, name) {
+			return c, true
+		}
+	}
+	return CipherInfo{}, false
+}
+
+func LookupCipherByID(id AlgorithmID) (CipherInfo, bool) {
+	registry.RLock()
+	defer registry.RUnlock()
+
+	for _, c := range registry.ciphers {
+		if c.ID == id {
+			return c, true
+		}
+	}
+	return CipherInfo{}, false
+}
+
+func LookupMode(name string) (ModeInfo, bool) {
+	registry.RLock()
+	defer registry.RUnlock()
+
+	for _, m := range registry.modes {
+		if strings.EqualFold(m.Name, name) {
+			return m, true
+		}
+	}
+	return ModeInfo{}, false
+}
+
+func LookupPadding(name string) (PaddingInfo, bool) {
+	registry.RLock()
+	defer registry.RUnlock()
+
+	for _, p := range registry.paddings {
+		if strings.EqualFold(p.Name, name) {
+			return p, true
+		}
+	}
+	return PaddingInfo{}, false
+}
+
+// CipherNames, ModeNames и PaddingNames возвращают имена в порядке регистрации — для списков в UI.
+func CipherNames() []string {
+	registry.RLock()
+	defer registry.RUnlock()
+
+	names := make([]string, 0, len(registry.ciphers))
+	for _, c := range registry.ciphers {
+		names = append(names, c.Name)
+	}
+	return names
+}
+
+func ModeNames() []string {
+	registry.RLock()
+	defer registry.RUnlock()
+
+	names := make([]string, 0, len(registry.modes))
+	for _, m := range registry.modes {
+		names = append(names, m.Name)
+	}
+	return names
+}
+
+func PaddingNames() []string {
+	registry.RLock()
+	defer registry.RUnlock()
+
+	names := make([]string, 0, len(registry.paddings))
+	for _, p := range registry.paddings {
+		names = append(names, p.Name)
+	}
+	return names
+}
+
+// ValidateSuite проверяет, что алгоритм, режим и набивка известны и совместимы
+// при заданных параметрах шифра и длине ключа (0 — не проверять длину ключа).
+func ValidateSuite(algorithm, mode, padding string, params Params, keySize int) error {
+	cipher, ok := LookupCipher(algorithm)
+	if !ok {
+		return fmt.Errorf("%w: unknown algorithm %q", ErrUnsupportedSuite, algorithm)
+	}
+	modeInfo, ok := LookupMode(mode)
+	if !ok {
+		return fmt.Errorf("%w: unknown mode %q", ErrUnsupportedSuite, mode)
+	}
+	if _, ok = LookupPadding(padding); !ok {
+		return fmt.Errorf("%w: unknown padding %q", ErrUnsupportedSuite, padding)
+	}
+
+	full, err := cipher.resolveParams(params)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrUnsupportedSuite, err)
+	}
+	if keySize != 0 && !cipher.KeySize.Valid(keySize) {
+		return fmt.Errorf("%w: %s does not support %d-byte keys", ErrUnsupportedSuite, cipher.Name, keySize)
+	}
+
+	blockSize := cipher.BlockSize(full)
+	if modeInfo.RequiredBlockSize != 0 && blockSize != modeInfo.RequiredBlockSize {
+		return fmt.Errorf("%w: %s requires a %d-byte block, %s has %d",
+			ErrUnsupportedSuite, modeInfo.Name, modeInfo.RequiredBlockSize, cipher.Name, blockSize)
+	}
+	return nil
+}
+
+func init() {
+	RegisterMode(ModeInfo{Name: "ECB", Mode: ECB})
+	RegisterMode(ModeInfo{Name: "CBC", Mode: CBC})
+	RegisterMode(ModeInfo{Name: "PCBC", Mode: PCBC})
+	RegisterMode(ModeInfo{Name: "CFB", Mode: CFB})
+	RegisterMode(ModeInfo{Name: "OFB", Mode: OFB})
+	RegisterMode(ModeInfo{Name: "CTR", Mode: CTR})
+	RegisterMode(ModeInfo{Name: "RandomDelta", Mode: RandomDelta, ExtraParams: []string{"randomDelta"}})
+	RegisterMode(ModeInfo{Name: "GCM", Mode: GCM, RequiredBlockSize: gcmBlockSize})
+
+	RegisterPadding(PaddingInfo{Name: "Zeros", Padding: Zeros})
+	RegisterPadding(PaddingInfo{Name: "ANSIX923", Padding: AnsiX923})
+	RegisterPadding(PaddingInfo{Name: "PKCS7", Padding: PKCS7})
+	RegisterPadding(PaddingInfo{Name: "ISO10126", Padding: Iso10126})
+}
@@ -0,0 +1,498 @@
+package symmetric
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Потоковый шифртекст имеет тот же формат, что и результат Encrypt для одного фрагмента:
+// заголовок с IV (или nonce для GCM), шифртекст с цепочкой через весь поток,
+// набивка последнего блока (или тег GCM) в конце.
+
+const streamReadSize = 32 * 1024
+
+var ErrTruncatedStream = errors.New("encrypted stream is truncated")
+
+// streamState хранит состояние сцепления блоков между вызовами,
+// чтобы поток можно было обрабатывать порциями произвольного размера.
+type streamState struct {
+	c  *CipherContext
+	iv []byte
+
+	gcmJ0      []byte
+	gcmHash    *ghashState
+	gcmAADLen  int
+	gcmWritten int
+}
+
+// newStreamState начинает поток с заданным IV; additionalData учитывается только в теге GCM.
+func (c *CipherContext) newStreamState(iv, additionalData []byte) (*streamState, error) {
+	s := &streamState{c: c, iv: iv}
+
+	if c.mode == GCM {
+		h, j0, err := c.gcmInit(iv)
+		if err != nil {
+			return nil, err
+		}
+		s.gcmJ0 = j0
+		s.gcmHash = newGHASH(h)
+		s.gcmHash.update(additionalData)
+		s.gcmAADLen = len(additionalData)
+		s.iv = gcmInc32(j0)
+	}
+
+	return s, nil
+}
+
+func (s *streamState) encryptBlocks(data []byte) ([]byte, error) {
+	if s.c.mode == GCM {
+		if err := checkGCMLength(int64(s.gcmWritten + len(data))); err != nil {
+			return nil, err
+		}
+		out := make([]byte, len(data))
+		next, err := s.c.gctr(s.iv, data, out)
+		if err != nil {
+			return nil, err
+		}
+		s.iv = next
+		s.gcmHash.update(out)
+		s.gcmWritten += len(out)
+		return out, nil
+	}
+
+	cc := s.c.withIV(s.iv)
+
+	var (
+		out []byte
+		err error
+	)
+
+	switch s.c.mode {
+	case ECB:
+		out, err = cc.EncryptECB(data)
+	case CBC:
+		out, err = cc.EncryptCBC(data)
+	case PCBC:
+		out, err = cc.EncryptPCBC(data)
+	case CFB:
+		out, err = cc.EncryptCFB(data)
+	case OFB:
+		out, err = cc.EncryptOFB(data)
+	case CTR:
+		out, err = cc.EncryptCTR(data)
+	case RandomDelta:
+		out, err = cc.EncryptRandomDelta(data)
+	default:
+		err = fmt.Errorf("unsupported cipher mode: %d", s.c.mode)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	s.advance(data, out)
+	return out, nil
+}
+
+func (s *streamState) decryptBlocks(data []byte) ([]byte, error) {
+	if s.c.mode == GCM {
+		if err := checkGCMLength(int64(s.gcmWritten + len(data))); err != nil {
+			return nil, err
+		}
+		s.gcmHash.update(data)
+		s.gcmWritten += len(data)
+		out := make([]byte, len(data))
+		next, err := s.c.gctr(s.iv, data, out)
+		if err != nil {
+			return nil, err
+		}
+		s.iv = next
+		return out, nil
+	}
+
+	cc := s.c.withIV(s.iv)
+
+	var (
+		out []byte
+		err error
+	)
+
+	switch s.c.mode {
+	case ECB:
+		out, err = cc.DecryptECB(data)
+	case CBC:
+		out, err = cc.DecryptCBC(data)
+	case PCBC:
+		out, err = cc.DecryptPCBC(data)
+	case CFB:
+		out, err = cc.DecryptCFB(data)
+	case OFB:
+		out, err = cc.DecryptOFB(data)
+	case CTR:
+		out, err = cc.DecryptCTR(data)
+	case RandomDelta:
+		out, err = cc.DecryptRandomDelta(data)
+	default:
+		err = fmt.Errorf("unsupported cipher mode: %d", s.c.mode)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	s.advance(out, data)
+	return out, nil
+}
+
+// advance переносит IV на следующий блок после обработки целых блоков plain/cipher.
+// Каждый режим продолжает цепочку так, будто все данные были зашифрованы одним вызовом.
+func (s *streamState) advance(plain, cipher []byte) {
+	blockSize := s.c.blockSize
+	blocks := len(plain) / blockSize
+	if blocks == 0 {
+		return
+	}
+
+	last := (blocks - 1) * blockSize
+	lastPlain := plain[last : last+blockSize]
+	lastCipher := cipher[last : last+blockSize]
+
+	switch s.c.mode {
+	case CBC, CFB:
+		s.iv = append([]byte(nil), lastCipher...)
+	case PCBC, OFB:
+		iv := make([]byte, blockSize)
+		xorInto(iv, lastPlain, lastCipher)
+		s.iv = iv
+	case CTR:
+		next := append([]byte(nil), s.iv...)
+		incrementCounterBy(next, blocks)
+		s.iv = next
+	case RandomDelta:
+		s.iv = computeCounter(s.iv, s.c.extraParams["randomDelta"], blocks)
+	}
+}
+
+func (s *streamState) gcmTag() ([]byte, error) {
+	return s.c.gcmFinish(s.gcmHash, s.gcmJ0, s.gcmAADLen, s.gcmWritten)
+}
+
+// newStreamIV возвращает IV (nonce) для нового потока; ECB обходится без него.
+func (c *CipherContext) newStreamIV() ([]byte, error) {
+	switch {
+	case c.mode == GCM:
+		return c.newGCMNonce()
+	case c.usesIV():
+		return c.newMessageIV()
+	default:
+		return nil, nil
+	}
+}
+
+func (c *CipherContext) streamHeader(iv []byte) []byte {
+	switch {
+	case c.mode == GCM:
+		return iv
+	case c.usesIV():
+		return prependMessageIV(iv, nil)
+	default:
+		return nil
+	}
+}
+
+func (c *CipherContext) streamHeaderSize() int {
+	switch {
+	case c.mode == GCM:
+		return GCMNonceSize
+	case c.usesIV():
+		return messageIVHeaderSize + c.blockSize
+	default:
+		return 0
+	}
+}
+
+// EncryptedSize возвращает длину шифртекста, который NewEncryptWriter выдаст для size байт.
+func (c *CipherContext) EncryptedSize(size int64) int64 {
+	if c.mode == GCM {
+		return GCMNonceSize + size + GCMTagSize
+	}
+	return int64(c.streamHeaderSize()) + c.streamBodySize(size)
+}
+
+// streamBodySize — длина шифртекста без заголовка потока.
+func (c *CipherContext) streamBodySize(size int64) int64 {
+	if c.mode == GCM {
+		return size + GCMTagSize
+	}
+	blockSize := int64(c.blockSize)
+	return size + blockSize - size%blockSize
+}
+
+type encryptWriter struct {
+	state  *streamState
+	w      io.Writer
+	header []byte
+	buf    []byte
+	closed bool
+}
+
+// NewEncryptWriter возвращает writer, который шифрует всё записанное в w одним потоком.
+// Набивка (или тег GCM) дописывается в Close, поэтому Close обязателен.
+func (c *CipherContext) NewEncryptWriter(w io.Writer) (io.WriteCloser, error) {
+	iv, err := c.newStreamIV()
+	if err != nil {
+		return nil, err
+	}
+	return c.newEncryptWriter(w, iv, c.streamHeader(iv), nil)
+}
+
+func (c *CipherContext) newEncryptWriter(w io.Writer, iv, header, additionalData []byte) (*encryptWriter, error) {
+	if iv == nil {
+		iv = c.iv
+	}
+
+	state, err := c.newStreamState(iv, additionalData)
+	if err != nil {
+		return nil, err
+	}
+
+	return &encryptWriter{state: state, w: w, header: header}, nil
+}
+
+func (e *encryptWriter) Write(p []byte) (int, error) {
+	if e.closed {
+		return 0, errors.New("write to closed encrypt writer")
+	}
+	if err := e.writeHeader(); err != nil {
+		return 0, err
+	}
+
+	e.buf = append(e.buf, p...)
+
+	full := len(e.buf) - len(e.buf)%e.state.c.blockSize
+	if full == 0 {
+		return len(p), nil
+	}
+
+	encrypted, err := e.state.encryptBlocks(e.buf[:full])
+	if err != nil {
+		return 0, fmt.Errorf("failed to encrypt data: %w", err)
+	}
+	if _, err = e.w.Write(encrypted); err != nil {
+		return 0, err
+	}
+
+	e.buf = append(e.buf[:0], e.buf[full:]...)
+	return len(p), nil
+}
+
+func (e *encryptWriter) Close() error {
+	if e.closed {
+		return nil
+	}
+	e.closed = true
+
+	if err := e.writeHeader(); err != nil {
+		return err
+	}
+
+	c := e.state.c
+	if c.mode == GCM {
+		encrypted, err := e.state.encryptBlocks(e.buf)
+		if err != nil {
+			return fmt.Errorf("failed to encrypt data: %w", err)
+		}
+		tag, err := e.state.gcmTag()
+		if err != nil {
+			return err
+		}
+		_, err = e.w.Write(append(encrypted, tag...))
+		return err
+	}
+
+	padded, err := c.applyPadding(e.buf, c.blockSize-len(e.buf)%c.blockSize)
+	if err != nil {
+		return fmt.Errorf("failed to add padding data: %w", err)
+	}
+
+	encrypted, err := e.state.encryptBlocks(padded)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt data: %w", err)
+	}
+	_, err = e.w.Write(encrypted)
+	return err
+}
+
+func (e *encryptWriter) writeHeader() error {
+	if e.header == nil {
+		return nil
+	}
+	header := e.header
+	e.header = nil
+	_, err := e.w.Write(header)
+	return err
+}
+
+type decryptReader struct {
+	c     *CipherContext
+	state *streamState
+	r     io.Reader
+	in    []byte
+	out   []byte
+	eof   bool
+	err   error
+}
+
+// NewDecryptReader возвращает reader, который расшифровывает поток, созданный NewEncryptWriter.
+// В режиме GCM тег проверяется только в конце потока: ErrAuthenticationFailed
+// возвращается после того, как весь открытый текст уже прочитан.
+func (c *CipherContext) NewDecryptReader(r io.Reader) (io.Reader, error) {
+	if c.mode != GCM && !c.usesIV() && c.mode != ECB {
+		return nil, fmt.Errorf("unsupported cipher mode: %d", c.mode)
+	}
+	return &decryptReader{c: c, r: r}, nil
+}
+
+// newBodyReader расшифровывает поток без заголовка, IV которого уже известен.
+func (c *CipherContext) newBodyReader(r io.Reader, iv, additionalData []byte) (*decryptReader, error) {
+	if iv == nil {
+		iv = c.iv
+	}
+
+	state, err := c.newStreamState(iv, additionalData)
+	if err != nil {
+		return nil, err
+	}
+	return &decryptReader{c: c, r: r, state: state}, nil
+}
+
+func (d *decryptReader) Read(p []byte) (int, error) {
+	for len(d.out) == 0 {
+		if d.err != nil {
+			return 0, d.err
+		}
+		d.err = d.fill()
+	}
+
+	n := copy(p, d.out)
+	d.out = d.out[n:]
+	return n, nil
+}
+
+// fill читает очередную порцию шифртекста и расшифровывает всё, кроме хвоста,
+// который может оказаться набивкой или тегом.
+func (d *decryptReader) fill() error {
+	if !d.eof {
+		buf := make([]byte, streamReadSize)
+		n, err := d.r.Read(buf)
+		d.in = append(d.in, buf[:n]...)
+		if err == io.EOF {
+			d.eof = true
+		} else if err != nil {
+			return err
+		}
+	}
+
+	if d.state == nil {
+		headerSize := d.c.streamHeaderSize()
+		if len(d.in) < headerSize {
+			if d.eof {
+				return ErrTruncatedStream
+			}
+			return nil
+		}
+		if err := d.readHeader(d.in[:headerSize]); err != nil {
+			return err
+		}
+		d.in = d.in[headerSize:]
+	}
+
+	if d.eof {
+		return d.finish()
+	}
+
+	blockSize := d.c.blockSize
+	hold := blockSize
+	if d.c.mode == GCM {
+		hold = GCMTagSize
+	}
+
+	ready := len(d.in) - hold
+	ready -= ready % blockSize
+	if ready <= 0 {
+		return nil
+	}
+
+	decrypted, err := d.state.decryptBlocks(d.in[:ready])
+	if err != nil {
+		return fmt.Errorf("failed to decrypt data: %w", err)
+	}
+	d.out = decrypted
+	d.in = append(d.in[:0], d.in[ready:]...)
+	return nil
+}
+
+func (d *decryptReader) readHeader(header []byte) error {
+	var iv []byte
+
+	switch {
+	case d.c.mode == GCM:
+		iv = append([]byte(nil), header...)
+	case d.c.usesIV():
+		if header[0] != messageIVVersion {
+			return fmt.Errorf("unsupported stream version: %d", header[0])
+		}
+		iv = append([]byte(nil), header[messageIVHeaderSize:]...)
+	default:
+		iv = d.c.iv
+	}
+
+	state, err := d.c.newStreamState(iv, nil)
+	if err != nil {
+		return err
+	}
+	d.state = state
+	return nil
+}
+
+func (d *decryptReader) finish() error {
+	if d.c.mode == GCM {
+		if len(d.in) < GCMTagSize {
+			return ErrTruncatedStream
+		}
+		encrypted, tag := d.in[:len(d.in)-GCMTagSize], d.in[len(d.in)-GCMTagSize:]
+
+		decrypted, err := d.state.decryptBlocks(encrypted)
+		if err != nil {
+			return fmt.Errorf("failed to decrypt data: %w", err)
+		}
+		expected, err := d.state.gcmTag()
+		if err != nil {
+			return err
+		}
+		if subtle.ConstantTimeCompare(expected, tag) != 1 {
+			return ErrAuthenticationFailed
+		}
+
+		d.in = nil
+		d.out = decrypted
+		return io.EOF
+	}
+
+	if len(d.in) == 0 || len(d.in)%d.c.blockSize != 0 {
+		return ErrTruncatedStream
+	}
+
+	decrypted, err := d.state.decryptBlocks(d.in)
+	if err != nil {
+		return fmt.Errorf("failed to decrypt data: %w", err)
+	}
+	decrypted, err = d.c.removePadding(decrypted)
+	if err != nil {
+		return fmt.Errorf("failed to remove padding data: %w", err)
+	}
+
+	d.in = nil
+	d.out = decrypted
+	return io.EOF
+}
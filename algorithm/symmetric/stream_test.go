@@ -0,0 +1,155 @@
+package symmetric_test
+
+import (
+	"CryptoMessenger/algorithm/symmetric"
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+	"testing/iotest"
+)
+
+var (
+	streamModes    = []string{"ECB", "CBC", "PCBC", "CFB", "OFB", "CTR", "RandomDelta", "GCM"}
+	streamPaddings = []string{"Zeros", "ANSIX923", "PKCS7", "ISO10126"}
+)
+
+func newStreamContext(t *testing.T, modeName, paddingName string) *symmetric.CipherContext {
+	t.Helper()
+	cipherInfo, _ := symmetric.LookupCipher("RC6")
+	mode, _ := symmetric.LookupMode(modeName)
+	padding, _ := symmetric.LookupPadding(paddingName)
+
+	key := randomBytes(t, 16)
+	scheme, blockSize, err := cipherInfo.Create(key, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cipherContext, err := symmetric.NewCipherContext(key, scheme, mode.Mode, padding.Padding,
+		randomBytes(t, blockSize), blockSize, "randomDelta", randomBytes(t, blockSize))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cipherContext
+}
+
+// streamPlaintext не заканчивается нулём: набивку Zeros иначе не отличить от данных.
+func streamPlaintext(t *testing.T, size int) []byte {
+	t.Helper()
+	plaintext := randomBytes(t, size)
+	if size > 0 {
+		plaintext[size-1] |= 1
+	}
+	return plaintext
+}
+
+// encryptStream пишет plaintext в NewEncryptWriter порциями из writeSizes по кругу.
+func encryptStream(t *testing.T, cipherContext *symmetric.CipherContext, plaintext []byte, writeSizes []int) []byte {
+	t.Helper()
+	var out bytes.Buffer
+	encryptWriter, err := cipherContext.NewEncryptWriter(&out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; len(plaintext) > 0; i++ {
+		n := min(writeSizes[i%len(writeSizes)], len(plaintext))
+		if written, err := encryptWriter.Write(plaintext[:n]); err != nil || written != n {
+			t.Fatalf("write %d bytes: wrote %d, %v", n, written, err)
+		}
+		plaintext = plaintext[n:]
+	}
+	if err = encryptWriter.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return out.Bytes()
+}
+
+func TestStreamRoundTrip(t *testing.T) {
+	writes := []struct {
+		name  string
+		sizes []int
+	}{
+		{"1 byte", []int{1}},
+		{"block minus 1", []int{15}},
+		{"odd chunks", []int{7, 33, 1, 100, 4097}},
+		{"whole input", []int{1 << 20}},
+	}
+	sizes := []int{0, 1, 15, 16, 17, 1000, 70000}
+
+	for _, modeName := range streamModes {
+		for _, paddingName := range streamPaddings {
+			t.Run(modeName+"/"+paddingName, func(t *testing.T) {
+				cipherContext := newStreamContext(t, modeName, paddingName)
+				for _, size := range sizes {
+					plaintext := streamPlaintext(t, size)
+					for _, w := range writes {
+						encrypted := encryptStream(t, cipherContext, plaintext, w.sizes)
+						if int64(len(encrypted)) != cipherContext.EncryptedSize(int64(size)) {
+							t.Fatalf("%d bytes, %s writes: encrypted %d bytes, EncryptedSize = %d",
+								size, w.name, len(encrypted), cipherContext.EncryptedSize(int64(size)))
+						}
+
+						// Шифртекст потока читается и обычным Decrypt, как одно сообщение
+						if size > 0 && modeName != "ECB" {
+							decrypted, err := cipherContext.Decrypt(encrypted, 0, 1)
+							if err != nil || !bytes.Equal(decrypted, plaintext) {
+								t.Fatalf("%d bytes, %s writes: Decrypt differs: %v", size, w.name, err)
+							}
+						}
+
+						readers := []func(io.Reader) io.Reader{iotest.HalfReader}
+						if size <= 1000 {
+							readers = append(readers, iotest.OneByteReader)
+						}
+						for _, reader := range readers {
+							decryptReader, err := cipherContext.NewDecryptReader(reader(bytes.NewReader(encrypted)))
+							if err != nil {
+								t.Fatal(err)
+							}
+							decrypted, err := io.ReadAll(decryptReader)
+							if err != nil {
+								t.Fatalf("%d bytes, %s writes: %v", size, w.name, err)
+							}
+							if !bytes.Equal(decrypted, plaintext) {
+								t.Fatalf("%d bytes, %s writes: decrypted data differs", size, w.name)
+							}
+						}
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestStreamRejectsTruncated(t *testing.T) {
+	for _, modeName := range streamModes {
+		t.Run(modeName, func(t *testing.T) {
+			cipherContext := newStreamContext(t, modeName, "PKCS7")
+			encrypted := encryptStream(t, cipherContext, streamPlaintext(t, 1000), []int{1000})
+
+			// Без набивки в конце потока GCM видит только несовпадение тега
+			lastByteErr := symmetric.ErrTruncatedStream
+			if modeName == "GCM" {
+				lastByteErr = symmetric.ErrAuthenticationFailed
+			}
+			tests := []struct {
+				name    string
+				data    []byte
+				wantErr error
+			}{
+				{"empty", nil, symmetric.ErrTruncatedStream},
+				{"header cut", encrypted[:5], symmetric.ErrTruncatedStream},
+				{"last byte missing", encrypted[:len(encrypted)-1], lastByteErr},
+			}
+			for _, tt := range tests {
+				decryptReader, err := cipherContext.NewDecryptReader(bytes.NewReader(tt.data))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if _, err = io.ReadAll(decryptReader); !errors.Is(err, tt.wantErr) {
+					t.Fatalf("%s: got %v, want %v", tt.name, err, tt.wantErr)
+				}
+			}
+		})
+	}
+}
@@ -10,6 +10,16 @@ const (
 	OFB
 	CTR
 	RandomDelta
+	GCM
+)
+
+// AlgorithmID записывается в заголовок зашифрованного файла, чтобы знать, каким шифром его открывать.
+type AlgorithmID uint8
+
+const (
+	UnknownAlgorithm AlgorithmID = iota
+	AlgorithmRC5
+	AlgorithmRC6
 )
 
 type PaddingMode int
@@ -34,6 +44,10 @@ type CipherScheme interface {
 	SetKey(key []byte) error
 	Encrypt(block []byte) ([]byte, error)
 	Decrypt(block []byte) ([]byte, error)
+	// EncryptBlock и DecryptBlock пишут результат в dst, не выделяя память;
+	// dst может совпадать с src. Их используют режимы шифрования в цикле по блокам.
+	EncryptBlock(dst, src []byte) error
+	DecryptBlock(dst, src []byte) error
 	EncryptAsync(data []byte) (<-chan []byte, <-chan error)
 	DecryptAsync(data []byte) (<-chan []byte, <-chan error)
 }
@@ -0,0 +1,294 @@
+package symmetric_test
+
+import (
+	"CryptoMessenger/algorithm/symmetric"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/hex"
+	"errors"
+	"testing"
+)
+
+func unhex(tb testing.TB, s string) []byte {
+	tb.Helper()
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return b
+}
+
+// Векторы из описания RC6 (Rivest, Robshaw, Sidney, Yin), RC6-32/20/b.
+func TestRC6PublishedVectors(t *testing.T) {
+	tests := []struct {
+		key        string
+		plaintext  string
+		ciphertext string
+	}{
+		{
+			"00000000000000000000000000000000",
+			"00000000000000000000000000000000",
+			"8fc3a53656b1f778c129df4e9848a41e",
+		},
+		{
+			"0123456789abcdef0112233445566778",
+			"02132435465768798a9bacbdcedfe0f1",
+			"524e192f4715c6231f51f6367ea43f18",
+		},
+		{
+			"000000000000000000000000000000000000000000000000",
+			"00000000000000000000000000000000",
+			"6cd61bcb190b30384e8a3f168690ae82",
+		},
+		{
+			"0123456789abcdef0112233445566778899aabbccddeeff0",
+			"02132435465768798a9bacbdcedfe0f1",
+			"688329d019e505041e52e92af95291d4",
+		},
+		{
+			"0000000000000000000000000000000000000000000000000000000000000000",
+			"00000000000000000000000000000000",
+			"8f5fbd0510d15fa893fa3fda6e857ec2",
+		},
+		{
+			"0123456789abcdef0112233445566778899aabbccddeeff01032547698badcfe",
+			"02132435465768798a9bacbdcedfe0f1",
+			"c8241816f0d7e48920ad16a1674e5d48",
+		},
+	}
+
+	cipherInfo, _ := symmetric.LookupCipher("RC6")
+	for _, tt := range tests {
+		key := unhex(t, tt.key)
+		t.Run(tt.key, func(t *testing.T) {
+			scheme, _, err := cipherInfo.Create(key, symmetric.Params{"w": 32, "r": 20})
+			if err != nil {
+				t.Fatal(err)
+			}
+			plaintext, ciphertext := unhex(t, tt.plaintext), unhex(t, tt.ciphertext)
+
+			out := make([]byte, len(plaintext))
+			if err = scheme.EncryptBlock(out, plaintext); err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(out, ciphertext) {
+				t.Fatalf("encrypt = %x, want %x", out, ciphertext)
+			}
+			if err = scheme.DecryptBlock(out, ciphertext); err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(out, plaintext) {
+				t.Fatalf("decrypt = %x, want %x", out, plaintext)
+			}
+		})
+	}
+}
+
+// aesScheme подключает AES к CipherContext, чтобы проверить GCM на векторах NIST.
+type aesScheme struct {
+	block cipher.Block
+}
+
+func (a *aesScheme) SetKey(key []byte) (err error) {
+	a.block, err = aes.NewCipher(key)
+	return err
+}
+
+func (a *aesScheme) Encrypt(block []byte) ([]byte, error) {
+	out := make([]byte, len(block))
+	return out, a.EncryptBlock(out, block)
+}
+
+func (a *aesScheme) Decrypt(block []byte) ([]byte, error) {
+	out := make([]byte, len(block))
+	return out, a.DecryptBlock(out, block)
+}
+
+func (a *aesScheme) EncryptBlock(dst, src []byte) error {
+	a.block.Encrypt(dst, src)
+	return nil
+}
+
+func (a *aesScheme) DecryptBlock(dst, src []byte) error {
+	a.block.Decrypt(dst, src)
+	return nil
+}
+
+func (a *aesScheme) EncryptAsync([]byte) (<-chan []byte, <-chan error) { return nil, nil }
+func (a *aesScheme) DecryptAsync([]byte) (<-chan []byte, <-chan error) { return nil, nil }
+
+// Тестовые случаи 1–4 и 13–16 из спецификации GCM (McGrew, Viega), вошедшей в NIST SP 800-38D.
+func TestGCMNISTVectors(t *testing.T) {
+	const (
+		key128 = "feffe9928665731c6d6a8f9467308308"
+		key256 = "feffe9928665731c6d6a8f9467308308feffe9928665731c6d6a8f9467308308"
+		nonce  = "cafebabefacedbaddecaf888"
+		aad    = "feedfacedeadbeeffeedfacedeadbeefabaddad2"
+
+		plaintext = "d9313225f88406e5a55909c5aff5269a86a7a9531534f7da2e4c303d8a318a72" +
+			"1c3c0c95956809532fcf0e2449a6b525b16aedf5aa0de657ba637b391aafd255"
+	)
+	tests := []struct {
+		name       string
+		key        string
+		nonce      string
+		plaintext  string
+		aad        string
+		ciphertext string
+		tag        string
+	}{
+		{
+			name:  "case 1",
+			key:   "00000000000000000000000000000000",
+			nonce: "000000000000000000000000",
+			tag:   "58e2fccefa7e3061367f1d57a4e7455a",
+		},
+		{
+			name:       "case 2",
+			key:        "00000000000000000000000000000000",
+			nonce:      "000000000000000000000000",
+			plaintext:  "00000000000000000000000000000000",
+			ciphertext: "0388dace60b6a392f328c2b971b2fe78",
+			tag:        "ab6e47d42cec13bdf53a67b21257bddf",
+		},
+		{
+			name:      "case 3",
+			key:       key128,
+			nonce:     nonce,
+			plaintext: plaintext,
+			ciphertext: "42831ec2217774244b7221b784d0d49ce3aa212f2c02a4e035c17e2329aca12e" +
+				"21d514b25466931c7d8f6a5aac84aa051ba30b396a0aac973d58e091473f5985",
+			tag: "4d5c2af327cd64a62cf35abd2ba6fab4",
+		},
+		{
+			name:      "case 4",
+			key:       key128,
+			nonce:     nonce,
+			plaintext: plaintext[:120],
+			aad:       aad,
+			ciphertext: "42831ec2217774244b7221b784d0d49ce3aa212f2c02a4e035c17e2329aca12e" +
+				"21d514b25466931c7d8f6a5aac84aa051ba30b396a0aac973d58e091",
+			tag: "5bc94fbc3221a5db94fae95ae7121a47",
+		},
+		{
+			name:  "case 13",
+			key:   "0000000000000000000000000000000000000000000000000000000000000000",
+			nonce: "000000000000000000000000",
+			tag:   "530f8afbc74536b9a963b4f1c4cb738b",
+		},
+		{
+			name:       "case 14",
+			key:        "0000000000000000000000000000000000000000000000000000000000000000",
+			nonce:      "000000000000000000000000",
+			plaintext:  "00000000000000000000000000000000",
+			ciphertext: "cea7403d4d606b6e074ec5d3baf39d18",
+			tag:        "d0d1c8a799996bf0265b98b5d48ab919",
+		},
+		{
+			name:      "case 15",
+			key:       key256,
+			nonce:     nonce,
+			plaintext: plaintext,
+			ciphertext: "522dc1f099567d07f47f37a32a84427d643a8cdcbfe5c0c97598a2bd2555d1aa" +
+				"8cb08e48590dbb3da7b08b1056828838c5f61e6393ba7a0abcc9f662898015ad",
+			tag: "b094dac5d93471bdec1a502270e3cc6c",
+		},
+		{
+			name:      "case 16",
+			key:       key256,
+			nonce:     nonce,
+			plaintext: plaintext[:120],
+			aad:       aad,
+			ciphertext: "522dc1f099567d07f47f37a32a84427d643a8cdcbfe5c0c97598a2bd2555d1aa" +
+				"8cb08e48590dbb3da7b08b1056828838c5f61e6393ba7a0abcc9f662",
+			tag: "76fc6ece0f4e1768cddf8853bb2d551b",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key := unhex(t, tt.key)
+			cipherContext, err := symmetric.NewCipherContext(key, &aesScheme{}, symmetric.GCM, symmetric.PKCS7, nil, aes.BlockSize)
+			if err != nil {
+				t.Fatal(err)
+			}
+			nonce, plaintext, aad := unhex(t, tt.nonce), unhex(t, tt.plaintext), unhex(t, tt.aad)
+			want := append(unhex(t, tt.ciphertext), unhex(t, tt.tag)...)
+
+			sealed, err := cipherContext.SealGCM(nonce, plaintext, aad)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(sealed, want) {
+				t.Fatalf("seal = %x, want %x", sealed, want)
+			}
+
+			opened, err := cipherContext.OpenGCM(nonce, want, aad)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(opened, plaintext) {
+				t.Fatalf("open = %x, want %x", opened, plaintext)
+			}
+
+			// Любой изменённый бит шифртекста, тега или дополнительных данных отвергается
+			for i := range want {
+				tampered := bytes.Clone(want)
+				tampered[i] ^= 0x01
+				if _, err = cipherContext.OpenGCM(nonce, tampered, aad); !errors.Is(err, symmetric.ErrAuthenticationFailed) {
+					t.Fatalf("byte %d flipped: got %v", i, err)
+				}
+			}
+			if _, err = cipherContext.OpenGCM(nonce, want, append(aad, 0)); !errors.Is(err, symmetric.ErrAuthenticationFailed) {
+				t.Fatalf("extra additional data: got %v", err)
+			}
+		})
+	}
+}
+
+// Изменённый байт IV, шифртекста, тега или дополнительных данных отвергается в любом режиме.
+func TestAEADRejectsTampering(t *testing.T) {
+	for _, modeName := range []string{"ECB", "CBC", "CTR", "RandomDelta", "GCM"} {
+		t.Run(modeName, func(t *testing.T) {
+			aead, err := symmetric.NewAEADContext(newBenchContext(t, "RC6", modeName))
+			if err != nil {
+				t.Fatal(err)
+			}
+			plaintext := randomBytes(t, 100)
+			aad := []byte("room\x00sender\x00message")
+
+			sealed, err := aead.Encrypt(plaintext, aad, 0, 1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			opened, err := aead.Decrypt(sealed, aad, 0, 1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(opened, plaintext) {
+				t.Fatal("decrypted data differs from the plaintext")
+			}
+
+			for i := range sealed {
+				tampered := bytes.Clone(sealed)
+				tampered[i] ^= 0x01
+				if _, err = aead.Decrypt(tampered, aad, 0, 1); !errors.Is(err, symmetric.ErrAuthenticationFailed) {
+					t.Fatalf("byte %d of %d flipped: got %v", i, len(sealed), err)
+				}
+			}
+			for i := range aad {
+				tampered := bytes.Clone(aad)
+				tampered[i] ^= 0x01
+				if _, err = aead.Decrypt(sealed, tampered, 0, 1); !errors.Is(err, symmetric.ErrAuthenticationFailed) {
+					t.Fatalf("additional data byte %d flipped: got %v", i, err)
+				}
+			}
+			for _, data := range [][]byte{nil, sealed[:len(sealed)-1], sealed[1:]} {
+				if _, err = aead.Decrypt(data, aad, 0, 1); !errors.Is(err, symmetric.ErrAuthenticationFailed) {
+					t.Fatalf("%d of %d bytes: got %v", len(data), len(sealed), err)
+				}
+			}
+		})
+	}
+}
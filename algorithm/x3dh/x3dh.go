@@ -0,0 +1,74 @@
+package x3dh
+
+import (
+	"crypto/sha256"
+	"errors"
+	"golang.org/x/crypto/hkdf"
+	"io"
+)
+
+// Упрощённый X3DH: получатель заранее публикует подписанный предключ и набор
+// одноразовых, отправитель делает DH своим эфемерным ключом с каждым из них и
+// сразу получает ключ комнаты. Подлинность обеспечивают подписи Ed25519
+// (предключа — получателем, приглашения — отправителем), поэтому DH с ключами
+// идентичности, как в Signal, не нужен.
+const (
+	SecretSize = 32
+
+	info = "CryptoMessenger X3DH v1"
+)
+
+var ErrEmptyKey = errors.New("x3dh: empty key")
+
+// DH — схема согласования: группа dh.Group или схема ecdh.Scheme.
+type DH interface {
+	SharedSecret(privateKey, peerPublicKey []byte) ([]byte, error)
+}
+
+// InitiatorSecret вычисляет секрет на стороне отправителя. oneTimePrekey может быть
+// пустым, если у получателя закончились одноразовые предключи.
+func InitiatorSecret(dh DH, ephemeralPrivateKey, signedPrekey, oneTimePrekey []byte) ([]byte, error) {
+	if len(signedPrekey) == 0 {
+		return nil, ErrEmptyKey
+	}
+	dh1, err := dh.SharedSecret(ephemeralPrivateKey, signedPrekey)
+	if err != nil {
+		return nil, err
+	}
+	var dh2 []byte
+	if len(oneTimePrekey) > 0 {
+		if dh2, err = dh.SharedSecret(ephemeralPrivateKey, oneTimePrekey); err != nil {
+			return nil, err
+		}
+	}
+	return kdf(dh1, dh2), nil
+}
+
+// ResponderSecret вычисляет тот же секрет на стороне получателя.
+func ResponderSecret(dh DH, signedPrekeyPrivate, oneTimePrekeyPrivate, ephemeralPublicKey []byte) ([]byte, error) {
+	if len(ephemeralPublicKey) == 0 {
+		return nil, ErrEmptyKey
+	}
+	dh1, err := dh.SharedSecret(signedPrekeyPrivate, ephemeralPublicKey)
+	if err != nil {
+		return nil, err
+	}
+	var dh2 []byte
+	if len(oneTimePrekeyPrivate) > 0 {
+		if dh2, err = dh.SharedSecret(oneTimePrekeyPrivate, ephemeralPublicKey); err != nil {
+			return nil, err
+		}
+	}
+	return kdf(dh1, dh2), nil
+}
+
+// kdf — HKDF-SHA256 над DH1 || DH2 с нулевой солью.
+func kdf(dh1, dh2 []byte) []byte {
+	secret := make([]byte, SecretSize)
+	r := hkdf.New(sha256.New, append(append([]byte(nil), dh1...), dh2...), make([]byte, sha256.Size), []byte(info))
+	if _, err := io.ReadFull(r, secret); err != nil {
+		// HKDF-SHA256 выдаёт до 8160 байт
+		panic(err)
+	}
+	return secret
+}
@@ -0,0 +1,108 @@
+package x3dh_test
+
+import (
+	dh "CryptoMessenger/algorithm/diffie_hellman"
+	"CryptoMessenger/algorithm/ecdh"
+	"CryptoMessenger/algorithm/x3dh"
+	"bytes"
+	"errors"
+	"testing"
+)
+
+type scheme interface {
+	x3dh.DH
+	GenerateKey() (privateKey, publicKey []byte, err error)
+}
+
+type keyPair struct {
+	private, public []byte
+}
+
+func generate(t *testing.T, s scheme) keyPair {
+	t.Helper()
+	private, public, err := s.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return keyPair{private, public}
+}
+
+func schemes(t *testing.T) map[string]scheme {
+	t.Helper()
+	x25519, err := ecdh.LookupScheme(ecdh.SchemeX25519)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p256, err := ecdh.LookupScheme(ecdh.SchemeP256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	group, err := dh.LookupGroup(dh.DefaultGroup)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return map[string]scheme{"X25519": x25519, "P-256": p256, "DH": group}
+}
+
+func TestInitiatorAndResponderAgree(t *testing.T) {
+	for name, s := range schemes(t) {
+		for _, withOneTime := range []bool{true, false} {
+			label := name + "/without one-time prekey"
+			if withOneTime {
+				label = name + "/with one-time prekey"
+			}
+			t.Run(label, func(t *testing.T) {
+				signed, ephemeral := generate(t, s), generate(t, s)
+				var oneTime keyPair
+				if withOneTime {
+					oneTime = generate(t, s)
+				}
+
+				initiator, err := x3dh.InitiatorSecret(s, ephemeral.private, signed.public, oneTime.public)
+				if err != nil {
+					t.Fatal(err)
+				}
+				responder, err := x3dh.ResponderSecret(s, signed.private, oneTime.private, ephemeral.public)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(initiator) != x3dh.SecretSize || !bytes.Equal(initiator, responder) {
+					t.Fatalf("initiator %x, responder %x", initiator, responder)
+				}
+
+				// Без одноразового предключа получается другой секрет
+				if withOneTime {
+					withoutOneTime, err := x3dh.ResponderSecret(s, signed.private, nil, ephemeral.public)
+					if err != nil {
+						t.Fatal(err)
+					}
+					if bytes.Equal(withoutOneTime, responder) {
+						t.Fatal("one-time prekey does not affect the secret")
+					}
+				}
+
+				// Чужой подписанный предключ не даёт того же секрета
+				other := generate(t, s)
+				forged, err := x3dh.ResponderSecret(s, other.private, oneTime.private, ephemeral.public)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if bytes.Equal(forged, initiator) {
+					t.Fatal("secret does not depend on the signed prekey")
+				}
+			})
+		}
+	}
+}
+
+func TestEmptyKeys(t *testing.T) {
+	s := schemes(t)["X25519"]
+	key := generate(t, s)
+
+	if _, err := x3dh.InitiatorSecret(s, key.private, nil, nil); !errors.Is(err, x3dh.ErrEmptyKey) {
+		t.Fatalf("initiator without signed prekey: got %v", err)
+	}
+	if _, err := x3dh.ResponderSecret(s, key.private, nil, nil); !errors.Is(err, x3dh.ErrEmptyKey) {
+		t.Fatalf("responder without ephemeral key: got %v", err)
+	}
+}
@@ -2,7 +2,6 @@ package domain
 
 import (
 	"errors"
-	"math/big"
 	"time"
 )
 
@@ -14,8 +13,29 @@ type Chat struct {
 	Padding     string
 	RandomDelta string
 	IV          string
+	WordSize    int
+	Rounds      int
+	KeyLength   int
+	// KeyAgreement — KeyAgreementDH или имя схемы ECDH ("X25519", "P-256").
+	KeyAgreement string
+	// DoubleRatchet — у каждого сообщения свой ключ (прямая секретность).
+	DoubleRatchet bool
+	// FirstMessage отправляется вместе с приглашением; нужны предключи собеседника.
+	FirstMessage string
 }
 
+// KeyAgreementDH — классический DH в именованной группе; пустое поле означает его же.
+const KeyAgreementDH = "DH"
+
+// Версии вывода ключей комнаты из общего секрета.
+const (
+	// KDFLegacy — ключом служит сам общий секрет, IV берётся из комнаты.
+	// Так шифруются комнаты, в room_info.json которых версии нет.
+	KDFLegacy = 0
+	// KDFHKDF — ключ шифрования, ключ MAC и затравка IV выводятся через HKDF.
+	KDFHKDF = 1
+)
+
 const (
 	CipherKey = iota
 	MyPublicKey
@@ -28,10 +48,12 @@ type RoomInfo struct {
 	Name           string `json:"room_name"`
 	MyClient       string `json:"my_client"`
 	Companion      string `json:"companion"`
-	CipherKey      string `json:"cipher_key"`
+	CipherKey      string `json:"cipher_key,omitempty"`
+	KeyAgreement   string `json:"key_agreement,omitempty"`
+	DHGroup        string `json:"dh_group,omitempty"`
 	P              string `json:"p"`
 	G              string `json:"g"`
-	PrivateKey     string `json:"private_key"`
+	PrivateKey     string `json:"private_key,omitempty"`
 	MyPublicKey    string `json:"public_key"`
 	OtherPublicKey string `json:"other_public_key"`
 	Algorithm      string `json:"algorithm"`
@@ -39,6 +61,68 @@ type RoomInfo struct {
 	Padding        string `json:"padding"`
 	RandomDelta    string `json:"random_delta"`
 	IV             string `json:"iv"`
+	WordSize       int    `json:"word_size,omitempty"`
+	Rounds         int    `json:"rounds,omitempty"`
+	KeyLength      int    `json:"key_length,omitempty"`
+	// KDFVersion — KDFLegacy или KDFHKDF, см. LegacyKDF.
+	KDFVersion int `json:"kdf_version,omitempty"`
+	// Verified — собеседник подтвердил номер безопасности VerifiedSafetyNumber.
+	// При смене ключей отметка снимается, а старый номер остаётся, чтобы предупредить об этом.
+	Verified             bool   `json:"verified,omitempty"`
+	VerifiedSafetyNumber string `json:"verified_safety_number,omitempty"`
+	// Epoch — номер текущего ключа комнаты, растёт при каждой смене ключа.
+	Epoch        int           `json:"epoch,omitempty"`
+	PastEpochs   []KeyEpoch    `json:"past_epochs,omitempty"`
+	PendingRekey *PendingRekey `json:"pending_rekey,omitempty"`
+	// DoubleRatchet — сообщения шифруются ключами Double Ratchet, состояние в хранилище ключей.
+	DoubleRatchet bool `json:"double_ratchet,omitempty"`
+}
+
+// LegacyKDF сообщает, что ключи комнаты берутся из общего секрета напрямую.
+// Комнат на ECDH и Double Ratchet до HKDF не было, поэтому для них версия не важна.
+func (r RoomInfo) LegacyKDF() bool {
+	return r.KDFVersion == KDFLegacy && !r.DoubleRatchet &&
+		(r.KeyAgreement == "" || r.KeyAgreement == KeyAgreementDH)
+}
+
+// KeyEpoch — ключ прошлой эпохи. Хранится, чтобы расшифровать сообщения,
+// отправленные до смены ключа.
+type KeyEpoch struct {
+	Epoch          int    `json:"epoch"`
+	CipherKey      string `json:"cipher_key,omitempty"`
+	MyPublicKey    string `json:"public_key"`
+	OtherPublicKey string `json:"other_public_key"`
+}
+
+// PendingRekey — предложенная собеседнику эпоха, на которую ещё нет ответа.
+type PendingRekey struct {
+	Epoch      int    `json:"epoch"`
+	PrivateKey string `json:"private_key,omitempty"`
+	PublicKey  string `json:"public_key"`
+}
+
+// KeyUpdate — результат обработки сообщения о смене ключа для UI.
+type KeyUpdate struct {
+	RoomID    string
+	Companion string
+	Epoch     int
+	Deleted   bool
+}
+
+// Event — событие для UI из потока сервера. Заполнено ровно одно поле.
+type Event struct {
+	Invitation         *Invitation   // новое приглашение
+	InvitationResponse *Invitation   // собеседник ответил на приглашение
+	KeyUpdate          *KeyUpdate    // ключ комнаты сменён или комната удалена
+	RoomChanged        string        // в комнате изменилась история
+	Progress           *FileProgress // расшифровка принятого файла
+}
+
+// FileProgress — ход расшифровки принятого файла.
+type FileProgress struct {
+	RoomID string
+	Done   int
+	Total  int
 }
 
 type User struct {
@@ -56,14 +140,8 @@ type Invitation struct {
 	MessageID string
 	SharedKey string
 	Accepted  bool
-}
-
-type DiffieHellmanParams struct {
-	Prime          *big.Int
-	G              *big.Int
-	MyPublicKey    *big.Int
-	OtherPublicKey *big.Int
-	PrivateKey     *big.Int
+	// FirstMessage — сообщение, пришедшее вместе с приглашением.
+	FirstMessage string
 }
 
 type StoredMessage struct {
@@ -75,6 +153,8 @@ type StoredMessage struct {
 	Filepath    string    `json:"filepath,omitempty"`
 	TotalChunks int       `json:"total_chunks,omitempty"`
 	Timestamp   time.Time `json:"timestamp"`
+	// Encrypted — Filepath указывает на принятый файл, зашифрованный ключом хранения.
+	Encrypted bool `json:"encrypted,omitempty"`
 }
 
 var (
@@ -0,0 +1,91 @@
+package grpc_client
+
+import (
+	pb "CryptoMessenger/proto/chatpb"
+	"context"
+	"fmt"
+	"github.com/google/uuid"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"time"
+)
+
+// fileChunkTimeout ограничивает отправку одного фрагмента, а не всего файла.
+const fileChunkTimeout = 8 * time.Second
+
+// fileChunkSender режет записанный в него шифртекст на FileChunk фиксированного размера
+// и отправляет их по мере заполнения, последний неполный фрагмент уходит в Flush.
+// ctx отменяет всю отправку; токен доступа берётся заново для каждого фрагмента,
+// поэтому большой файл переживает его обновление.
+type fileChunkSender struct {
+	ctx         context.Context
+	withAuth    func(context.Context) context.Context
+	client      pb.ChatServiceClient
+	roomID      string
+	receiver    string
+	keyEpoch    int
+	ratchet     *pb.RatchetHeader
+	fileID      string
+	filename    string
+	timestamp   time.Time
+	chunkSize   int
+	totalChunks int
+	sent        int
+	buf         []byte
+	progress    func(done, total int)
+}
+
+func (s *fileChunkSender) Write(p []byte) (int, error) {
+	s.buf = append(s.buf, p...)
+	for len(s.buf) >= s.chunkSize {
+		if err := s.send(s.buf[:s.chunkSize]); err != nil {
+			return 0, err
+		}
+		s.buf = append(s.buf[:0], s.buf[s.chunkSize:]...)
+	}
+	return len(p), nil
+}
+
+func (s *fileChunkSender) Flush() error {
+	if len(s.buf) == 0 {
+		return nil
+	}
+	err := s.send(s.buf)
+	s.buf = nil
+	return err
+}
+
+func (s *fileChunkSender) send(data []byte) error {
+	if s.sent >= s.totalChunks {
+		return fmt.Errorf("file chunk %d exceeds expected total %d", s.sent, s.totalChunks)
+	}
+
+	chunkData := make([]byte, len(data))
+	copy(chunkData, data)
+
+	ctx, cancel := context.WithTimeout(s.withAuth(s.ctx), fileChunkTimeout)
+	defer cancel()
+
+	if _, err := s.client.SendMessage(ctx, &pb.ChatMessage{
+		MessageId:    uuid.New().String(),
+		ChatId:       s.roomID,
+		ReceiverName: s.receiver,
+		Timestamp:    timestamppb.New(s.timestamp),
+		KeyEpoch:     int32(s.keyEpoch),
+		Ratchet:      s.ratchet,
+		Payload: &pb.ChatMessage_Chunk{
+			Chunk: &pb.FileChunk{
+				FileId:      s.fileID,
+				Filename:    s.filename,
+				ChunkIndex:  int32(s.sent),
+				TotalChunks: int32(s.totalChunks),
+				ChunkData:   chunkData,
+			},
+		},
+	}); err != nil {
+		return fmt.Errorf("sending file chunk %d: %w", s.sent, err)
+	}
+
+	s.sent++
+	s.progress(s.sent, s.totalChunks)
+	return nil
+}
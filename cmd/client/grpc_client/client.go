@@ -3,59 +3,74 @@ package grpc_client
 import "C"
 import (
 	dh "CryptoMessenger/algorithm/diffie_hellman"
-	"CryptoMessenger/algorithm/rc5"
-	"CryptoMessenger/algorithm/rc6"
 	"CryptoMessenger/algorithm/symmetric"
 	"CryptoMessenger/cmd/client/domain"
 	"CryptoMessenger/cmd/client/pkg"
 	pb "CryptoMessenger/proto/chatpb"
 	"context"
+	"crypto/ed25519"
 	"crypto/rand"
 	"encoding/base64"
+	"encoding/binary"
 	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/google/uuid"
 	"google.golang.org/grpc"
-	"google.golang.org/grpc/codes"
-	"google.golang.org/grpc/credentials/insecure"
-	"google.golang.org/grpc/metadata"
-	"google.golang.org/grpc/status"
-	"google.golang.org/protobuf/types/known/emptypb"
 	"google.golang.org/protobuf/types/known/timestamppb"
 	"io"
-	"io/ioutil"
 	"log"
 	"log/slog"
 	"math/big"
 	"os"
 	"path/filepath"
-	"strings"
 	"sync"
 	"time"
 )
 
+// defaultKeyLength — длина ключа комнаты, если она не задана при создании (старые комнаты).
+const defaultKeyLength = 32
+
 type ChatClient struct {
 	conn          *grpc.ClientConn
 	client        pb.ChatServiceClient
-	Messages      sync.Map
 	username      string
 	UserID        string
-	authToken     string
 	CipherContext sync.Map
+
+	tokenMu      sync.Mutex
+	authToken    string
+	refreshToken string
+	tokenExpiry  time.Time
+
+	identityKey ed25519.PrivateKey
+	pinsMu      sync.Mutex
+	rekeyMu     sync.Mutex
+	ratchetMu   sync.Mutex
+	prekeysMu   sync.Mutex
+
+	keystoreMu sync.Mutex
+	keys       *keystore
+
+	events chan domain.Event
+	cursor eventCursor
 }
 
-func NewChatClient(serverAddr string) (*ChatClient, error) {
-	conn, err := grpc.Dial(serverAddr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+func NewChatClient(serverAddr string, transport TransportConfig) (*ChatClient, error) {
+	creds, err := transport.credentials()
+	if err != nil {
+		return nil, err
+	}
+	conn, err := grpc.Dial(serverAddr, grpc.WithTransportCredentials(creds))
 	if err != nil {
 		return nil, err
 	}
 	return &ChatClient{
 		conn:          conn,
 		client:        pb.NewChatServiceClient(conn),
-		Messages:      sync.Map{},
 		CipherContext: sync.Map{},
+		events:        make(chan domain.Event, eventsBuffer),
 	}, nil
 }
 
@@ -73,7 +88,7 @@ func (c *ChatClient) RegisterUser(username, password string) error {
 		return err
 	}
 
-	c.authToken = resp.Token
+	c.setTokens(resp.Token, resp.RefreshToken, resp.ExpiresAt)
 	c.username = username
 	c.UserID = resp.UserID
 
@@ -99,7 +114,6 @@ func (c *ChatClient) RegisterUser(username, password string) error {
 	if err = enc.Encode(&info); err != nil {
 		return fmt.Errorf("failed to write user info: %w", err)
 	}
-
 	return nil
 }
 
@@ -128,98 +142,162 @@ func (c *ChatClient) LoginUser(username, password string) error {
 		return err
 	}
 
-	c.authToken = resp.Token
+	c.setTokens(resp.Token, resp.RefreshToken, resp.ExpiresAt)
 	c.UserID = resp.UserID
 	c.username = username
-
 	return nil
 }
 
 func (c *ChatClient) CreateChat(info domain.Chat) error {
-	ctx, cancel := context.WithTimeout(c.AuthenticatedContext(), time.Second*3)
+	ctx, cancel := context.WithTimeout(c.AuthenticatedContext(), time.Second*5)
 	defer cancel()
 
 	if info.Receiver == c.username {
 		return errors.New("creating a chat with yourself is not allowed")
 	}
 
-	dhParams, err := c.generateDHParams(2048)
+	exchange, err := newKeyExchange(info.KeyAgreement, dh.DefaultGroup)
 	if err != nil {
 		return err
 	}
 
-	iv := make([]byte, 16)
-	if _, err = rand.Read(iv); err != nil {
-		return fmt.Errorf("could not generate IV: %w", err)
+	cipherInfo, ok := symmetric.LookupCipher(info.Algorithm)
+	if !ok {
+		return fmt.Errorf("unsupported algorithm: %s", info.Algorithm)
+	}
+	params := symmetric.RoomParams(info.WordSize, info.Rounds)
+	if err = symmetric.ValidateSuite(info.Algorithm, info.Mode, info.Padding, params, info.KeyLength); err != nil {
+		return err
+	}
+	blockSize, err := cipherInfo.BlockSizeFor(params)
+	if err != nil {
+		return err
 	}
 
-	randomDelta := make([]byte, 16)
+	// Если собеседник опубликовал предключи, ключ комнаты вычисляется сразу,
+	// не дожидаясь ответа на приглашение.
+	bundle, err := c.prekeyBundle(ctx, info.Receiver, exchange)
+	if err != nil {
+		return err
+	}
+	if bundle == nil && info.FirstMessage != "" {
+		return ErrNoPrekeys
+	}
+
+	randomDelta := make([]byte, blockSize)
 	if _, err = rand.Read(randomDelta); err != nil {
 		return fmt.Errorf("failed to generate random delta: %w", err)
 	}
 
-	info.IV = hex.EncodeToString(iv)
+	// IV комнаты больше не генерируется: CipherContext выбирает новый IV для каждого сообщения.
 	info.RandomDelta = hex.EncodeToString(randomDelta)
 
-	roomID, err := c.createAndInvite(ctx, info, dhParams)
+	roomID, err := c.createRoom(ctx, info)
 	if err != nil {
 		return err
 	}
 
 	roomInfo := domain.RoomInfo{
-		ID:          roomID,
-		Name:        info.ChatName,
-		MyClient:    c.username,
-		Companion:   info.Receiver,
-		CipherKey:   "",
-		P:           dhParams.Prime.Text(16),
-		G:           dhParams.G.Text(16),
-		PrivateKey:  dhParams.PrivateKey.Text(16),
-		MyPublicKey: dhParams.MyPublicKey.Text(16),
-		Algorithm:   info.Algorithm,
-		CipherMode:  info.Mode,
-		Padding:     info.Padding,
-		RandomDelta: info.RandomDelta,
-		IV:          info.IV,
+		ID:           roomID,
+		Name:         info.ChatName,
+		MyClient:     c.username,
+		Companion:    info.Receiver,
+		CipherKey:    "",
+		KeyAgreement: exchange.KeyAgreement,
+		DHGroup:      exchange.Group,
+		P:            exchange.P,
+		G:            exchange.G,
+		PrivateKey:   exchange.PrivateKey,
+		MyPublicKey:  exchange.PublicKey,
+		Algorithm:    info.Algorithm,
+		CipherMode:   info.Mode,
+		Padding:      info.Padding,
+		RandomDelta:  info.RandomDelta,
+		IV:           info.IV,
+		WordSize:     info.WordSize,
+		Rounds:       info.Rounds,
+		KeyLength:    info.KeyLength,
+		KDFVersion:   domain.KDFHKDF,
+
+		DoubleRatchet: info.DoubleRatchet,
 	}
 
-	return c.saveRoomInfo(roomInfo)
-}
+	invitation := &pb.Invitation{
+		ReceiverName: info.Receiver,
+		RoomId:       roomID,
+		RoomName:     info.ChatName,
+		Algorithm:    info.Algorithm,
+		Mode:         info.Mode,
+		Padding:      info.Padding,
+		Iv:           info.IV,
+		RandomDelta:  info.RandomDelta,
+		KeyAgreement: exchange.KeyAgreement,
+		DhGroup:      exchange.Group,
+		PublicKey:    exchange.PublicKey,
+		WordSize:     int32(info.WordSize),
+		Rounds:       int32(info.Rounds),
+		KeyLength:    int32(info.KeyLength),
 
-func (c *ChatClient) createAndInvite(ctx context.Context, info domain.Chat, params *domain.DiffieHellmanParams) (string, error) {
+		DoubleRatchet: info.DoubleRatchet,
+	}
 
+	if bundle != nil {
+		if roomInfo.CipherKey, err = prekeyCipherKey(exchange, bundle); err != nil {
+			return fmt.Errorf("prekeys of %s: %w", info.Receiver, err)
+		}
+		roomInfo.OtherPublicKey = bundle.SignedPrekey.PublicKey
+		invitation.SignedPrekeyId = bundle.SignedPrekey.KeyId
+		if bundle.OneTimePrekey != nil {
+			invitation.OneTimePrekeyId = bundle.OneTimePrekey.KeyId
+		}
+	}
+
+	if err = c.saveRoomInfo(roomInfo); err != nil {
+		return err
+	}
+
+	var firstMessage domain.StoredMessage
+	if info.FirstMessage != "" {
+		if invitation.FirstMessage, firstMessage, err = c.sealText(roomInfo, info.FirstMessage); err != nil {
+			_ = c.removeRoom(roomInfo)
+			return err
+		}
+	}
+
+	if err = c.signInvitation(invitation); err != nil {
+		_ = c.removeRoom(roomInfo)
+		return err
+	}
+	if _, err = c.client.InviteUser(ctx, invitation); err != nil {
+		_ = c.removeRoom(roomInfo)
+		return fmt.Errorf("could not invite user: %w", err)
+	}
+
+	if info.FirstMessage != "" {
+		if err = c.appendToChatFile(roomID, firstMessage); err != nil {
+			return fmt.Errorf("save to chat file: %w", err)
+		}
+		c.notifyRoom(roomID)
+	}
+	return nil
+}
+
+func (c *ChatClient) createRoom(ctx context.Context, info domain.Chat) (string, error) {
 	resp, err := c.client.CreateRoom(ctx, &pb.CreateRoomRequest{
 		RoomName:    info.ChatName,
 		Algorithm:   info.Algorithm,
 		Mode:        info.Mode,
 		Padding:     info.Padding,
-		Prime:       params.Prime.Text(16),
 		Iv:          info.IV,
 		RandomDelta: info.RandomDelta,
+		WordSize:    int32(info.WordSize),
+		Rounds:      int32(info.Rounds),
+		KeyLength:   int32(info.KeyLength),
 	})
 	if err != nil {
 		return "", fmt.Errorf("could not create room: %w", err)
 	}
-	roomID := resp.RoomId
-
-	_, err = c.client.InviteUser(ctx, &pb.Invitation{
-		ReceiverName: info.Receiver,
-		RoomId:       roomID,
-		RoomName:     info.ChatName,
-		Algorithm:    info.Algorithm,
-		Mode:         info.Mode,
-		Padding:      info.Padding,
-		Iv:           info.IV,
-		RandomDelta:  info.RandomDelta,
-		Prime:        params.Prime.Text(16),
-		G:            params.G.Text(16),
-		PublicKey:    params.MyPublicKey.Text(16),
-	})
-	if err != nil {
-		return "", fmt.Errorf("could not invite user: %w", err)
-	}
-
-	return roomID, nil
+	return resp.RoomId, nil
 }
 
 func (c *ChatClient) saveRoomInfo(roomInfo domain.RoomInfo) error {
@@ -228,44 +306,40 @@ func (c *ChatClient) saveRoomInfo(roomInfo domain.RoomInfo) error {
 		return fmt.Errorf("could not create dir %s: %w", dir, err)
 	}
 
-	chatPath := filepath.Join(dir, "chat.jsonl")
-	f, err := os.Create(chatPath)
+	chatPath := c.chatHistoryPath(roomInfo.ID)
+	f, err := os.OpenFile(chatPath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o600)
 	if err != nil {
 		return fmt.Errorf("could not create file %s: %w", chatPath, err)
 	}
 	defer f.Close()
 
-	roomInfoPath := filepath.Join(dir, "room_info.json")
-	f, err = os.Create(roomInfoPath)
-	if err != nil {
-		return fmt.Errorf("could not create file %s: %w", roomInfoPath, err)
-	}
-	defer f.Close()
-
-	enc := json.NewEncoder(f)
-	enc.SetIndent("", "  ")
-	if err = enc.Encode(&roomInfo); err != nil {
-		return fmt.Errorf("could not write JSON: %w", err)
-	}
-	return nil
+	return c.writeRoomInfoToDisk(roomInfo)
 }
 
-func (c *ChatClient) ReceiveInvitation() (domain.Invitation, error) {
-	ctx, cancel := context.WithTimeout(c.AuthenticatedContext(), time.Second*4)
-	defer cancel()
-
-	invitation, err := c.client.ReceiveInvitation(ctx, &emptypb.Empty{})
-
-	if err != nil {
-		st, ok := status.FromError(err)
-		if ok && st.Code() == codes.NotFound {
-			return domain.Invitation{}, nil
+// receiveInvitation сохраняет комнату из приглашения. Пустой результат означает,
+// что приглашение отброшено.
+func (c *ChatClient) receiveInvitation(ctx context.Context, invitation *pb.Invitation) (domain.Invitation, error) {
+	// Приглашение без верной подписи отправителя отбрасывается: открытый ключ в нём
+	// мог подменить сервер. Если ключ идентичности просто не удалось получить, приглашение
+	// остаётся в очереди до следующей попытки.
+	if err := c.verifyInvitation(ctx, invitation); err != nil {
+		if !isForgedKeyExchange(err) {
+			return domain.Invitation{}, err
 		}
-		if errors.Is(err, ctx.Err()) {
-			return domain.Invitation{}, nil
+		if ackErr := c.ackEvent(ctx, invitation.MessageId); ackErr != nil {
+			log.Printf("could not ack invitation: %v", ackErr)
 		}
-		return domain.Invitation{}, err
+		return domain.Invitation{}, fmt.Errorf("invitation from %s rejected: %w", invitation.SenderName, err)
+	}
 
+	// Приглашения с собственным простым вместо именованной группы не принимаются:
+	// для них нельзя проверить открытый ключ собеседника.
+	exchange, err := invitationKeyExchange(invitation)
+	if err != nil {
+		if ackErr := c.ackEvent(ctx, invitation.MessageId); ackErr != nil {
+			log.Printf("could not ack invitation: %v", ackErr)
+		}
+		return domain.Invitation{}, fmt.Errorf("invitation from %s rejected: %w", invitation.SenderName, err)
 	}
 
 	roomInfo := domain.RoomInfo{
@@ -274,8 +348,10 @@ func (c *ChatClient) ReceiveInvitation() (domain.Invitation, error) {
 		MyClient:       c.username,
 		Companion:      invitation.SenderName,
 		CipherKey:      "",
-		P:              invitation.Prime,
-		G:              invitation.G,
+		KeyAgreement:   exchange.KeyAgreement,
+		DHGroup:        exchange.Group,
+		P:              exchange.P,
+		G:              exchange.G,
 		PrivateKey:     "",
 		OtherPublicKey: invitation.PublicKey,
 		Algorithm:      invitation.Algorithm,
@@ -283,58 +359,110 @@ func (c *ChatClient) ReceiveInvitation() (domain.Invitation, error) {
 		Padding:        invitation.Padding,
 		RandomDelta:    invitation.RandomDelta,
 		IV:             invitation.Iv,
+		WordSize:       int(invitation.WordSize),
+		Rounds:         int(invitation.Rounds),
+		KeyLength:      int(invitation.KeyLength),
+		KDFVersion:     domain.KDFHKDF,
+
+		DoubleRatchet: invitation.DoubleRatchet,
 	}
 
-	err = c.saveRoomInfo(roomInfo)
+	// Приглашение на наши предключи: ключ комнаты вычисляется сразу
+	if invitation.SignedPrekeyId != "" {
+		if err = c.acceptPrekeyInvitation(&roomInfo, invitation); err != nil {
+			if ackErr := c.ackEvent(ctx, invitation.MessageId); ackErr != nil {
+				log.Printf("could not ack invitation: %v", ackErr)
+			}
+			return domain.Invitation{}, fmt.Errorf("invitation from %s rejected: %w", invitation.SenderName, err)
+		}
+	}
 
-	_, err = c.client.AckEvent(ctx, &pb.AckRequest{MessageId: invitation.MessageId})
-	if err != nil {
-		log.Printf("could not ack invitation: %v", err)
+	if err = c.saveRoomInfo(roomInfo); err != nil {
 		return domain.Invitation{}, err
 	}
 
-	return domain.Invitation{
+	result := domain.Invitation{
 		Sender:   invitation.SenderName,
 		RoomID:   invitation.RoomId,
 		RoomName: invitation.RoomName,
-	}, nil
+	}
+
+	if invitation.SignedPrekeyId != "" {
+		if invitation.FirstMessage != nil {
+			if result.FirstMessage, err = c.receiveFirstMessage(roomInfo, invitation.FirstMessage); err != nil {
+				slog.Error("could not open first message", "room", roomInfo.ID, "error", err)
+			}
+		}
+		if invitation.OneTimePrekeyId != "" {
+			if err = c.consumeOneTimePrekey(invitation.OneTimePrekeyId); err != nil {
+				slog.Error("could not remove one-time prekey", "error", err)
+			}
+			if err = c.ensurePrekeys(ctx); err != nil {
+				slog.Warn("could not replenish prekeys", "err", err)
+			}
+		}
+	}
+
+	err = c.ackEvent(ctx, invitation.MessageId)
+	if err != nil {
+		log.Printf("could not ack invitation: %v", err)
+		return domain.Invitation{}, err
+	}
+
+	return result, nil
+}
+
+// receiveFirstMessage расшифровывает сообщение, пришедшее вместе с приглашением,
+// и сохраняет его в истории комнаты.
+func (c *ChatClient) receiveFirstMessage(info domain.RoomInfo, msg *pb.ChatMessage) (string, error) {
+	if msg.ChatId != info.ID || msg.SenderName != info.Companion {
+		return "", fmt.Errorf("first message %s does not belong to room %s", msg.MessageId, info.ID)
+	}
+	byteText, err := c.openText(info, msg)
+	if err != nil {
+		return "", err
+	}
+
+	storedMsg := domain.StoredMessage{
+		MessageID: msg.MessageId,
+		Sender:    msg.SenderName,
+		Type:      "text",
+		Content:   string(byteText),
+		Timestamp: msg.Timestamp.AsTime(),
+	}
+	if err = c.appendToChatFile(info.ID, storedMsg); err != nil {
+		return "", fmt.Errorf("write to chat file: %w", err)
+	}
+	c.notifyRoom(info.ID)
+	return storedMsg.Content, nil
 }
 
 func (c *ChatClient) ReactToInvitation(invitation domain.Invitation, accepted bool) error {
 	ctx, cancel := context.WithTimeout(c.AuthenticatedContext(), 3*time.Second)
 	defer cancel()
 
-	publicKey := new(big.Int)
+	var publicKey string
 
 	if accepted {
 
-		params, err := c.loadDHParamsFromDisk(invitation.RoomID, false)
-		if err != nil {
-			return fmt.Errorf("could not load DH params: %w", err)
-		}
-
-		privateKey, err := dh.GeneratePrivateKey(params.Prime)
+		info, err := c.loadRoomInfoFromDisk(invitation.RoomID)
 		if err != nil {
-			return fmt.Errorf("could not generate private key: %v", err)
-		}
-
-		cipherKey := dh.GenerateSharedKey(privateKey, params.OtherPublicKey, params.Prime)
-
-		publicKey = dh.GeneratePublicKey(params.G, privateKey, params.Prime)
-
-		if err = c.updateRoomInfoOnDisk(invitation.RoomID, cipherKey.Text(16), domain.CipherKey); err != nil {
-			return fmt.Errorf("could not update room info on disk: %w", err)
-		}
-		if err = c.updateRoomInfoOnDisk(invitation.RoomID, publicKey.Text(16), domain.MyPublicKey); err != nil {
-			return fmt.Errorf("could not update room info on disk: %w", err)
+			return fmt.Errorf("could not load room info: %w", err)
 		}
-		if err = c.updateRoomInfoOnDisk(invitation.RoomID, privateKey.Text(16), domain.PrivateKey); err != nil {
-			return fmt.Errorf("could not update room info on disk: %w", err)
+		// Ключ комнаты из предключей уже вычислен, ответ только сообщает о согласии
+		if info.CipherKey == "" {
+			if publicKey, err = c.acceptKeyExchange(info); err != nil {
+				return fmt.Errorf("invitation from %s: %w", invitation.Sender, err)
+			}
 		}
+	}
 
+	reaction := &pb.InvitationReaction{ReceiverName: invitation.Receiver, RoomId: invitation.RoomID, PublicKey: publicKey, Accepted: accepted}
+	if err := c.signReaction(reaction); err != nil {
+		return err
 	}
 
-	_, err := c.client.ReactToInvitation(ctx, &pb.InvitationReaction{ReceiverName: invitation.Receiver, RoomId: invitation.RoomID, PublicKey: publicKey.Text(16), Accepted: accepted})
+	_, err := c.client.ReactToInvitation(ctx, reaction)
 	if err != nil {
 		return fmt.Errorf("could not react to invitation: %v", err)
 	}
@@ -342,28 +470,48 @@ func (c *ChatClient) ReactToInvitation(invitation domain.Invitation, accepted bo
 	return nil
 }
 
-func (c *ChatClient) ReceiveInvitationResponse() (domain.Invitation, error) {
-	ctx, cancel := context.WithTimeout(c.AuthenticatedContext(), time.Second*4)
-	defer cancel()
+// acceptKeyExchange отвечает на открытый ключ из приглашения своим и сохраняет ключ комнаты.
+func (c *ChatClient) acceptKeyExchange(info domain.RoomInfo) (string, error) {
+	exchange, err := newKeyExchange(info.KeyAgreement, info.DHGroup)
+	if err != nil {
+		return "", err
+	}
 
-	reaction, err := c.client.ReceiveInvitationReaction(ctx, &emptypb.Empty{})
+	cipherKey, err := computeCipherKey(info, exchange.PrivateKey, info.OtherPublicKey)
 	if err != nil {
-		st, ok := status.FromError(err)
-		if ok && st.Code() == codes.NotFound {
-			return domain.Invitation{}, nil
-		}
-		if errors.Is(err, ctx.Err()) {
-			return domain.Invitation{}, nil
-		}
-		return domain.Invitation{}, err
+		return "", err
+	}
+
+	if err = c.updateRoomInfoOnDisk(info.ID, cipherKey, domain.CipherKey); err != nil {
+		return "", fmt.Errorf("could not update room info on disk: %w", err)
 	}
+	if err = c.updateRoomInfoOnDisk(info.ID, exchange.PublicKey, domain.MyPublicKey); err != nil {
+		return "", fmt.Errorf("could not update room info on disk: %w", err)
+	}
+	if err = c.updateRoomInfoOnDisk(info.ID, exchange.PrivateKey, domain.PrivateKey); err != nil {
+		return "", fmt.Errorf("could not update room info on disk: %w", err)
+	}
+	return exchange.PublicKey, nil
+}
 
-	_, err = c.client.AckEvent(ctx, &pb.AckRequest{MessageId: reaction.MessageId})
+// receiveInvitationResponse завершает согласование ключа по ответу на приглашение.
+func (c *ChatClient) receiveInvitationResponse(ctx context.Context, reaction *pb.InvitationReaction) (domain.Invitation, error) {
+	verifyErr := c.verifyReaction(ctx, reaction)
+	if verifyErr != nil && !isForgedKeyExchange(verifyErr) {
+		return domain.Invitation{}, verifyErr
+	}
+
+	err := c.ackEvent(ctx, reaction.MessageId)
 	if err != nil {
 		log.Printf("could not ack invitation: %v", err)
 		return domain.Invitation{}, err
 	}
 
+	// Поддельный ответ (в том числе отказ) не меняет состояние комнаты.
+	if verifyErr != nil {
+		return domain.Invitation{}, fmt.Errorf("response from %s rejected: %w", reaction.SenderName, verifyErr)
+	}
+
 	slog.Info("Acked message: %v", reaction.MessageId)
 
 	if !reaction.Accepted {
@@ -377,19 +525,24 @@ func (c *ChatClient) ReceiveInvitationResponse() (domain.Invitation, error) {
 		}, nil
 	}
 
-	dhParams, err := c.loadDHParamsFromDisk(reaction.RoomId, true)
+	info, err := c.loadRoomInfoFromDisk(reaction.RoomId)
 	if err != nil {
-		return domain.Invitation{}, err
+		return domain.Invitation{}, fmt.Errorf("could not load room info: %w", err)
 	}
-
-	otherPublicKey, ok := new(big.Int).SetString(reaction.PublicKey, 16)
-	if !ok {
-		return domain.Invitation{}, fmt.Errorf("invalid public key")
+	// Комната создана на предключи собеседника: ключ уже есть
+	if info.CipherKey != "" {
+		return domain.Invitation{
+			Sender:   reaction.SenderName,
+			Accepted: true,
+		}, nil
 	}
 
-	cipherKey := dh.GenerateSharedKey(dhParams.PrivateKey, otherPublicKey, dhParams.Prime)
+	cipherKey, err := computeCipherKey(info, info.PrivateKey, reaction.PublicKey)
+	if err != nil {
+		return domain.Invitation{}, fmt.Errorf("response from %s: %w", reaction.SenderName, err)
+	}
 
-	if err = c.updateRoomInfoOnDisk(reaction.RoomId, cipherKey.Text(16), domain.CipherKey); err != nil {
+	if err = c.updateRoomInfoOnDisk(reaction.RoomId, cipherKey, domain.CipherKey); err != nil {
 		slog.Error("updateRoomInfoOnDisk 1")
 		return domain.Invitation{}, fmt.Errorf("could not update room info on disk: %w", err)
 	}
@@ -404,27 +557,6 @@ func (c *ChatClient) ReceiveInvitationResponse() (domain.Invitation, error) {
 	}, nil
 }
 
-func (c *ChatClient) generateDHParams(bits int) (*domain.DiffieHellmanParams, error) {
-	prime, err := dh.GeneratePrime(bits)
-	if err != nil {
-		return nil, fmt.Errorf("could not generate prime: %w", err)
-	}
-	g := big.NewInt(2)
-
-	privateKey, err := dh.GeneratePrivateKey(prime)
-	if err != nil {
-		return nil, fmt.Errorf("could not generate private key: %w", err)
-	}
-	publicKey := dh.GeneratePublicKey(g, privateKey, prime)
-
-	return &domain.DiffieHellmanParams{
-		Prime:       prime,
-		G:           g,
-		PrivateKey:  privateKey,
-		MyPublicKey: publicKey,
-	}, nil
-}
-
 func (c *ChatClient) SendMessage(cancelContext context.Context, roomID, text, filePath string, progressFunc func(done, total int)) error {
 	ctx, cancel := context.WithTimeout(c.AuthenticatedContext(), 8*time.Second)
 	defer cancel()
@@ -442,122 +574,108 @@ func (c *ChatClient) SendMessage(cancelContext context.Context, roomID, text, fi
 		return fmt.Errorf("comrad haven't accepted invitation yet")
 	}
 
-	var cipherContext *symmetric.CipherContext
-
-	cipherCtxRaw, ok := c.CipherContext.Load(roomID)
-	if !ok {
-		cipherContext, err = c.newRoomCipher(info)
-		if err != nil {
-			return fmt.Errorf("could not create cipher context: %w", err)
-		}
-		c.CipherContext.Store(roomID, cipherContext)
-	} else {
-		cipherContext, ok = cipherCtxRaw.(*symmetric.CipherContext)
-		if !ok {
-			return fmt.Errorf("invalid cipher context type for room %s", roomID)
-		}
-	}
-
-	messageID := uuid.New().String()
-	timestamp := time.Now()
-
-	var storedMsg domain.StoredMessage
-
 	if text != "" {
-		byteText, err := cipherContext.Encrypt([]byte(text), 0, 1)
+		msg, storedMsg, err := c.sealText(info, text)
 		if err != nil {
-			return fmt.Errorf("could not encrypt message: %w", err)
-		}
-		_, err = c.client.SendMessage(ctx, &pb.ChatMessage{
-			MessageId:    messageID,
-			ChatId:       roomID,
-			ReceiverName: info.Companion,
-			Timestamp:    timestamppb.New(timestamp),
-			Payload: &pb.ChatMessage_Text{
-				Text: &pb.TextPayload{
-					Content: base64.StdEncoding.EncodeToString(byteText),
-				},
-			},
-		})
-		if err != nil {
-			return fmt.Errorf("sending text message: %w", err)
+			return err
 		}
-
-		storedMsg = domain.StoredMessage{
-			MessageID: messageID,
-			Sender:    info.MyClient,
-			Type:      "text",
-			Content:   text,
-			Timestamp: timestamp,
+		if _, err = c.client.SendMessage(ctx, msg); err != nil {
+			return fmt.Errorf("sending text message: %w", err)
 		}
 
 		if err = c.appendToChatFile(roomID, storedMsg); err != nil {
 			return fmt.Errorf("save to chat file: %w", err)
 		}
 
-		c.Messages.Store(info.ID, struct{}{})
+		c.notifyRoom(info.ID)
 	}
 
 	if filePath != "" {
-		encryptedPath := filepath.Join(filepath.Dir(filePath), "encrypted_"+filepath.Base(filePath))
-		if err := cipherContext.EncryptFile(cancelContext, filePath, encryptedPath, progressFunc); err != nil {
-			return fmt.Errorf("could not encrypt file: %w", err)
-		}
-
-		encryptedFile, err := os.Open(encryptedPath)
+		inputFile, err := os.Open(filePath)
 		if err != nil {
-			return fmt.Errorf("open encrypted file: %w", err)
+			return fmt.Errorf("open file: %w", err)
 		}
-		defer encryptedFile.Close()
+		defer inputFile.Close()
 
-		infoStat, err := encryptedFile.Stat()
+		infoStat, err := inputFile.Stat()
 		if err != nil {
-			return fmt.Errorf("stat encrypted file: %w", err)
+			return fmt.Errorf("stat file: %w", err)
 		}
-		fileSize := infoStat.Size()
-
-		if fileSize == 0 {
+		if infoStat.Size() == 0 {
 			return domain.EmptyFileError
 		}
 
+		cipherContext, header, err := c.messageCipher(info)
+		if err != nil {
+			return fmt.Errorf("could not create cipher context: %w", err)
+		}
+
 		const chunkSize = 1024 * 256 // 256KB
-		totalChunks := int((fileSize + chunkSize - 1) / chunkSize)
+		encryptedSize := cipherContext.EncryptedSize(infoStat.Size())
+		totalChunks := int((encryptedSize + chunkSize - 1) / chunkSize)
 
 		filename := filepath.Base(filePath)
+		messageID := uuid.New().String()
 		fileID := uuid.New().String()
-		messageID = uuid.New().String()
+		timestamp := time.Now()
+
+		// Шифруем прямо в gRPC-фрагменты, без временного encrypted_ файла
+		sender := &fileChunkSender{
+			ctx:         cancelContext,
+			withAuth:    c.withAuth,
+			client:      c.client,
+			roomID:      roomID,
+			receiver:    info.Companion,
+			keyEpoch:    info.Epoch,
+			ratchet:     header,
+			fileID:      fileID,
+			filename:    filename,
+			timestamp:   timestamp,
+			chunkSize:   chunkSize,
+			totalChunks: totalChunks,
+			progress:    progressFunc,
+		}
 
-		for i := 0; ; i++ {
-			buf := make([]byte, chunkSize)
-			n, err := encryptedFile.Read(buf)
-			if err != nil && err != io.EOF {
-				return fmt.Errorf("read encrypted chunk: %w", err)
+		// Фрагменты файла приходят с разными ID сообщений, поэтому файл связывается с ID файла
+		aad := ratchetAdditionalData(messageAdditionalData(info.ID, info.MyClient, fileID), header)
+		encryptWriter, err := cipherContext.NewEncryptWriter(sender, infoStat.Size(), aad)
+		if err != nil {
+			return fmt.Errorf("could not encrypt file: %w", err)
+		}
+
+		buf := make([]byte, chunkSize)
+		for {
+			select {
+			case <-cancelContext.Done():
+				return cancelContext.Err()
+			default:
+			}
+
+			n, err := inputFile.Read(buf)
+			if n > 0 {
+				if _, err := encryptWriter.Write(buf[:n]); err != nil {
+					return fmt.Errorf("could not encrypt file: %w", err)
+				}
 			}
-			if n == 0 {
+			if err == io.EOF {
 				break
 			}
-			slog.Error("sent chunk:", i, totalChunks)
-
-			if _, err = c.client.SendMessage(ctx, &pb.ChatMessage{
-				MessageId:    uuid.New().String(),
-				ChatId:       roomID,
-				ReceiverName: info.Companion,
-				Timestamp:    timestamppb.New(timestamp),
-				Payload: &pb.ChatMessage_Chunk{
-					Chunk: &pb.FileChunk{
-						FileId:      fileID,
-						Filename:    filename,
-						ChunkIndex:  int32(i),
-						TotalChunks: int32(totalChunks),
-						ChunkData:   buf[:n],
-					},
-				},
-			}); err != nil {
-				return fmt.Errorf("sending file chunk %d: %w", i, err)
+			if err != nil {
+				return fmt.Errorf("read file: %w", err)
 			}
 		}
 
-		storedMsg = domain.StoredMessage{
+		if err = encryptWriter.Close(); err != nil {
+			return fmt.Errorf("could not encrypt file: %w", err)
+		}
+		if err = sender.Flush(); err != nil {
+			return err
+		}
+		if sender.sent != totalChunks {
+			return fmt.Errorf("sent %d file chunks, expected %d", sender.sent, totalChunks)
+		}
+
+		storedMsg := domain.StoredMessage{
 			MessageID:   messageID,
 			Sender:      info.MyClient,
 			Type:        "file",
@@ -571,54 +689,105 @@ func (c *ChatClient) SendMessage(cancelContext context.Context, roomID, text, fi
 			return fmt.Errorf("save to chat file: %w", err)
 		}
 
-		c.Messages.Store(info.ID, struct{}{})
-
-		if err = os.Remove(encryptedPath); err != nil {
-			return fmt.Errorf("remove encrypted file: %w", err)
-		}
+		c.notifyRoom(info.ID)
 	}
 
 	return nil
 }
 
-func (c *ChatClient) ReceiveMessage(roomID string, progressFunc func(done, total int)) error {
-	ctx, cancel := context.WithTimeout(c.AuthenticatedContext(), 10*time.Second)
-	defer cancel()
+// messageCipher возвращает контекст шифрования следующего сообщения. В комнате
+// с Double Ratchet каждое сообщение шифруется собственным ключом.
+func (c *ChatClient) messageCipher(info domain.RoomInfo) (*symmetric.AEADContext, *pb.RatchetHeader, error) {
+	if info.DoubleRatchet {
+		return c.ratchetEncryptor(info)
+	}
+	cipherContext, err := c.roomCipher(info, info.Epoch)
+	return cipherContext, nil, err
+}
 
-	resp, err := c.client.ReceiveMessage(ctx, &pb.ReceiveMessagesRequest{
-		ChatId: roomID,
-		UserId: c.UserID,
-	})
+// sealText шифрует текстовое сообщение для собеседника и возвращает его вместе
+// с записью для локальной истории.
+func (c *ChatClient) sealText(info domain.RoomInfo, text string) (*pb.ChatMessage, domain.StoredMessage, error) {
+	cipherContext, header, err := c.messageCipher(info)
 	if err != nil {
-		st, ok := status.FromError(err)
-		if ok && st.Code() == codes.NotFound {
-			return nil
-		}
-		if errors.Is(err, ctx.Err()) {
-			return nil
-		}
-		return fmt.Errorf("receive message: %w", err)
+		return nil, domain.StoredMessage{}, fmt.Errorf("could not create cipher context: %w", err)
 	}
 
-	info, err := c.loadRoomInfoFromDisk(roomID)
+	messageID := uuid.New().String()
+	timestamp := time.Now()
+	aad := ratchetAdditionalData(messageAdditionalData(info.ID, info.MyClient, messageID), header)
+	byteText, err := cipherContext.Encrypt([]byte(text), aad, 0, 1)
 	if err != nil {
-		return fmt.Errorf("could not load room info from disk: %w", err)
+		return nil, domain.StoredMessage{}, fmt.Errorf("could not encrypt message: %w", err)
+	}
+
+	msg := &pb.ChatMessage{
+		MessageId:    messageID,
+		ChatId:       info.ID,
+		ReceiverName: info.Companion,
+		Timestamp:    timestamppb.New(timestamp),
+		KeyEpoch:     int32(info.Epoch),
+		Ratchet:      header,
+		Payload: &pb.ChatMessage_Text{
+			Text: &pb.TextPayload{
+				Content: base64.StdEncoding.EncodeToString(byteText),
+			},
+		},
+	}
+	return msg, domain.StoredMessage{
+		MessageID: messageID,
+		Sender:    info.MyClient,
+		Type:      "text",
+		Content:   text,
+		Timestamp: timestamp,
+	}, nil
+}
+
+// openText расшифровывает текстовое сообщение собеседника.
+func (c *ChatClient) openText(info domain.RoomInfo, msg *pb.ChatMessage) ([]byte, error) {
+	text := msg.GetText()
+	if text == nil {
+		return nil, fmt.Errorf("message %s is not a text message", msg.MessageId)
+	}
+	cipherBytes, err := base64.StdEncoding.DecodeString(text.Content)
+	if err != nil {
+		return nil, fmt.Errorf("invalid base64 ciphertext: %w", err)
 	}
 
-	var cipherContext *symmetric.CipherContext
+	aad := ratchetAdditionalData(messageAdditionalData(info.ID, msg.SenderName, msg.MessageId), msg.Ratchet)
+	var byteText []byte
+	err = c.openMessage(info, msg, func(cipherContext *symmetric.AEADContext) error {
+		var err error
+		byteText, err = cipherContext.Decrypt(cipherBytes, aad, 0, 1)
+		return err
+	})
+	return byteText, err
+}
 
-	cipherCtxRaw, ok := c.CipherContext.Load(roomID)
-	if !ok {
-		cipherContext, err = c.newRoomCipher(info)
-		if err != nil {
-			return fmt.Errorf("could not create cipher context: %w", err)
-		}
-		c.CipherContext.Store(roomID, cipherContext)
-	} else {
-		cipherContext, ok = cipherCtxRaw.(*symmetric.CipherContext)
-		if !ok {
-			return fmt.Errorf("invalid cipher context type for room %s", roomID)
-		}
+// openMessage передаёт в fn контекст, которым зашифровано сообщение. Сообщение новой
+// эпохи может прийти раньше ответа на смену ключа: тогда возвращается ErrUnknownEpoch.
+func (c *ChatClient) openMessage(info domain.RoomInfo, msg *pb.ChatMessage, fn func(*symmetric.AEADContext) error) error {
+	if info.DoubleRatchet {
+		return c.ratchetDecrypt(info, int(msg.KeyEpoch), msg.Ratchet, fn)
+	}
+	cipherContext, err := c.roomCipher(info, int(msg.KeyEpoch))
+	if err != nil {
+		return fmt.Errorf("could not create cipher context: %w", err)
+	}
+	return fn(cipherContext)
+}
+
+// receiveMessage расшифровывает сообщение или очередной фрагмент файла и сохраняет его в истории.
+func (c *ChatClient) receiveMessage(ctx context.Context, resp *pb.ChatMessage, progressFunc func(done, total int)) error {
+	if !isUUID(resp.MessageId) || (resp.GetChunk() != nil && !isUUID(resp.GetChunk().FileId)) {
+		c.rejectMessage(ctx, resp.MessageId)
+		return fmt.Errorf("%w: %q", ErrInvalidMessageID, resp.MessageId)
+	}
+
+	roomID := resp.ChatId
+	info, err := c.loadRoomInfoFromDisk(roomID)
+	if err != nil {
+		return fmt.Errorf("could not load room info from disk: %w", err)
 	}
 
 	timestamp := resp.Timestamp.AsTime()
@@ -627,12 +796,12 @@ func (c *ChatClient) ReceiveMessage(roomID string, progressFunc func(done, total
 	switch payload := resp.Payload.(type) {
 
 	case *pb.ChatMessage_Text:
-		cipherBytes, err := base64.StdEncoding.DecodeString(payload.Text.Content)
-		if err != nil {
-			return fmt.Errorf("invalid base64 ciphertext: %w", err)
+		// Сообщение новой эпохи не подтверждается и будет доставлено повторно
+		byteText, err := c.openText(info, resp)
+		if errors.Is(err, symmetric.ErrAuthenticationFailed) || errors.Is(err, ErrRatchetMessageRejected) {
+			c.rejectMessage(ctx, messageID)
+			return fmt.Errorf("message %s rejected: %w", messageID, err)
 		}
-
-		byteText, err := cipherContext.Decrypt(cipherBytes, 0, 1)
 		if err != nil {
 			return fmt.Errorf("could not decrypt message: %w", err)
 		}
@@ -649,16 +818,16 @@ func (c *ChatClient) ReceiveMessage(roomID string, progressFunc func(done, total
 		}
 
 	case *pb.ChatMessage_Chunk:
-		slog.Error("Got chunk", payload.Chunk.ChunkIndex, payload.Chunk.TotalChunks)
-		dirPath := filepath.Join("cmd/client", "users", c.UserID, "chats", roomID, "files")
-		if err := os.MkdirAll(dirPath, 0755); err != nil {
+		slog.Debug("got chunk", "index", payload.Chunk.ChunkIndex, "total", payload.Chunk.TotalChunks)
+		dirPath := c.roomFilesDir(roomID)
+		if err := os.MkdirAll(dirPath, 0o700); err != nil {
 			return fmt.Errorf("mkdir for files: %w", err)
 		}
 
 		tempFileName := fmt.Sprintf("%s.part", payload.Chunk.FileId)
 		tempFilePath := filepath.Join(dirPath, tempFileName)
 
-		f, err := os.OpenFile(tempFilePath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+		f, err := os.OpenFile(tempFilePath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
 		if err != nil {
 			return fmt.Errorf("open file for chunk: %w", err)
 		}
@@ -669,17 +838,35 @@ func (c *ChatClient) ReceiveMessage(roomID string, progressFunc func(done, total
 		}
 
 		if int(payload.Chunk.ChunkIndex) == int(payload.Chunk.TotalChunks)-1 {
-			slog.Error("Here now")
-			finalFilePath := filepath.Join(dirPath, payload.Chunk.Filename)
-			decryptedFile, err := os.Create(finalFilePath)
+			// Файл сразу перешифровывается ключом хранения: открытый текст на диск не попадает
+			aead, err := c.storageCipher()
 			if err != nil {
-				return fmt.Errorf("create encrypted file: %w", err)
+				return err
+			}
+			finalFilePath := filepath.Join(dirPath, messageID)
+			storedFile, err := newStoredFileWriter(aead, finalFilePath)
+			if err != nil {
+				return err
 			}
-			defer decryptedFile.Close()
 
-			if err = cipherContext.DecryptFile(tempFilePath, finalFilePath, progressFunc); err != nil {
-				return fmt.Errorf("could not encrypt file: %w", err)
+			aad := ratchetAdditionalData(messageAdditionalData(info.ID, resp.SenderName, payload.Chunk.FileId), resp.Ratchet)
+			err = c.openMessage(info, resp, func(cipherContext *symmetric.AEADContext) error {
+				return cipherContext.DecryptFileTo(tempFilePath, aad, storedFile, progressFunc)
+			})
+			if errors.Is(err, symmetric.ErrAuthenticationFailed) || errors.Is(err, ErrRatchetMessageRejected) {
+				storedFile.Abort()
+				_ = os.Remove(tempFilePath)
+				c.rejectMessage(ctx, messageID)
+				return fmt.Errorf("file %s rejected: %w", payload.Chunk.Filename, err)
+			}
+			if err != nil {
+				storedFile.Abort()
+				return fmt.Errorf("could not decrypt file: %w", err)
 			}
+			if err = storedFile.Close(); err != nil {
+				return err
+			}
+			_ = os.Remove(tempFilePath)
 
 			storedMsg := domain.StoredMessage{
 				MessageID:   messageID,
@@ -687,6 +874,7 @@ func (c *ChatClient) ReceiveMessage(roomID string, progressFunc func(done, total
 				Type:        "file",
 				Filename:    payload.Chunk.Filename,
 				Filepath:    finalFilePath,
+				Encrypted:   true,
 				TotalChunks: int(payload.Chunk.TotalChunks),
 				Timestamp:   timestamp,
 			}
@@ -699,22 +887,27 @@ func (c *ChatClient) ReceiveMessage(roomID string, progressFunc func(done, total
 		return fmt.Errorf("unknown message payload")
 	}
 
-	if _, err = c.client.AckEvent(ctx, &pb.AckRequest{MessageId: messageID}); err != nil {
+	if err = c.ackEvent(ctx, messageID); err != nil {
 		return fmt.Errorf("ack event: %w", err)
 	}
 
-	c.Messages.Store(resp.ChatId, struct{}{})
+	c.notifyRoom(resp.ChatId)
 
 	return nil
 }
 
+// rejectMessage подтверждает сообщение, не прошедшее проверку тега, чтобы сервер не доставлял его повторно.
+func (c *ChatClient) rejectMessage(ctx context.Context, messageID string) {
+	if err := c.ackEvent(ctx, messageID); err != nil {
+		slog.Error("could not ack rejected message", "message_id", messageID, "error", err)
+	}
+}
+
 func (c *ChatClient) ClearMyChatHistory(chatID string) error {
-	chatPath := fmt.Sprintf("cmd/client/users/%s/chats/%s/chat.jsonl",
-		c.UserID, chatID)
-	filesPath := fmt.Sprintf("cmd/client/users/%s/chats/%s/files",
-		c.UserID, chatID)
+	chatPath := c.chatHistoryPath(chatID)
+	filesPath := c.roomFilesDir(chatID)
 
-	if err := os.WriteFile(chatPath, []byte{}, 0644); err != nil {
+	if err := os.WriteFile(chatPath, []byte{}, 0o600); err != nil {
 		return fmt.Errorf("ошибка очистки чата")
 	}
 	if err := os.RemoveAll(filesPath); err != nil && !os.IsNotExist(err) {
@@ -749,191 +942,178 @@ func (c *ChatClient) ClearChatHistory(chatID string) error {
 	return nil
 }
 
-func (c *ChatClient) ReceiveClearChatHistoryRequest(chatID string) error {
-	ctx, cancel := context.WithTimeout(c.AuthenticatedContext(), 10*time.Second)
-	defer cancel()
-
-	req, err := c.client.ReceiveChatHistoryRequest(ctx, &pb.ClearHistoryRequest{ChatId: chatID, UserId: c.UserID})
-	if err != nil {
-		st, ok := status.FromError(err)
-		if ok && st.Code() == codes.NotFound {
-			return domain.ErrNotFound
-		}
-		if errors.Is(err, ctx.Err()) {
-			return nil
-		}
-		slog.Error("err", err)
-		return fmt.Errorf("can't receive clear history request")
-	}
-	err = c.ClearMyChatHistory(req.ChatId)
+// receiveClearHistory очищает историю комнаты по просьбе собеседника.
+func (c *ChatClient) receiveClearHistory(ctx context.Context, req *pb.ClearHistoryRequest) error {
+	err := c.ClearMyChatHistory(req.ChatId)
 	if err != nil {
 		return fmt.Errorf("can't clear history")
 	}
 
-	_, err = c.client.AckEvent(ctx, &pb.AckRequest{MessageId: req.MessageId})
-	if err != nil {
+	if err = c.ackEvent(ctx, req.MessageId); err != nil {
 		return err
 	}
 
-	c.Messages.Store(chatID, struct{}{})
+	c.notifyRoom(req.ChatId)
 
 	return nil
 }
 
 func (c *ChatClient) appendToChatFile(chatID string, msg domain.StoredMessage) error {
-	path := fmt.Sprintf("cmd/client/users/%s/chats/%s/chat.jsonl",
-		c.UserID, chatID)
-
-	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	aead, err := c.storageCipher()
 	if err != nil {
-		return fmt.Errorf("open chat file: %w", err)
+		return err
+	}
+	line, err := sealHistoryLine(aead, chatID, msg)
+	if err != nil {
+		return err
 	}
-	defer f.Close()
 
-	data, err := json.Marshal(msg)
+	f, err := os.OpenFile(c.chatHistoryPath(chatID), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
 	if err != nil {
-		return fmt.Errorf("marshal message: %w", err)
+		return fmt.Errorf("open chat file: %w", err)
 	}
+	defer f.Close()
 
-	if _, err = f.Write(append(data, '\n')); err != nil {
+	if _, err = f.Write(line); err != nil {
 		return fmt.Errorf("write message: %w", err)
 	}
 	return nil
 }
 
-func (c *ChatClient) loadDHParamsFromDisk(roomID string, myClient bool) (*domain.DiffieHellmanParams, error) {
-	info, err := c.loadRoomInfoFromDisk(roomID)
+func (c *ChatClient) loadRoomInfoFromDisk(roomID string) (domain.RoomInfo, error) {
+	ks, err := c.keystore()
 	if err != nil {
-		return nil, fmt.Errorf("could not load room_info: %w", err)
+		return domain.RoomInfo{}, err
 	}
 
-	var otherPublicKey, privateKey *big.Int
-
-	p, ok := new(big.Int).SetString(info.P, 16)
-	if !ok {
-		return nil, fmt.Errorf("invalid prime hex: %s", info.P)
+	info, err := readRoomInfoFile(filepath.Join("cmd", "client", "users", c.UserID, "chats", roomID, "room_info.json"))
+	if err != nil {
+		return domain.RoomInfo{}, err
 	}
+	mergeRoomSecrets(&info, ks.room(roomID))
+	return info, nil
+}
 
-	g, ok := new(big.Int).SetString(info.G, 16)
-	if !ok {
-		return nil, fmt.Errorf("invalid G hex: %s", info.G)
+func (c *ChatClient) updateRoomInfoOnDisk(roomID, newField string, field int) error {
+	info, err := c.loadRoomInfoFromDisk(roomID)
+	if err != nil {
+		return err
 	}
 
-	if !myClient {
-		otherPublicKey, ok = new(big.Int).SetString(info.OtherPublicKey, 16)
-		if !ok {
-			return nil, fmt.Errorf("invalid OtherPublicKey hex: %s", info.OtherPublicKey)
+	switch field {
+	case domain.CipherKey:
+		info.CipherKey = newField
+	case domain.MyPublicKey:
+		if info.MyPublicKey != newField {
+			info.Verified = false
 		}
-	}
-
-	if myClient {
-		privateKey, ok = new(big.Int).SetString(info.PrivateKey, 16)
-		if !ok {
-			return nil, fmt.Errorf("invalid PrivateKey hex: %s", info.PrivateKey)
+		info.MyPublicKey = newField
+	case domain.PrivateKey:
+		info.PrivateKey = newField
+	case domain.OtherPublicKey:
+		if info.OtherPublicKey != newField {
+			info.Verified = false
 		}
+		info.OtherPublicKey = newField
 	}
 
-	return &domain.DiffieHellmanParams{
-		Prime:          p,
-		G:              g,
-		OtherPublicKey: otherPublicKey,
-		PrivateKey:     privateKey,
-	}, nil
+	return c.writeRoomInfoToDisk(info)
 }
 
-func (c *ChatClient) loadRoomInfoFromDisk(roomID string) (domain.RoomInfo, error) {
-	path := filepath.Join("cmd", "client", "users", c.UserID, "chats", roomID, "room_info.json")
-	data, err := ioutil.ReadFile(path)
+// writeRoomInfoToDisk сохраняет секреты комнаты в хранилище ключей,
+// а остальные поля — в room_info.json.
+func (c *ChatClient) writeRoomInfoToDisk(info domain.RoomInfo) error {
+	ks, err := c.keystore()
 	if err != nil {
-		return domain.RoomInfo{}, fmt.Errorf("could not read room_info.json: %w", err)
+		return err
 	}
 
-	var info domain.RoomInfo
-	if err = json.Unmarshal(data, &info); err != nil {
-		return domain.RoomInfo{}, fmt.Errorf("invalid JSON in room_info.json: %w", err)
+	stripped, secrets := splitRoomSecrets(info)
+	if err = ks.update(info.ID, func(stored *roomSecrets) {
+		secrets.Ratchet = stored.Ratchet
+		*stored = secrets
+	}); err != nil {
+		return err
 	}
-	return info, nil
+	return writeRoomInfoFile(filepath.Join("cmd", "client", "users", c.UserID, "chats", info.ID, "room_info.json"), stripped)
 }
 
-func (c *ChatClient) updateRoomInfoOnDisk(roomID, newField string, field int) error {
-	path := filepath.Join("cmd", "client", "users", c.UserID, "chats", roomID, "room_info.json")
-
-	data, err := ioutil.ReadFile(path)
+func readRoomInfoFile(path string) (domain.RoomInfo, error) {
+	data, err := os.ReadFile(path)
 	if err != nil {
-		return fmt.Errorf("could not read room_info.json: %w", err)
+		return domain.RoomInfo{}, fmt.Errorf("could not read room_info.json: %w", err)
 	}
+
 	var info domain.RoomInfo
 	if err = json.Unmarshal(data, &info); err != nil {
-		return fmt.Errorf("invalid JSON: %w", err)
-	}
-
-	switch field {
-	case domain.CipherKey:
-		info.CipherKey = newField
-	case domain.MyPublicKey:
-		info.MyPublicKey = newField
-	case domain.PrivateKey:
-		info.PrivateKey = newField
-	case domain.OtherPublicKey:
-		info.OtherPublicKey = newField
+		return domain.RoomInfo{}, fmt.Errorf("invalid JSON in room_info.json: %w", err)
 	}
+	return info, nil
+}
 
+func writeRoomInfoFile(path string, info domain.RoomInfo) error {
 	out, err := json.MarshalIndent(&info, "", "  ")
 	if err != nil {
 		return fmt.Errorf("could not marshal updated JSON: %w", err)
 	}
-	if err = ioutil.WriteFile(path, out, 0o600); err != nil {
+	if err = os.WriteFile(path, out, 0o600); err != nil {
 		return fmt.Errorf("could not write back room_info.json: %w", err)
 	}
 	return nil
 }
 
-func (c *ChatClient) AuthenticatedContext() context.Context {
-	md := metadata.New(map[string]string{
-		"authorization": "Bearer " + c.authToken,
-	})
-	return metadata.NewOutgoingContext(context.Background(), md)
+// messageAdditionalData связывает шифртекст с комнатой, отправителем и ID сообщения,
+// чтобы сервер не мог переслать его в другой чат или от чужого имени.
+func messageAdditionalData(roomID, sender, messageID string) []byte {
+	var aad []byte
+	for _, field := range []string{roomID, sender, messageID} {
+		aad = binary.BigEndian.AppendUint32(aad, uint32(len(field)))
+		aad = append(aad, field...)
+	}
+	return aad
 }
 
-func (c *ChatClient) newRoomCipher(info domain.RoomInfo) (*symmetric.CipherContext, error) {
-	tmp, err := hex.DecodeString(info.CipherKey)
+func (c *ChatClient) newRoomCipher(info domain.RoomInfo) (*symmetric.AEADContext, error) {
+	if info.LegacyKDF() {
+		// До HKDF ключом служили байты hex-записи общего ключа как есть
+		secret, err := hex.DecodeString(info.CipherKey)
+		if err != nil {
+			return nil, fmt.Errorf("invalid hex cipher key: %w", err)
+		}
+		return c.newCipherFromSecret(info, secret, nil, nil)
+	}
+
+	secret, prime, g, err := roomSecret(info)
 	if err != nil {
-		return nil, fmt.Errorf("invalid hex cipher key: %w", err)
+		return nil, err
 	}
+	return c.newCipherFromSecret(info, secret, prime, g)
+}
 
+// newCipherFromSecret строит контекст шифрования комнаты из секрета: общего ключа
+// эпохи или ключа отдельного сообщения Double Ratchet.
+func (c *ChatClient) newCipherFromSecret(info domain.RoomInfo, secret []byte, prime, g *big.Int) (*symmetric.AEADContext, error) {
 	iv, err := hex.DecodeString(info.IV)
 	if err != nil {
 		return nil, fmt.Errorf("invalid IV hex: %w", err)
 	}
 
-	key := make([]byte, 32)
-	copy(key, tmp)
-
-	var (
-		cipher    symmetric.CipherScheme
-		blockSize = 16
-		extra     []interface{}
-	)
+	keyLength := info.KeyLength
+	if keyLength == 0 {
+		keyLength = defaultKeyLength
+	}
 
-	switch strings.ToUpper(info.Algorithm) {
-	case "RC5":
-		cipher, err = rc5.NewRC5(64, 12, uint(len(key)), key)
-		if err != nil {
-			slog.Error("could not create RC5 block cipher: %w", err)
-			return nil, err
-		}
-		blockSize = 16
-	case "RC6":
-		cipher, err = rc6.NewRC6(key)
-		if err != nil {
-			slog.Error("could not create RC6 block cipher: %w", err)
-			return nil, err
-		}
-		blockSize = 16
-	default:
+	cipherInfo, ok := symmetric.LookupCipher(info.Algorithm)
+	if !ok {
 		return nil, fmt.Errorf("unsupported algorithm: %s", info.Algorithm)
 	}
 
+	params, err := cipherInfo.ResolveParams(symmetric.RoomParams(info.WordSize, info.Rounds))
+	if err != nil {
+		return nil, err
+	}
+	blockSize := cipherInfo.BlockSize(params)
+
 	mode, err := pkg.ParseCipherMode(info.CipherMode)
 	if err != nil {
 		return nil, err
@@ -944,14 +1124,61 @@ func (c *ChatClient) newRoomCipher(info domain.RoomInfo) (*symmetric.CipherConte
 		return nil, err
 	}
 
+	var (
+		extra       []interface{}
+		randomDelta []byte
+	)
 	if mode == symmetric.RandomDelta {
-		randomDelta, err := hex.DecodeString(info.RandomDelta)
+		randomDelta, err = hex.DecodeString(info.RandomDelta)
 		if err != nil {
 			return nil, fmt.Errorf("invalid RandomDelta hex: %w", err)
 		}
 		extra = []interface{}{"randomDelta", randomDelta}
 	}
 
+	if info.LegacyKDF() {
+		// Комнаты, созданные до HKDF: секрет дополняется нулями до длины ключа,
+		// MAC выводится из ключа шифра, а IV комнаты остаётся запасным для старых сообщений
+		key := make([]byte, keyLength)
+		copy(key, secret)
+		return newRoomAEAD(cipherInfo, params, key, nil, mode, padding, iv, blockSize, extra)
+	}
+
+	// Ключи выводятся из общего секрета и параметров комнаты, а не берутся из него напрямую
+	keys, err := dh.DeriveRoomKeys(secret, dh.Transcript{
+		RoomID:       info.ID,
+		KeyAgreement: info.KeyAgreement,
+		Algorithm:    cipherInfo.Name,
+		Mode:         info.CipherMode,
+		Padding:      info.Padding,
+		Params:       params,
+		KeyLength:    keyLength,
+		Prime:        prime,
+		G:            g,
+		IV:           iv,
+		RandomDelta:  randomDelta,
+		Epoch:        info.Epoch,
+	}, dh.KeyLengths{
+		Encryption: keyLength,
+		MAC:        symmetric.MACKeySize,
+		IVSeed:     blockSize,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not derive room keys: %w", err)
+	}
+
+	return newRoomAEAD(cipherInfo, params, keys.EncryptionKey, keys.MACKey, mode, padding, keys.IVSeed, blockSize, extra)
+}
+
+// newRoomAEAD собирает контекст шифрования комнаты; без macKey ключ MAC выводится из key.
+func newRoomAEAD(cipherInfo symmetric.CipherInfo, params symmetric.Params, key, macKey []byte,
+	mode symmetric.CipherMode, padding symmetric.PaddingMode, iv []byte, blockSize int, extra []interface{}) (*symmetric.AEADContext, error) {
+	cipher, _, err := cipherInfo.Create(key, params)
+	if err != nil {
+		slog.Error("could not create block cipher", "algorithm", cipherInfo.Name, "error", err)
+		return nil, err
+	}
+
 	ctx, err := symmetric.NewCipherContext(
 		key,
 		cipher,
@@ -964,5 +1191,17 @@ func (c *ChatClient) newRoomCipher(info domain.RoomInfo) (*symmetric.CipherConte
 	if err != nil {
 		return nil, fmt.Errorf("could not create symmetric context: %w", err)
 	}
-	return ctx, nil
+	ctx.SetAlgorithm(cipherInfo.ID)
+	ctx.SetCipherParams(params["w"], params["r"])
+
+	var aead *symmetric.AEADContext
+	if macKey == nil {
+		aead, err = symmetric.NewAEADContext(ctx)
+	} else {
+		aead, err = symmetric.NewAEADContextWithMACKey(ctx, macKey)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not create authenticated context: %w", err)
+	}
+	return aead, nil
 }
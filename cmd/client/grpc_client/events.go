@@ -0,0 +1,203 @@
+package grpc_client
+
+import (
+	"CryptoMessenger/cmd/client/domain"
+	pb "CryptoMessenger/proto/chatpb"
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+const (
+	eventsBuffer = 64
+	// eventTimeout ограничивает обработку одного события, включая расшифровку файла.
+	eventTimeout = 30 * time.Second
+
+	minResubscribeDelay = time.Second
+	maxResubscribeDelay = 30 * time.Second
+)
+
+// eventCursor помнит, до какого номера клиент обработал события без пропусков.
+// Событие, которое оставлено без подтверждения (например, сообщение новой эпохи),
+// держит курсор: после переподключения сервер доставит его снова.
+type eventCursor struct {
+	mu        sync.Mutex
+	lastAcked uint64
+	pending   map[string]uint64 // messageID → номер ещё не подтверждённого события
+}
+
+func (c *eventCursor) received(messageID string, sequence uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.pending == nil {
+		c.pending = make(map[string]uint64)
+	}
+	c.pending[messageID] = sequence
+}
+
+func (c *eventCursor) acked(messageID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	sequence, ok := c.pending[messageID]
+	if !ok {
+		return
+	}
+	delete(c.pending, messageID)
+	if sequence > c.lastAcked {
+		c.lastAcked = sequence
+	}
+}
+
+// resume — номер, с которого продолжить: все события до него включительно подтверждены.
+func (c *eventCursor) resume() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	resume := c.lastAcked
+	for _, sequence := range c.pending {
+		if sequence <= resume {
+			resume = sequence - 1
+		}
+	}
+	return resume
+}
+
+// Events — канал событий для UI. Заполняется, пока работает Subscribe.
+func (c *ChatClient) Events() <-chan domain.Event {
+	return c.events
+}
+
+// Subscribe держит поток событий сервера, пока не отменён ctx. События обрабатываются
+// по порядку; после обрыва поток открывается заново с последнего подтверждённого номера.
+func (c *ChatClient) Subscribe(ctx context.Context) {
+	delay := minResubscribeDelay
+	for {
+		received, err := c.receiveEvents(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		if received {
+			delay = minResubscribeDelay
+		}
+		slog.Warn("event stream interrupted", "error", err, "retry_in", delay)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+		delay = min(delay*2, maxResubscribeDelay)
+	}
+}
+
+// receiveEvents читает один поток до его обрыва и сообщает, пришло ли хоть одно событие.
+func (c *ChatClient) receiveEvents(ctx context.Context) (bool, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	stream, err := c.client.Subscribe(c.withAuth(ctx), &pb.SubscribeRequest{LastAckedSequence: c.cursor.resume()})
+	if err != nil {
+		return false, fmt.Errorf("subscribe: %w", err)
+	}
+
+	received := false
+	for {
+		event, err := stream.Recv()
+		if err != nil {
+			return received, fmt.Errorf("receive event: %w", err)
+		}
+		received = true
+		c.handleEvent(ctx, event)
+	}
+}
+
+func (c *ChatClient) handleEvent(ctx context.Context, event *pb.Event) {
+	ctx, cancel := context.WithTimeout(c.withAuth(ctx), eventTimeout)
+	defer cancel()
+
+	switch payload := event.Payload.(type) {
+	case *pb.Event_Message:
+		msg := payload.Message
+		c.cursor.received(msg.MessageId, event.Sequence)
+		progressFunc := func(done, total int) {
+			c.offer(domain.Event{Progress: &domain.FileProgress{RoomID: msg.ChatId, Done: done, Total: total}})
+		}
+		if err := c.receiveMessage(ctx, msg, progressFunc); err != nil {
+			slog.Error("could not receive message", "room", msg.ChatId, "error", err)
+		}
+
+	case *pb.Event_Invitation:
+		c.cursor.received(payload.Invitation.MessageId, event.Sequence)
+		invitation, err := c.receiveInvitation(ctx, payload.Invitation)
+		if err != nil {
+			slog.Error("could not receive invitation", "error", err)
+			return
+		}
+		if invitation.Sender != "" {
+			c.emit(ctx, domain.Event{Invitation: &invitation})
+		}
+
+	case *pb.Event_Reaction:
+		c.cursor.received(payload.Reaction.MessageId, event.Sequence)
+		response, err := c.receiveInvitationResponse(ctx, payload.Reaction)
+		if err != nil {
+			slog.Error("could not receive invitation response", "error", err)
+			return
+		}
+		if response.Sender != "" {
+			c.emit(ctx, domain.Event{InvitationResponse: &response})
+		}
+
+	case *pb.Event_ClearHistory:
+		c.cursor.received(payload.ClearHistory.MessageId, event.Sequence)
+		if err := c.receiveClearHistory(ctx, payload.ClearHistory); err != nil {
+			slog.Error("could not clear chat history", "room", payload.ClearHistory.ChatId, "error", err)
+		}
+
+	case *pb.Event_KeyUpdate:
+		c.cursor.received(payload.KeyUpdate.MessageId, event.Sequence)
+		update, err := c.receiveCipherKeyUpdate(ctx, payload.KeyUpdate)
+		if err != nil {
+			slog.Error("could not process key update", "error", err)
+			return
+		}
+		if update.RoomID != "" {
+			c.emit(ctx, domain.Event{KeyUpdate: &update})
+		}
+
+	default:
+		slog.Warn("unknown event", "sequence", event.Sequence)
+	}
+}
+
+// ackEvent подтверждает событие на сервере и сдвигает курсор потока.
+func (c *ChatClient) ackEvent(ctx context.Context, messageID string) error {
+	if _, err := c.client.AckEvent(ctx, &pb.AckRequest{MessageId: messageID}); err != nil {
+		return err
+	}
+	c.cursor.acked(messageID)
+	return nil
+}
+
+// emit ждёт, пока UI примет событие.
+func (c *ChatClient) emit(ctx context.Context, event domain.Event) {
+	select {
+	case c.events <- event:
+	case <-ctx.Done():
+	}
+}
+
+// offer отдаёт событие, только если в канале есть место: так его можно
+// вызывать и из потока UI.
+func (c *ChatClient) offer(event domain.Event) {
+	select {
+	case c.events <- event:
+	default:
+	}
+}
+
+// notifyRoom сообщает UI, что история комнаты изменилась.
+func (c *ChatClient) notifyRoom(roomID string) {
+	c.offer(domain.Event{RoomChanged: roomID})
+}
@@ -0,0 +1,215 @@
+package grpc_client
+
+import (
+	"CryptoMessenger/algorithm/identity"
+	pb "CryptoMessenger/proto/chatpb"
+	"context"
+	"crypto/ed25519"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+var ErrIdentityKeyNotFound = errors.New("собеседник не опубликовал ключ идентичности")
+
+// identityKeyFile — identity_key.json, в котором ключ лежал до хранилища ключей.
+type identityKeyFile struct {
+	PublicKey  string `json:"public_key"`
+	PrivateKey string `json:"private_key"`
+}
+
+func (c *ChatClient) identityKeyPath() string {
+	return filepath.Join("cmd", "client", "users", c.UserID, "identity_key.json")
+}
+
+// pinnedKeysPath — ключи идентичности собеседников, закреплённые при первом контакте.
+func (c *ChatClient) pinnedKeysPath() string {
+	return filepath.Join("cmd", "client", "users", c.UserID, "identity_pins.json")
+}
+
+// publishKeys загружает ключ идентичности и публикует его вместе с предключами.
+// Ключи лежат в хранилище, поэтому это делается после его открытия. Без ключа
+// идентичности нельзя подписывать приглашения, но вход при этом не блокируется:
+// например, если на новом устройстве ключ не совпадает с уже опубликованным.
+func (c *ChatClient) publishKeys() {
+	ctx, cancel := context.WithTimeout(c.AuthenticatedContext(), time.Second*5)
+	defer cancel()
+
+	if err := c.ensureIdentityKey(ctx); err != nil {
+		slog.Warn("identity key is not available", "err", err)
+		return
+	}
+	// Без предключей пригласить пользователя всё равно можно, просто ключ комнаты
+	// будет согласован после его ответа.
+	if err := c.ensurePrekeys(ctx); err != nil {
+		slog.Warn("could not publish prekeys", "err", err)
+	}
+}
+
+// ensureIdentityKey загружает ключ идентичности из хранилища, при его отсутствии создаёт
+// новый, и публикует открытую часть на сервере.
+func (c *ChatClient) ensureIdentityKey(ctx context.Context) error {
+	ks, err := c.keystore()
+	if err != nil {
+		return err
+	}
+
+	var privateKey ed25519.PrivateKey
+	if stored := ks.identity(); len(stored) > 0 {
+		privateKey, err = identity.ParsePrivateKey(stored)
+	} else {
+		privateKey, err = generateIdentityKey(ks)
+	}
+	if err != nil {
+		return err
+	}
+	c.identityKey = privateKey
+
+	publicKey := privateKey.Public().(ed25519.PublicKey)
+	if _, err = c.client.PublishIdentityKey(ctx, &pb.IdentityKey{PublicKey: publicKey}); err != nil {
+		return fmt.Errorf("could not publish identity key: %w", err)
+	}
+	return nil
+}
+
+func generateIdentityKey(ks *keystore) (ed25519.PrivateKey, error) {
+	_, privateKey, err := identity.GenerateKey()
+	if err != nil {
+		return nil, err
+	}
+	if err = ks.setIdentity(privateKey); err != nil {
+		return nil, err
+	}
+	return privateKey, nil
+}
+
+// readIdentityKeyFile читает ключ из identity_key.json, где он хранился до хранилища ключей.
+func readIdentityKeyFile(path string) (ed25519.PrivateKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var stored identityKeyFile
+	if err = json.Unmarshal(data, &stored); err != nil {
+		return nil, fmt.Errorf("invalid JSON in identity_key.json: %w", err)
+	}
+	key, err := hex.DecodeString(stored.PrivateKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid identity key hex: %w", err)
+	}
+	return identity.ParsePrivateKey(key)
+}
+
+// peerIdentityKey возвращает закреплённый ключ собеседника. При первом контакте ключ
+// запрашивается у сервера и закрепляется (TOFU): дальнейшая подмена на сервере
+// приведёт к ошибке проверки подписи, а не к тихой смене ключа.
+func (c *ChatClient) peerIdentityKey(ctx context.Context, username string) (ed25519.PublicKey, error) {
+	c.pinsMu.Lock()
+	defer c.pinsMu.Unlock()
+
+	pins := map[string]string{}
+	data, err := os.ReadFile(c.pinnedKeysPath())
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("could not read identity_pins.json: %w", err)
+	}
+	if err == nil {
+		if err = json.Unmarshal(data, &pins); err != nil {
+			return nil, fmt.Errorf("invalid JSON in identity_pins.json: %w", err)
+		}
+	}
+
+	if pinned, ok := pins[username]; ok {
+		key, err := hex.DecodeString(pinned)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pinned key for %s: %w", username, err)
+		}
+		return identity.ParsePublicKey(key)
+	}
+
+	resp, err := c.client.GetIdentityKey(ctx, &pb.IdentityKeyRequest{Username: username})
+	if err != nil {
+		if status.Code(err) == codes.NotFound {
+			return nil, fmt.Errorf("%w: %s", ErrIdentityKeyNotFound, username)
+		}
+		return nil, fmt.Errorf("could not get identity key of %s: %w", username, err)
+	}
+	key, err := identity.ParsePublicKey(resp.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	pins[username] = hex.EncodeToString(key)
+	out, err := json.MarshalIndent(pins, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("could not marshal pinned keys: %w", err)
+	}
+	if err = os.WriteFile(c.pinnedKeysPath(), out, 0o600); err != nil {
+		return nil, fmt.Errorf("could not write identity_pins.json: %w", err)
+	}
+	return key, nil
+}
+
+// invitationFields — всё, что влияет на ключ комнаты и её шифр.
+func invitationFields(sender, receiver string, inv *pb.Invitation) []string {
+	return []string{
+		sender, receiver, inv.RoomId, inv.RoomName,
+		inv.Algorithm, inv.Mode, inv.Padding, inv.Iv, inv.RandomDelta,
+		strconv.Itoa(int(inv.WordSize)), strconv.Itoa(int(inv.Rounds)), strconv.Itoa(int(inv.KeyLength)),
+		inv.KeyAgreement, inv.DhGroup, inv.PublicKey, strconv.FormatBool(inv.DoubleRatchet),
+		inv.SignedPrekeyId, inv.OneTimePrekeyId,
+	}
+}
+
+func reactionFields(sender, receiver string, reaction *pb.InvitationReaction) []string {
+	return []string{
+		sender, receiver, reaction.RoomId, reaction.PublicKey, strconv.FormatBool(reaction.Accepted),
+	}
+}
+
+func (c *ChatClient) signInvitation(inv *pb.Invitation) error {
+	if c.identityKey == nil {
+		return errors.New("identity key is not loaded")
+	}
+	inv.Signature = identity.Sign(c.identityKey, identity.InvitationContext, invitationFields(c.username, inv.ReceiverName, inv)...)
+	return nil
+}
+
+func (c *ChatClient) signReaction(reaction *pb.InvitationReaction) error {
+	if c.identityKey == nil {
+		return errors.New("identity key is not loaded")
+	}
+	reaction.Signature = identity.Sign(c.identityKey, identity.ReactionContext, reactionFields(c.username, reaction.ReceiverName, reaction)...)
+	return nil
+}
+
+// verifyInvitation проверяет подпись приглашения ключом идентичности отправителя.
+func (c *ChatClient) verifyInvitation(ctx context.Context, inv *pb.Invitation) error {
+	publicKey, err := c.peerIdentityKey(ctx, inv.SenderName)
+	if err != nil {
+		return err
+	}
+	return identity.Verify(publicKey, inv.Signature, identity.InvitationContext, invitationFields(inv.SenderName, c.username, inv)...)
+}
+
+func (c *ChatClient) verifyReaction(ctx context.Context, reaction *pb.InvitationReaction) error {
+	publicKey, err := c.peerIdentityKey(ctx, reaction.SenderName)
+	if err != nil {
+		return err
+	}
+	return identity.Verify(publicKey, reaction.Signature, identity.ReactionContext, reactionFields(reaction.SenderName, c.username, reaction)...)
+}
+
+// isForgedKeyExchange — подпись не сошлась или ключа нет: такое сообщение отбрасывается,
+// а не запрашивается повторно.
+func isForgedKeyExchange(err error) bool {
+	return errors.Is(err, identity.ErrInvalidSignature) || errors.Is(err, identity.ErrInvalidPublicKey) ||
+		errors.Is(err, ErrIdentityKeyNotFound)
+}
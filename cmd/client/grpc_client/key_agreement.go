@@ -0,0 +1,220 @@
+package grpc_client
+
+import (
+	dh "CryptoMessenger/algorithm/diffie_hellman"
+	"CryptoMessenger/algorithm/ecdh"
+	"CryptoMessenger/algorithm/ratchet"
+	"CryptoMessenger/cmd/client/domain"
+	pb "CryptoMessenger/proto/chatpb"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// keyExchange — ключи одной стороны для выбранной схемы согласования, в hex,
+// в том виде, в каком они хранятся у клиента и передаются в приглашении.
+type keyExchange struct {
+	KeyAgreement string
+	Group        string
+	P            string
+	G            string
+	PrivateKey   string
+	PublicKey    string
+}
+
+// ecdhScheme возвращает схему ECDH или nil, если используется классический DH.
+func ecdhScheme(keyAgreement string) (*ecdh.Scheme, error) {
+	if keyAgreement == "" || strings.EqualFold(keyAgreement, domain.KeyAgreementDH) {
+		return nil, nil
+	}
+	return ecdh.LookupScheme(keyAgreement)
+}
+
+// newKeyExchange генерирует пару ключей. Для DH используется группа groupName.
+func newKeyExchange(keyAgreement, groupName string) (*keyExchange, error) {
+	scheme, err := ecdhScheme(keyAgreement)
+	if err != nil {
+		return nil, err
+	}
+
+	if scheme != nil {
+		privateKey, publicKey, err := scheme.GenerateKey()
+		if err != nil {
+			return nil, err
+		}
+		return &keyExchange{
+			KeyAgreement: scheme.Name,
+			PrivateKey:   hex.EncodeToString(privateKey),
+			PublicKey:    hex.EncodeToString(publicKey),
+		}, nil
+	}
+
+	group, err := dh.LookupGroup(groupName)
+	if err != nil {
+		return nil, err
+	}
+	privateKey, err := group.GeneratePrivateKey()
+	if err != nil {
+		return nil, fmt.Errorf("could not generate private key: %w", err)
+	}
+
+	return &keyExchange{
+		KeyAgreement: domain.KeyAgreementDH,
+		Group:        group.Name,
+		P:            group.P.Text(16),
+		G:            group.G.Text(16),
+		PrivateKey:   privateKey.Text(16),
+		PublicKey:    group.PublicKey(privateKey).Text(16),
+	}, nil
+}
+
+// computeCipherKey проверяет открытый ключ собеседника и вычисляет общий секрет в hex.
+func computeCipherKey(info domain.RoomInfo, privateKeyHex, otherPublicKeyHex string) (string, error) {
+	scheme, err := ecdhScheme(info.KeyAgreement)
+	if err != nil {
+		return "", err
+	}
+
+	if scheme != nil {
+		privateKey, err := hex.DecodeString(privateKeyHex)
+		if err != nil {
+			return "", fmt.Errorf("invalid private key hex: %w", err)
+		}
+		otherPublicKey, err := hex.DecodeString(otherPublicKeyHex)
+		if err != nil {
+			return "", fmt.Errorf("invalid public key hex: %w", err)
+		}
+		secret, err := scheme.SharedSecret(privateKey, otherPublicKey)
+		if err != nil {
+			return "", err
+		}
+		return hex.EncodeToString(secret), nil
+	}
+
+	group, err := dh.LookupGroup(info.DHGroup)
+	if err != nil {
+		return "", err
+	}
+	privateKey, ok := new(big.Int).SetString(privateKeyHex, 16)
+	if !ok {
+		return "", fmt.Errorf("invalid private key hex")
+	}
+	otherPublicKey, ok := new(big.Int).SetString(otherPublicKeyHex, 16)
+	if !ok {
+		return "", fmt.Errorf("invalid public key")
+	}
+	if err = group.ValidatePublicKey(otherPublicKey); err != nil {
+		return "", err
+	}
+
+	return dh.GenerateSharedKey(privateKey, otherPublicKey, group.P).Text(16), nil
+}
+
+// roomSecret возвращает общий секрет комнаты в виде байтов для вывода ключей,
+// а для DH — ещё p и g, которые входят в транскрипт.
+func roomSecret(info domain.RoomInfo) (secret []byte, prime, g *big.Int, err error) {
+	scheme, err := ecdhScheme(info.KeyAgreement)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if scheme != nil {
+		secret, err = hex.DecodeString(info.CipherKey)
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("invalid hex cipher key: %w", err)
+		}
+		return secret, nil, nil, nil
+	}
+
+	sharedKey, ok := new(big.Int).SetString(info.CipherKey, 16)
+	if !ok {
+		return nil, nil, nil, fmt.Errorf("invalid hex cipher key")
+	}
+	prime, ok = new(big.Int).SetString(info.P, 16)
+	if !ok {
+		return nil, nil, nil, fmt.Errorf("invalid prime hex: %s", info.P)
+	}
+	g, ok = new(big.Int).SetString(info.G, 16)
+	if !ok {
+		return nil, nil, nil, fmt.Errorf("invalid G hex: %s", info.G)
+	}
+	return dh.SharedSecretBytes(sharedKey, prime), prime, g, nil
+}
+
+// invitationKeyExchange проверяет схему, группу и открытый ключ из приглашения
+// и возвращает параметры согласования без собственных ключей.
+func invitationKeyExchange(invitation *pb.Invitation) (*keyExchange, error) {
+	scheme, err := ecdhScheme(invitation.KeyAgreement)
+	if err != nil {
+		return nil, err
+	}
+
+	if scheme != nil {
+		publicKey, err := hex.DecodeString(invitation.PublicKey)
+		if err != nil {
+			return nil, fmt.Errorf("invalid public key hex: %w", err)
+		}
+		if err = scheme.ValidatePublicKey(publicKey); err != nil {
+			return nil, err
+		}
+		return &keyExchange{KeyAgreement: scheme.Name}, nil
+	}
+
+	group, err := dh.LookupGroup(invitation.DhGroup)
+	if err != nil {
+		return nil, err
+	}
+	publicKey, ok := new(big.Int).SetString(invitation.PublicKey, 16)
+	if !ok {
+		return nil, fmt.Errorf("invalid public key")
+	}
+	if err = group.ValidatePublicKey(publicKey); err != nil {
+		return nil, err
+	}
+
+	return &keyExchange{
+		KeyAgreement: domain.KeyAgreementDH,
+		Group:        group.Name,
+		P:            group.P.Text(16),
+		G:            group.G.Text(16),
+	}, nil
+}
+
+// agreementDH возвращает схему в байтовом виде (для храповика и X3DH):
+// кривую ECDH или именованную группу DH.
+func agreementDH(keyAgreement, groupName string) (ratchet.DH, error) {
+	scheme, err := ecdhScheme(keyAgreement)
+	if err != nil {
+		return nil, err
+	}
+	if scheme != nil {
+		return scheme, nil
+	}
+	return dh.LookupGroup(groupName)
+}
+
+// keyBytes переводит ключ в hex в байты схемы:
+// для DH это число длины p, для ECDH — его кодировка.
+func keyBytes(keyAgreement, groupName, keyHex string) ([]byte, error) {
+	scheme, err := ecdhScheme(keyAgreement)
+	if err != nil {
+		return nil, err
+	}
+	if scheme != nil {
+		key, err := hex.DecodeString(keyHex)
+		if err != nil {
+			return nil, fmt.Errorf("invalid key hex: %w", err)
+		}
+		return key, nil
+	}
+
+	group, err := dh.LookupGroup(groupName)
+	if err != nil {
+		return nil, err
+	}
+	key, ok := new(big.Int).SetString(keyHex, 16)
+	if !ok {
+		return nil, fmt.Errorf("invalid key hex")
+	}
+	return dh.SharedSecretBytes(key, group.P), nil
+}
@@ -0,0 +1,516 @@
+package grpc_client
+
+import (
+	"CryptoMessenger/cmd/client/domain"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"golang.org/x/crypto/argon2"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Секреты комнат (общий ключ, закрытые ключи, ключи прошлых эпох и состояние
+// храповика), ключ идентичности, закрытые предключи и ключ шифрования локальной
+// истории хранятся не в отдельных файлах, а в keystore.json, зашифрованном ключом
+// из парольной фразы (Argon2id + AES-256-GCM).
+// Хранилище открывается при входе; до этого комнаты и история с диска не читаются.
+var (
+	ErrKeystoreLocked  = errors.New("хранилище ключей не открыто")
+	ErrWrongPassphrase = errors.New("неверная парольная фраза хранилища ключей")
+)
+
+// keystoreVersion 2 добавил ключ хранения истории, 3 — ключ идентичности и предключи;
+// версия 1 содержала только комнаты. Версия растёт, чтобы старый клиент не переписал
+// хранилище без новых полей.
+const keystoreVersion = 3
+
+// storageKeyLen — длина ключа хранения (AES-256).
+const storageKeyLen = 32
+
+// Параметры Argon2id — второй рекомендуемый набор из RFC 9106 (64 МиБ памяти).
+const (
+	argonTime    = 3
+	argonMemory  = 64 * 1024
+	argonThreads = 4
+	argonKeyLen  = 32
+	argonSaltLen = 16
+)
+
+// keystoreFile — keystore.json на диске. Параметры KDF хранятся в файле,
+// чтобы их можно было усилить, не теряя старые хранилища.
+type keystoreFile struct {
+	Version    int    `json:"version"`
+	KDF        string `json:"kdf"`
+	Salt       []byte `json:"salt"`
+	Time       uint32 `json:"time"`
+	Memory     uint32 `json:"memory"`
+	Threads    uint8  `json:"threads"`
+	Nonce      []byte `json:"nonce"`
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+// roomSecrets — секретные поля одной комнаты.
+type roomSecrets struct {
+	CipherKey         string          `json:"cipher_key,omitempty"`
+	PrivateKey        string          `json:"private_key,omitempty"`
+	PastCipherKeys    map[int]string  `json:"past_cipher_keys,omitempty"`
+	PendingPrivateKey string          `json:"pending_private_key,omitempty"`
+	Ratchet           json.RawMessage `json:"ratchet,omitempty"`
+}
+
+// keystoreContents — расшифрованное содержимое хранилища. StorageKey шифрует
+// историю и принятые файлы и не связан с ключами комнат.
+type keystoreContents struct {
+	StorageKey  []byte                 `json:"storage_key"`
+	Rooms       map[string]roomSecrets `json:"rooms"`
+	IdentityKey []byte                 `json:"identity_key,omitempty"`
+	Prekeys     prekeyStore            `json:"prekeys"`
+}
+
+type keystore struct {
+	mu          sync.Mutex
+	path        string
+	file        keystoreFile
+	aead        cipher.AEAD
+	storageKey  []byte
+	rooms       map[string]roomSecrets
+	identityKey []byte
+	prekeys     prekeyStore
+}
+
+func (c *ChatClient) keystorePath() string {
+	return filepath.Join("cmd", "client", "users", c.UserID, "keystore.json")
+}
+
+// UnlockKeystore открывает хранилище ключей пользователя или создаёт новое,
+// переносит в него секреты из старых незашифрованных room_info.json, ratchet.json,
+// identity_key.json и prekeys.json и публикует ключ идентичности и предключи.
+func (c *ChatClient) UnlockKeystore(passphrase string) error {
+	if passphrase == "" {
+		return fmt.Errorf("keystore passphrase cannot be empty")
+	}
+
+	ks, err := openKeystore(c.keystorePath(), passphrase)
+	if err != nil {
+		return err
+	}
+	if err = c.migratePlaintextRooms(ks); err != nil {
+		return err
+	}
+	if err = c.migratePlaintextHistory(ks); err != nil {
+		return err
+	}
+	if err = c.migratePlaintextKeys(ks); err != nil {
+		return err
+	}
+
+	c.keystoreMu.Lock()
+	c.keys = ks
+	c.keystoreMu.Unlock()
+
+	c.publishKeys()
+	return nil
+}
+
+// LockKeystore забывает ключ хранилища при выходе из аккаунта.
+func (c *ChatClient) LockKeystore() {
+	c.keystoreMu.Lock()
+	c.keys = nil
+	c.identityKey = nil
+	c.keystoreMu.Unlock()
+	c.removeOpenedFiles()
+}
+
+func (c *ChatClient) keystore() (*keystore, error) {
+	c.keystoreMu.Lock()
+	defer c.keystoreMu.Unlock()
+	if c.keys == nil {
+		return nil, ErrKeystoreLocked
+	}
+	return c.keys, nil
+}
+
+func openKeystore(path, passphrase string) (*keystore, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return newKeystore(path, passphrase)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not read keystore.json: %w", err)
+	}
+
+	ks := &keystore{path: path, rooms: map[string]roomSecrets{}}
+	if err = json.Unmarshal(data, &ks.file); err != nil {
+		return nil, fmt.Errorf("invalid JSON in keystore.json: %w", err)
+	}
+	if ks.file.Version < 1 || ks.file.Version > keystoreVersion || ks.file.KDF != "argon2id" {
+		return nil, fmt.Errorf("unsupported keystore version %d (%s)", ks.file.Version, ks.file.KDF)
+	}
+	if ks.aead, err = keystoreAEAD(passphrase, ks.file); err != nil {
+		return nil, err
+	}
+
+	plaintext, err := ks.aead.Open(nil, ks.file.Nonce, ks.file.Ciphertext, keystoreAdditionalData(ks.file))
+	if err != nil {
+		return nil, ErrWrongPassphrase
+	}
+	if ks.file.Version == 1 {
+		err = json.Unmarshal(plaintext, &ks.rooms)
+	} else {
+		var contents keystoreContents
+		err = json.Unmarshal(plaintext, &contents)
+		ks.storageKey, ks.rooms = contents.StorageKey, contents.Rooms
+		ks.identityKey, ks.prekeys = contents.IdentityKey, contents.Prekeys
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid keystore contents: %w", err)
+	}
+	if ks.rooms == nil {
+		ks.rooms = map[string]roomSecrets{}
+	}
+
+	// Старое хранилище получает ключ хранения и переписывается в текущей версии
+	if len(ks.storageKey) != storageKeyLen || ks.file.Version < keystoreVersion {
+		if len(ks.storageKey) != storageKeyLen {
+			if ks.storageKey, err = newStorageKey(); err != nil {
+				return nil, err
+			}
+		}
+		ks.file.Version = keystoreVersion
+		if err = ks.save(); err != nil {
+			return nil, err
+		}
+	}
+	return ks, nil
+}
+
+func newKeystore(path, passphrase string) (*keystore, error) {
+	salt := make([]byte, argonSaltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, fmt.Errorf("could not generate salt: %w", err)
+	}
+	storageKey, err := newStorageKey()
+	if err != nil {
+		return nil, err
+	}
+
+	ks := &keystore{
+		path: path,
+		file: keystoreFile{
+			Version: keystoreVersion,
+			KDF:     "argon2id",
+			Salt:    salt,
+			Time:    argonTime,
+			Memory:  argonMemory,
+			Threads: argonThreads,
+		},
+		storageKey: storageKey,
+		rooms:      map[string]roomSecrets{},
+	}
+	if ks.aead, err = keystoreAEAD(passphrase, ks.file); err != nil {
+		return nil, err
+	}
+	if err = ks.save(); err != nil {
+		return nil, err
+	}
+	return ks, nil
+}
+
+func newStorageKey() ([]byte, error) {
+	key := make([]byte, storageKeyLen)
+	if _, err := rand.Read(key); err != nil {
+		return nil, fmt.Errorf("could not generate storage key: %w", err)
+	}
+	return key, nil
+}
+
+func keystoreAEAD(passphrase string, file keystoreFile) (cipher.AEAD, error) {
+	key := argon2.IDKey([]byte(passphrase), file.Salt, file.Time, file.Memory, file.Threads, argonKeyLen)
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// keystoreAdditionalData привязывает шифртекст к параметрам KDF,
+// чтобы их нельзя было подменить в файле.
+func keystoreAdditionalData(file keystoreFile) []byte {
+	return []byte(fmt.Sprintf("CryptoMessenger keystore v%d %s %x %d %d %d",
+		file.Version, file.KDF, file.Salt, file.Time, file.Memory, file.Threads))
+}
+
+// save перешифровывает хранилище со свежим nonce. Файл подменяется через rename,
+// чтобы сбой записи не оставил его наполовину записанным.
+func (ks *keystore) save() error {
+	plaintext, err := json.Marshal(keystoreContents{
+		StorageKey:  ks.storageKey,
+		Rooms:       ks.rooms,
+		IdentityKey: ks.identityKey,
+		Prekeys:     ks.prekeys,
+	})
+	if err != nil {
+		return fmt.Errorf("could not marshal keystore: %w", err)
+	}
+	nonce := make([]byte, ks.aead.NonceSize())
+	if _, err = rand.Read(nonce); err != nil {
+		return fmt.Errorf("could not generate nonce: %w", err)
+	}
+
+	file := ks.file
+	file.Nonce = nonce
+	file.Ciphertext = ks.aead.Seal(nil, nonce, plaintext, keystoreAdditionalData(file))
+	out, err := json.MarshalIndent(&file, "", "  ")
+	if err != nil {
+		return fmt.Errorf("could not marshal keystore.json: %w", err)
+	}
+
+	if err = os.MkdirAll(filepath.Dir(ks.path), 0o700); err != nil {
+		return fmt.Errorf("could not create keystore dir: %w", err)
+	}
+	tmp := ks.path + ".tmp"
+	if err = os.WriteFile(tmp, out, 0o600); err != nil {
+		return fmt.Errorf("could not write keystore.json: %w", err)
+	}
+	if err = os.Rename(tmp, ks.path); err != nil {
+		return fmt.Errorf("could not replace keystore.json: %w", err)
+	}
+	ks.file = file
+	return nil
+}
+
+func (ks *keystore) room(roomID string) roomSecrets {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	return ks.rooms[roomID]
+}
+
+// update изменяет секреты комнаты и сразу сохраняет хранилище.
+func (ks *keystore) update(roomID string, fn func(*roomSecrets)) error {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	secrets := ks.rooms[roomID]
+	fn(&secrets)
+	ks.rooms[roomID] = secrets
+	return ks.save()
+}
+
+func (ks *keystore) remove(roomID string) error {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	if _, ok := ks.rooms[roomID]; !ok {
+		return nil
+	}
+	delete(ks.rooms, roomID)
+	return ks.save()
+}
+
+func (ks *keystore) identity() []byte {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	return ks.identityKey
+}
+
+func (ks *keystore) setIdentity(privateKey []byte) error {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	ks.identityKey = privateKey
+	return ks.save()
+}
+
+// prekeyStore возвращает копию предключей, которую можно менять до setPrekeys.
+func (ks *keystore) prekeyStore() *prekeyStore {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	return &prekeyStore{
+		Signed:  append([]storedPrekey(nil), ks.prekeys.Signed...),
+		OneTime: append([]storedPrekey(nil), ks.prekeys.OneTime...),
+	}
+}
+
+func (ks *keystore) setPrekeys(store *prekeyStore) error {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	ks.prekeys = *store
+	return ks.save()
+}
+
+// splitRoomSecrets возвращает копию info без секретов и сами секреты.
+// Состояние храповика в RoomInfo не входит и задаётся отдельно.
+func splitRoomSecrets(info domain.RoomInfo) (domain.RoomInfo, roomSecrets) {
+	secrets := roomSecrets{CipherKey: info.CipherKey, PrivateKey: info.PrivateKey}
+	info.CipherKey = ""
+	info.PrivateKey = ""
+
+	if len(info.PastEpochs) > 0 {
+		secrets.PastCipherKeys = make(map[int]string, len(info.PastEpochs))
+		past := make([]domain.KeyEpoch, len(info.PastEpochs))
+		for i, epoch := range info.PastEpochs {
+			if epoch.CipherKey != "" {
+				secrets.PastCipherKeys[epoch.Epoch] = epoch.CipherKey
+			}
+			epoch.CipherKey = ""
+			past[i] = epoch
+		}
+		info.PastEpochs = past
+	}
+	if info.PendingRekey != nil {
+		pending := *info.PendingRekey
+		secrets.PendingPrivateKey = pending.PrivateKey
+		pending.PrivateKey = ""
+		info.PendingRekey = &pending
+	}
+	return info, secrets
+}
+
+// mergeRoomSecrets возвращает секреты из хранилища в info.
+func mergeRoomSecrets(info *domain.RoomInfo, secrets roomSecrets) {
+	info.CipherKey = secrets.CipherKey
+	info.PrivateKey = secrets.PrivateKey
+	for i := range info.PastEpochs {
+		info.PastEpochs[i].CipherKey = secrets.PastCipherKeys[info.PastEpochs[i].Epoch]
+	}
+	if info.PendingRekey != nil {
+		info.PendingRekey.PrivateKey = secrets.PendingPrivateKey
+	}
+}
+
+func hasRoomSecrets(secrets roomSecrets) bool {
+	return secrets.CipherKey != "" || secrets.PrivateKey != "" ||
+		len(secrets.PastCipherKeys) > 0 || secrets.PendingPrivateKey != ""
+}
+
+// migratePlaintextRooms переносит секреты из файлов, записанных до появления
+// хранилища. Сначала сохраняется хранилище, и только потом секреты стираются с диска.
+func (c *ChatClient) migratePlaintextRooms(ks *keystore) error {
+	dir := filepath.Join("cmd", "client", "users", c.UserID, "chats")
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("could not read chats dir: %w", err)
+	}
+
+	var migrated []domain.RoomInfo
+	var ratchets []string
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		roomID := entry.Name()
+		info, err := readRoomInfoFile(filepath.Join(dir, roomID, "room_info.json"))
+		if err != nil {
+			slog.Warn("skip room migration", "room", roomID, "err", err)
+			continue
+		}
+		stripped, secrets := splitRoomSecrets(info)
+		if hasRoomSecrets(secrets) {
+			existing := ks.rooms[roomID]
+			secrets.Ratchet = existing.Ratchet
+			ks.rooms[roomID] = secrets
+			migrated = append(migrated, stripped)
+		}
+
+		ratchetPath := filepath.Join(dir, roomID, "ratchet.json")
+		if data, err := os.ReadFile(ratchetPath); err == nil && json.Valid(data) {
+			secrets := ks.rooms[roomID]
+			secrets.Ratchet = data
+			ks.rooms[roomID] = secrets
+			ratchets = append(ratchets, ratchetPath)
+		}
+	}
+	if len(migrated) == 0 && len(ratchets) == 0 {
+		return nil
+	}
+
+	if err = ks.save(); err != nil {
+		return err
+	}
+	for _, info := range migrated {
+		if err = writeRoomInfoFile(filepath.Join(dir, info.ID, "room_info.json"), info); err != nil {
+			return err
+		}
+	}
+	for _, path := range ratchets {
+		if err = os.Remove(path); err != nil {
+			return fmt.Errorf("could not remove ratchet.json: %w", err)
+		}
+	}
+	slog.Info("room secrets moved to keystore", "rooms", len(migrated), "ratchets", len(ratchets))
+	return nil
+}
+
+// migratePlaintextKeys переносит ключ идентичности и закрытые предключи из
+// identity_key.json и prekeys.json. Файлы удаляются после сохранения хранилища.
+func (c *ChatClient) migratePlaintextKeys(ks *keystore) error {
+	var migrated []string
+
+	privateKey, err := readIdentityKeyFile(c.identityKeyPath())
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+	case err != nil:
+		return err
+	case len(ks.identityKey) == 0:
+		ks.identityKey = privateKey
+		migrated = append(migrated, c.identityKeyPath())
+	case bytes.Equal(ks.identityKey, privateKey):
+		migrated = append(migrated, c.identityKeyPath())
+	default:
+		// Другой закрытый ключ не удаляется молча: восстановить его будет неоткуда
+		slog.Warn("identity_key.json differs from the keystore identity key and is kept")
+	}
+
+	data, err := os.ReadFile(c.prekeysPath())
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("could not read prekeys.json: %w", err)
+	}
+	if err == nil {
+		var stored prekeyStore
+		if err = json.Unmarshal(data, &stored); err != nil {
+			return fmt.Errorf("invalid JSON in prekeys.json: %w", err)
+		}
+		ks.prekeys.Signed = mergePrekeys(ks.prekeys.Signed, stored.Signed)
+		ks.prekeys.OneTime = mergePrekeys(ks.prekeys.OneTime, stored.OneTime)
+		migrated = append(migrated, c.prekeysPath())
+	}
+
+	if len(migrated) == 0 {
+		return nil
+	}
+	if err = ks.save(); err != nil {
+		return err
+	}
+	for _, path := range migrated {
+		if err = os.Remove(path); err != nil {
+			return fmt.Errorf("could not remove %s: %w", filepath.Base(path), err)
+		}
+	}
+	slog.Info("identity key and prekeys moved to keystore", "files", len(migrated))
+	return nil
+}
+
+// mergePrekeys добавляет к keys предключи из from, которых ещё нет по ID.
+func mergePrekeys(keys, from []storedPrekey) []storedPrekey {
+	known := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		known[key.ID] = true
+	}
+	for _, key := range from {
+		if !known[key.ID] {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
@@ -0,0 +1,123 @@
+package grpc_client
+
+import (
+	"CryptoMessenger/algorithm/identity"
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// chdirTemp переходит во временный каталог: пути пользователя в клиенте относительные.
+func chdirTemp(t *testing.T) {
+	t.Helper()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = os.Chdir(wd) })
+}
+
+func writeJSON(t *testing.T, path string, v any) {
+	t.Helper()
+	out, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	if err = os.WriteFile(path, out, 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMigratePlaintextKeys(t *testing.T) {
+	chdirTemp(t)
+	c := &ChatClient{UserID: "user"}
+
+	publicKey, privateKey, err := identity.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeJSON(t, c.identityKeyPath(), identityKeyFile{
+		PublicKey:  hex.EncodeToString(publicKey),
+		PrivateKey: hex.EncodeToString(privateKey),
+	})
+	prekeys := prekeyStore{
+		Signed:  []storedPrekey{{ID: "signed", KeyAgreement: "X25519", PrivateKey: "aa", PublicKey: "bb"}},
+		OneTime: []storedPrekey{{ID: "one-time", KeyAgreement: "X25519", PrivateKey: "cc", PublicKey: "dd"}},
+	}
+	writeJSON(t, c.prekeysPath(), prekeys)
+
+	ks, err := openKeystore(c.keystorePath(), "passphrase")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = c.migratePlaintextKeys(ks); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, path := range []string{c.identityKeyPath(), c.prekeysPath()} {
+		if _, err = os.Stat(path); !errors.Is(err, os.ErrNotExist) {
+			t.Fatalf("%s was not removed", filepath.Base(path))
+		}
+	}
+	data, err := os.ReadFile(c.keystorePath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(data, []byte(hex.EncodeToString(privateKey))) || bytes.Contains(data, []byte(`"aa"`)) {
+		t.Fatal("keystore.json contains plaintext keys")
+	}
+
+	// После повторного открытия ключи читаются из хранилища
+	reopened, err := openKeystore(c.keystorePath(), "passphrase")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(reopened.identity(), privateKey) {
+		t.Fatal("identity key was not migrated")
+	}
+	store := reopened.prekeyStore()
+	if len(store.Signed) != 1 || store.Signed[0] != prekeys.Signed[0] ||
+		len(store.OneTime) != 1 || store.OneTime[0] != prekeys.OneTime[0] {
+		t.Fatalf("prekeys = %+v", store)
+	}
+}
+
+// Ключ идентичности, отличный от уже сохранённого, не удаляется с диска.
+func TestMigratePlaintextKeysKeepsConflictingIdentity(t *testing.T) {
+	chdirTemp(t)
+	c := &ChatClient{UserID: "user"}
+
+	ks, err := openKeystore(c.keystorePath(), "passphrase")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, stored, _ := identity.GenerateKey()
+	if err = ks.setIdentity(stored); err != nil {
+		t.Fatal(err)
+	}
+
+	publicKey, other, _ := identity.GenerateKey()
+	writeJSON(t, c.identityKeyPath(), identityKeyFile{
+		PublicKey:  hex.EncodeToString(publicKey),
+		PrivateKey: hex.EncodeToString(other),
+	})
+	if err = c.migratePlaintextKeys(ks); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = os.Stat(c.identityKeyPath()); err != nil {
+		t.Fatal("conflicting identity_key.json was removed")
+	}
+	if !bytes.Equal(ks.identity(), stored) {
+		t.Fatal("keystore identity key was replaced")
+	}
+}
@@ -0,0 +1,325 @@
+package grpc_client
+
+import (
+	dh "CryptoMessenger/algorithm/diffie_hellman"
+	"CryptoMessenger/algorithm/ecdh"
+	"CryptoMessenger/algorithm/identity"
+	"CryptoMessenger/algorithm/x3dh"
+	"CryptoMessenger/cmd/client/domain"
+	pb "CryptoMessenger/proto/chatpb"
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"math/big"
+	"path/filepath"
+)
+
+// Предключи позволяют создать комнату, пока собеседник не в сети: его подписанный
+// и одноразовый предключи лежат на сервере, и ключ комнаты вычисляется сразу (X3DH).
+// Закрытые части хранятся в хранилище ключей; одноразовый удаляется после использования.
+var ErrNoPrekeys = errors.New("собеседник не опубликовал предключи: первое сообщение можно отправить после принятия приглашения")
+
+const (
+	// oneTimePrekeyTarget — сколько одноразовых предключей держать на сервере по каждой схеме.
+	oneTimePrekeyTarget = 20
+	// oneTimePrekeyMinimum — меньше этого запас пополняется.
+	oneTimePrekeyMinimum = 10
+)
+
+type storedPrekey struct {
+	ID           string `json:"id"`
+	KeyAgreement string `json:"key_agreement"`
+	DHGroup      string `json:"dh_group,omitempty"`
+	PrivateKey   string `json:"private_key"`
+	PublicKey    string `json:"public_key"`
+}
+
+type prekeyStore struct {
+	Signed  []storedPrekey `json:"signed"`
+	OneTime []storedPrekey `json:"one_time"`
+}
+
+// prekeysPath — prekeys.json, в котором предключи лежали до хранилища ключей.
+func (c *ChatClient) prekeysPath() string {
+	return filepath.Join("cmd", "client", "users", c.UserID, "prekeys.json")
+}
+
+// prekeySuites — схемы, для которых публикуются предключи: все кривые ECDH и DH
+// в группе по умолчанию, которой пользуется CreateChat.
+func prekeySuites() []*keyExchange {
+	suites := make([]*keyExchange, 0, len(ecdh.Schemes())+1)
+	for _, name := range ecdh.Schemes() {
+		suites = append(suites, &keyExchange{KeyAgreement: name})
+	}
+	return append(suites, &keyExchange{KeyAgreement: domain.KeyAgreementDH, Group: dh.DefaultGroup})
+}
+
+// ensurePrekeys публикует подписанные предключи и пополняет запас одноразовых.
+func (c *ChatClient) ensurePrekeys(ctx context.Context) error {
+	if c.identityKey == nil {
+		return errors.New("identity key is not loaded")
+	}
+
+	c.prekeysMu.Lock()
+	defer c.prekeysMu.Unlock()
+
+	store, err := c.loadPrekeys()
+	if err != nil {
+		return err
+	}
+
+	var signed []*pb.Prekey
+	for _, suite := range prekeySuites() {
+		key, ok := store.signedFor(suite.KeyAgreement, suite.Group)
+		if !ok {
+			if key, err = newStoredPrekey(suite); err != nil {
+				return err
+			}
+			store.Signed = append(store.Signed, key)
+			if err = c.savePrekeys(store); err != nil {
+				return err
+			}
+		}
+		prekey := key.proto()
+		prekey.Signature = identity.Sign(c.identityKey, identity.PrekeyContext,
+			identity.PrekeyFields(c.username, key.ID, key.KeyAgreement, key.DHGroup, key.PublicKey)...)
+		signed = append(signed, prekey)
+	}
+
+	resp, err := c.client.UploadPrekeys(ctx, &pb.UploadPrekeysRequest{SignedPrekeys: signed})
+	if err != nil {
+		return fmt.Errorf("could not upload signed prekeys: %w", err)
+	}
+
+	counts := map[string]int{}
+	for _, count := range resp.Counts {
+		counts[count.KeyAgreement+"/"+count.DhGroup] = int(count.OneTimeCount)
+	}
+
+	var oneTime []*pb.Prekey
+	for _, suite := range prekeySuites() {
+		group := suite.Group
+		if suite.KeyAgreement != domain.KeyAgreementDH {
+			group = ""
+		}
+		left := counts[suite.KeyAgreement+"/"+group]
+		if left >= oneTimePrekeyMinimum {
+			continue
+		}
+		for i := left; i < oneTimePrekeyTarget; i++ {
+			key, err := newStoredPrekey(suite)
+			if err != nil {
+				return err
+			}
+			store.OneTime = append(store.OneTime, key)
+			oneTime = append(oneTime, key.proto())
+		}
+	}
+	if len(oneTime) == 0 {
+		return nil
+	}
+
+	// Закрытые ключи сохраняются до загрузки: иначе пришедшее приглашение нечем было бы открыть
+	if err = c.savePrekeys(store); err != nil {
+		return err
+	}
+	if _, err = c.client.UploadPrekeys(ctx, &pb.UploadPrekeysRequest{OneTimePrekeys: oneTime}); err != nil {
+		return fmt.Errorf("could not upload one-time prekeys: %w", err)
+	}
+	return nil
+}
+
+// prekeyBundle получает предключи собеседника для схемы exchange и проверяет подпись
+// подписанного предключа. Если собеседник их не публиковал, возвращает nil.
+func (c *ChatClient) prekeyBundle(ctx context.Context, username string, exchange *keyExchange) (*pb.PrekeyBundle, error) {
+	bundle, err := c.client.GetPrekeyBundle(ctx, &pb.PrekeyBundleRequest{
+		Username:     username,
+		KeyAgreement: exchange.KeyAgreement,
+		DhGroup:      exchange.Group,
+	})
+	if status.Code(err) == codes.NotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not get prekeys of %s: %w", username, err)
+	}
+
+	signed := bundle.SignedPrekey
+	if signed == nil || signed.KeyAgreement != exchange.KeyAgreement || signed.DhGroup != exchange.Group {
+		return nil, fmt.Errorf("prekeys of %s do not match %s", username, exchange.KeyAgreement)
+	}
+	identityKey, err := c.peerIdentityKey(ctx, username)
+	if err != nil {
+		return nil, err
+	}
+	err = identity.Verify(identityKey, signed.Signature, identity.PrekeyContext,
+		identity.PrekeyFields(username, signed.KeyId, signed.KeyAgreement, signed.DhGroup, signed.PublicKey)...)
+	if err != nil {
+		return nil, fmt.Errorf("signed prekey of %s: %w", username, err)
+	}
+	return bundle, nil
+}
+
+// prekeyCipherKey вычисляет ключ комнаты на стороне отправителя.
+func prekeyCipherKey(exchange *keyExchange, bundle *pb.PrekeyBundle) (string, error) {
+	scheme, err := agreementDH(exchange.KeyAgreement, exchange.Group)
+	if err != nil {
+		return "", err
+	}
+	ephemeralPrivateKey, err := keyBytes(exchange.KeyAgreement, exchange.Group, exchange.PrivateKey)
+	if err != nil {
+		return "", err
+	}
+	signedPrekey, err := keyBytes(exchange.KeyAgreement, exchange.Group, bundle.SignedPrekey.PublicKey)
+	if err != nil {
+		return "", err
+	}
+	var oneTimePrekey []byte
+	if bundle.OneTimePrekey != nil {
+		if oneTimePrekey, err = keyBytes(exchange.KeyAgreement, exchange.Group, bundle.OneTimePrekey.PublicKey); err != nil {
+			return "", err
+		}
+	}
+
+	secret, err := x3dh.InitiatorSecret(scheme, ephemeralPrivateKey, signedPrekey, oneTimePrekey)
+	if err != nil {
+		return "", err
+	}
+	return encodeCipherKey(exchange.KeyAgreement, secret), nil
+}
+
+// acceptPrekeyInvitation вычисляет ключ комнаты из приглашения, отправленного на наши
+// предключи, и подставляет в info ключи подписанного предключа.
+func (c *ChatClient) acceptPrekeyInvitation(info *domain.RoomInfo, invitation *pb.Invitation) error {
+	c.prekeysMu.Lock()
+	defer c.prekeysMu.Unlock()
+
+	store, err := c.loadPrekeys()
+	if err != nil {
+		return err
+	}
+	signed, ok := store.find(store.Signed, invitation.SignedPrekeyId)
+	if !ok || signed.KeyAgreement != info.KeyAgreement || signed.DHGroup != info.DHGroup {
+		return fmt.Errorf("unknown signed prekey %s", invitation.SignedPrekeyId)
+	}
+
+	scheme, err := agreementDH(info.KeyAgreement, info.DHGroup)
+	if err != nil {
+		return err
+	}
+	signedPrivateKey, err := keyBytes(info.KeyAgreement, info.DHGroup, signed.PrivateKey)
+	if err != nil {
+		return err
+	}
+	ephemeralPublicKey, err := keyBytes(info.KeyAgreement, info.DHGroup, invitation.PublicKey)
+	if err != nil {
+		return err
+	}
+	var oneTimePrivateKey []byte
+	if invitation.OneTimePrekeyId != "" {
+		oneTime, ok := store.find(store.OneTime, invitation.OneTimePrekeyId)
+		if !ok || oneTime.KeyAgreement != info.KeyAgreement || oneTime.DHGroup != info.DHGroup {
+			return fmt.Errorf("unknown one-time prekey %s", invitation.OneTimePrekeyId)
+		}
+		if oneTimePrivateKey, err = keyBytes(info.KeyAgreement, info.DHGroup, oneTime.PrivateKey); err != nil {
+			return err
+		}
+	}
+
+	secret, err := x3dh.ResponderSecret(scheme, signedPrivateKey, oneTimePrivateKey, ephemeralPublicKey)
+	if err != nil {
+		return err
+	}
+	info.CipherKey = encodeCipherKey(info.KeyAgreement, secret)
+	info.PrivateKey = signed.PrivateKey
+	info.MyPublicKey = signed.PublicKey
+	return nil
+}
+
+// consumeOneTimePrekey удаляет использованный одноразовый предключ.
+func (c *ChatClient) consumeOneTimePrekey(id string) error {
+	c.prekeysMu.Lock()
+	defer c.prekeysMu.Unlock()
+
+	store, err := c.loadPrekeys()
+	if err != nil {
+		return err
+	}
+	for i, key := range store.OneTime {
+		if key.ID == id {
+			store.OneTime = append(store.OneTime[:i], store.OneTime[i+1:]...)
+			return c.savePrekeys(store)
+		}
+	}
+	return nil
+}
+
+func (c *ChatClient) loadPrekeys() (*prekeyStore, error) {
+	ks, err := c.keystore()
+	if err != nil {
+		return nil, err
+	}
+	return ks.prekeyStore(), nil
+}
+
+func (c *ChatClient) savePrekeys(store *prekeyStore) error {
+	ks, err := c.keystore()
+	if err != nil {
+		return err
+	}
+	return ks.setPrekeys(store)
+}
+
+func (s *prekeyStore) signedFor(keyAgreement, group string) (storedPrekey, bool) {
+	for _, key := range s.Signed {
+		if key.KeyAgreement == keyAgreement && key.DHGroup == group {
+			return key, true
+		}
+	}
+	return storedPrekey{}, false
+}
+
+func (s *prekeyStore) find(keys []storedPrekey, id string) (storedPrekey, bool) {
+	for _, key := range keys {
+		if key.ID == id {
+			return key, true
+		}
+	}
+	return storedPrekey{}, false
+}
+
+func newStoredPrekey(suite *keyExchange) (storedPrekey, error) {
+	exchange, err := newKeyExchange(suite.KeyAgreement, suite.Group)
+	if err != nil {
+		return storedPrekey{}, err
+	}
+	return storedPrekey{
+		ID:           uuid.New().String(),
+		KeyAgreement: exchange.KeyAgreement,
+		DHGroup:      exchange.Group,
+		PrivateKey:   exchange.PrivateKey,
+		PublicKey:    exchange.PublicKey,
+	}, nil
+}
+
+func (k storedPrekey) proto() *pb.Prekey {
+	return &pb.Prekey{
+		KeyId:        k.ID,
+		KeyAgreement: k.KeyAgreement,
+		DhGroup:      k.DHGroup,
+		PublicKey:    k.PublicKey,
+	}
+}
+
+// encodeCipherKey записывает секрет X3DH в формате CipherKey из room_info.json:
+// для ECDH это hex байтов, для DH — hex числа (roomSecret дополнит его до длины p).
+func encodeCipherKey(keyAgreement string, secret []byte) string {
+	if keyAgreement == domain.KeyAgreementDH {
+		return new(big.Int).SetBytes(secret).Text(16)
+	}
+	return hex.EncodeToString(secret)
+}
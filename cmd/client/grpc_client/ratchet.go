@@ -0,0 +1,195 @@
+package grpc_client
+
+import (
+	"CryptoMessenger/algorithm/ratchet"
+	"CryptoMessenger/algorithm/symmetric"
+	"CryptoMessenger/cmd/client/domain"
+	pb "CryptoMessenger/proto/chatpb"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// Double Ratchet включается при создании комнаты. Состояние хранится в хранилище
+// ключей вместе с секретами комнаты и начинается заново с каждой эпохой ключа: сообщения,
+// зашифрованные в прошлой эпохе храповика, после смены ключа не расшифровываются.
+var ErrRatchetMessageRejected = errors.New("сообщение не подходит к состоянию Double Ratchet")
+
+type ratchetSession struct {
+	Epoch int            `json:"epoch"`
+	State *ratchet.State `json:"state"`
+}
+
+// ratchetEncryptor выдаёт контекст шифрования и заголовок для следующего сообщения.
+// Состояние сохраняется до отправки: потерянное сообщение получатель просто пропустит.
+func (c *ChatClient) ratchetEncryptor(info domain.RoomInfo) (*symmetric.AEADContext, *pb.RatchetHeader, error) {
+	c.ratchetMu.Lock()
+	defer c.ratchetMu.Unlock()
+
+	session, err := c.loadRatchet(info)
+	if err != nil {
+		return nil, nil, err
+	}
+	header, messageKey, err := session.State.Encrypt()
+	if err != nil {
+		return nil, nil, err
+	}
+	if err = c.saveRatchet(info.ID, session); err != nil {
+		return nil, nil, err
+	}
+
+	cipherContext, err := c.ratchetCipher(info, messageKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	return cipherContext, &pb.RatchetHeader{
+		PublicKey:           header.PublicKey,
+		PreviousChainLength: header.PreviousChainLength,
+		MessageNumber:       header.MessageNumber,
+	}, nil
+}
+
+// ratchetDecrypt выводит ключ полученного сообщения и передаёт контекст в decrypt.
+// Новое состояние сохраняется, только если decrypt завершился без ошибки.
+func (c *ChatClient) ratchetDecrypt(info domain.RoomInfo, epoch int, h *pb.RatchetHeader, decrypt func(*symmetric.AEADContext) error) error {
+	if h == nil {
+		return fmt.Errorf("%w: missing header", ErrRatchetMessageRejected)
+	}
+	if epoch != info.Epoch {
+		return fmt.Errorf("%w: %d", ErrUnknownEpoch, epoch)
+	}
+
+	c.ratchetMu.Lock()
+	defer c.ratchetMu.Unlock()
+
+	session, err := c.loadRatchet(info)
+	if err != nil {
+		return err
+	}
+	scheme, err := ratchetDH(info)
+	if err != nil {
+		return err
+	}
+
+	next := session.State.Clone()
+	messageKey, err := next.Decrypt(scheme, ratchet.Header{
+		PublicKey:           h.PublicKey,
+		PreviousChainLength: h.PreviousChainLength,
+		MessageNumber:       h.MessageNumber,
+	})
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrRatchetMessageRejected, err)
+	}
+
+	cipherContext, err := c.ratchetCipher(info, messageKey)
+	if err != nil {
+		return err
+	}
+	if err = decrypt(cipherContext); err != nil {
+		return err
+	}
+
+	session.State = next
+	return c.saveRatchet(info.ID, session)
+}
+
+func (c *ChatClient) ratchetCipher(info domain.RoomInfo, messageKey []byte) (*symmetric.AEADContext, error) {
+	_, prime, g, err := roomSecret(info)
+	if err != nil {
+		return nil, err
+	}
+	return c.newCipherFromSecret(info, messageKey, prime, g)
+}
+
+// loadRatchet читает состояние храповика или создаёт его из ключей текущей эпохи.
+// Инициатором становится участник с меньшим именем, так что роли не нужно согласовывать.
+func (c *ChatClient) loadRatchet(info domain.RoomInfo) (*ratchetSession, error) {
+	ks, err := c.keystore()
+	if err != nil {
+		return nil, err
+	}
+	if data := ks.room(info.ID).Ratchet; len(data) > 0 {
+		var session ratchetSession
+		if err = json.Unmarshal(data, &session); err != nil {
+			return nil, fmt.Errorf("invalid ratchet state: %w", err)
+		}
+		if session.Epoch == info.Epoch && session.State != nil {
+			return &session, nil
+		}
+	}
+
+	if info.CipherKey == "" || info.PrivateKey == "" {
+		return nil, ErrKeyExchangeIncomplete
+	}
+	scheme, err := ratchetDH(info)
+	if err != nil {
+		return nil, err
+	}
+	secret, _, _, err := roomSecret(info)
+	if err != nil {
+		return nil, err
+	}
+	privateKey, err := roomKeyBytes(info, info.PrivateKey)
+	if err != nil {
+		return nil, err
+	}
+	publicKey, err := roomKeyBytes(info, info.MyPublicKey)
+	if err != nil {
+		return nil, err
+	}
+	otherPublicKey, err := roomKeyBytes(info, info.OtherPublicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	newState := ratchet.NewResponder
+	if info.MyClient < info.Companion {
+		newState = ratchet.NewInitiator
+	}
+	state, err := newState(scheme, secret, privateKey, publicKey, otherPublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("could not init ratchet: %w", err)
+	}
+
+	session := &ratchetSession{Epoch: info.Epoch, State: state}
+	if err = c.saveRatchet(info.ID, session); err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+func (c *ChatClient) saveRatchet(roomID string, session *ratchetSession) error {
+	ks, err := c.keystore()
+	if err != nil {
+		return err
+	}
+	out, err := json.Marshal(session)
+	if err != nil {
+		return fmt.Errorf("could not marshal ratchet state: %w", err)
+	}
+	return ks.update(roomID, func(secrets *roomSecrets) {
+		secrets.Ratchet = out
+	})
+}
+
+// ratchetDH возвращает схему комнаты: кривую ECDH или именованную группу DH.
+func ratchetDH(info domain.RoomInfo) (ratchet.DH, error) {
+	return agreementDH(info.KeyAgreement, info.DHGroup)
+}
+
+// roomKeyBytes переводит ключ комнаты из hex в байты схемы храповика.
+func roomKeyBytes(info domain.RoomInfo, keyHex string) ([]byte, error) {
+	return keyBytes(info.KeyAgreement, info.DHGroup, keyHex)
+}
+
+// ratchetAdditionalData добавляет заголовок храповика к дополнительным данным сообщения.
+func ratchetAdditionalData(aad []byte, h *pb.RatchetHeader) []byte {
+	if h == nil {
+		return aad
+	}
+	return append(aad, ratchet.Header{
+		PublicKey:           h.PublicKey,
+		PreviousChainLength: h.PreviousChainLength,
+		MessageNumber:       h.MessageNumber,
+	}.Bytes()...)
+}
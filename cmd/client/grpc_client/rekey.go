@@ -0,0 +1,301 @@
+package grpc_client
+
+import (
+	"CryptoMessenger/algorithm/identity"
+	"CryptoMessenger/algorithm/symmetric"
+	"CryptoMessenger/cmd/client/domain"
+	pb "CryptoMessenger/proto/chatpb"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// Смена ключа комнаты: участник отправляет новый открытый ключ для эпохи N+1,
+// собеседник отвечает своим, и оба выводят новый ключ. Сообщения несут номер эпохи,
+// ключи прошлых эпох остаются в хранилище ключей. Если оба предложили смену одновременно,
+// продолжается предложение участника с меньшим именем.
+var ErrUnknownEpoch = errors.New("неизвестная эпоха ключа")
+
+// roomCipher возвращает контекст шифрования для эпохи epoch, создавая его при первом обращении.
+func (c *ChatClient) roomCipher(info domain.RoomInfo, epoch int) (*symmetric.AEADContext, error) {
+	cacheKey := info.ID + "/" + strconv.Itoa(epoch)
+	if raw, ok := c.CipherContext.Load(cacheKey); ok {
+		cipherContext, ok := raw.(*symmetric.AEADContext)
+		if !ok {
+			return nil, fmt.Errorf("invalid cipher context type for room %s", info.ID)
+		}
+		return cipherContext, nil
+	}
+
+	epochInfo, err := roomInfoForEpoch(info, epoch)
+	if err != nil {
+		return nil, err
+	}
+	cipherContext, err := c.newRoomCipher(epochInfo)
+	if err != nil {
+		return nil, err
+	}
+	c.CipherContext.Store(cacheKey, cipherContext)
+	return cipherContext, nil
+}
+
+func roomInfoForEpoch(info domain.RoomInfo, epoch int) (domain.RoomInfo, error) {
+	if epoch == info.Epoch {
+		return info, nil
+	}
+	for _, past := range info.PastEpochs {
+		if past.Epoch == epoch {
+			info.Epoch = past.Epoch
+			info.CipherKey = past.CipherKey
+			return info, nil
+		}
+	}
+	return domain.RoomInfo{}, fmt.Errorf("%w: %d", ErrUnknownEpoch, epoch)
+}
+
+// RotateKey предлагает собеседнику новую эпоху ключа. Пока ответа нет, повторный вызов
+// отправляет то же предложение.
+func (c *ChatClient) RotateKey(roomID string) error {
+	ctx, cancel := context.WithTimeout(c.AuthenticatedContext(), 5*time.Second)
+	defer cancel()
+
+	c.rekeyMu.Lock()
+	defer c.rekeyMu.Unlock()
+
+	info, err := c.loadRoomInfoFromDisk(roomID)
+	if err != nil {
+		return err
+	}
+	if info.CipherKey == "" {
+		return ErrKeyExchangeIncomplete
+	}
+
+	if info.PendingRekey == nil {
+		exchange, err := newKeyExchange(info.KeyAgreement, info.DHGroup)
+		if err != nil {
+			return err
+		}
+		info.PendingRekey = &domain.PendingRekey{
+			Epoch:      info.Epoch + 1,
+			PrivateKey: exchange.PrivateKey,
+			PublicKey:  exchange.PublicKey,
+		}
+		if err = c.writeRoomInfoToDisk(info); err != nil {
+			return err
+		}
+	}
+
+	return c.sendKeyUpdate(ctx, info, info.PendingRekey.PublicKey, info.PendingRekey.Epoch, false)
+}
+
+// DeleteRoom сообщает собеседнику об удалении комнаты и удаляет её локально.
+func (c *ChatClient) DeleteRoom(roomID string) error {
+	ctx, cancel := context.WithTimeout(c.AuthenticatedContext(), 5*time.Second)
+	defer cancel()
+
+	c.rekeyMu.Lock()
+	defer c.rekeyMu.Unlock()
+
+	info, err := c.loadRoomInfoFromDisk(roomID)
+	if err != nil {
+		return err
+	}
+	// Сервер отказывает, если собеседник уже вышел или отклонил приглашение:
+	// сообщать некому, и комната всё равно удаляется
+	err = c.sendKeyUpdate(ctx, info, "", 0, false)
+	if status.Code(err) == codes.PermissionDenied {
+		slog.Warn("room deletion notice was not delivered", "room", roomID, "err", err)
+	} else if err != nil {
+		return err
+	}
+	c.leaveRoom(ctx, roomID)
+	return c.removeRoom(info)
+}
+
+// leaveRoom выходит из комнаты на сервере; последний вышедший удаляет её.
+// Комнаты, созданные до учёта участников, на сервере не найдутся — это не ошибка.
+func (c *ChatClient) leaveRoom(ctx context.Context, roomID string) {
+	_, err := c.client.LeaveRoom(ctx, &pb.LeaveRoomRequest{RoomId: roomID})
+	if err != nil && status.Code(err) != codes.NotFound {
+		slog.Warn("could not leave room", "room", roomID, "err", err)
+	}
+}
+
+func (c *ChatClient) sendKeyUpdate(ctx context.Context, info domain.RoomInfo, publicKey string, epoch int, answer bool) error {
+	if c.identityKey == nil {
+		return errors.New("identity key is not loaded")
+	}
+
+	req := &pb.UpdateCipherKeyRequest{
+		UserName:  info.Companion,
+		ChatId:    info.ID,
+		PublicKey: publicKey,
+		MessageId: uuid.New().String(),
+		Epoch:     int32(epoch),
+		Answer:    answer,
+	}
+	req.Signature = identity.Sign(c.identityKey, identity.RekeyContext, keyUpdateFields(c.username, info.Companion, req)...)
+
+	if _, err := c.client.UpdateOrDeleteCipherKey(ctx, req); err != nil {
+		return fmt.Errorf("could not send key update: %w", err)
+	}
+	return nil
+}
+
+func keyUpdateFields(sender, receiver string, req *pb.UpdateCipherKeyRequest) []string {
+	return []string{
+		sender, receiver, req.ChatId, strconv.Itoa(int(req.Epoch)), strconv.FormatBool(req.Answer), req.PublicKey,
+	}
+}
+
+// receiveCipherKeyUpdate обрабатывает сообщение о смене ключа или удалении комнаты.
+// Пустой KeyUpdate означает, что сообщение устарело.
+func (c *ChatClient) receiveCipherKeyUpdate(ctx context.Context, req *pb.UpdateCipherKeyRequest) (domain.KeyUpdate, error) {
+	publicKey, err := c.peerIdentityKey(ctx, req.SenderName)
+	if err == nil {
+		err = identity.Verify(publicKey, req.Signature, identity.RekeyContext, keyUpdateFields(req.SenderName, c.username, req)...)
+	}
+	if err != nil && !isForgedKeyExchange(err) {
+		return domain.KeyUpdate{}, err
+	}
+
+	if ackErr := c.ackEvent(ctx, req.MessageId); ackErr != nil {
+		return domain.KeyUpdate{}, fmt.Errorf("ack event: %w", ackErr)
+	}
+	if err != nil {
+		return domain.KeyUpdate{}, fmt.Errorf("key update from %s rejected: %w", req.SenderName, err)
+	}
+
+	c.rekeyMu.Lock()
+	defer c.rekeyMu.Unlock()
+
+	info, err := c.loadRoomInfoFromDisk(req.ChatId)
+	if errors.Is(err, os.ErrNotExist) {
+		return domain.KeyUpdate{}, nil
+	}
+	if err != nil {
+		return domain.KeyUpdate{}, err
+	}
+	if info.Companion != req.SenderName {
+		return domain.KeyUpdate{}, fmt.Errorf("key update for room %s from non-participant %s", info.ID, req.SenderName)
+	}
+
+	if req.PublicKey == "" {
+		c.leaveRoom(ctx, info.ID)
+		if err = c.removeRoom(info); err != nil {
+			return domain.KeyUpdate{}, err
+		}
+		return domain.KeyUpdate{RoomID: info.ID, Companion: info.Companion, Deleted: true}, nil
+	}
+
+	epoch := int(req.Epoch)
+	if req.Answer {
+		return c.applyRekeyAnswer(info, epoch, req.PublicKey)
+	}
+	return c.answerRekeyOffer(ctx, info, epoch, req.PublicKey)
+}
+
+func (c *ChatClient) answerRekeyOffer(ctx context.Context, info domain.RoomInfo, epoch int, otherPublicKey string) (domain.KeyUpdate, error) {
+	// Повтор предложения, на которое мы уже ответили: наш ответ мог потеряться.
+	if epoch == info.Epoch && otherPublicKey == info.OtherPublicKey {
+		return domain.KeyUpdate{}, c.sendKeyUpdate(ctx, info, info.MyPublicKey, info.Epoch, true)
+	}
+	if epoch != info.Epoch+1 {
+		slog.Warn("stale key update offer", "room", info.ID, "epoch", epoch, "current", info.Epoch)
+		return domain.KeyUpdate{}, nil
+	}
+	if info.PendingRekey != nil && info.PendingRekey.Epoch == epoch && c.username < info.Companion {
+		// Встречные предложения: собеседник ответит на наше.
+		return domain.KeyUpdate{}, nil
+	}
+
+	exchange, err := newKeyExchange(info.KeyAgreement, info.DHGroup)
+	if err != nil {
+		return domain.KeyUpdate{}, err
+	}
+	cipherKey, err := computeCipherKey(info, exchange.PrivateKey, otherPublicKey)
+	if err != nil {
+		return domain.KeyUpdate{}, fmt.Errorf("key update from %s: %w", info.Companion, err)
+	}
+
+	advanceEpoch(&info, epoch, cipherKey, exchange.PrivateKey, exchange.PublicKey, otherPublicKey)
+	if err = c.writeRoomInfoToDisk(info); err != nil {
+		return domain.KeyUpdate{}, err
+	}
+	if err = c.sendKeyUpdate(ctx, info, exchange.PublicKey, epoch, true); err != nil {
+		return domain.KeyUpdate{}, err
+	}
+	return domain.KeyUpdate{RoomID: info.ID, Companion: info.Companion, Epoch: epoch}, nil
+}
+
+func (c *ChatClient) applyRekeyAnswer(info domain.RoomInfo, epoch int, otherPublicKey string) (domain.KeyUpdate, error) {
+	pending := info.PendingRekey
+	if pending == nil || pending.Epoch != epoch {
+		slog.Warn("unexpected key update answer", "room", info.ID, "epoch", epoch, "current", info.Epoch)
+		return domain.KeyUpdate{}, nil
+	}
+
+	cipherKey, err := computeCipherKey(info, pending.PrivateKey, otherPublicKey)
+	if err != nil {
+		return domain.KeyUpdate{}, fmt.Errorf("key update from %s: %w", info.Companion, err)
+	}
+
+	advanceEpoch(&info, epoch, cipherKey, pending.PrivateKey, pending.PublicKey, otherPublicKey)
+	if err = c.writeRoomInfoToDisk(info); err != nil {
+		return domain.KeyUpdate{}, err
+	}
+	return domain.KeyUpdate{RoomID: info.ID, Companion: info.Companion, Epoch: epoch}, nil
+}
+
+// advanceEpoch переносит текущий ключ в прошлые эпохи и устанавливает новый.
+// Новые открытые ключи подписаны закреплённым ключом идентичности собеседника,
+// поэтому подтверждённый номер безопасности переносится на новую эпоху.
+func advanceEpoch(info *domain.RoomInfo, epoch int, cipherKey, privateKey, myPublicKey, otherPublicKey string) {
+	oldNumber, err := roomSafetyNumber(*info)
+	verified := err == nil && info.Verified && info.VerifiedSafetyNumber == oldNumber
+
+	info.PastEpochs = append(info.PastEpochs, domain.KeyEpoch{
+		Epoch:          info.Epoch,
+		CipherKey:      info.CipherKey,
+		MyPublicKey:    info.MyPublicKey,
+		OtherPublicKey: info.OtherPublicKey,
+	})
+	info.Epoch = epoch
+	info.CipherKey = cipherKey
+	info.PrivateKey = privateKey
+	info.MyPublicKey = myPublicKey
+	info.OtherPublicKey = otherPublicKey
+	info.PendingRekey = nil
+
+	if verified {
+		if number, err := roomSafetyNumber(*info); err == nil {
+			info.VerifiedSafetyNumber = number
+		}
+	}
+}
+
+func (c *ChatClient) removeRoom(info domain.RoomInfo) error {
+	for _, past := range info.PastEpochs {
+		c.CipherContext.Delete(info.ID + "/" + strconv.Itoa(past.Epoch))
+	}
+	c.CipherContext.Delete(info.ID + "/" + strconv.Itoa(info.Epoch))
+
+	if err := os.RemoveAll(filepath.Join("cmd", "client", "users", c.UserID, "chats", info.ID)); err != nil {
+		return fmt.Errorf("could not remove room %s: %w", info.ID, err)
+	}
+	if ks, err := c.keystore(); err == nil {
+		if err = ks.remove(info.ID); err != nil {
+			return err
+		}
+	}
+	c.notifyRoom(info.ID)
+	return nil
+}
@@ -0,0 +1,430 @@
+package grpc_client
+
+import (
+	"CryptoMessenger/cmd/client/domain"
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Локальная история (chat.jsonl) и принятые файлы шифруются ключом хранения
+// из хранилища ключей. Каждая строка истории — base64(nonce || AES-GCM(JSON)),
+// файл — последовательность сегментов AES-GCM. Расшифровываются они только
+// при показе чата или открытии файла.
+var ErrCorruptedStorage = errors.New("локальная история повреждена или зашифрована другим ключом")
+
+// ErrInvalidMessageID — ID сообщения или файла не UUID. ID выбирает отправитель,
+// а принятые файлы называются по ним, поэтому другие значения не принимаются.
+var ErrInvalidMessageID = errors.New("недопустимый идентификатор сообщения")
+
+const (
+	storedFileMagic = "CMSF1"
+	// storedFileSegment — размер открытого текста в одном сегменте файла.
+	storedFileSegment = 64 * 1024
+	// storedFilePrefixLen — случайная часть nonce; остальное — номер сегмента и флаг последнего.
+	storedFilePrefixLen = 7
+)
+
+func (c *ChatClient) chatHistoryPath(roomID string) string {
+	return filepath.Join("cmd", "client", "users", c.UserID, "chats", roomID, "chat.jsonl")
+}
+
+func (c *ChatClient) roomFilesDir(roomID string) string {
+	return filepath.Join("cmd", "client", "users", c.UserID, "chats", roomID, "files")
+}
+
+// openedFilesDir — куда расшифровываются файлы, открытые пользователем; очищается при выходе.
+func (c *ChatClient) openedFilesDir() string {
+	return filepath.Join(os.TempDir(), "cryptomessenger-"+c.UserID)
+}
+
+func (c *ChatClient) storageCipher() (cipher.AEAD, error) {
+	ks, err := c.keystore()
+	if err != nil {
+		return nil, err
+	}
+	return storageAEAD(ks.storageKey)
+}
+
+func storageAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// sealHistoryLine шифрует запись истории; комната входит в AAD,
+// чтобы строки нельзя было перенести в другой чат.
+func sealHistoryLine(aead cipher.AEAD, roomID string, msg domain.StoredMessage) ([]byte, error) {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return nil, fmt.Errorf("marshal message: %w", err)
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err = rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("could not generate nonce: %w", err)
+	}
+	sealed := aead.Seal(nonce, nonce, data, []byte(roomID))
+
+	line := make([]byte, base64.StdEncoding.EncodedLen(len(sealed)), base64.StdEncoding.EncodedLen(len(sealed))+1)
+	base64.StdEncoding.Encode(line, sealed)
+	return append(line, '\n'), nil
+}
+
+// openHistoryLine расшифровывает строку истории. Строки, записанные до шифрования
+// истории, — обычный JSON; они читаются как есть и переписываются при входе.
+func openHistoryLine(aead cipher.AEAD, roomID string, line []byte) (domain.StoredMessage, bool, error) {
+	var msg domain.StoredMessage
+	if bytes.HasPrefix(line, []byte("{")) {
+		if err := json.Unmarshal(line, &msg); err != nil {
+			return msg, true, fmt.Errorf("invalid history line: %w", err)
+		}
+		return msg, true, nil
+	}
+
+	sealed, err := base64.StdEncoding.DecodeString(string(line))
+	if err != nil || len(sealed) < aead.NonceSize() {
+		return msg, false, ErrCorruptedStorage
+	}
+	data, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], []byte(roomID))
+	if err != nil {
+		return msg, false, ErrCorruptedStorage
+	}
+	if err = json.Unmarshal(data, &msg); err != nil {
+		return msg, false, fmt.Errorf("invalid history line: %w", err)
+	}
+	return msg, false, nil
+}
+
+// LoadChatHistory расшифровывает историю комнаты. Повреждённые строки пропускаются.
+func (c *ChatClient) LoadChatHistory(roomID string) ([]domain.StoredMessage, error) {
+	aead, err := c.storageCipher()
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(c.chatHistoryPath(roomID))
+	if err != nil {
+		return nil, fmt.Errorf("open chat file: %w", err)
+	}
+
+	var messages []domain.StoredMessage
+	for _, line := range bytes.Split(data, []byte("\n")) {
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+		msg, _, err := openHistoryLine(aead, roomID, line)
+		if err != nil {
+			slog.Warn("skip history line", "room", roomID, "err", err)
+			continue
+		}
+		messages = append(messages, msg)
+	}
+	return messages, nil
+}
+
+// storedFileWriter шифрует файл сегментами по мере записи. Nonce сегмента —
+// случайный префикс, номер сегмента и флаг последнего, поэтому обрезанный
+// или переставленный файл не расшифруется.
+type storedFileWriter struct {
+	f      *os.File
+	aead   cipher.AEAD
+	prefix []byte
+	aad    []byte
+	buf    []byte
+	index  uint32
+}
+
+func newStoredFileWriter(aead cipher.AEAD, path string) (*storedFileWriter, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, fmt.Errorf("mkdir for files: %w", err)
+	}
+	prefix := make([]byte, storedFilePrefixLen)
+	if _, err := rand.Read(prefix); err != nil {
+		return nil, fmt.Errorf("could not generate nonce: %w", err)
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("create stored file: %w", err)
+	}
+	if _, err = f.Write(append([]byte(storedFileMagic), prefix...)); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("write stored file: %w", err)
+	}
+	return &storedFileWriter{f: f, aead: aead, prefix: prefix, aad: []byte(filepath.Base(path))}, nil
+}
+
+func (w *storedFileWriter) Write(p []byte) (int, error) {
+	w.buf = append(w.buf, p...)
+	// Последний сегмент остаётся в буфере до Close, чтобы пометить его флагом
+	for len(w.buf) > storedFileSegment {
+		if err := w.writeSegment(w.buf[:storedFileSegment], false); err != nil {
+			return 0, err
+		}
+		w.buf = w.buf[storedFileSegment:]
+	}
+	return len(p), nil
+}
+
+func (w *storedFileWriter) Close() error {
+	if err := w.writeSegment(w.buf, true); err != nil {
+		w.f.Close()
+		return err
+	}
+	return w.f.Close()
+}
+
+// Abort закрывает и удаляет недописанный файл.
+func (w *storedFileWriter) Abort() {
+	w.f.Close()
+	_ = os.Remove(w.f.Name())
+}
+
+func (w *storedFileWriter) writeSegment(p []byte, last bool) error {
+	sealed := w.aead.Seal(nil, storedFileNonce(w.prefix, w.index, last), p, w.aad)
+	w.index++
+	if _, err := w.f.Write(sealed); err != nil {
+		return fmt.Errorf("write stored file: %w", err)
+	}
+	return nil
+}
+
+func storedFileNonce(prefix []byte, index uint32, last bool) []byte {
+	nonce := append([]byte{}, prefix...)
+	nonce = binary.BigEndian.AppendUint32(nonce, index)
+	if last {
+		return append(nonce, 1)
+	}
+	return append(nonce, 0)
+}
+
+// decryptStoredFile расшифровывает файл из хранилища в w.
+func decryptStoredFile(aead cipher.AEAD, path string, w io.Writer) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("open stored file: %w", err)
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+	header := make([]byte, len(storedFileMagic)+storedFilePrefixLen)
+	if _, err = io.ReadFull(r, header); err != nil || string(header[:len(storedFileMagic)]) != storedFileMagic {
+		return ErrCorruptedStorage
+	}
+	prefix := header[len(storedFileMagic):]
+	aad := []byte(filepath.Base(path))
+
+	segment := make([]byte, storedFileSegment+aead.Overhead())
+	for index := uint32(0); ; index++ {
+		n, err := io.ReadFull(r, segment)
+		if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+			return fmt.Errorf("read stored file: %w", err)
+		}
+		_, peekErr := r.Peek(1)
+		last := errors.Is(peekErr, io.EOF)
+
+		plain, err := aead.Open(segment[:0], storedFileNonce(prefix, index, last), segment[:n], aad)
+		if err != nil {
+			return ErrCorruptedStorage
+		}
+		if _, err = w.Write(plain); err != nil {
+			return fmt.Errorf("write decrypted file: %w", err)
+		}
+		if last {
+			return nil
+		}
+	}
+}
+
+// StoredFileBytes расшифровывает принятый файл в память (для предпросмотра картинок).
+func (c *ChatClient) StoredFileBytes(msg domain.StoredMessage) ([]byte, error) {
+	if !msg.Encrypted {
+		return os.ReadFile(msg.Filepath)
+	}
+	aead, err := c.storageCipher()
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err = decryptStoredFile(aead, msg.Filepath, &buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// OpenStoredFile расшифровывает принятый файл во временный каталог и возвращает
+// путь к копии, которую можно открыть внешней программой. Отправленные файлы
+// лежат там, откуда их выбрал пользователь, и возвращаются как есть.
+func (c *ChatClient) OpenStoredFile(msg domain.StoredMessage) (string, error) {
+	if !msg.Encrypted {
+		return msg.Filepath, nil
+	}
+	aead, err := c.storageCipher()
+	if err != nil {
+		return "", err
+	}
+
+	dir := filepath.Join(c.openedFilesDir(), filepath.Base(msg.Filepath))
+	if err = os.MkdirAll(dir, 0o700); err != nil {
+		return "", fmt.Errorf("mkdir for opened file: %w", err)
+	}
+	path := filepath.Join(dir, filepath.Base(msg.Filename))
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o600)
+	if err != nil {
+		return "", fmt.Errorf("create opened file: %w", err)
+	}
+	defer f.Close()
+
+	if err = decryptStoredFile(aead, msg.Filepath, f); err != nil {
+		_ = os.Remove(path)
+		return "", err
+	}
+	return path, nil
+}
+
+func (c *ChatClient) removeOpenedFiles() {
+	if c.UserID == "" {
+		return
+	}
+	if err := os.RemoveAll(c.openedFilesDir()); err != nil {
+		slog.Warn("could not remove opened files", "err", err)
+	}
+}
+
+// migratePlaintextHistory шифрует историю и принятые файлы, сохранённые до
+// появления ключа хранения. История переписывается целиком через rename.
+func (c *ChatClient) migratePlaintextHistory(ks *keystore) error {
+	aead, err := storageAEAD(ks.storageKey)
+	if err != nil {
+		return err
+	}
+
+	dir := filepath.Join("cmd", "client", "users", c.UserID, "chats")
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("could not read chats dir: %w", err)
+	}
+
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		if err = c.migrateRoomHistory(aead, entry.Name()); err != nil {
+			slog.Warn("skip history migration", "room", entry.Name(), "err", err)
+		}
+	}
+	return nil
+}
+
+func (c *ChatClient) migrateRoomHistory(aead cipher.AEAD, roomID string) error {
+	path := c.chatHistoryPath(roomID)
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("open chat file: %w", err)
+	}
+
+	var out []byte
+	var plaintextFiles []string
+	migrated := 0
+	for _, line := range bytes.Split(data, []byte("\n")) {
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+		msg, legacy, err := openHistoryLine(aead, roomID, line)
+		if err != nil || !legacy {
+			// Уже зашифрованные и нечитаемые строки переносятся без изменений
+			out = append(append(out, line...), '\n')
+			continue
+		}
+
+		if msg.Type == "file" && !msg.Encrypted && c.isRoomFile(roomID, msg.Filepath) {
+			if !isUUID(msg.MessageID) {
+				msg.MessageID = uuid.New().String()
+			}
+			storedPath := filepath.Join(c.roomFilesDir(roomID), msg.MessageID)
+			if err = sealPlaintextFile(aead, msg.Filepath, storedPath); err == nil {
+				plaintextFiles = append(plaintextFiles, msg.Filepath)
+				msg.Filepath = storedPath
+				msg.Encrypted = true
+			} else if !errors.Is(err, os.ErrNotExist) {
+				return err
+			}
+		}
+
+		sealed, err := sealHistoryLine(aead, roomID, msg)
+		if err != nil {
+			return err
+		}
+		out = append(out, sealed...)
+		migrated++
+	}
+	if migrated == 0 {
+		return nil
+	}
+
+	tmp := path + ".tmp"
+	if err = os.WriteFile(tmp, out, 0o600); err != nil {
+		return fmt.Errorf("write chat file: %w", err)
+	}
+	if err = os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("replace chat file: %w", err)
+	}
+	for _, file := range plaintextFiles {
+		if err = os.Remove(file); err != nil {
+			slog.Warn("could not remove plaintext file", "path", file, "err", err)
+		}
+	}
+	slog.Info("chat history encrypted", "room", roomID, "messages", migrated, "files", len(plaintextFiles))
+	return nil
+}
+
+// isRoomFile — файл лежит в каталоге принятых файлов комнаты (а не у пользователя на диске).
+func (c *ChatClient) isRoomFile(roomID, path string) bool {
+	rel, err := filepath.Rel(c.roomFilesDir(roomID), path)
+	return err == nil && !strings.HasPrefix(rel, "..") && rel != "."
+}
+
+func sealPlaintextFile(aead cipher.AEAD, src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	w, err := newStoredFileWriter(aead, dst)
+	if err != nil {
+		return err
+	}
+	if _, err = io.Copy(w, in); err != nil {
+		w.Abort()
+		return fmt.Errorf("encrypt file: %w", err)
+	}
+	return w.Close()
+}
+
+// isUUID принимает только каноническую запись UUID, без скобок и префикса urn.
+func isUUID(s string) bool {
+	id, err := uuid.Parse(s)
+	return err == nil && id.String() == s
+}
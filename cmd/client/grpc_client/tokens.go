@@ -0,0 +1,72 @@
+package grpc_client
+
+import (
+	pb "CryptoMessenger/proto/chatpb"
+	"context"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"log/slog"
+	"time"
+)
+
+const (
+	// refreshBefore — за сколько до истечения токена доступа клиент его обновляет.
+	refreshBefore  = time.Minute
+	refreshTimeout = 5 * time.Second
+)
+
+func (c *ChatClient) setTokens(access, refresh string, expiresAt *timestamppb.Timestamp) {
+	c.tokenMu.Lock()
+	defer c.tokenMu.Unlock()
+	c.authToken, c.refreshToken = access, refresh
+	c.tokenExpiry = expiresAt.AsTime()
+}
+
+// accessToken возвращает токен доступа, при необходимости заранее обновив его.
+// Если обновить не удалось, возвращается текущий токен: пока он не истёк, он действует.
+func (c *ChatClient) accessToken(ctx context.Context) string {
+	c.tokenMu.Lock()
+	defer c.tokenMu.Unlock()
+
+	if c.refreshToken == "" || time.Until(c.tokenExpiry) > refreshBefore {
+		return c.authToken
+	}
+
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), refreshTimeout)
+	defer cancel()
+	resp, err := c.client.Refresh(ctx, &pb.RefreshRequest{RefreshToken: c.refreshToken})
+	if err != nil {
+		slog.Warn("could not refresh access token", "err", err)
+		return c.authToken
+	}
+	c.authToken, c.refreshToken = resp.Token, resp.RefreshToken
+	c.tokenExpiry = resp.ExpiresAt.AsTime()
+	return c.authToken
+}
+
+func (c *ChatClient) AuthenticatedContext() context.Context {
+	return c.withAuth(context.Background())
+}
+
+func (c *ChatClient) withAuth(ctx context.Context) context.Context {
+	md := metadata.New(map[string]string{
+		"authorization": "Bearer " + c.accessToken(ctx),
+	})
+	return metadata.NewOutgoingContext(ctx, md)
+}
+
+// Logout отзывает токены на сервере. Локально они забываются в любом случае,
+// даже если сервер недоступен.
+func (c *ChatClient) Logout() error {
+	ctx, cancel := context.WithTimeout(c.AuthenticatedContext(), refreshTimeout)
+	defer cancel()
+
+	c.tokenMu.Lock()
+	refresh := c.refreshToken
+	c.authToken, c.refreshToken = "", ""
+	c.tokenExpiry = time.Time{}
+	c.tokenMu.Unlock()
+
+	_, err := c.client.Logout(ctx, &pb.LogoutRequest{RefreshToken: refresh})
+	return err
+}
@@ -0,0 +1,113 @@
+package grpc_client
+
+import (
+	"CryptoMessenger/algorithm/fingerprint"
+	"CryptoMessenger/cmd/client/domain"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/big"
+)
+
+var ErrKeyExchangeIncomplete = errors.New("собеседник ещё не принял приглашение")
+
+// RoomVerification — номер безопасности комнаты и состояние его проверки.
+type RoomVerification struct {
+	SafetyNumber string
+	Verified     bool
+	// KeyChanged — ключи изменились после того, как номер был подтверждён.
+	KeyChanged bool
+}
+
+func (c *ChatClient) RoomInfo(roomID string) (domain.RoomInfo, error) {
+	return c.loadRoomInfoFromDisk(roomID)
+}
+
+// RoomVerification вычисляет номер безопасности по открытым ключам обоих участников.
+// Если ключи изменились после подтверждения, отметка снимается на диске.
+func (c *ChatClient) RoomVerification(roomID string) (RoomVerification, error) {
+	info, err := c.loadRoomInfoFromDisk(roomID)
+	if err != nil {
+		return RoomVerification{}, err
+	}
+
+	number, err := roomSafetyNumber(info)
+	if err != nil {
+		return RoomVerification{}, err
+	}
+
+	result := RoomVerification{
+		SafetyNumber: number,
+		Verified:     info.Verified && info.VerifiedSafetyNumber == number,
+		KeyChanged:   info.VerifiedSafetyNumber != "" && info.VerifiedSafetyNumber != number,
+	}
+
+	if result.KeyChanged && info.Verified {
+		slog.Warn("safety number changed, verification cleared", "room", roomID, "companion", info.Companion)
+		info.Verified = false
+		if err = c.writeRoomInfoToDisk(info); err != nil {
+			return RoomVerification{}, err
+		}
+	}
+	return result, nil
+}
+
+// SetRoomVerified отмечает текущий номер безопасности как сверенный с собеседником или снимает отметку.
+func (c *ChatClient) SetRoomVerified(roomID string, verified bool) error {
+	info, err := c.loadRoomInfoFromDisk(roomID)
+	if err != nil {
+		return err
+	}
+
+	info.Verified = verified
+	info.VerifiedSafetyNumber = ""
+	if verified {
+		if info.VerifiedSafetyNumber, err = roomSafetyNumber(info); err != nil {
+			return err
+		}
+	}
+	return c.writeRoomInfoToDisk(info)
+}
+
+func roomSafetyNumber(info domain.RoomInfo) (string, error) {
+	if info.CipherKey == "" || info.MyPublicKey == "" || info.OtherPublicKey == "" {
+		return "", ErrKeyExchangeIncomplete
+	}
+
+	myKey, err := publicKeyBytes(info, info.MyPublicKey)
+	if err != nil {
+		return "", err
+	}
+	otherKey, err := publicKeyBytes(info, info.OtherPublicKey)
+	if err != nil {
+		return "", err
+	}
+
+	return fingerprint.SafetyNumber(info.ID,
+		fingerprint.Party{Name: info.MyClient, PublicKey: myKey},
+		fingerprint.Party{Name: info.Companion, PublicKey: otherKey},
+	), nil
+}
+
+// publicKeyBytes приводит открытый ключ к байтам: для ECDH это его кодировка,
+// для DH — число без ведущих нулей, чтобы запись в hex не влияла на результат.
+func publicKeyBytes(info domain.RoomInfo, keyHex string) ([]byte, error) {
+	scheme, err := ecdhScheme(info.KeyAgreement)
+	if err != nil {
+		return nil, err
+	}
+	if scheme != nil {
+		key, err := hex.DecodeString(keyHex)
+		if err != nil {
+			return nil, fmt.Errorf("invalid public key hex: %w", err)
+		}
+		return key, nil
+	}
+
+	key, ok := new(big.Int).SetString(keyHex, 16)
+	if !ok {
+		return nil, fmt.Errorf("invalid public key hex")
+	}
+	return key.Bytes(), nil
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"CryptoMessenger/cmd/client/ui"
+	"flag"
 	"log"
 
 	"fyne.io/fyne/v2"
@@ -31,11 +32,21 @@ import (
 //}
 
 func main() {
+	addr := flag.String("addr", "localhost:50051", "адрес сервера")
+	var transport grpc_client.TransportConfig
+	flag.StringVar(&transport.CAFile, "ca", "", "PEM с сертификатом CA сервера (по умолчанию системные)")
+	flag.StringVar(&transport.PinnedCertSHA256, "pin", "", "SHA-256 сертификата сервера в hex")
+	flag.StringVar(&transport.CertFile, "cert", "", "сертификат клиента для mTLS")
+	flag.StringVar(&transport.KeyFile, "key", "", "ключ сертификата клиента")
+	flag.StringVar(&transport.ServerName, "server-name", "", "имя сервера в сертификате, если отличается от адреса")
+	flag.BoolVar(&transport.Insecure, "insecure", false, "подключаться без TLS (только для отладки)")
+	flag.Parse()
+
 	a := app.New()
 	w := a.NewWindow("CryptoMessenger")
 	w.Resize(fyne.NewSize(800, 600))
 
-	chatClient, err := grpc_client.NewChatClient("localhost:50051")
+	chatClient, err := grpc_client.NewChatClient(*addr, transport)
 	if err != nil {
 		log.Fatalf("cannot connect to server: %v", err)
 	}
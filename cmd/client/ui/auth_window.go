@@ -64,6 +64,9 @@ func (a *AuthWindow) Show() {
 	entryName.SetPlaceHolder("Enter your username")
 	entryPassword := widget.NewPasswordEntry()
 	entryPassword.SetPlaceHolder("Enter your password")
+	// Парольная фраза открывает локальное хранилище ключей комнат и на сервер не отправляется
+	entryPassphrase := widget.NewPasswordEntry()
+	entryPassphrase.SetPlaceHolder("Enter your keystore passphrase")
 	validate := func() error {
 		if entryName.Text == "" {
 			return fmt.Errorf("username cannot be empty")
@@ -71,6 +74,9 @@ func (a *AuthWindow) Show() {
 		if entryPassword.Text == "" {
 			return fmt.Errorf("password cannot be empty")
 		}
+		if entryPassphrase.Text == "" {
+			return fmt.Errorf("keystore passphrase cannot be empty")
+		}
 		return nil
 	}
 
@@ -83,6 +89,9 @@ func (a *AuthWindow) Show() {
 		pd.Show()
 		go func() {
 			err := a.chatClient.RegisterUser(entryName.Text, entryPassword.Text)
+			if err == nil {
+				err = a.chatClient.UnlockKeystore(entryPassphrase.Text)
+			}
 			pd.Hide()
 			if err != nil {
 				dialog.ShowError(err, a.window)
@@ -107,6 +116,10 @@ func (a *AuthWindow) Show() {
 				dialog.ShowError(fmt.Errorf("Invalid credentials"), a.window)
 				return
 			}
+			if err = a.chatClient.UnlockKeystore(entryPassphrase.Text); err != nil {
+				dialog.ShowError(err, a.window)
+				return
+			}
 			info := dialog.NewInformation("Welcome Back!", "Authentication successful", a.window)
 			info.SetOnClosed(func() { a.onSuccess(entryName.Text) })
 			info.Show()
@@ -120,6 +133,7 @@ func (a *AuthWindow) Show() {
 		layout.NewSpacer(),
 		entryName,
 		entryPassword,
+		entryPassphrase,
 		layout.NewSpacer(),
 		container.NewGridWithColumns(2, btnReg, btnLog),
 	)
@@ -128,7 +142,7 @@ func (a *AuthWindow) Show() {
 	//    — для полностью непрозрачной карточки: A = 255
 	//    — для 30% прозрачности: A ≈ 77
 	cardBg := canvas.NewRectangle(color.NRGBA{R: 255, G: 255, B: 255, A: 77})
-	cardBg.SetMinSize(fyne.NewSize(400, 280))
+	cardBg.SetMinSize(fyne.NewSize(400, 320))
 
 	cardContent := container.NewMax(cardBg, container.NewPadded(form))
 
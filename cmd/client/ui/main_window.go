@@ -1,6 +1,9 @@
 package ui
 
 import (
+	"CryptoMessenger/algorithm/ecdh"
+	"CryptoMessenger/algorithm/fingerprint"
+	"CryptoMessenger/algorithm/symmetric"
 	"CryptoMessenger/cmd/client/domain"
 	"CryptoMessenger/cmd/client/grpc_client"
 	"context"
@@ -12,16 +15,15 @@ import (
 	"fyne.io/fyne/v2/container"
 	"fyne.io/fyne/v2/dialog"
 	"fyne.io/fyne/v2/layout"
-	"fyne.io/fyne/v2/storage"
 	"fyne.io/fyne/v2/theme"
 	"fyne.io/fyne/v2/widget"
 	"image/color"
-	"log"
 	"log/slog"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"runtime"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -43,6 +45,7 @@ type MainWindow struct {
 	cancelButton      *widget.Button
 	progressBar       *widget.ProgressBar
 	cancelSending     context.CancelFunc
+	stopEvents        context.CancelFunc
 	onLogout          func()
 }
 
@@ -59,11 +62,10 @@ func (m *MainWindow) Show() {
 	// Размеры окна
 	m.window.Resize(fyne.NewSize(800, 600))
 
-	go m.checkInvitationsPeriodically()
-	go m.checkInvitationResponsesPeriodically()
-	go m.checkClearChatRequestsPeriodically()
-	go m.getMessages()
-	go m.refreshChat()
+	eventsCtx, stopEvents := context.WithCancel(context.Background())
+	m.stopEvents = stopEvents
+	go m.chatClient.Subscribe(eventsCtx)
+	go m.handleEvents(eventsCtx)
 
 	// Фоновая картинка
 	bgImage := canvas.NewImageFromFile("cmd/client/ui/test.jpg")
@@ -212,6 +214,13 @@ func (m *MainWindow) Show() {
 		if m.cancelSending != nil {
 			m.cancelSending()
 		}
+		if m.stopEvents != nil {
+			m.stopEvents()
+		}
+		if err := m.chatClient.Logout(); err != nil {
+			slog.Warn("logout failed", "err", err)
+		}
+		m.chatClient.LockKeystore()
 		m.window.Hide()
 		if m.onLogout != nil {
 			m.onLogout()
@@ -305,12 +314,50 @@ func (m *MainWindow) Show() {
 	deleteHistoryBtn.Importance = widget.LowImportance
 	deleteHistoryBtn.Alignment = widget.ButtonAlignCenter
 
+	roomInfoBtn := widget.NewButtonWithIcon("", theme.InfoIcon(), func() {
+		if m.currentChat == "" {
+			dialog.ShowError(errors.New("откройте чат, чтобы посмотреть информацию о нём"), m.window)
+			return
+		}
+		m.showRoomInfoDialog(m.currentChat)
+	})
+	roomInfoBtn.Importance = widget.LowImportance
+	roomInfoBtn.Alignment = widget.ButtonAlignCenter
+
+	rotateKeyBtn := widget.NewButtonWithIcon("", theme.ViewRefreshIcon(), func() {
+		if m.currentChat == "" {
+			dialog.ShowError(errors.New("откройте чат, чтобы сменить его ключ"), m.window)
+			return
+		}
+		roomID := m.currentChat
+		dialog.ShowConfirm("Сменить ключ", "Предложить собеседнику новый ключ шифрования?", func(ok bool) {
+			if !ok {
+				return
+			}
+			go func() {
+				if err := m.chatClient.RotateKey(roomID); err != nil {
+					fyne.DoAndWait(func() {
+						dialog.ShowError(err, m.window)
+					})
+					return
+				}
+				fyne.DoAndWait(func() {
+					dialog.ShowInformation("Смена ключа", "Предложение отправлено. Ключ сменится, когда собеседник ответит.", m.window)
+				})
+			}()
+		}, m.window)
+	})
+	rotateKeyBtn.Importance = widget.LowImportance
+	rotateKeyBtn.Alignment = widget.ButtonAlignCenter
+
 	topBar := container.New(
 		layout.NewHBoxLayout(),
 		createChatBtn,
 		layout.NewSpacer(),
 		m.chatNameLabel,
 		layout.NewSpacer(),
+		roomInfoBtn,
+		rotateKeyBtn,
 		deleteHistoryBtn,
 		homeBtn,
 		exitBtn,
@@ -376,6 +423,7 @@ func (m *MainWindow) refreshChatList() {
 			m.loadCurrentChat()
 			m.chatScroll.ScrollToBottom()
 			m.messageInput.SetText("")
+			m.warnIfKeyChanged(roomID)
 		})
 
 		m.leftPanelContent.Add(btn)
@@ -386,9 +434,8 @@ func (m *MainWindow) refreshChatList() {
 }
 
 func (m *MainWindow) loadCurrentChat() {
-	chatPath := filepath.Join("cmd", "client", "users", m.chatClient.UserID, "chats", m.currentChat, "chat.jsonl")
-
-	data, err := os.ReadFile(chatPath)
+	// История хранится зашифрованной и расшифровывается только для показа
+	history, err := m.chatClient.LoadChatHistory(m.currentChat)
 	if err != nil {
 		m.chatHistory.Objects = []fyne.CanvasObject{
 			widget.NewLabel("Ошибка открытия чата: " + err.Error()),
@@ -397,19 +444,9 @@ func (m *MainWindow) loadCurrentChat() {
 		return
 	}
 
-	lines := strings.Split(string(data), "\n")
 	var messages []fyne.CanvasObject
 
-	for _, line := range lines {
-		if strings.TrimSpace(line) == "" {
-			continue
-		}
-
-		var msg domain.StoredMessage
-		if err := json.Unmarshal([]byte(line), &msg); err != nil {
-			continue
-		}
-
+	for _, msg := range history {
 		switch msg.Type {
 		case "text":
 			label := widget.NewLabel(fmt.Sprintf("[%s] %s: %s", msg.Timestamp.Format(time.DateTime), msg.Sender, msg.Content))
@@ -421,18 +458,24 @@ func (m *MainWindow) loadCurrentChat() {
 			filePath := filepath.Join(msg.Filepath)
 
 			if _, err := os.Stat(filePath); err == nil {
-				ext := strings.ToLower(filepath.Ext(filePath))
+				ext := strings.ToLower(filepath.Ext(msg.Filename))
 				label := widget.NewLabel(fileLabel)
 				label.Wrapping = fyne.TextWrapWord
 
 				switch ext {
 				case ".png", ".jpg", ".jpeg", ".gif":
-					img := canvas.NewImageFromFile(filePath)
+					data, err := m.chatClient.StoredFileBytes(msg)
+					if err != nil {
+						messages = append(messages, widget.NewLabel(fileLabel+" (не удалось расшифровать)"))
+						continue
+					}
+					res := fyne.NewStaticResource(msg.Filename, data)
+					img := canvas.NewImageFromResource(res)
 					img.FillMode = canvas.ImageFillContain
 					img.SetMinSize(fyne.NewSize(200, 200))
 
 					tapImgObj := NewTransparentButton(func() {
-						fullImg := canvas.NewImageFromFile(filePath)
+						fullImg := canvas.NewImageFromResource(res)
 						fullImg.FillMode = canvas.ImageFillContain
 						fullImg.SetMinSize(fyne.NewSize(600, 600))
 
@@ -447,14 +490,13 @@ func (m *MainWindow) loadCurrentChat() {
 
 				default:
 					// Кнопка для других типов файлов
-					uri := storage.NewFileURI(filePath)
 					openBtn := widget.NewButtonWithIcon("Открыть файл", theme.FileIcon(), func() {
-						f, err := storage.OpenFileFromURI(uri)
+						// Принятый файл расшифровывается во временный каталог только при открытии
+						filePath, err := m.chatClient.OpenStoredFile(msg)
 						if err != nil {
 							dialog.ShowError(err, m.window)
 							return
 						}
-						defer f.Close()
 
 						var openCmd *exec.Cmd
 						switch runtime.GOOS {
@@ -492,9 +534,48 @@ func (m *MainWindow) loadCurrentChat() {
 func (m *MainWindow) openNewChatDialog() {
 	chatNameEntry := widget.NewEntry()
 	receiverEntry := widget.NewEntry()
-	algorithmSelect := widget.NewSelect([]string{"RC5", "RC6"}, nil)
-	modeSelect := widget.NewSelect([]string{"ECB", "CBC", "PCBC", "CFB", "OFB", "CTR", "RandomDelta"}, nil)
-	paddingSelect := widget.NewSelect([]string{"Zeros", "ANSIX923", "PKCS7", "ISO10126"}, nil)
+	algorithmSelect := widget.NewSelect(symmetric.CipherNames(), nil)
+	modeSelect := widget.NewSelect(symmetric.ModeNames(), nil)
+	paddingSelect := widget.NewSelect(symmetric.PaddingNames(), nil)
+
+	// Размер слова и число раундов показываются только для шифров, у которых они есть
+	// (RC5, RC6); пустое поле — значение по умолчанию
+	wordSizeLabel := widget.NewLabel("Размер слова (бит):")
+	wordSizeSelect := widget.NewSelect([]string{"16", "32", "64"}, nil)
+	roundsLabel := widget.NewLabel("Число раундов:")
+	roundsEntry := widget.NewEntry()
+	keyLengthEntry := widget.NewEntry()
+	keyLengthEntry.SetPlaceHolder("32")
+	keyAgreementSelect := widget.NewSelect(append([]string{domain.KeyAgreementDH}, ecdh.Schemes()...), nil)
+	keyAgreementSelect.SetSelected(ecdh.SchemeX25519)
+	doubleRatchetCheck := widget.NewCheck("Double Ratchet (ключ на каждое сообщение)", nil)
+	firstMessageEntry := widget.NewMultiLineEntry()
+	firstMessageEntry.SetPlaceHolder("Можно отправить сразу, если собеседник не в сети")
+
+	hasWordSize, hasRounds := false, false
+	algorithmSelect.OnChanged = func(selected string) {
+		info, _ := symmetric.LookupCipher(selected)
+
+		var spec symmetric.ParamSpec
+		if spec, hasWordSize = info.Param("w"); hasWordSize {
+			wordSizeSelect.SetSelected(strconv.Itoa(spec.Default))
+			wordSizeLabel.Show()
+			wordSizeSelect.Show()
+		} else {
+			wordSizeLabel.Hide()
+			wordSizeSelect.Hide()
+		}
+		if spec, hasRounds = info.Param("r"); hasRounds {
+			roundsEntry.SetPlaceHolder(strconv.Itoa(spec.Default))
+			roundsLabel.Show()
+			roundsEntry.Show()
+		} else {
+			roundsLabel.Hide()
+			roundsEntry.Hide()
+		}
+	}
+	algorithmSelect.OnChanged("")
+
 	errorLabel := widget.NewLabel("")
 	errorLabel.Hide()
 	var dlg *dialog.CustomDialog
@@ -505,6 +586,12 @@ func (m *MainWindow) openNewChatDialog() {
 		widget.NewLabel("Алгоритм:"), algorithmSelect,
 		widget.NewLabel("Режим шифрования:"), modeSelect,
 		widget.NewLabel("Набивка:"), paddingSelect,
+		wordSizeLabel, wordSizeSelect,
+		roundsLabel, roundsEntry,
+		widget.NewLabel("Длина ключа (байт):"), keyLengthEntry,
+		widget.NewLabel("Согласование ключа:"), keyAgreementSelect,
+		doubleRatchetCheck,
+		widget.NewLabel("Первое сообщение (необязательно):"), firstMessageEntry,
 	)
 
 	onCreate := func() {
@@ -521,13 +608,42 @@ func (m *MainWindow) openNewChatDialog() {
 			errorLabel.Show()
 			return
 		}
-		err := m.chatClient.CreateChat(domain.Chat{
-			ChatName:  name,
-			Receiver:  recv,
-			Algorithm: algorithmSelect.Selected,
-			Mode:      modeSelect.Selected,
-			Padding:   paddingSelect.Selected,
-		})
+		chat := domain.Chat{
+			ChatName:     name,
+			Receiver:     recv,
+			Algorithm:    algorithmSelect.Selected,
+			Mode:         modeSelect.Selected,
+			Padding:      paddingSelect.Selected,
+			KeyAgreement: keyAgreementSelect.Selected,
+
+			DoubleRatchet: doubleRatchetCheck.Checked,
+			FirstMessage:  strings.TrimSpace(firstMessageEntry.Text),
+		}
+
+		var err error
+		if chat.KeyLength, err = parseOptionalInt(keyLengthEntry.Text); err != nil {
+			errorLabel.SetText("Длина ключа должна быть числом.")
+			errorLabel.Show()
+			return
+		}
+		if hasWordSize {
+			chat.WordSize, _ = strconv.Atoi(wordSizeSelect.Selected)
+		}
+		if hasRounds {
+			if chat.Rounds, err = parseOptionalInt(roundsEntry.Text); err != nil {
+				errorLabel.SetText("Число раундов должно быть числом.")
+				errorLabel.Show()
+				return
+			}
+		}
+
+		params := symmetric.RoomParams(chat.WordSize, chat.Rounds)
+		if err = symmetric.ValidateSuite(chat.Algorithm, chat.Mode, chat.Padding, params, chat.KeyLength); err != nil {
+			errorLabel.SetText(fmt.Sprintf("Недопустимое сочетание параметров: %v", err))
+			errorLabel.Show()
+			return
+		}
+		err = m.chatClient.CreateChat(chat)
 		if err != nil {
 			dialog.ShowError(fmt.Errorf("ошибка создания чата: %v", err), m.window)
 			return
@@ -545,134 +661,90 @@ func (m *MainWindow) openNewChatDialog() {
 		container.NewPadded(form, errorLabel),
 	)
 	dlg = dialog.NewCustomWithoutButtons("Создание нового чата", content, m.window)
-	dlg.Resize(fyne.NewSize(400, 400))
+	dlg.Resize(fyne.NewSize(400, 760))
 	dlg.Show()
 }
 
-func (m *MainWindow) getMessages() {
-	ticker := time.NewTicker(10 * time.Millisecond)
-	defer ticker.Stop()
-
-	for {
-		select {
-		case <-ticker.C:
-			progressFunc := func(done, total int) {
-				if total == 0 {
-					return
-				}
-				progress := float64(done) / float64(total)
-				fyne.DoAndWait(func() {
-					m.progressBar.SetValue(progress)
-					m.progressBar.Show()
-				})
-			}
-
-			err := m.chatClient.ReceiveMessage(m.currentChat, progressFunc)
-			if err != nil {
-				slog.Error(err.Error())
-			} else {
-				fyne.DoAndWait(func() {
-					m.progressBar.Hide()
-					m.progressBar.SetValue(0)
-				})
-			}
-		}
+// parseOptionalInt разбирает необязательное числовое поле: пустая строка даёт 0.
+func parseOptionalInt(text string) (int, error) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return 0, nil
 	}
+	return strconv.Atoi(text)
 }
 
-func (m *MainWindow) refreshChat() {
-	ticker := time.NewTicker(1 * time.Second)
-	defer ticker.Stop()
+// handleEvents показывает события из потока сервера, пока окно открыто.
+func (m *MainWindow) handleEvents(ctx context.Context) {
 	for {
 		select {
-		case <-ticker.C:
-			if _, ok := m.chatClient.Messages.LoadAndDelete(m.currentChat); ok {
-				fyne.DoAndWait(func() {
-					m.loadCurrentChat()
-				})
-			}
+		case <-ctx.Done():
+			return
+		case event := <-m.chatClient.Events():
+			fyne.DoAndWait(func() {
+				m.showEvent(event)
+			})
 		}
 	}
 }
 
-func (m *MainWindow) checkInvitationsPeriodically() {
-	ticker := time.NewTicker(7 * time.Second)
-	defer ticker.Stop()
-
-	for {
-		select {
-		case <-ticker.C:
-			inv, err := m.chatClient.ReceiveInvitation()
-			if err != nil {
-				if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
-					continue
-				}
-				log.Printf("Error checking invitations: %v", err)
-				continue
-			}
+func (m *MainWindow) showEvent(event domain.Event) {
+	switch {
+	case event.RoomChanged != "":
+		if event.RoomChanged == m.currentChat {
+			m.loadCurrentChat()
+		}
 
-			if inv.Sender != "" {
-				fyne.DoAndWait(func() {
-					m.showInvitationDialog(inv)
-				})
-			}
+	case event.Progress != nil:
+		if event.Progress.RoomID != m.currentChat || event.Progress.Total == 0 {
+			return
 		}
-	}
-}
+		if event.Progress.Done >= event.Progress.Total {
+			m.progressBar.Hide()
+			m.progressBar.SetValue(0)
+			return
+		}
+		m.progressBar.SetValue(float64(event.Progress.Done) / float64(event.Progress.Total))
+		m.progressBar.Show()
 
-func (m *MainWindow) checkClearChatRequestsPeriodically() {
-	ticker := time.NewTicker(3 * time.Second)
-	defer ticker.Stop()
+	case event.Invitation != nil:
+		m.refreshChatList()
+		m.showInvitationDialog(*event.Invitation)
 
-	for {
-		select {
-		case <-ticker.C:
-			if m.currentChat != "" {
-				err := m.chatClient.ReceiveClearChatHistoryRequest(m.currentChat)
-				if err != nil {
-					if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) || errors.Is(err, domain.ErrNotFound) {
-						continue
-					}
-					continue
-				}
+	case event.InvitationResponse != nil:
+		if event.InvitationResponse.Accepted {
+			m.showSuccessInvitationResponseDialog(*event.InvitationResponse)
+		} else {
+			m.showRejectedInvitationResponseDialog(*event.InvitationResponse)
+		}
+		m.refreshChatList()
+
+	case event.KeyUpdate != nil:
+		update := event.KeyUpdate
+		if update.Deleted {
+			if m.currentChat == update.RoomID {
+				m.currentChat = ""
+				m.chatNameLabel.SetText("")
+				m.rightPanelContent.Hide()
+				m.rightEmptyBox.Show()
 			}
+			m.refreshChatList()
+			dialog.ShowInformation("Чат удалён", fmt.Sprintf("%s удалил чат", update.Companion), m.window)
+			return
 		}
+		dialog.ShowInformation("Смена ключа",
+			fmt.Sprintf("Ключ чата с %s сменён (эпоха %d)", update.Companion, update.Epoch), m.window)
 	}
 }
 
-func (m *MainWindow) checkInvitationResponsesPeriodically() {
-	ticker := time.NewTicker(7 * time.Second)
-	defer ticker.Stop()
-
-	for {
-		select {
-		case <-ticker.C:
-			resp, err := m.chatClient.ReceiveInvitationResponse()
-			if err != nil {
-				if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
-					continue
-				}
-				log.Printf("Error checking invitation responses: %v", err)
-				continue
-			}
-
-			if resp.Sender != "" {
-				switch resp.Accepted {
-				case true:
-					fyne.DoAndWait(func() {
-						m.showSuccessInvitationResponseDialog(resp)
-					})
-				case false:
-					fyne.DoAndWait(func() {
-						m.showRejectedInvitationResponseDialog(resp)
-					})
-				}
-			}
-			fyne.DoAndWait(func() {
-				m.refreshChatList()
-			})
-		}
+// firstMessageLabel показывает сообщение, пришедшее вместе с приглашением.
+func firstMessageLabel(text string) fyne.CanvasObject {
+	label := widget.NewLabel(fmt.Sprintf("Сообщение: %s", text))
+	label.Wrapping = fyne.TextWrapWord
+	if text == "" {
+		label.Hide()
 	}
+	return label
 }
 
 func (m *MainWindow) showInvitationDialog(inv domain.Invitation) {
@@ -683,6 +755,7 @@ func (m *MainWindow) showInvitationDialog(inv domain.Invitation) {
 		container.NewVBox(
 			widget.NewLabel(fmt.Sprintf("От: %s", inv.Sender)),
 			widget.NewLabel(fmt.Sprintf("Комната: %s", inv.RoomName)),
+			firstMessageLabel(inv.FirstMessage),
 		),
 		func(accepted bool) {
 			err := m.chatClient.ReactToInvitation(domain.Invitation{RoomID: inv.RoomID, Receiver: inv.Sender}, accepted)
@@ -741,3 +814,96 @@ func (m *MainWindow) showRejectedInvitationResponseDialog(resp domain.Invitation
 		m.window,
 	)
 }
+
+// showRoomInfoDialog показывает параметры комнаты и номер безопасности, который
+// собеседники сверяют вслух или по QR-коду, чтобы убедиться, что сервер не подменил ключи.
+func (m *MainWindow) showRoomInfoDialog(roomID string) {
+	info, err := m.chatClient.RoomInfo(roomID)
+	if err != nil {
+		dialog.ShowError(err, m.window)
+		return
+	}
+
+	keyAgreement := info.KeyAgreement
+	if keyAgreement == "" {
+		keyAgreement = domain.KeyAgreementDH
+	}
+	if info.DHGroup != "" {
+		keyAgreement += " (" + info.DHGroup + ")"
+	}
+
+	content := container.NewVBox(
+		widget.NewLabel(fmt.Sprintf("Собеседник: %s", info.Companion)),
+		widget.NewLabel(fmt.Sprintf("Шифр: %s, %s, %s", info.Algorithm, info.CipherMode, info.Padding)),
+		widget.NewLabel(fmt.Sprintf("Согласование ключа: %s", keyAgreement)),
+	)
+	if info.DoubleRatchet {
+		content.Add(widget.NewLabel(fmt.Sprintf("Double Ratchet: включён, эпоха ключа %d", info.Epoch)))
+	}
+
+	verification, err := m.chatClient.RoomVerification(roomID)
+	if err != nil {
+		content.Add(widget.NewLabel(fmt.Sprintf("Номер безопасности недоступен: %v", err)))
+		dialog.ShowCustom("Информация о чате", "Закрыть", content, m.window)
+		return
+	}
+
+	if verification.KeyChanged {
+		warning := widget.NewLabel("Внимание: ключи изменились после проверки. Сверьте номер заново.")
+		warning.Importance = widget.DangerImportance
+		warning.Wrapping = fyne.TextWrapWord
+		content.Add(warning)
+	}
+
+	numberLabel := widget.NewLabelWithStyle(fingerprint.Format(verification.SafetyNumber), fyne.TextAlignCenter, fyne.TextStyle{Monospace: true})
+	content.Add(widget.NewLabel("Номер безопасности:"))
+	content.Add(numberLabel)
+
+	if qr, err := fingerprint.QRCode(roomID, verification.SafetyNumber, 256); err == nil {
+		qrImage := canvas.NewImageFromImage(qr)
+		qrImage.FillMode = canvas.ImageFillContain
+		qrImage.SetMinSize(fyne.NewSize(200, 200))
+		content.Add(qrImage)
+	} else {
+		slog.Error("could not render safety number QR code", "error", err)
+	}
+
+	statusLabel := widget.NewLabel("")
+	verifyBtn := widget.NewButton("", nil)
+	verified := verification.Verified
+	updateStatus := func() {
+		if verified {
+			statusLabel.SetText("Номер сверен с собеседником")
+			verifyBtn.SetText("Снять отметку")
+		} else {
+			statusLabel.SetText("Номер не сверен")
+			verifyBtn.SetText("Отметить как сверенный")
+		}
+	}
+	verifyBtn.OnTapped = func() {
+		if err := m.chatClient.SetRoomVerified(roomID, !verified); err != nil {
+			dialog.ShowError(err, m.window)
+			return
+		}
+		verified = !verified
+		updateStatus()
+	}
+	updateStatus()
+	content.Add(statusLabel)
+	content.Add(verifyBtn)
+
+	dialog.ShowCustom("Информация о чате", "Закрыть", content, m.window)
+}
+
+// warnIfKeyChanged предупреждает, если ключи собеседника сменились после сверки номера безопасности.
+func (m *MainWindow) warnIfKeyChanged(roomID string) {
+	verification, err := m.chatClient.RoomVerification(roomID)
+	if err != nil || !verification.KeyChanged {
+		return
+	}
+	dialog.ShowInformation(
+		"Ключи изменились",
+		"Номер безопасности этого чата изменился после проверки.\nСверьте его с собеседником заново.",
+		m.window,
+	)
+}
@@ -0,0 +1,134 @@
+// gencerts создаёт локальный CA и подписанные им сертификаты сервера и клиента для разработки.
+//
+//	go run ./cmd/gencerts -out certs -hosts localhost,127.0.0.1
+//
+// Сервер: SERVER_TLS_CERT=certs/server.crt SERVER_TLS_KEY=certs/server.key,
+// для mTLS ещё SERVER_TLS_CLIENT_CA=certs/ca.crt.
+// Клиент: -ca certs/ca.crt, для mTLS -cert certs/client.crt -key certs/client.key.
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"flag"
+	"fmt"
+	"log"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+type keyPair struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+func main() {
+	out := flag.String("out", "certs", "каталог для сертификатов")
+	hosts := flag.String("hosts", "localhost,127.0.0.1,::1", "имена и адреса сервера через запятую")
+	client := flag.String("client", "dev-client", "имя (CN) клиентского сертификата")
+	validFor := flag.Duration("valid-for", 365*24*time.Hour, "срок действия сертификатов")
+	flag.Parse()
+
+	if err := os.MkdirAll(*out, 0o700); err != nil {
+		log.Fatalf("cannot create %s: %v", *out, err)
+	}
+
+	ca, err := issue(&x509.Certificate{
+		Subject:               pkix.Name{CommonName: "CryptoMessenger dev CA"},
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		MaxPathLenZero:        true,
+	}, nil, *validFor)
+	if err != nil {
+		log.Fatalf("CA: %v", err)
+	}
+
+	serverTemplate := &x509.Certificate{
+		Subject:     pkix.Name{CommonName: "CryptoMessenger server"},
+		KeyUsage:    x509.KeyUsageDigitalSignature,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	for _, host := range strings.Split(*hosts, ",") {
+		host = strings.TrimSpace(host)
+		if ip := net.ParseIP(host); ip != nil {
+			serverTemplate.IPAddresses = append(serverTemplate.IPAddresses, ip)
+		} else if host != "" {
+			serverTemplate.DNSNames = append(serverTemplate.DNSNames, host)
+		}
+	}
+	server, err := issue(serverTemplate, &ca, *validFor)
+	if err != nil {
+		log.Fatalf("server certificate: %v", err)
+	}
+
+	clientPair, err := issue(&x509.Certificate{
+		Subject:     pkix.Name{CommonName: *client},
+		KeyUsage:    x509.KeyUsageDigitalSignature,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}, &ca, *validFor)
+	if err != nil {
+		log.Fatalf("client certificate: %v", err)
+	}
+
+	for name, pair := range map[string]keyPair{"ca": ca, "server": server, "client": clientPair} {
+		if err = write(*out, name, pair); err != nil {
+			log.Fatalf("%s: %v", name, err)
+		}
+	}
+
+	pin := sha256.Sum256(server.cert.Raw)
+	fmt.Printf("certificates written to %s\n", *out)
+	fmt.Printf("server certificate SHA-256 (client -pin): %x\n", pin)
+}
+
+// issue создаёт ключ P-256 и сертификат; без parent сертификат самоподписанный.
+func issue(template *x509.Certificate, parent *keyPair, validFor time.Duration) (keyPair, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return keyPair{}, err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return keyPair{}, err
+	}
+	template.SerialNumber = serial
+	template.NotBefore = time.Now().Add(-time.Hour)
+	template.NotAfter = time.Now().Add(validFor)
+
+	issuer, signer := template, key
+	if parent != nil {
+		issuer, signer = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, issuer, &key.PublicKey, signer)
+	if err != nil {
+		return keyPair{}, err
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return keyPair{}, err
+	}
+	return keyPair{cert: cert, key: key}, nil
+}
+
+func write(dir, name string, pair keyPair) error {
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: pair.cert.Raw})
+	if err := os.WriteFile(filepath.Join(dir, name+".crt"), certPEM, 0o644); err != nil {
+		return err
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(pair.key)
+	if err != nil {
+		return err
+	}
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER})
+	return os.WriteFile(filepath.Join(dir, name+".key"), keyPEM, 0o600)
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"CryptoMessenger/internal/auth"
 	"CryptoMessenger/internal/config/serverConfig"
 	"CryptoMessenger/internal/config/storageConfig"
 	natsjs "CryptoMessenger/internal/infrastructure/nats"
@@ -46,7 +47,12 @@ func main() {
 
 	defer broker.Conn.Close()
 
-	chatService := service.NewService(repos, broker)
+	signer, err := auth.NewSigner(config.Auth.SigningKeys, config.Auth.ActiveKeyID, config.Auth.AccessTokenTTL)
+	if err != nil {
+		log.Fatalf("invalid auth config: %v", err)
+	}
+
+	chatService := service.NewService(repos, broker, signer, config.Auth.RefreshTokenTTL)
 
 	if err := grpc.RunGRPCServer(config.Server, chatService); err != nil {
 		log.Fatalf("cannot start gRPC server: %v", err)
@@ -2,36 +2,79 @@ package auth
 
 import (
 	"errors"
+	"fmt"
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
 	"time"
 )
 
-var jwtKey = []byte("your_secret_key") // вынеси потом в .env/config
+// minKeyLength — минимальная длина ключа подписи HS256.
+const minKeyLength = 32
 
 type Claims struct {
 	ClientID string `json:"client_id"`
 	jwt.RegisteredClaims
 }
 
-func GenerateToken(clientID string) (string, error) {
+// Signer выпускает и проверяет токены доступа. Токен подписывается активным ключом,
+// а его ID пишется в заголовок kid: так после смены ключа старые токены
+// остаются действительными, пока их ключ не убран из конфигурации.
+type Signer struct {
+	keys        map[string][]byte
+	activeKeyID string
+	ttl         time.Duration
+}
+
+func NewSigner(keys map[string]string, activeKeyID string, ttl time.Duration) (*Signer, error) {
+	if ttl <= 0 {
+		return nil, fmt.Errorf("access token ttl must be positive")
+	}
+	s := &Signer{keys: make(map[string][]byte, len(keys)), activeKeyID: activeKeyID, ttl: ttl}
+	for id, key := range keys {
+		if len(key) < minKeyLength {
+			return nil, fmt.Errorf("signing key %q is shorter than %d bytes", id, minKeyLength)
+		}
+		s.keys[id] = []byte(key)
+	}
+	if _, ok := s.keys[activeKeyID]; !ok {
+		return nil, fmt.Errorf("active signing key %q is not configured", activeKeyID)
+	}
+	return s, nil
+}
+
+// GenerateToken выпускает токен доступа; у каждого токена свой ID (jti), по нему токен отзывается.
+func (s *Signer) GenerateToken(clientID string) (string, *Claims, error) {
+	now := time.Now()
 	claims := &Claims{
 		ClientID: clientID,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(12 * time.Hour)),
+			ID:        uuid.New().String(),
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(s.ttl)),
 		},
 	}
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString(jwtKey)
+	token.Header["kid"] = s.activeKeyID
+	signed, err := token.SignedString(s.keys[s.activeKeyID])
+	if err != nil {
+		return "", nil, err
+	}
+	return signed, claims, nil
 }
 
-func ParseToken(tokenStr string) (*Claims, error) {
+func (s *Signer) ParseToken(tokenStr string) (*Claims, error) {
 	token, err := jwt.ParseWithClaims(tokenStr, &Claims{}, func(t *jwt.Token) (interface{}, error) {
-		return jwtKey, nil
-	})
+		kid, _ := t.Header["kid"].(string)
+		key, ok := s.keys[kid]
+		if !ok {
+			return nil, fmt.Errorf("unknown signing key %q", kid)
+		}
+		return key, nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}), jwt.WithExpirationRequired())
 	if err != nil {
 		return nil, err
 	}
-	if claims, ok := token.Claims.(*Claims); ok && token.Valid {
+	if claims, ok := token.Claims.(*Claims); ok && token.Valid && claims.ClientID != "" && claims.ID != "" {
 		return claims, nil
 	}
 	return nil, errors.New("invalid token")
@@ -0,0 +1,126 @@
+package auth_test
+
+import (
+	"CryptoMessenger/internal/auth"
+	"github.com/golang-jwt/jwt/v5"
+	"strings"
+	"testing"
+	"time"
+)
+
+const (
+	oldKey = "old-signing-key-0123456789abcdef"
+	newKey = "new-signing-key-0123456789abcdef"
+)
+
+func newSigner(t *testing.T, keys map[string]string, active string) *auth.Signer {
+	t.Helper()
+	s, err := auth.NewSigner(keys, active, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func keyID(t *testing.T, token string) string {
+	t.Helper()
+	parsed, _, err := jwt.NewParser().ParseUnverified(token, &auth.Claims{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	kid, _ := parsed.Header["kid"].(string)
+	return kid
+}
+
+func TestKeyRotation(t *testing.T) {
+	before := newSigner(t, map[string]string{"k1": oldKey}, "k1")
+	oldToken, _, err := before.GenerateToken("alice-id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if kid := keyID(t, oldToken); kid != "k1" {
+		t.Fatalf("kid = %q, want k1", kid)
+	}
+
+	// После смены активного ключа новые токены подписываются им, а старые ещё принимаются
+	during := newSigner(t, map[string]string{"k1": oldKey, "k2": newKey}, "k2")
+	newToken, _, err := during.GenerateToken("alice-id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if kid := keyID(t, newToken); kid != "k2" {
+		t.Fatalf("kid = %q, want k2", kid)
+	}
+	for name, token := range map[string]string{"old": oldToken, "new": newToken} {
+		claims, err := during.ParseToken(token)
+		if err != nil {
+			t.Fatalf("%s token: %v", name, err)
+		}
+		if claims.ClientID != "alice-id" {
+			t.Fatalf("%s token: client id %q", name, claims.ClientID)
+		}
+	}
+	if _, err = before.ParseToken(newToken); err == nil {
+		t.Fatal("token signed with an unknown key accepted")
+	}
+
+	// Когда старый ключ убран из конфигурации, его токены отклоняются
+	after := newSigner(t, map[string]string{"k2": newKey}, "k2")
+	if _, err = after.ParseToken(oldToken); err == nil {
+		t.Fatal("token of a removed key accepted")
+	}
+	if _, err = after.ParseToken(newToken); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestParseTokenChecksKeyID(t *testing.T) {
+	s := newSigner(t, map[string]string{"k1": oldKey, "k2": newKey}, "k1")
+	token, _, err := s.GenerateToken("alice-id")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Подпись проверяется ключом из kid: токен с чужим kid не проходит
+	claims := &auth.Claims{ClientID: "alice-id", RegisteredClaims: jwt.RegisteredClaims{
+		ID:        "token-id",
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute)),
+	}}
+	tests := map[string]func() string{
+		"kid of another key": func() string {
+			forged := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+			forged.Header["kid"] = "k2"
+			signed, _ := forged.SignedString([]byte(oldKey))
+			return signed
+		},
+		"missing kid": func() string {
+			signed, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(oldKey))
+			return signed
+		},
+		"unknown kid": func() string {
+			forged := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+			forged.Header["kid"] = "k3"
+			signed, _ := forged.SignedString([]byte(oldKey))
+			return signed
+		},
+		"unsigned": func() string {
+			forged := jwt.NewWithClaims(jwt.SigningMethodNone, claims)
+			forged.Header["kid"] = "k1"
+			signed, _ := forged.SignedString(jwt.UnsafeAllowNoneSignatureType)
+			return signed
+		},
+		"signature of another token": func() string {
+			other, _, _ := s.GenerateToken("bob-id")
+			parts := strings.Split(token, ".")
+			parts[2] = strings.Split(other, ".")[2]
+			return strings.Join(parts, ".")
+		},
+	}
+	for name, forge := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := s.ParseToken(forge()); err == nil {
+				t.Fatal("token accepted")
+			}
+		})
+	}
+}
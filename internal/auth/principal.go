@@ -0,0 +1,27 @@
+package auth
+
+import (
+	"context"
+	"time"
+)
+
+// Principal — аутентифицированный вызывающий, которого interceptor кладёт в контекст запроса.
+type Principal struct {
+	UserID string
+	Admin  bool
+	// TokenID и TokenExpiresAt — jti и срок токена доступа, с которым сделан вызов; нужны для выхода.
+	TokenID        string
+	TokenExpiresAt time.Time
+}
+
+type principalKey struct{}
+
+func WithPrincipal(ctx context.Context, p Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// PrincipalFromContext возвращает вызывающего; false — запрос не аутентифицирован.
+func PrincipalFromContext(ctx context.Context) (Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(Principal)
+	return p, ok && p.UserID != ""
+}
@@ -15,12 +15,33 @@ const (
 type Config struct {
 	Server ServerConfig `yaml:"server"`
 	Kafka  KafkaConfig  `yaml:"kafka"`
+	Auth   AuthConfig   `yaml:"auth"`
 }
 
 type ServerConfig struct {
 	Address string        `yaml:"address" env-default:"localhost:50051"`
 	TimeOut time.Duration `yaml:"timeout" env-default:"8s"`
 	Type    string        `yaml:"type" env-default:"tcp"`
+	// Admins — ID пользователей, которым доступны методы уровня Admin.
+	Admins []string `yaml:"admins" env:"SERVER_ADMINS"`
+
+	// CertFile и KeyFile — сертификат и ключ сервера в PEM. Если задан ClientCAFile,
+	// клиенты обязаны предъявить сертификат, подписанный этим CA (mTLS).
+	CertFile     string `yaml:"cert_file" env:"SERVER_TLS_CERT"`
+	KeyFile      string `yaml:"key_file" env:"SERVER_TLS_KEY"`
+	ClientCAFile string `yaml:"client_ca_file" env:"SERVER_TLS_CLIENT_CA"`
+	// Insecure разрешает работу без TLS, когда сертификат не задан; только для отладки.
+	Insecure bool `yaml:"insecure" env:"SERVER_INSECURE"`
+}
+
+// AuthConfig — ключи подписи токенов доступа. Для смены ключа новый добавляется
+// в SigningKeys и делается активным; старый удаляется, когда истекут подписанные им токены.
+type AuthConfig struct {
+	// SigningKeys — ID ключа → секрет не короче 32 байт; в переменной окружения: "id1:secret1,id2:secret2".
+	SigningKeys     map[string]string `yaml:"signing_keys" env:"JWT_SIGNING_KEYS"`
+	ActiveKeyID     string            `yaml:"active_key_id" env:"JWT_ACTIVE_KEY_ID"`
+	AccessTokenTTL  time.Duration     `yaml:"access_token_ttl" env:"JWT_ACCESS_TOKEN_TTL" env-default:"15m"`
+	RefreshTokenTTL time.Duration     `yaml:"refresh_token_ttl" env:"JWT_REFRESH_TOKEN_TTL" env-default:"720h"`
 }
 
 type KafkaConfig struct {
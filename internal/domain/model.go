@@ -8,22 +8,75 @@ type User struct {
 	PasswordHash string
 }
 
+// TokenPair — токены, которые получает клиент при входе и при обновлении.
+type TokenPair struct {
+	AccessToken     string
+	AccessExpiresAt time.Time
+	RefreshToken    string
+}
+
+// RefreshToken — запись о токене обновления; сам токен сервер не хранит, только его хеш.
+type RefreshToken struct {
+	Hash      []byte
+	UserID    string
+	FamilyID  string
+	ExpiresAt time.Time
+	Used      bool
+}
+
+// IdentityKey — долговременный открытый ключ Ed25519 пользователя.
+type IdentityKey struct {
+	UserID    string
+	Username  string
+	PublicKey []byte
+}
+
 type RoomConfig struct {
 	RoomID      string
 	RoomName    string
+	CreatorID   string
 	Algorithm   string
 	Mode        string
 	Padding     string
 	PrimeHex    string
 	Iv          string
 	RandomDelta string
+	WordSize    int
+	Rounds      int
+	KeyLength   int
 }
 
 type PublicKey struct {
+	RoomID       string
 	ClientID     string
 	PublicKeyHex string
 }
 
+// Prekey — предключ пользователя для согласования ключа комнаты без его участия.
+// Подписанный предключ один на схему и подписан ключом идентичности,
+// одноразовые выдаются по одному и удаляются.
+type Prekey struct {
+	UserID       string
+	KeyID        string
+	KeyAgreement string
+	DHGroup      string
+	PublicKey    string
+	Signature    []byte
+	OneTime      bool
+}
+
+type PrekeyBundle struct {
+	Username      string
+	SignedPrekey  Prekey
+	OneTimePrekey *Prekey
+}
+
+type PrekeyCount struct {
+	KeyAgreement string
+	DHGroup      string
+	Count        int
+}
+
 type EncryptedMessage struct {
 	SenderID    string
 	Data        []byte
@@ -33,6 +86,9 @@ type EncryptedMessage struct {
 	TotalChunks int
 }
 
+// KeyAgreementDH — классический DH в именованной группе; пустое поле в приглашении означает его же.
+const KeyAgreementDH = "DH"
+
 type ChatInvitation struct {
 	MessageID string `json:"message_id"`
 
@@ -45,14 +101,26 @@ type ChatInvitation struct {
 	RoomID   string `json:"room_id"`
 	RoomName string `json:"room_name"`
 
-	Prime       string `json:"prime"`
-	G           string `json:"g"`
-	PublicKey   string `json:"public_key"`
-	Algorithm   string `json:"algorithm"`
-	Mode        string `json:"mode"`
-	Padding     string `json:"padding"`
-	Iv          string `json:"iv"`
-	RandomDelta string `json:"random_delta"`
+	Prime        string `json:"prime"`
+	G            string `json:"g"`
+	PublicKey    string `json:"public_key"`
+	Algorithm    string `json:"algorithm"`
+	Mode         string `json:"mode"`
+	Padding      string `json:"padding"`
+	Iv           string `json:"iv"`
+	RandomDelta  string `json:"random_delta"`
+	WordSize     int    `json:"word_size"`
+	Rounds       int    `json:"rounds"`
+	KeyLength    int    `json:"key_length"`
+	DHGroup      string `json:"dh_group"`
+	KeyAgreement string `json:"key_agreement"`
+	Signature    []byte `json:"signature"`
+	// DoubleRatchet — сообщения комнаты шифруются ключами Double Ratchet.
+	DoubleRatchet bool `json:"double_ratchet"`
+
+	SignedPrekeyID  string       `json:"signed_prekey_id,omitempty"`
+	OneTimePrekeyID string       `json:"one_time_prekey_id,omitempty"`
+	FirstMessage    *ChatMessage `json:"first_message,omitempty"`
 }
 
 type InvitationReaction struct {
@@ -68,6 +136,7 @@ type InvitationReaction struct {
 	RoomName  string `json:"room_name"`
 	PublicKey string `json:"public_key"`
 	Accepted  bool   `json:"accepted"`
+	Signature []byte `json:"signature"`
 }
 
 type ChatMessage struct {
@@ -78,6 +147,9 @@ type ChatMessage struct {
 	ReceiverName string    `json:"receiver_name"`
 	ChatID       string    `json:"chat_id"`
 	Timestamp    time.Time `json:"timestamp"`
+	KeyEpoch     int       `json:"key_epoch"`
+
+	Ratchet *RatchetHeader `json:"ratchet,omitempty"`
 
 	Text       TextPayload `json:"text"`
 	FileHeader FileHeader  `json:"file_header"`
@@ -94,6 +166,13 @@ type FileHeader struct {
 	TotalChunks int    `json:"total_chunks"`
 }
 
+// RatchetHeader — открытый заголовок Double Ratchet, сервер передаёт его без изменений.
+type RatchetHeader struct {
+	PublicKey           []byte `json:"public_key"`
+	PreviousChainLength uint32 `json:"previous_chain_length"`
+	MessageNumber       uint32 `json:"message_number"`
+}
+
 type FileChunk struct {
 	FileID      string `json:"file_id"`
 	Filename    string `json:"filename"`
@@ -108,4 +187,23 @@ type ChatActions struct {
 	UserID    string `json:"user_id"`
 	PublicKey string `json:"public_key"`
 	MessageID string `json:"message_id"`
+
+	// Поля смены ключа комнаты (UpdateOrDeleteCipherKey).
+	SenderID   string `json:"-"`
+	SenderName string `json:"sender_name"`
+	Epoch      int    `json:"epoch"`
+	Answer     bool   `json:"answer"`
+	Signature  []byte `json:"signature"`
+}
+
+// Event — событие из очереди пользователя. Заполнено ровно одно из полей с содержимым.
+type Event struct {
+	Sequence  uint64
+	MessageID string
+
+	Message      *ChatMessage
+	Invitation   *ChatInvitation
+	Reaction     *InvitationReaction
+	ClearHistory *ChatActions
+	KeyUpdate    *ChatActions
 }
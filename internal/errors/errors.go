@@ -3,9 +3,21 @@ package myErrors
 import "errors"
 
 var (
-	ErrUserNotFound    = errors.New("invalid username")
-	ErrInvalidPassword = errors.New("invalid password")
-	ErrUserExists      = errors.New("user already exists")
-	ErrRoomNotFound    = errors.New("room not found")
-	ErrUnauthorized    = errors.New("unauthorized")
+	ErrUserNotFound      = errors.New("invalid username")
+	ErrInvalidPassword   = errors.New("invalid password")
+	ErrUserExists        = errors.New("user already exists")
+	ErrRoomNotFound      = errors.New("room not found")
+	ErrUnauthorized      = errors.New("unauthorized")
+	ErrInvalidRoom       = errors.New("invalid room config")
+	ErrInvalidInvitation = errors.New("invalid invitation")
+	ErrPermissionDenied  = errors.New("permission denied")
+
+	ErrInvalidRefreshToken = errors.New("invalid refresh token")
+
+	ErrInvalidIdentityKey  = errors.New("invalid identity key")
+	ErrIdentityKeyExists   = errors.New("identity key already published")
+	ErrIdentityKeyNotFound = errors.New("identity key not found")
+
+	ErrInvalidPrekey  = errors.New("invalid prekey")
+	ErrPrekeyNotFound = errors.New("prekey not found")
 )
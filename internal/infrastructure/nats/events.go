@@ -0,0 +1,221 @@
+package natsjs
+
+import (
+	"CryptoMessenger/internal/domain"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+const (
+	eventsFetchBatch = 16
+	eventsFetchWait  = 30 * time.Second
+)
+
+// Раньше у пользователя был отдельный consumer на каждый вид событий и на каждую комнату.
+// В work-queue потоке темы consumer'ов не должны пересекаться, поэтому перед созданием
+// общего consumer'а событий старые удаляются; недоставленные сообщения остаются в потоке.
+var legacyConsumerPrefixes = []string{
+	"invite_consumer_",
+	"invite_reactions_consumer_",
+	"message_consumer_",
+	"clear_consumer_",
+	"rekey_consumer_",
+}
+
+// eventsSubjects — все темы, адресованные пользователю.
+func eventsSubjects(userID string) []string {
+	return []string{
+		fmt.Sprintf(InvitesSubjectPrefix, userID),
+		fmt.Sprintf(InvitesReactionSubjectPrefix, userID),
+		fmt.Sprintf(MessagesSubjectPrefix, "*", userID),
+		fmt.Sprintf(ClearChatSubjectPrefix, userID),
+		fmt.Sprintf(RekeySubjectPrefix, userID),
+	}
+}
+
+// EnsureEventsConsumer создаёт единственный consumer пользователя, через который
+// идут все его события. Один consumer отдаёт сообщения в порядке потока, поэтому
+// клиент может продолжить с последнего подтверждённого номера.
+func (c *JSClient) EnsureEventsConsumer(userID string) error {
+	consumerName := fmt.Sprintf(EventsConsumerName, userID)
+
+	for name := range c.JS.ConsumerNames(StreamName) {
+		if !isLegacyConsumer(name, userID) {
+			continue
+		}
+		if err := c.JS.DeleteConsumer(StreamName, name); err != nil && !errors.Is(err, nats.ErrConsumerNotFound) {
+			return fmt.Errorf("failed to delete consumer %s: %w", name, err)
+		}
+	}
+
+	_, err := c.JS.AddConsumer(StreamName, &nats.ConsumerConfig{
+		Durable:        consumerName,
+		FilterSubjects: eventsSubjects(userID),
+		AckPolicy:      nats.AckExplicitPolicy,
+		AckWait:        30 * time.Second,
+		// Неподтверждённые события возвращаются в очередь и при обрыве потока
+		MaxDeliver:    10,
+		DeliverPolicy: nats.DeliverAllPolicy,
+		ReplayPolicy:  nats.ReplayInstantPolicy,
+	})
+	if err != nil && !isConsumerExists(err) {
+		return fmt.Errorf("failed to create consumer: %w", err)
+	}
+	return nil
+}
+
+func isLegacyConsumer(name, userID string) bool {
+	if !strings.HasSuffix(name, "_"+userID) {
+		return false
+	}
+	for _, prefix := range legacyConsumerPrefixes {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Subscription читает события одного пользователя; используется из одной горутины.
+// Выданные и не подтверждённые через AckEvent события при закрытии сразу
+// возвращаются в очередь.
+type Subscription struct {
+	c         *JSClient
+	userID    string
+	sub       *nats.Subscription
+	queue     []*nats.Msg
+	delivered map[string]*nats.Msg
+}
+
+func (c *JSClient) SubscribeEvents(userID string) (*Subscription, error) {
+	consumerName := fmt.Sprintf(EventsConsumerName, userID)
+
+	sub, err := c.JS.PullSubscribe("", consumerName, nats.Bind(StreamName, consumerName))
+	if err != nil {
+		return nil, fmt.Errorf("pull subscribe: %w", err)
+	}
+	return &Subscription{
+		c:         c,
+		userID:    userID,
+		sub:       sub,
+		delivered: make(map[string]*nats.Msg),
+	}, nil
+}
+
+// Next ждёт следующее событие, пока не закончится ctx.
+func (s *Subscription) Next(ctx context.Context) (domain.Event, error) {
+	for {
+		if len(s.queue) == 0 {
+			fetchCtx, cancel := context.WithTimeout(ctx, eventsFetchWait)
+			msgs, err := s.sub.Fetch(eventsFetchBatch, nats.Context(fetchCtx))
+			cancel()
+			if ctx.Err() != nil {
+				return domain.Event{}, ctx.Err()
+			}
+			if err != nil && !errors.Is(err, context.DeadlineExceeded) && !errors.Is(err, nats.ErrTimeout) {
+				return domain.Event{}, fmt.Errorf("fetch: %w", err)
+			}
+			s.queue = msgs
+			continue
+		}
+
+		msg := s.queue[0]
+		s.queue = s.queue[1:]
+
+		event, err := decodeEvent(msg)
+		if err != nil {
+			slog.Error("dropping malformed event", "subject", msg.Subject, "error", err)
+			_ = msg.Term()
+			continue
+		}
+
+		s.forgetAcked()
+		s.c.pendingEvents.Store(s.pendingKey(event.MessageID), msg)
+		s.delivered[event.MessageID] = msg
+		return event, nil
+	}
+}
+
+// forgetAcked убирает из выданных события, которые клиент уже подтвердил.
+func (s *Subscription) forgetAcked() {
+	for id, msg := range s.delivered {
+		if val, ok := s.c.pendingEvents.Load(s.pendingKey(id)); !ok || val != msg {
+			delete(s.delivered, id)
+		}
+	}
+}
+
+func (s *Subscription) pendingKey(messageID string) pendingKey {
+	return pendingKey{userID: s.userID, messageID: messageID}
+}
+
+// Close возвращает в очередь всё, что было выдано, но не подтверждено.
+func (s *Subscription) Close() {
+	for id, msg := range s.delivered {
+		if s.c.pendingEvents.CompareAndDelete(s.pendingKey(id), msg) {
+			_ = msg.Nak()
+		}
+	}
+	for _, msg := range s.queue {
+		_ = msg.Nak()
+	}
+	s.delivered = nil
+	s.queue = nil
+
+	if err := s.sub.Unsubscribe(); err != nil {
+		slog.Warn("could not unsubscribe from events", "user", s.userID, "error", err)
+	}
+}
+
+// decodeEvent определяет вид события по теме: chat.<вид>[.<комната>].<получатель>.
+func decodeEvent(msg *nats.Msg) (domain.Event, error) {
+	meta, err := msg.Metadata()
+	if err != nil {
+		return domain.Event{}, fmt.Errorf("metadata: %w", err)
+	}
+	event := domain.Event{Sequence: meta.Sequence.Stream}
+
+	tokens := strings.Split(msg.Subject, ".")
+	if len(tokens) < 3 {
+		return domain.Event{}, fmt.Errorf("unexpected subject %q", msg.Subject)
+	}
+
+	switch {
+	case tokens[1] == "messages":
+		var chatMsg domain.ChatMessage
+		err = json.Unmarshal(msg.Data, &chatMsg)
+		event.Message, event.MessageID = &chatMsg, chatMsg.MessageID
+	case tokens[1] == "invite" && len(tokens) == 4 && tokens[2] == "reaction":
+		var reaction domain.InvitationReaction
+		err = json.Unmarshal(msg.Data, &reaction)
+		event.Reaction, event.MessageID = &reaction, reaction.MessageID
+	case tokens[1] == "invite":
+		var invite domain.ChatInvitation
+		err = json.Unmarshal(msg.Data, &invite)
+		event.Invitation, event.MessageID = &invite, invite.MessageID
+	case tokens[1] == "clear":
+		var action domain.ChatActions
+		err = json.Unmarshal(msg.Data, &action)
+		event.ClearHistory, event.MessageID = &action, action.MessageID
+	case tokens[1] == "rekey":
+		var action domain.ChatActions
+		err = json.Unmarshal(msg.Data, &action)
+		event.KeyUpdate, event.MessageID = &action, action.MessageID
+	default:
+		return domain.Event{}, fmt.Errorf("unexpected subject %q", msg.Subject)
+	}
+	if err != nil {
+		return domain.Event{}, fmt.Errorf("unmarshal: %w", err)
+	}
+	if event.MessageID == "" {
+		return domain.Event{}, fmt.Errorf("event without message id")
+	}
+	return event, nil
+}
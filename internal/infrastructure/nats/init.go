@@ -20,17 +20,25 @@ const (
 	InvitesSubjectPrefix         = "chat.invite.%s"
 	InvitesReactionSubjectPrefix = "chat.invite.reaction.%s"
 	MessagesSubjectPrefix        = "chat.messages.%s.%s"
-	InvitesConsumerName          = "invite_consumer_%s"
-	InviteReactionsConsumerName  = "invite_reactions_consumer_%s"
-	MessagesConsumerName         = "message_consumer_%s_%s"
 	ClearChatSubjectPrefix       = "chat.clear.%s"
-	ClearChatConsumerName        = "clear_consumer_%s"
+	RekeySubjectPrefix           = "chat.rekey.%s"
+	EventsConsumerName           = "events_consumer_%s"
 )
 
+// ErrForeignEvent — попытка подтвердить событие другого пользователя.
+var ErrForeignEvent = errors.New("event belongs to another user")
+
 type JSClient struct {
 	Conn          *nats.Conn
 	JS            nats.JetStreamContext
-	pendingEvents sync.Map
+	pendingEvents sync.Map // pendingKey -> *nats.Msg
+}
+
+// pendingKey — выданное и ещё не подтверждённое событие. ID сообщения выбирает
+// отправитель, поэтому он уникален только в пределах событий одного получателя.
+type pendingKey struct {
+	userID    string
+	messageID string
 }
 
 func NewJSClient(url string) *JSClient {
@@ -63,88 +71,6 @@ func NewJSClient(url string) *JSClient {
 	return &JSClient{Conn: nc, JS: js}
 }
 
-func (c *JSClient) EnsureInvitesConsumer(userID string) error {
-	consumerName := fmt.Sprintf(InvitesConsumerName, userID)
-	subject := fmt.Sprintf(InvitesSubjectPrefix, userID)
-
-	_, err := c.JS.AddConsumer(StreamName, &nats.ConsumerConfig{
-		Durable:       consumerName,
-		FilterSubject: subject,
-		AckPolicy:     nats.AckExplicitPolicy,
-		AckWait:       5 * time.Second,
-		MaxDeliver:    3,
-		DeliverPolicy: nats.DeliverAllPolicy,
-		ReplayPolicy:  nats.ReplayInstantPolicy,
-	})
-
-	if err != nil && !isConsumerExists(err) {
-		return fmt.Errorf("failed to create consumer: %w", err)
-	}
-
-	return nil
-}
-
-func (c *JSClient) EnsureInviteReactionsConsumer(userID string) error {
-	consumerName := fmt.Sprintf(InviteReactionsConsumerName, userID)
-	subject := fmt.Sprintf(InvitesReactionSubjectPrefix, userID)
-
-	_, err := c.JS.AddConsumer(StreamName, &nats.ConsumerConfig{
-		Durable:       consumerName,
-		FilterSubject: subject,
-		AckPolicy:     nats.AckExplicitPolicy,
-		AckWait:       5 * time.Second,
-		MaxDeliver:    3,
-		DeliverPolicy: nats.DeliverAllPolicy,
-		ReplayPolicy:  nats.ReplayInstantPolicy,
-	})
-
-	if err != nil && !isConsumerExists(err) {
-		return fmt.Errorf("failed to create consumer: %w", err)
-	}
-
-	return nil
-}
-
-func (c *JSClient) EnsureMessagesConsumer(userID, chatID string) error {
-	consumerName := fmt.Sprintf(MessagesConsumerName, chatID, userID)
-	subject := fmt.Sprintf(MessagesSubjectPrefix, chatID, userID)
-
-	_, err := c.JS.AddConsumer(StreamName, &nats.ConsumerConfig{
-		Durable:       consumerName,
-		FilterSubject: subject,
-		AckPolicy:     nats.AckExplicitPolicy,
-		AckWait:       5 * time.Second,
-		MaxDeliver:    3,
-		DeliverPolicy: nats.DeliverAllPolicy,
-		ReplayPolicy:  nats.ReplayInstantPolicy,
-	})
-
-	if err != nil && !isConsumerExists(err) {
-		return fmt.Errorf("failed to create consumer: %w", err)
-	}
-	return nil
-}
-
-func (c *JSClient) EnsureClearChatConsumer(userID string) error {
-	consumerName := fmt.Sprintf(ClearChatConsumerName, userID)
-	subject := fmt.Sprintf(ClearChatSubjectPrefix, userID)
-
-	_, err := c.JS.AddConsumer(StreamName, &nats.ConsumerConfig{
-		Durable:       consumerName,
-		FilterSubject: subject,
-		AckPolicy:     nats.AckExplicitPolicy,
-		AckWait:       5 * time.Second,
-		MaxDeliver:    3,
-		DeliverPolicy: nats.DeliverAllPolicy,
-		ReplayPolicy:  nats.ReplayInstantPolicy,
-	})
-
-	if err != nil && !isConsumerExists(err) {
-		return fmt.Errorf("failed to create consumer: %w", err)
-	}
-	return nil
-}
-
 func (c *JSClient) PublishInvitation(ctx context.Context, message domain.ChatInvitation) error {
 	var err error
 	subject := fmt.Sprintf(InvitesSubjectPrefix, message.ReceiverID)
@@ -205,40 +131,6 @@ func (c *JSClient) PublishChatMessage(ctx context.Context, msg *domain.ChatMessa
 	return nil
 }
 
-func (c *JSClient) FetchOneChatMessage(ctx context.Context, userID, chatID string) (domain.ChatMessage, error) {
-	subject := fmt.Sprintf(MessagesSubjectPrefix, chatID, userID)
-	consumerName := fmt.Sprintf(MessagesConsumerName, chatID, userID)
-
-	sub, err := c.JS.PullSubscribe(subject, consumerName)
-	if err != nil {
-		if errors.Is(err, nats.ErrJetStreamNotEnabled) {
-			return domain.ChatMessage{}, nats.ErrMsgNotFound
-		}
-		return domain.ChatMessage{}, fmt.Errorf("pull subscribe: %w", err)
-	}
-
-	msgs, err := sub.Fetch(1, nats.MaxWait(1*time.Second))
-
-	if err != nil && !errors.Is(err, ctx.Err()) && !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded) && !errors.Is(err, nats.ErrTimeout) {
-		slog.Error("failed to fetch message: %w", err)
-		return domain.ChatMessage{}, fmt.Errorf("fetch: %w", err)
-	}
-
-	if len(msgs) == 0 {
-		return domain.ChatMessage{}, nats.ErrMsgNotFound
-	}
-
-	msg := msgs[0]
-	var chatMsg domain.ChatMessage
-	if err = json.Unmarshal(msg.Data, &chatMsg); err != nil {
-		msg.Nak()
-		return domain.ChatMessage{}, fmt.Errorf("unmarshal: %w", err)
-	}
-
-	c.pendingEvents.Store(chatMsg.MessageID, msg)
-	return chatMsg, nil
-}
-
 func (c *JSClient) PublishClearChatHistoryRequest(ctx context.Context, actions domain.ChatActions) error {
 	subject := fmt.Sprintf(ClearChatSubjectPrefix, actions.UserID)
 
@@ -259,42 +151,31 @@ func (c *JSClient) PublishClearChatHistoryRequest(ctx context.Context, actions d
 	return nil
 }
 
-func (c *JSClient) FetchClearChatHistoryRequest(ctx context.Context, userID string) (domain.ChatActions, error) {
-	subject := fmt.Sprintf(ClearChatSubjectPrefix, userID)
-	consumerName := fmt.Sprintf(ClearChatConsumerName, userID)
+func (c *JSClient) PublishCipherKeyUpdate(ctx context.Context, action domain.ChatActions) error {
+	subject := fmt.Sprintf(RekeySubjectPrefix, action.UserID)
 
-	sub, err := c.JS.PullSubscribe(subject, consumerName)
+	data, err := json.Marshal(action)
 	if err != nil {
-		if errors.Is(err, nats.ErrJetStreamNotEnabled) {
-			return domain.ChatActions{}, nats.ErrMsgNotFound
-		}
-		return domain.ChatActions{}, fmt.Errorf("pull subscribe: %w", err)
-	}
-
-	msgs, err := sub.Fetch(1, nats.MaxWait(1*time.Second))
-
-	if err != nil && !errors.Is(err, ctx.Err()) && !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded) && !errors.Is(err, nats.ErrTimeout) {
-		slog.Error("failed to fetch message: %w", err)
-		return domain.ChatActions{}, fmt.Errorf("fetch: %w", err)
+		return fmt.Errorf("marshal: %w", err)
 	}
 
-	if len(msgs) == 0 {
-		return domain.ChatActions{}, nats.ErrMsgNotFound
-	}
+	natsMsg := nats.NewMsg(subject)
+	natsMsg.Header.Set("Message-ID", action.MessageID)
+	natsMsg.Data = data
 
-	msg := msgs[0]
-	var action domain.ChatActions
-	if err = json.Unmarshal(msg.Data, &action); err != nil {
-		msg.Nak()
-		return domain.ChatActions{}, fmt.Errorf("unmarshal: %w", err)
+	_, err = c.JS.PublishMsg(natsMsg, nats.MsgId(action.MessageID), nats.Context(ctx))
+	if err != nil {
+		slog.Error("failed to publish cipher key update", err.Error(), action)
+		return fmt.Errorf("publish: %w", err)
 	}
-
-	c.pendingEvents.Store(action.MessageID, msg)
-	return action, nil
+	return nil
 }
 
-func (c *JSClient) AckEvent(messageID string) error {
-	val, ok := c.pendingEvents.LoadAndDelete(messageID)
+// AckEvent подтверждает событие, выданное пользователю userID. Все темы заканчиваются
+// ID получателя, поэтому чужое событие подтвердить нельзя.
+func (c *JSClient) AckEvent(userID, messageID string) error {
+	key := pendingKey{userID: userID, messageID: messageID}
+	val, ok := c.pendingEvents.Load(key)
 	if !ok {
 		return nil
 	}
@@ -302,68 +183,11 @@ func (c *JSClient) AckEvent(messageID string) error {
 	if !ok {
 		return fmt.Errorf("invalid message type")
 	}
-	return msg.Ack()
-}
-
-func (c *JSClient) FetchOneInvitation(ctx context.Context, userID string) (domain.ChatInvitation, error) {
-	subject := fmt.Sprintf(InvitesSubjectPrefix, userID)
-	consumerName := fmt.Sprintf(InvitesConsumerName, userID)
-
-	sub, err := c.JS.PullSubscribe(subject, consumerName)
-	if err != nil {
-		return domain.ChatInvitation{}, fmt.Errorf("pull subscribe: %w", err)
+	if !strings.HasSuffix(msg.Subject, "."+userID) {
+		return ErrForeignEvent
 	}
-
-	msgs, err := sub.Fetch(1, nats.MaxWait(1*time.Second))
-
-	if err != nil && !errors.Is(err, ctx.Err()) && !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded) && !errors.Is(err, nats.ErrTimeout) {
-		slog.Error("failed to fetch invitation: %w", err)
-		return domain.ChatInvitation{}, fmt.Errorf("fetch: %w", err)
-	}
-
-	if len(msgs) == 0 {
-		return domain.ChatInvitation{}, nats.ErrMsgNotFound
-	}
-
-	msg := msgs[0]
-	var invite domain.ChatInvitation
-	if err = json.Unmarshal(msg.Data, &invite); err != nil {
-		msg.Nak()
-		return domain.ChatInvitation{}, fmt.Errorf("unmarshal: %w", err)
-	}
-
-	c.pendingEvents.Store(invite.MessageID, msg)
-	return invite, nil
-}
-
-func (c *JSClient) FetchOneInvitationReaction(ctx context.Context, userID string) (domain.InvitationReaction, error) {
-	subject := fmt.Sprintf(InvitesReactionSubjectPrefix, userID)
-	consumerName := fmt.Sprintf(InviteReactionsConsumerName, userID)
-
-	sub, err := c.JS.PullSubscribe(subject, consumerName)
-	if err != nil {
-		return domain.InvitationReaction{}, fmt.Errorf("pull subscribe: %w", err)
-	}
-
-	msgs, err := sub.Fetch(1, nats.MaxWait(1*time.Second))
-	if err != nil && !errors.Is(err, ctx.Err()) && !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded) && !errors.Is(err, nats.ErrTimeout) {
-		slog.Error("failed to fetch invitation reactions: %w", err)
-		return domain.InvitationReaction{}, fmt.Errorf("fetch: %w", err)
-	}
-
-	if len(msgs) == 0 {
-		return domain.InvitationReaction{}, nats.ErrMsgNotFound
-	}
-
-	msg := msgs[0]
-	var invite domain.InvitationReaction
-	if err = json.Unmarshal(msg.Data, &invite); err != nil {
-		msg.Nak()
-		return domain.InvitationReaction{}, fmt.Errorf("unmarshal: %w", err)
-	}
-
-	c.pendingEvents.Store(invite.MessageID, msg)
-	return invite, nil
+	c.pendingEvents.Delete(key)
+	return msg.Ack()
 }
 
 func isConsumerExists(err error) bool {
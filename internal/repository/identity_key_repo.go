@@ -0,0 +1,48 @@
+package repository
+
+import (
+	"CryptoMessenger/internal/domain"
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+type IdentityKeyRepository struct {
+	db *sql.DB
+}
+
+func (r *IdentityKeyRepository) Create(ctx context.Context, key domain.IdentityKey) error {
+	query := "INSERT INTO identity_keys (user_id, public_key) VALUES ($1, $2)"
+	if _, err := r.db.ExecContext(ctx, query, key.UserID, key.PublicKey); err != nil {
+		return fmt.Errorf("error while inserting identity key: %w", err)
+	}
+	return nil
+}
+
+func (r *IdentityKeyRepository) GetByUserID(ctx context.Context, userID string) (domain.IdentityKey, error) {
+	query := `SELECT k.user_id, u.username, k.public_key
+		FROM identity_keys k JOIN users u ON u.user_id = k.user_id
+		WHERE k.user_id = $1`
+	return r.get(ctx, query, userID)
+}
+
+func (r *IdentityKeyRepository) GetByUsername(ctx context.Context, username string) (domain.IdentityKey, error) {
+	query := `SELECT k.user_id, u.username, k.public_key
+		FROM identity_keys k JOIN users u ON u.user_id = k.user_id
+		WHERE u.username = $1`
+	return r.get(ctx, query, username)
+}
+
+func (r *IdentityKeyRepository) get(ctx context.Context, query string, arg string) (domain.IdentityKey, error) {
+	var key domain.IdentityKey
+	if err := r.db.QueryRowContext(ctx, query, arg).Scan(&key.UserID, &key.Username, &key.PublicKey); err != nil {
+		return domain.IdentityKey{}, fmt.Errorf("error getting identity key: %w", err)
+	}
+	return key, nil
+}
+
+func NewIdentityKeyRepository(db *sql.DB) *IdentityKeyRepository {
+	return &IdentityKeyRepository{
+		db: db,
+	}
+}
@@ -4,20 +4,134 @@ import (
 	"CryptoMessenger/internal/domain"
 	"context"
 	"database/sql"
+	"errors"
+	"fmt"
 )
 
 type KeyRepository struct {
 	db *sql.DB
 }
 
+// Store запоминает открытый ключ участника комнаты, повторный вызов заменяет его.
 func (k *KeyRepository) Store(ctx context.Context, pk domain.PublicKey) error {
-	//TODO implement me
-	panic("implement me")
+	query := `INSERT INTO room_keys (room_id, user_id, public_key) VALUES ($1, $2, $3)
+		ON CONFLICT (room_id, user_id) DO UPDATE SET public_key = EXCLUDED.public_key, created_at = now()`
+	if _, err := k.db.ExecContext(ctx, query, pk.RoomID, pk.ClientID, pk.PublicKeyHex); err != nil {
+		return fmt.Errorf("error while storing room key: %w", err)
+	}
+	return nil
 }
 
 func (k *KeyRepository) ListByRoom(ctx context.Context, roomID string) ([]domain.PublicKey, error) {
-	//TODO implement me
-	panic("implement me")
+	rows, err := k.db.QueryContext(ctx, "SELECT room_id, user_id, public_key FROM room_keys WHERE room_id = $1", roomID)
+	if err != nil {
+		return nil, fmt.Errorf("error listing room keys: %w", err)
+	}
+	defer rows.Close()
+
+	var keys []domain.PublicKey
+	for rows.Next() {
+		var pk domain.PublicKey
+		if err = rows.Scan(&pk.RoomID, &pk.ClientID, &pk.PublicKeyHex); err != nil {
+			return nil, fmt.Errorf("error scanning room key: %w", err)
+		}
+		keys = append(keys, pk)
+	}
+	return keys, rows.Err()
+}
+
+// StorePrekeys заменяет подписанные предключи и добавляет одноразовые в одной транзакции.
+func (k *KeyRepository) StorePrekeys(ctx context.Context, userID string, prekeys []domain.Prekey) error {
+	tx, err := k.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	signedQuery := `INSERT INTO prekeys (user_id, key_id, key_agreement, dh_group, public_key, signature, one_time)
+		VALUES ($1, $2, $3, $4, $5, $6, FALSE)
+		ON CONFLICT (user_id, key_agreement, dh_group) WHERE NOT one_time
+		DO UPDATE SET key_id = EXCLUDED.key_id, public_key = EXCLUDED.public_key,
+			signature = EXCLUDED.signature, created_at = now()`
+	oneTimeQuery := `INSERT INTO prekeys (user_id, key_id, key_agreement, dh_group, public_key, one_time)
+		VALUES ($1, $2, $3, $4, $5, TRUE)
+		ON CONFLICT (user_id, key_id) DO NOTHING`
+
+	for _, p := range prekeys {
+		if p.OneTime {
+			_, err = tx.ExecContext(ctx, oneTimeQuery, userID, p.KeyID, p.KeyAgreement, p.DHGroup, p.PublicKey)
+		} else {
+			_, err = tx.ExecContext(ctx, signedQuery, userID, p.KeyID, p.KeyAgreement, p.DHGroup, p.PublicKey, p.Signature)
+		}
+		if err != nil {
+			return fmt.Errorf("error while inserting prekey: %w", err)
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("error committing prekeys: %w", err)
+	}
+	return nil
+}
+
+// TakePrekeyBundle возвращает подписанный предключ пользователя и удаляет один
+// одноразовый, чтобы он не достался двум отправителям.
+func (k *KeyRepository) TakePrekeyBundle(ctx context.Context, username, keyAgreement, dhGroup string) (domain.PrekeyBundle, error) {
+	tx, err := k.db.BeginTx(ctx, nil)
+	if err != nil {
+		return domain.PrekeyBundle{}, fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	bundle := domain.PrekeyBundle{Username: username}
+	signed := &bundle.SignedPrekey
+	query := `SELECT p.user_id, p.key_id, p.key_agreement, p.dh_group, p.public_key, p.signature
+		FROM prekeys p JOIN users u ON u.user_id = p.user_id
+		WHERE u.username = $1 AND p.key_agreement = $2 AND p.dh_group = $3 AND NOT p.one_time`
+	err = tx.QueryRowContext(ctx, query, username, keyAgreement, dhGroup).Scan(
+		&signed.UserID, &signed.KeyID, &signed.KeyAgreement, &signed.DHGroup, &signed.PublicKey, &signed.Signature)
+	if err != nil {
+		return domain.PrekeyBundle{}, fmt.Errorf("error getting signed prekey: %w", err)
+	}
+
+	oneTime := domain.Prekey{UserID: signed.UserID, KeyAgreement: keyAgreement, DHGroup: dhGroup, OneTime: true}
+	query = `DELETE FROM prekeys WHERE user_id = $1 AND key_id = (
+			SELECT key_id FROM prekeys
+			WHERE user_id = $1 AND key_agreement = $2 AND dh_group = $3 AND one_time
+			ORDER BY created_at LIMIT 1 FOR UPDATE SKIP LOCKED)
+		RETURNING key_id, public_key`
+	err = tx.QueryRowContext(ctx, query, signed.UserID, keyAgreement, dhGroup).Scan(&oneTime.KeyID, &oneTime.PublicKey)
+	switch {
+	case err == nil:
+		bundle.OneTimePrekey = &oneTime
+	case !errors.Is(err, sql.ErrNoRows):
+		return domain.PrekeyBundle{}, fmt.Errorf("error taking one-time prekey: %w", err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return domain.PrekeyBundle{}, fmt.Errorf("error committing prekey bundle: %w", err)
+	}
+	return bundle, nil
+}
+
+func (k *KeyRepository) CountOneTimePrekeys(ctx context.Context, userID string) ([]domain.PrekeyCount, error) {
+	query := `SELECT key_agreement, dh_group, count(*) FROM prekeys
+		WHERE user_id = $1 AND one_time GROUP BY key_agreement, dh_group`
+	rows, err := k.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("error counting prekeys: %w", err)
+	}
+	defer rows.Close()
+
+	var counts []domain.PrekeyCount
+	for rows.Next() {
+		var c domain.PrekeyCount
+		if err = rows.Scan(&c.KeyAgreement, &c.DHGroup, &c.Count); err != nil {
+			return nil, fmt.Errorf("error scanning prekey count: %w", err)
+		}
+		counts = append(counts, c)
+	}
+	return counts, rows.Err()
 }
 
 func NewKeyRepository(db *sql.DB) *KeyRepository {
@@ -4,11 +4,16 @@ import (
 	"CryptoMessenger/internal/domain"
 	"context"
 	"database/sql"
+	"time"
 )
 
 type KeyRepo interface {
 	Store(ctx context.Context, pk domain.PublicKey) error
 	ListByRoom(ctx context.Context, roomID string) ([]domain.PublicKey, error)
+
+	StorePrekeys(ctx context.Context, userID string, prekeys []domain.Prekey) error
+	TakePrekeyBundle(ctx context.Context, username, keyAgreement, dhGroup string) (domain.PrekeyBundle, error)
+	CountOneTimePrekeys(ctx context.Context, userID string) ([]domain.PrekeyCount, error)
 }
 
 type RoomRepo interface {
@@ -19,6 +24,11 @@ type RoomRepo interface {
 	AddMember(ctx context.Context, roomID, userID string) error
 	RemoveMember(ctx context.Context, roomID, userID string) error
 	ListMembers(ctx context.Context, roomID string) ([]string, error)
+	IsMember(ctx context.Context, roomID, userID string) (bool, error)
+
+	AddInvitation(ctx context.Context, roomID, userID, invitedBy string) error
+	TakeInvitation(ctx context.Context, roomID, userID string) (string, error)
+	InvitedBy(ctx context.Context, roomID, userID string) (string, error)
 }
 
 type UserRepo interface {
@@ -27,17 +37,37 @@ type UserRepo interface {
 	GetByID(ctx context.Context, id string) (domain.User, error)
 }
 
+type IdentityKeyRepo interface {
+	Create(ctx context.Context, key domain.IdentityKey) error
+	GetByUserID(ctx context.Context, userID string) (domain.IdentityKey, error)
+	GetByUsername(ctx context.Context, username string) (domain.IdentityKey, error)
+}
+
+// TokenRepo хранит токены обновления и список отозванных токенов доступа.
+type TokenRepo interface {
+	CreateRefreshToken(ctx context.Context, token domain.RefreshToken) error
+	FindRefreshToken(ctx context.Context, hash []byte) (domain.RefreshToken, error)
+	MarkRefreshTokenUsed(ctx context.Context, hash []byte) (bool, error)
+	RevokeFamily(ctx context.Context, familyID string) error
+	RevokeAccessToken(ctx context.Context, tokenID string, expiresAt time.Time) error
+	IsAccessTokenRevoked(ctx context.Context, tokenID string) (bool, error)
+}
+
 type Repository struct {
 	KeyRepo
 	RoomRepo
 	UserRepo
+	IdentityKeyRepo
+	TokenRepo
 }
 
 func NewRepository(db *sql.DB) *Repository {
 	return &Repository{
-		KeyRepo:  NewKeyRepository(db),
-		RoomRepo: NewRoomRepository(db),
-		UserRepo: NewUserRepository(db),
+		KeyRepo:         NewKeyRepository(db),
+		RoomRepo:        NewRoomRepository(db),
+		UserRepo:        NewUserRepository(db),
+		IdentityKeyRepo: NewIdentityKeyRepository(db),
+		TokenRepo:       NewTokenRepository(db),
 	}
 
 }
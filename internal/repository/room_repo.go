@@ -11,38 +11,133 @@ type RoomRepository struct {
 	db *sql.DB
 }
 
+// Create сохраняет комнату и сразу добавляет в неё создателя.
 func (r *RoomRepository) Create(ctx context.Context, cfg domain.RoomConfig) error {
-	query := "INSERT INTO chats (chat_id, name, algorithm, mode, padding, iv, random_delta) VALUES ($1, $2, $3, $4, $5, $6, $7)"
-	_, err := r.db.ExecContext(ctx, query, cfg.RoomID, cfg.RoomName, cfg.Algorithm, cfg.Mode, cfg.Padding, cfg.Iv, cfg.RandomDelta)
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	query := "INSERT INTO chats (chat_id, name, algorithm, mode, padding, iv, random_delta, word_size, rounds, key_length) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)"
+	_, err = tx.ExecContext(ctx, query, cfg.RoomID, cfg.RoomName, cfg.Algorithm, cfg.Mode, cfg.Padding, cfg.Iv, cfg.RandomDelta,
+		cfg.WordSize, cfg.Rounds, cfg.KeyLength)
 	if err != nil {
 		return fmt.Errorf("error creating room: %w", err)
 	}
+	if cfg.CreatorID != "" {
+		if _, err = tx.ExecContext(ctx, "INSERT INTO room_participants (room_id, user_id) VALUES ($1, $2)", cfg.RoomID, cfg.CreatorID); err != nil {
+			return fmt.Errorf("error adding room creator: %w", err)
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("error committing room: %w", err)
+	}
 	return nil
 }
 
+// Delete удаляет комнату; участники и ключи комнаты удаляются каскадно.
 func (r *RoomRepository) Delete(ctx context.Context, roomID string) error {
-	//TODO implement me
-	panic("implement me")
+	res, err := r.db.ExecContext(ctx, "DELETE FROM chats WHERE chat_id = $1", roomID)
+	if err != nil {
+		return fmt.Errorf("error deleting room: %w", err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return fmt.Errorf("error deleting room: %w", sql.ErrNoRows)
+	}
+	return nil
 }
 
 func (r *RoomRepository) Get(ctx context.Context, roomID string) (domain.RoomConfig, error) {
-	//TODO implement me
-	panic("implement me")
+	query := `SELECT chat_id, name, algorithm, mode, padding, iv, random_delta, word_size, rounds, key_length
+		FROM chats WHERE chat_id = $1`
+
+	var cfg domain.RoomConfig
+	err := r.db.QueryRowContext(ctx, query, roomID).Scan(&cfg.RoomID, &cfg.RoomName, &cfg.Algorithm, &cfg.Mode, &cfg.Padding,
+		&cfg.Iv, &cfg.RandomDelta, &cfg.WordSize, &cfg.Rounds, &cfg.KeyLength)
+	if err != nil {
+		return domain.RoomConfig{}, fmt.Errorf("error getting room: %w", err)
+	}
+	return cfg, nil
 }
 
+// AddMember добавляет участника; повторное добавление ничего не меняет.
 func (r *RoomRepository) AddMember(ctx context.Context, roomID, userID string) error {
-	//TODO implement me
-	panic("implement me")
+	query := "INSERT INTO room_participants (room_id, user_id) VALUES ($1, $2) ON CONFLICT (room_id, user_id) DO NOTHING"
+	if _, err := r.db.ExecContext(ctx, query, roomID, userID); err != nil {
+		return fmt.Errorf("error adding room member: %w", err)
+	}
+	return nil
 }
 
 func (r *RoomRepository) RemoveMember(ctx context.Context, roomID, userID string) error {
-	//TODO implement me
-	panic("implement me")
+	res, err := r.db.ExecContext(ctx, "DELETE FROM room_participants WHERE room_id = $1 AND user_id = $2", roomID, userID)
+	if err != nil {
+		return fmt.Errorf("error removing room member: %w", err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return fmt.Errorf("error removing room member: %w", sql.ErrNoRows)
+	}
+	return nil
 }
 
+// ListMembers возвращает ID участников в порядке вступления.
 func (r *RoomRepository) ListMembers(ctx context.Context, roomID string) ([]string, error) {
-	//TODO implement me
-	panic("implement me")
+	rows, err := r.db.QueryContext(ctx, "SELECT user_id FROM room_participants WHERE room_id = $1 ORDER BY joined_at", roomID)
+	if err != nil {
+		return nil, fmt.Errorf("error listing room members: %w", err)
+	}
+	defer rows.Close()
+
+	var members []string
+	for rows.Next() {
+		var userID string
+		if err = rows.Scan(&userID); err != nil {
+			return nil, fmt.Errorf("error scanning room member: %w", err)
+		}
+		members = append(members, userID)
+	}
+	return members, rows.Err()
+}
+
+func (r *RoomRepository) IsMember(ctx context.Context, roomID, userID string) (bool, error) {
+	query := "SELECT EXISTS (SELECT 1 FROM room_participants WHERE room_id = $1 AND user_id = $2)"
+	var ok bool
+	if err := r.db.QueryRowContext(ctx, query, roomID, userID).Scan(&ok); err != nil {
+		return false, fmt.Errorf("error checking room member: %w", err)
+	}
+	return ok, nil
+}
+
+// AddInvitation запоминает приглашение; повторное приглашение заменяет прежнее.
+func (r *RoomRepository) AddInvitation(ctx context.Context, roomID, userID, invitedBy string) error {
+	query := `INSERT INTO room_invitations (room_id, user_id, invited_by) VALUES ($1, $2, $3)
+		ON CONFLICT (room_id, user_id) DO UPDATE SET invited_by = EXCLUDED.invited_by, created_at = now()`
+	if _, err := r.db.ExecContext(ctx, query, roomID, userID, invitedBy); err != nil {
+		return fmt.Errorf("error adding room invitation: %w", err)
+	}
+	return nil
+}
+
+// TakeInvitation удаляет приглашение и возвращает ID пригласившего.
+func (r *RoomRepository) TakeInvitation(ctx context.Context, roomID, userID string) (string, error) {
+	query := "DELETE FROM room_invitations WHERE room_id = $1 AND user_id = $2 RETURNING invited_by"
+	var invitedBy string
+	if err := r.db.QueryRowContext(ctx, query, roomID, userID).Scan(&invitedBy); err != nil {
+		return "", fmt.Errorf("error taking room invitation: %w", err)
+	}
+	return invitedBy, nil
+}
+
+// InvitedBy возвращает ID пригласившего, не удаляя приглашение.
+func (r *RoomRepository) InvitedBy(ctx context.Context, roomID, userID string) (string, error) {
+	query := "SELECT invited_by FROM room_invitations WHERE room_id = $1 AND user_id = $2"
+	var invitedBy string
+	if err := r.db.QueryRowContext(ctx, query, roomID, userID).Scan(&invitedBy); err != nil {
+		return "", fmt.Errorf("error getting room invitation: %w", err)
+	}
+	return invitedBy, nil
 }
 
 func NewRoomRepository(db *sql.DB) *RoomRepository {
@@ -0,0 +1,85 @@
+package repository
+
+import (
+	"CryptoMessenger/internal/domain"
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+type TokenRepository struct {
+	db *sql.DB
+}
+
+// CreateRefreshToken сохраняет токен обновления и заодно удаляет истёкшие записи пользователя.
+func (r *TokenRepository) CreateRefreshToken(ctx context.Context, token domain.RefreshToken) error {
+	if _, err := r.db.ExecContext(ctx, "DELETE FROM refresh_tokens WHERE user_id = $1 AND expires_at < now()", token.UserID); err != nil {
+		return fmt.Errorf("error purging refresh tokens: %w", err)
+	}
+	query := "INSERT INTO refresh_tokens (token_hash, user_id, family_id, expires_at) VALUES ($1, $2, $3, $4)"
+	if _, err := r.db.ExecContext(ctx, query, token.Hash, token.UserID, token.FamilyID, token.ExpiresAt); err != nil {
+		return fmt.Errorf("error creating refresh token: %w", err)
+	}
+	return nil
+}
+
+func (r *TokenRepository) FindRefreshToken(ctx context.Context, hash []byte) (domain.RefreshToken, error) {
+	query := "SELECT token_hash, user_id, family_id, expires_at, used_at IS NOT NULL FROM refresh_tokens WHERE token_hash = $1"
+
+	var token domain.RefreshToken
+	err := r.db.QueryRowContext(ctx, query, hash).Scan(&token.Hash, &token.UserID, &token.FamilyID, &token.ExpiresAt, &token.Used)
+	if err != nil {
+		return domain.RefreshToken{}, fmt.Errorf("error getting refresh token: %w", err)
+	}
+	return token, nil
+}
+
+// MarkRefreshTokenUsed помечает токен использованным; false — его уже использовал
+// другой запрос. Так из двух одновременных обновлений одним токеном успешно только одно.
+func (r *TokenRepository) MarkRefreshTokenUsed(ctx context.Context, hash []byte) (bool, error) {
+	res, err := r.db.ExecContext(ctx, "UPDATE refresh_tokens SET used_at = now() WHERE token_hash = $1 AND used_at IS NULL", hash)
+	if err != nil {
+		return false, fmt.Errorf("error marking refresh token used: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("error marking refresh token used: %w", err)
+	}
+	return n == 1, nil
+}
+
+// RevokeFamily удаляет все токены обновления, выпущенные от одного входа.
+func (r *TokenRepository) RevokeFamily(ctx context.Context, familyID string) error {
+	if _, err := r.db.ExecContext(ctx, "DELETE FROM refresh_tokens WHERE family_id = $1", familyID); err != nil {
+		return fmt.Errorf("error revoking refresh tokens: %w", err)
+	}
+	return nil
+}
+
+// RevokeAccessToken вносит токен доступа в список отозванных; истёкшие записи удаляются.
+func (r *TokenRepository) RevokeAccessToken(ctx context.Context, tokenID string, expiresAt time.Time) error {
+	if _, err := r.db.ExecContext(ctx, "DELETE FROM revoked_tokens WHERE expires_at < now()"); err != nil {
+		return fmt.Errorf("error purging revoked tokens: %w", err)
+	}
+	query := "INSERT INTO revoked_tokens (token_id, expires_at) VALUES ($1, $2) ON CONFLICT (token_id) DO NOTHING"
+	if _, err := r.db.ExecContext(ctx, query, tokenID, expiresAt); err != nil {
+		return fmt.Errorf("error revoking access token: %w", err)
+	}
+	return nil
+}
+
+func (r *TokenRepository) IsAccessTokenRevoked(ctx context.Context, tokenID string) (bool, error) {
+	var revoked bool
+	err := r.db.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM revoked_tokens WHERE token_id = $1)", tokenID).Scan(&revoked)
+	if err != nil {
+		return false, fmt.Errorf("error checking revoked token: %w", err)
+	}
+	return revoked, nil
+}
+
+func NewTokenRepository(db *sql.DB) *TokenRepository {
+	return &TokenRepository{
+		db: db,
+	}
+}
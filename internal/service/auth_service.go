@@ -1,27 +1,48 @@
 package service
 
 import (
+	"CryptoMessenger/algorithm/identity"
+	"CryptoMessenger/internal/auth"
 	"CryptoMessenger/internal/domain"
 	myErrors "CryptoMessenger/internal/errors"
 	natsjs "CryptoMessenger/internal/infrastructure/nats"
 	"CryptoMessenger/internal/repository"
+	"bytes"
 	"context"
+	"crypto/ed25519"
 	"database/sql"
 	"errors"
 	"fmt"
 	"github.com/google/uuid"
 	"golang.org/x/crypto/bcrypt"
+	"strings"
+	"time"
 )
 
+// maxPrekeysPerUpload ограничивает число одноразовых предключей в одном запросе.
+const maxPrekeysPerUpload = 100
+
 type AuthService struct {
-	users    repository.UserRepo
-	jsClient *natsjs.JSClient
+	users        repository.UserRepo
+	identityKeys repository.IdentityKeyRepo
+	keys         repository.KeyRepo
+	tokens       repository.TokenRepo
+	jsClient     *natsjs.JSClient
+
+	signer     *auth.Signer
+	refreshTTL time.Duration
 }
 
-func NewAuthService(userRepo repository.UserRepo, jsClient *natsjs.JSClient) *AuthService {
+func NewAuthService(userRepo repository.UserRepo, identityKeyRepo repository.IdentityKeyRepo, keyRepo repository.KeyRepo,
+	tokenRepo repository.TokenRepo, jsClient *natsjs.JSClient, signer *auth.Signer, refreshTTL time.Duration) *AuthService {
 	return &AuthService{
-		users:    userRepo,
-		jsClient: jsClient,
+		users:        userRepo,
+		identityKeys: identityKeyRepo,
+		keys:         keyRepo,
+		tokens:       tokenRepo,
+		jsClient:     jsClient,
+		signer:       signer,
+		refreshTTL:   refreshTTL,
 	}
 }
 
@@ -49,16 +70,8 @@ func (s *AuthService) Register(ctx context.Context, username, password string) (
 		return "", fmt.Errorf("error creating user: %w", err)
 	}
 
-	if err = s.jsClient.EnsureInvitesConsumer(uid); err != nil {
-		return "", fmt.Errorf("failed to init invites consumer: %w", err)
-	}
-
-	if err = s.jsClient.EnsureInviteReactionsConsumer(uid); err != nil {
-		return "", fmt.Errorf("failed to init invite reactions consumer: %w", err)
-	}
-
-	if err = s.jsClient.EnsureClearChatConsumer(uid); err != nil {
-		return "", fmt.Errorf("failed to init clear chat consumer: %w", err)
+	if err = s.jsClient.EnsureEventsConsumer(uid); err != nil {
+		return "", fmt.Errorf("failed to init events consumer: %w", err)
 	}
 
 	return uid, nil
@@ -75,5 +88,117 @@ func (s *AuthService) Login(ctx context.Context, username, password string) (str
 	if err = bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)); err != nil {
 		return "", myErrors.ErrInvalidPassword
 	}
+	// Пользователи, зарегистрированные до появления потока событий, получают consumer при входе.
+	if err = s.jsClient.EnsureEventsConsumer(user.ID); err != nil {
+		return "", fmt.Errorf("failed to init events consumer: %w", err)
+	}
 	return user.ID, nil
 }
+
+// PublishIdentityKey закрепляет ключ идентичности за пользователем. Заменить его нельзя:
+// иначе сервер (или укравший токен) мог бы выдать собеседникам чужой ключ.
+// Повторная публикация того же ключа не считается ошибкой.
+func (s *AuthService) PublishIdentityKey(ctx context.Context, userID string, publicKey []byte) error {
+	if len(publicKey) != ed25519.PublicKeySize {
+		return fmt.Errorf("%w: expected %d bytes, got %d", myErrors.ErrInvalidIdentityKey, ed25519.PublicKeySize, len(publicKey))
+	}
+
+	existing, err := s.identityKeys.GetByUserID(ctx, userID)
+	if err == nil {
+		if bytes.Equal(existing.PublicKey, publicKey) {
+			return nil
+		}
+		return myErrors.ErrIdentityKeyExists
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("error getting identity key: %w", err)
+	}
+
+	if err = s.identityKeys.Create(ctx, domain.IdentityKey{UserID: userID, PublicKey: publicKey}); err != nil {
+		return fmt.Errorf("error saving identity key: %w", err)
+	}
+	return nil
+}
+
+func (s *AuthService) GetIdentityKey(ctx context.Context, username string) (domain.IdentityKey, error) {
+	key, err := s.identityKeys.GetByUsername(ctx, username)
+	if errors.Is(err, sql.ErrNoRows) {
+		return domain.IdentityKey{}, myErrors.ErrIdentityKeyNotFound
+	}
+	if err != nil {
+		return domain.IdentityKey{}, fmt.Errorf("error getting identity key: %w", err)
+	}
+	return key, nil
+}
+
+// UploadPrekeys сохраняет предключи пользователя. Подпись подписанных предключей
+// проверяется ключом идентичности, поэтому загрузить их можно только после его публикации.
+// Возвращает число оставшихся одноразовых предключей по схемам.
+func (s *AuthService) UploadPrekeys(ctx context.Context, userID string, prekeys []domain.Prekey) ([]domain.PrekeyCount, error) {
+	oneTime := 0
+	for _, p := range prekeys {
+		if p.OneTime {
+			oneTime++
+		}
+	}
+	if oneTime > maxPrekeysPerUpload {
+		return nil, fmt.Errorf("%w: too many one-time prekeys (%d)", myErrors.ErrInvalidPrekey, oneTime)
+	}
+
+	var owner domain.IdentityKey
+	if oneTime < len(prekeys) {
+		var err error
+		owner, err = s.identityKeys.GetByUserID(ctx, userID)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("%w: identity key is not published", myErrors.ErrInvalidPrekey)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error getting identity key: %w", err)
+		}
+	}
+
+	for i, p := range prekeys {
+		if p.KeyID == "" {
+			return nil, fmt.Errorf("%w: missing key id", myErrors.ErrInvalidPrekey)
+		}
+		keyAgreement, group, err := validateAgreementKey(p.KeyAgreement, p.DHGroup, p.PublicKey)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", myErrors.ErrInvalidPrekey, err)
+		}
+		prekeys[i].KeyAgreement, prekeys[i].DHGroup = keyAgreement, group
+		if p.OneTime {
+			continue
+		}
+		fields := identity.PrekeyFields(owner.Username, p.KeyID, keyAgreement, group, p.PublicKey)
+		if err = identity.Verify(owner.PublicKey, p.Signature, identity.PrekeyContext, fields...); err != nil {
+			return nil, fmt.Errorf("%w: %v", myErrors.ErrInvalidPrekey, err)
+		}
+	}
+
+	if len(prekeys) > 0 {
+		if err := s.keys.StorePrekeys(ctx, userID, prekeys); err != nil {
+			return nil, fmt.Errorf("error saving prekeys: %w", err)
+		}
+	}
+	counts, err := s.keys.CountOneTimePrekeys(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("error counting prekeys: %w", err)
+	}
+	return counts, nil
+}
+
+func (s *AuthService) GetPrekeyBundle(ctx context.Context, username, keyAgreement, dhGroup string) (domain.PrekeyBundle, error) {
+	if keyAgreement == "" || strings.EqualFold(keyAgreement, domain.KeyAgreementDH) {
+		keyAgreement = domain.KeyAgreementDH
+	} else {
+		dhGroup = ""
+	}
+	bundle, err := s.keys.TakePrekeyBundle(ctx, username, keyAgreement, dhGroup)
+	if errors.Is(err, sql.ErrNoRows) {
+		return domain.PrekeyBundle{}, myErrors.ErrPrekeyNotFound
+	}
+	if err != nil {
+		return domain.PrekeyBundle{}, fmt.Errorf("error getting prekey bundle: %w", err)
+	}
+	return bundle, nil
+}
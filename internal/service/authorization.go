@@ -0,0 +1,70 @@
+package service
+
+import (
+	"CryptoMessenger/internal/auth"
+	myErrors "CryptoMessenger/internal/errors"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// Проверки доступа. Вызывающий — Principal, который interceptor кладёт в контекст
+// по JWT; ID пользователя в теле запроса допускается только совпадающий с ним.
+// Доступ к комнате есть только у её участников (room_participants).
+
+// AuthorizeUser запрещает действовать от имени другого пользователя.
+func AuthorizeUser(ctx context.Context, requestedUserID string) error {
+	principal, ok := auth.PrincipalFromContext(ctx)
+	if !ok {
+		return fmt.Errorf("%w: unauthenticated call", myErrors.ErrPermissionDenied)
+	}
+	if requestedUserID != "" && requestedUserID != principal.UserID {
+		return fmt.Errorf("%w: request for another user", myErrors.ErrPermissionDenied)
+	}
+	return nil
+}
+
+// authorizeMember проверяет, что userID участвует в комнате roomID.
+func (s *ChatService) authorizeMember(ctx context.Context, roomID, userID string) error {
+	if roomID == "" {
+		return fmt.Errorf("%w: room is not specified", myErrors.ErrPermissionDenied)
+	}
+	ok, err := s.rooms.IsMember(ctx, roomID, userID)
+	if err != nil {
+		return fmt.Errorf("cannot check room membership: %w", err)
+	}
+	if !ok {
+		return fmt.Errorf("%w: not a member of room %s", myErrors.ErrPermissionDenied, roomID)
+	}
+	return nil
+}
+
+// authorizePeer проверяет, что получатель — другой участник комнаты: сообщения
+// комнаты доставляются только её участникам.
+func (s *ChatService) authorizePeer(ctx context.Context, roomID, senderID, receiverID string) error {
+	if receiverID == senderID {
+		return fmt.Errorf("%w: receiver is the sender", myErrors.ErrPermissionDenied)
+	}
+	return s.authorizeMember(ctx, roomID, receiverID)
+}
+
+// authorizePeerOrInvitee пропускает, кроме участника, и того, кого отправитель
+// пригласил в комнату, но кто ещё не ответил на приглашение.
+func (s *ChatService) authorizePeerOrInvitee(ctx context.Context, roomID, senderID, receiverID string) error {
+	err := s.authorizePeer(ctx, roomID, senderID, receiverID)
+	if !errors.Is(err, myErrors.ErrPermissionDenied) || receiverID == senderID {
+		return err
+	}
+	invitedBy, invErr := s.rooms.InvitedBy(ctx, roomID, receiverID)
+	if errors.Is(invErr, sql.ErrNoRows) {
+		return err
+	}
+	if invErr != nil {
+		return fmt.Errorf("cannot check room invitation: %w", invErr)
+	}
+	if invitedBy != senderID {
+		return err
+	}
+	return nil
+}
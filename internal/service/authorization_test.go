@@ -0,0 +1,182 @@
+package service
+
+import (
+	"CryptoMessenger/internal/auth"
+	"CryptoMessenger/internal/domain"
+	myErrors "CryptoMessenger/internal/errors"
+	"CryptoMessenger/internal/repository"
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+)
+
+// fakeRoomRepo хранит участников и приглашения в памяти; остальные методы
+// RoomRepo проверкам доступа не нужны и при вызове паникуют.
+type fakeRoomRepo struct {
+	repository.RoomRepo
+	members     map[string]map[string]bool
+	invitations map[string]map[string]string
+}
+
+func (r *fakeRoomRepo) IsMember(_ context.Context, roomID, userID string) (bool, error) {
+	return r.members[roomID][userID], nil
+}
+
+func (r *fakeRoomRepo) InvitedBy(_ context.Context, roomID, userID string) (string, error) {
+	invitedBy, ok := r.invitations[roomID][userID]
+	if !ok {
+		return "", sql.ErrNoRows
+	}
+	return invitedBy, nil
+}
+
+type fakeUserRepo struct {
+	repository.UserRepo
+	users []domain.User
+}
+
+func (r *fakeUserRepo) GetByID(_ context.Context, id string) (domain.User, error) {
+	for _, u := range r.users {
+		if u.ID == id {
+			return u, nil
+		}
+	}
+	return domain.User{}, sql.ErrNoRows
+}
+
+func (r *fakeUserRepo) GetByUsername(_ context.Context, username string) (domain.User, error) {
+	for _, u := range r.users {
+		if u.Username == username {
+			return u, nil
+		}
+	}
+	return domain.User{}, sql.ErrNoRows
+}
+
+// newAuthorizationService: в комнате room участвуют alice и bob; carol alice
+// пригласила, но carol ещё не ответила; dave пригласил bob; eve ни при чём.
+func newAuthorizationService() *ChatService {
+	rooms := &fakeRoomRepo{
+		members: map[string]map[string]bool{
+			"room": {"alice-id": true, "bob-id": true},
+		},
+		invitations: map[string]map[string]string{
+			"room": {"carol-id": "alice-id", "dave-id": "bob-id"},
+		},
+	}
+	users := &fakeUserRepo{}
+	for _, name := range []string{"alice", "bob", "carol", "dave", "eve"} {
+		users.users = append(users.users, domain.User{ID: name + "-id", Username: name})
+	}
+	// jsClient не нужен: отказ в доступе происходит до публикации
+	return &ChatService{rooms: rooms, users: users}
+}
+
+func TestAuthorizeUser(t *testing.T) {
+	authenticated := auth.WithPrincipal(context.Background(), auth.Principal{UserID: "alice-id"})
+	tests := []struct {
+		name    string
+		ctx     context.Context
+		userID  string
+		allowed bool
+	}{
+		{"own id", authenticated, "alice-id", true},
+		{"id omitted", authenticated, "", true},
+		{"another user", authenticated, "bob-id", false},
+		{"no principal", context.Background(), "alice-id", false},
+		{"empty principal", auth.WithPrincipal(context.Background(), auth.Principal{}), "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := AuthorizeUser(tt.ctx, tt.userID)
+			checkAllowed(t, err, tt.allowed)
+		})
+	}
+}
+
+func TestAuthorizeMember(t *testing.T) {
+	s := newAuthorizationService()
+	tests := []struct {
+		name    string
+		roomID  string
+		userID  string
+		allowed bool
+	}{
+		{"member", "room", "alice-id", true},
+		{"invitee before acceptance", "room", "carol-id", false},
+		{"stranger", "room", "eve-id", false},
+		{"other room", "other", "alice-id", false},
+		{"room omitted", "", "alice-id", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := s.authorizeMember(context.Background(), tt.roomID, tt.userID)
+			checkAllowed(t, err, tt.allowed)
+		})
+	}
+}
+
+func TestAuthorizePeer(t *testing.T) {
+	s := newAuthorizationService()
+	tests := []struct {
+		name            string
+		sender          string
+		receiver        string
+		allowed         bool
+		allowedInvitees bool
+	}{
+		{"other member", "alice-id", "bob-id", true, true},
+		{"receiver is the sender", "alice-id", "alice-id", false, false},
+		{"invitee of the sender", "alice-id", "carol-id", false, true},
+		{"invitee of another member", "alice-id", "dave-id", false, false},
+		{"stranger", "alice-id", "eve-id", false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := s.authorizePeer(context.Background(), "room", tt.sender, tt.receiver)
+			checkAllowed(t, err, tt.allowed)
+			// Уведомление об удалении комнаты доходит и до приглашённого отправителем
+			err = s.authorizePeerOrInvitee(context.Background(), "room", tt.sender, tt.receiver)
+			checkAllowed(t, err, tt.allowedInvitees)
+		})
+	}
+}
+
+func TestSendMessageRejectsOutsiders(t *testing.T) {
+	s := newAuthorizationService()
+	tests := []struct {
+		name     string
+		sender   string
+		receiver string
+		room     string
+	}{
+		{"non-member sender", "eve-id", "bob", "room"},
+		{"invitee sends before acceptance", "carol-id", "alice", "room"},
+		{"receiver is not a member", "alice-id", "eve", "room"},
+		{"receiver is an invitee", "alice-id", "carol", "room"},
+		{"receiver is the sender", "alice-id", "alice", "room"},
+		{"room omitted", "alice-id", "bob", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			message := &domain.ChatMessage{SenderID: tt.sender, ReceiverName: tt.receiver, ChatID: tt.room}
+			if err := s.SendMessage(context.Background(), message); !errors.Is(err, myErrors.ErrPermissionDenied) {
+				t.Fatalf("got %v, want ErrPermissionDenied", err)
+			}
+		})
+	}
+}
+
+func checkAllowed(t *testing.T, err error, allowed bool) {
+	t.Helper()
+	if allowed {
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return
+	}
+	if !errors.Is(err, myErrors.ErrPermissionDenied) {
+		t.Fatalf("got %v, want ErrPermissionDenied", err)
+	}
+}
@@ -1,27 +1,70 @@
 package service
 
 import (
+	dh "CryptoMessenger/algorithm/diffie_hellman"
+	"CryptoMessenger/algorithm/ecdh"
+	_ "CryptoMessenger/algorithm/rc5"
+	_ "CryptoMessenger/algorithm/rc6"
+	"CryptoMessenger/algorithm/symmetric"
+	"CryptoMessenger/internal/auth"
 	"CryptoMessenger/internal/domain"
+	myErrors "CryptoMessenger/internal/errors"
 	natsjs "CryptoMessenger/internal/infrastructure/nats"
 	"CryptoMessenger/internal/repository"
 	"context"
+	"crypto/ed25519"
+	"database/sql"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"github.com/google/uuid"
 	"log/slog"
+	"math/big"
+	"strings"
+	"time"
 )
 
+// revocationCheckInterval — как часто открытый поток событий проверяет, не отозван ли токен.
+const revocationCheckInterval = 30 * time.Second
+
+// eventStream — очередь событий пользователя (natsjs.Subscription).
+type eventStream interface {
+	Next(ctx context.Context) (domain.Event, error)
+	Close()
+}
+
 type ChatService struct {
 	rooms    repository.RoomRepo
 	keys     repository.KeyRepo
 	users    repository.UserRepo
+	tokens   repository.TokenRepo
 	jsClient *natsjs.JSClient
+
+	subscribeEvents func(userID string) (eventStream, error)
+	revocationCheck time.Duration
 }
 
-func NewChatService(repo repository.RoomRepo, keys repository.KeyRepo, users repository.UserRepo, jsClient *natsjs.JSClient) *ChatService {
-	return &ChatService{rooms: repo, keys: keys, users: users, jsClient: jsClient}
+func NewChatService(repo repository.RoomRepo, keys repository.KeyRepo, users repository.UserRepo,
+	tokens repository.TokenRepo, jsClient *natsjs.JSClient) *ChatService {
+	return &ChatService{
+		rooms:    repo,
+		keys:     keys,
+		users:    users,
+		tokens:   tokens,
+		jsClient: jsClient,
+		subscribeEvents: func(userID string) (eventStream, error) {
+			return jsClient.SubscribeEvents(userID)
+		},
+		revocationCheck: revocationCheckInterval,
+	}
 }
 
 func (s *ChatService) CreateRoom(ctx context.Context, cfg domain.RoomConfig) (string, error) {
+	params := symmetric.RoomParams(cfg.WordSize, cfg.Rounds)
+	if err := symmetric.ValidateSuite(cfg.Algorithm, cfg.Mode, cfg.Padding, params, cfg.KeyLength); err != nil {
+		return "", fmt.Errorf("%w: %v", myErrors.ErrInvalidRoom, err)
+	}
+
 	cfg.RoomID = uuid.New().String()
 
 	if err := s.rooms.Create(ctx, cfg); err != nil {
@@ -35,6 +78,17 @@ func (s *ChatService) InviteUser(ctx context.Context, invitation domain.ChatInvi
 	var err error
 	slog.Info(invitation.RoomID)
 
+	if err = validateInvitationKey(invitation); err != nil {
+		return "", err
+	}
+	if len(invitation.Signature) != ed25519.SignatureSize {
+		return "", fmt.Errorf("%w: missing identity signature", myErrors.ErrInvalidInvitation)
+	}
+	// Первое сообщение можно зашифровать только ключом из предключей получателя
+	if invitation.FirstMessage != nil && (invitation.SignedPrekeyID == "" || invitation.FirstMessage.ChatID != invitation.RoomID) {
+		return "", fmt.Errorf("%w: unexpected first message", myErrors.ErrInvalidInvitation)
+	}
+
 	sender, err := s.users.GetByID(ctx, invitation.SenderID)
 	if err != nil {
 		return "", fmt.Errorf("cannot get sender: %w", err)
@@ -48,36 +102,179 @@ func (s *ChatService) InviteUser(ctx context.Context, invitation domain.ChatInvi
 	if sender.Username == receiver.Username {
 		return "", fmt.Errorf("sender and receiver cannot be the same user")
 	}
+	if err = s.authorizeMember(ctx, invitation.RoomID, sender.ID); err != nil {
+		return "", err
+	}
+	if err = s.rooms.AddInvitation(ctx, invitation.RoomID, receiver.ID, sender.ID); err != nil {
+		return "", fmt.Errorf("cannot store invitation: %w", err)
+	}
 
 	messageID := uuid.New().String()
 	invitation.SenderName = sender.Username
 	invitation.ReceiverID = receiver.ID
 	invitation.MessageID = messageID
+	if msg := invitation.FirstMessage; msg != nil {
+		msg.SenderID = sender.ID
+		msg.SenderName = sender.Username
+		msg.ReceiverID = receiver.ID
+		msg.ReceiverName = receiver.Username
+	}
+
+	if err = s.SendPublicKey(ctx, invitation.RoomID, sender.ID, invitation.PublicKey); err != nil {
+		return "", err
+	}
 
 	if err = s.jsClient.PublishInvitation(ctx, invitation); err != nil {
 		return "", fmt.Errorf("failed to publish invitation: %w", err)
 	}
 
-	if err = s.jsClient.EnsureMessagesConsumer(invitation.SenderID, messageID); err != nil {
-		return "", fmt.Errorf("failed to ensure messages: %w", err)
+	return messageID, nil
+}
+
+// validateInvitationKey проверяет схему согласования, группу DH и открытый ключ
+// приглашения до того, как оно попадёт к получателю.
+func validateInvitationKey(invitation domain.ChatInvitation) error {
+	if _, _, err := validateAgreementKey(invitation.KeyAgreement, invitation.DHGroup, invitation.PublicKey); err != nil {
+		return fmt.Errorf("%w: %v", myErrors.ErrInvalidInvitation, err)
 	}
+	return nil
+}
 
-	return messageID, nil
+// validateAgreementKey проверяет открытый ключ выбранной схемы и возвращает
+// канонические имена схемы и группы (для ECDH группа пустая).
+func validateAgreementKey(keyAgreement, dhGroup, publicKeyHex string) (string, string, error) {
+	if keyAgreement != "" && !strings.EqualFold(keyAgreement, domain.KeyAgreementDH) {
+		scheme, err := ecdh.LookupScheme(keyAgreement)
+		if err != nil {
+			return "", "", err
+		}
+		publicKey, err := hex.DecodeString(publicKeyHex)
+		if err != nil {
+			return "", "", fmt.Errorf("malformed public key")
+		}
+		if err = scheme.ValidatePublicKey(publicKey); err != nil {
+			return "", "", err
+		}
+		return scheme.Name, "", nil
+	}
+
+	group, err := dh.LookupGroup(dhGroup)
+	if err != nil {
+		return "", "", err
+	}
+	publicKey, ok := new(big.Int).SetString(publicKeyHex, 16)
+	if !ok {
+		return "", "", fmt.Errorf("malformed public key")
+	}
+	if err = group.ValidatePublicKey(publicKey); err != nil {
+		return "", "", err
+	}
+	return domain.KeyAgreementDH, group.Name, nil
 }
 
-func (s *ChatService) AckEvent(messageID string) error {
-	return s.jsClient.AckEvent(messageID)
+// AckEvent подтверждает событие вызывающего пользователя.
+func (s *ChatService) AckEvent(ctx context.Context, messageID string) error {
+	principal, ok := auth.PrincipalFromContext(ctx)
+	if !ok {
+		return fmt.Errorf("%w: unauthenticated call", myErrors.ErrPermissionDenied)
+	}
+	return s.jsClient.AckEvent(principal.UserID, messageID)
 }
 
-func (s *ChatService) ReceiveInvitation(ctx context.Context, userID string) (domain.ChatInvitation, error) {
-	return s.jsClient.FetchOneInvitation(ctx, userID)
+// Subscribe передаёт в send события пользователя, пока не закончится ctx или не
+// оборвётся отправка. События с номером не больше lastAcked клиент уже обработал, но
+// подтверждение до сервера не дошло: они подтверждаются здесь и повторно не отправляются.
+// Сообщения комнат, из которых пользователь вышел, отбрасываются.
+// Поток живёт не дольше токена доступа, с которым открыт, и закрывается с
+// ErrUnauthorized, когда токен истёк или отозван: клиент переподписывается с новым.
+func (s *ChatService) Subscribe(ctx context.Context, userID string, lastAcked uint64, send func(domain.Event) error) error {
+	if err := AuthorizeUser(ctx, userID); err != nil {
+		return err
+	}
+	ctx, cancel := s.tokenContext(ctx)
+	defer cancel()
+
+	sub, err := s.subscribeEvents(userID)
+	if err != nil {
+		return fmt.Errorf("cannot subscribe to events: %w", err)
+	}
+	defer sub.Close()
+
+	for {
+		event, err := sub.Next(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				if cause := context.Cause(ctx); errors.Is(cause, myErrors.ErrUnauthorized) {
+					return cause
+				}
+				return nil
+			}
+			return fmt.Errorf("cannot receive event: %w", err)
+		}
+
+		skip := event.Sequence <= lastAcked
+		if !skip && event.Message != nil {
+			if err = s.authorizeMember(ctx, event.Message.ChatID, userID); err != nil {
+				if !errors.Is(err, myErrors.ErrPermissionDenied) {
+					return err
+				}
+				slog.Info("dropping message for a room the user has left", "user", userID, "room", event.Message.ChatID)
+				skip = true
+			}
+		}
+		if skip {
+			if err = s.jsClient.AckEvent(userID, event.MessageID); err != nil {
+				return fmt.Errorf("cannot ack event: %w", err)
+			}
+			continue
+		}
+
+		if err = send(event); err != nil {
+			return err
+		}
+	}
 }
 
-func (s *ChatService) ReceiveInvitationReaction(ctx context.Context, userID string) (domain.InvitationReaction, error) {
-	return s.jsClient.FetchOneInvitationReaction(ctx, userID)
+// tokenContext ограничивает ctx сроком токена доступа вызывающего и отменяет его,
+// как только токен попадёт в список отозванных. Причина отмены — ErrUnauthorized.
+func (s *ChatService) tokenContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	principal, _ := auth.PrincipalFromContext(ctx)
+	ctx, revoke := context.WithCancelCause(ctx)
+	ctx, cancel := context.WithDeadlineCause(ctx, principal.TokenExpiresAt,
+		fmt.Errorf("%w: access token expired", myErrors.ErrUnauthorized))
+
+	go func() {
+		ticker := time.NewTicker(s.revocationCheck)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			revoked, err := s.tokens.IsAccessTokenRevoked(ctx, principal.TokenID)
+			if err != nil {
+				slog.Warn("cannot check access token revocation", "user", principal.UserID, "error", err)
+				continue
+			}
+			if revoked {
+				revoke(fmt.Errorf("%w: access token is revoked", myErrors.ErrUnauthorized))
+				return
+			}
+		}
+	}()
+
+	return ctx, func() {
+		cancel()
+		revoke(nil)
+	}
 }
 
 func (s *ChatService) ReactToInvitation(ctx context.Context, reaction domain.InvitationReaction) error {
+	if len(reaction.Signature) != ed25519.SignatureSize {
+		return fmt.Errorf("%w: missing identity signature", myErrors.ErrInvalidInvitation)
+	}
+
 	sender, err := s.users.GetByID(ctx, reaction.SenderID)
 	if err != nil {
 		return fmt.Errorf("cannot get sender: %w", err)
@@ -86,6 +283,17 @@ func (s *ChatService) ReactToInvitation(ctx context.Context, reaction domain.Inv
 	if err != nil {
 		return fmt.Errorf("user doesnt't exist: %w", err)
 	}
+	// Ответить можно только на своё приглашение и только пригласившему
+	invitedBy, err := s.rooms.TakeInvitation(ctx, reaction.RoomID, sender.ID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("%w: no pending invitation to room %s", myErrors.ErrPermissionDenied, reaction.RoomID)
+	}
+	if err != nil {
+		return fmt.Errorf("cannot take invitation: %w", err)
+	}
+	if invitedBy != receiver.ID {
+		return fmt.Errorf("%w: invitation to room %s was sent by another user", myErrors.ErrPermissionDenied, reaction.RoomID)
+	}
 	messageID := uuid.New().String()
 
 	slog.Info("roomID", reaction.RoomID)
@@ -95,16 +303,24 @@ func (s *ChatService) ReactToInvitation(ctx context.Context, reaction domain.Inv
 	reaction.ReceiverName = receiver.Username
 	reaction.ReceiverID = receiver.ID
 
-	if err = s.jsClient.PublishInvitationReaction(ctx, reaction); err != nil {
-		return fmt.Errorf("failed to publish invitation: %w", err)
+	// Приглашённый становится участником до того, как создатель узнает о согласии
+	if reaction.Accepted {
+		if err = s.rooms.AddMember(ctx, reaction.RoomID, sender.ID); err != nil {
+			return fmt.Errorf("cannot add room member: %w", err)
+		}
 	}
 
-	if reaction.Accepted {
-		if err = s.jsClient.EnsureMessagesConsumer(reaction.SenderID, reaction.RoomID); err != nil {
-			return fmt.Errorf("failed to ensure messages: %w", err)
+	// В комнате из предключей ключ согласован заранее, и ответ его не содержит
+	if reaction.Accepted && reaction.PublicKey != "" {
+		if err = s.SendPublicKey(ctx, reaction.RoomID, sender.ID, reaction.PublicKey); err != nil {
+			return err
 		}
 	}
 
+	if err = s.jsClient.PublishInvitationReaction(ctx, reaction); err != nil {
+		return fmt.Errorf("failed to publish invitation: %w", err)
+	}
+
 	return nil
 }
 
@@ -114,10 +330,17 @@ func (s *ChatService) SendMessage(ctx context.Context, message *domain.ChatMessa
 		return fmt.Errorf("cannot get sender: %w", err)
 	}
 
+	if err = s.authorizeMember(ctx, message.ChatID, sender.ID); err != nil {
+		return err
+	}
+
 	receiver, err := s.users.GetByUsername(ctx, message.ReceiverName)
 	if err != nil {
 		return fmt.Errorf("user doesnt't exist: %w", err)
 	}
+	if err = s.authorizePeer(ctx, message.ChatID, sender.ID, receiver.ID); err != nil {
+		return err
+	}
 
 	message.ReceiverID = receiver.ID
 	message.SenderName = sender.Username
@@ -128,43 +351,110 @@ func (s *ChatService) SendMessage(ctx context.Context, message *domain.ChatMessa
 	return nil
 }
 
-func (s *ChatService) ReceiveMessage(ctx context.Context, userID, chatID string) (domain.ChatMessage, error) {
-	msg, err := s.jsClient.FetchOneChatMessage(ctx, userID, chatID)
-	if err != nil {
-		return domain.ChatMessage{}, fmt.Errorf("failed to fetch chat message: %w", err)
+func (s *ChatService) CloseRoom(ctx context.Context, roomID, clientID string) error {
+	if err := s.authorizeMember(ctx, roomID, clientID); err != nil {
+		return err
 	}
-	return msg, nil
-}
-
-func (s *ChatService) CloseRoom(ctx context.Context, roomID string) error {
-	if err := s.rooms.Delete(ctx, roomID); err != nil {
+	err := s.rooms.Delete(ctx, roomID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return myErrors.ErrRoomNotFound
+	}
+	if err != nil {
 		return fmt.Errorf("cannot close room: %w", err)
 	}
 	return nil
 }
 
+// ClearChatHistory просит собеседника очистить историю комнаты; оба должны в ней участвовать.
 func (s *ChatService) ClearChatHistory(ctx context.Context, action domain.ChatActions) error {
+	if err := s.authorizeMember(ctx, action.ID, action.SenderID); err != nil {
+		return err
+	}
 	user, err := s.users.GetByUsername(ctx, action.UserName)
 	if err != nil {
 		return fmt.Errorf("user doesnt't exist: %w", err)
 	}
+	if err = s.authorizeMember(ctx, action.ID, user.ID); err != nil {
+		return err
+	}
 	action.UserID = user.ID
 	return s.jsClient.PublishClearChatHistoryRequest(ctx, action)
 }
 
-func (s *ChatService) ReceiveClearChatHistoryRequest(ctx context.Context, userID string) (domain.ChatActions, error) {
-	return s.jsClient.FetchClearChatHistoryRequest(ctx, userID)
-}
-
+// UpdateOrDeleteCipherKey пересылает собеседнику предложение сменить ключ комнаты,
+// ответ на него или, при пустом открытом ключе, уведомление об удалении комнаты.
+// Сервер только проверяет наличие подписи: сверяет её клиент получателя.
 func (s *ChatService) UpdateOrDeleteCipherKey(ctx context.Context, action domain.ChatActions) error {
-	return nil
+	if len(action.Signature) != ed25519.SignatureSize {
+		return fmt.Errorf("%w: missing identity signature", myErrors.ErrInvalidInvitation)
+	}
+	if action.PublicKey != "" && action.Epoch <= 0 {
+		return fmt.Errorf("%w: invalid key epoch %d", myErrors.ErrInvalidInvitation, action.Epoch)
+	}
+
+	sender, err := s.users.GetByID(ctx, action.SenderID)
+	if err != nil {
+		return fmt.Errorf("cannot get sender: %w", err)
+	}
+	receiver, err := s.users.GetByUsername(ctx, action.UserName)
+	if err != nil {
+		return fmt.Errorf("user doesnt't exist: %w", err)
+	}
+	if err = s.authorizeMember(ctx, action.ID, sender.ID); err != nil {
+		return err
+	}
+	// Об удалении комнаты можно сообщить и тому, кто ещё не ответил на приглашение
+	if action.PublicKey != "" {
+		err = s.authorizePeer(ctx, action.ID, sender.ID, receiver.ID)
+	} else {
+		err = s.authorizePeerOrInvitee(ctx, action.ID, sender.ID, receiver.ID)
+	}
+	if err != nil {
+		return err
+	}
+
+	action.SenderName = sender.Username
+	action.UserID = receiver.ID
+	if action.MessageID == "" {
+		action.MessageID = uuid.New().String()
+	}
+	return s.jsClient.PublishCipherKeyUpdate(ctx, action)
 }
 
+// JoinRoom принимает приглашение в комнату без ответа пригласившему.
 func (s *ChatService) JoinRoom(ctx context.Context, roomID, clientID string) error {
+	_, err := s.rooms.TakeInvitation(ctx, roomID, clientID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("%w: no pending invitation to room %s", myErrors.ErrPermissionDenied, roomID)
+	}
+	if err != nil {
+		return fmt.Errorf("cannot take invitation: %w", err)
+	}
+	if err = s.rooms.AddMember(ctx, roomID, clientID); err != nil {
+		return fmt.Errorf("cannot join room: %w", err)
+	}
 	return nil
 }
 
+// LeaveRoom удаляет участника, а комнату, из которой вышли все, — целиком.
 func (s *ChatService) LeaveRoom(ctx context.Context, roomID, clientID string) error {
+	err := s.rooms.RemoveMember(ctx, roomID, clientID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return myErrors.ErrRoomNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("cannot leave room: %w", err)
+	}
+
+	members, err := s.rooms.ListMembers(ctx, roomID)
+	if err != nil {
+		return fmt.Errorf("cannot list room members: %w", err)
+	}
+	if len(members) == 0 {
+		if err = s.rooms.Delete(ctx, roomID); err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("cannot delete empty room: %w", err)
+		}
+	}
 	return nil
 }
 
@@ -174,13 +464,30 @@ func (s *ChatService) SendInvitation(ctx context.Context, invite domain.ChatInvi
 }
 
 func (s *ChatService) SendPublicKey(ctx context.Context, roomID, clientID, pubHex string) error {
+	if err := s.keys.Store(ctx, domain.PublicKey{RoomID: roomID, ClientID: clientID, PublicKeyHex: pubHex}); err != nil {
+		return fmt.Errorf("cannot store room key: %w", err)
+	}
 	return nil
 }
 
 func (s *ChatService) GetPublicKeys(ctx context.Context, roomID string) ([]domain.PublicKey, error) {
-	return nil, nil
+	keys, err := s.keys.ListByRoom(ctx, roomID)
+	if err != nil {
+		return nil, fmt.Errorf("cannot list room keys: %w", err)
+	}
+	return keys, nil
 }
 
-func (s *ChatService) GetRoomConfig(ctx context.Context, roomID string) (domain.RoomConfig, error) {
-	return domain.RoomConfig{}, nil
+func (s *ChatService) GetRoomConfig(ctx context.Context, roomID, clientID string) (domain.RoomConfig, error) {
+	if err := s.authorizeMember(ctx, roomID, clientID); err != nil {
+		return domain.RoomConfig{}, err
+	}
+	cfg, err := s.rooms.Get(ctx, roomID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return domain.RoomConfig{}, myErrors.ErrRoomNotFound
+	}
+	if err != nil {
+		return domain.RoomConfig{}, fmt.Errorf("cannot get room: %w", err)
+	}
+	return cfg, nil
 }
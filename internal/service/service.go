@@ -1,35 +1,42 @@
 package service
 
 import (
+	"CryptoMessenger/internal/auth"
 	"CryptoMessenger/internal/domain"
 	natsjs "CryptoMessenger/internal/infrastructure/nats"
 	"CryptoMessenger/internal/repository"
 	"context"
+	"time"
 )
 
 type Auth interface {
 	Register(ctx context.Context, username, password string) (string, error)
 	Login(ctx context.Context, username, password string) (string, error)
+	IssueTokens(ctx context.Context, userID string) (domain.TokenPair, error)
+	Refresh(ctx context.Context, refreshToken string) (domain.TokenPair, error)
+	Logout(ctx context.Context, refreshToken string) error
+	VerifyAccessToken(ctx context.Context, token string) (*auth.Claims, error)
+	PublishIdentityKey(ctx context.Context, userID string, publicKey []byte) error
+	GetIdentityKey(ctx context.Context, username string) (domain.IdentityKey, error)
+	UploadPrekeys(ctx context.Context, userID string, prekeys []domain.Prekey) ([]domain.PrekeyCount, error)
+	GetPrekeyBundle(ctx context.Context, username, keyAgreement, dhGroup string) (domain.PrekeyBundle, error)
 }
 
 type Chat interface {
 	CreateRoom(ctx context.Context, cfg domain.RoomConfig) (string, error)
-	CloseRoom(ctx context.Context, roomID string) error
+	CloseRoom(ctx context.Context, roomID, clientID string) error
 	JoinRoom(ctx context.Context, roomID, clientID string) error
 	LeaveRoom(ctx context.Context, roomID, clientID string) error
 	SendPublicKey(ctx context.Context, roomID, clientID, pubHex string) error
 	GetPublicKeys(ctx context.Context, roomID string) ([]domain.PublicKey, error)
 	SendMessage(ctx context.Context, msg *domain.ChatMessage) error
-	ReceiveMessage(ctx context.Context, roomID, clientID string) (domain.ChatMessage, error)
-	GetRoomConfig(ctx context.Context, roomID string) (domain.RoomConfig, error)
+	GetRoomConfig(ctx context.Context, roomID, clientID string) (domain.RoomConfig, error)
 	SendInvitation(ctx context.Context, invite domain.ChatInvitation) error
 	InviteUser(ctx context.Context, invitation domain.ChatInvitation) (string, error)
-	ReceiveInvitation(ctx context.Context, userID string) (domain.ChatInvitation, error)
-	ReceiveInvitationReaction(ctx context.Context, userID string) (domain.InvitationReaction, error)
 	ReactToInvitation(ctx context.Context, reaction domain.InvitationReaction) error
-	AckEvent(messageID string) error
+	Subscribe(ctx context.Context, userID string, lastAcked uint64, send func(domain.Event) error) error
+	AckEvent(ctx context.Context, messageID string) error
 	ClearChatHistory(ctx context.Context, action domain.ChatActions) error
-	ReceiveClearChatHistoryRequest(ctx context.Context, userID string) (domain.ChatActions, error)
 	UpdateOrDeleteCipherKey(ctx context.Context, action domain.ChatActions) error
 }
 
@@ -38,9 +45,10 @@ type Service struct {
 	Chat
 }
 
-func NewService(repositories *repository.Repository, jsClient *natsjs.JSClient) *Service {
+func NewService(repositories *repository.Repository, jsClient *natsjs.JSClient, signer *auth.Signer, refreshTTL time.Duration) *Service {
 	return &Service{
-		Auth: NewAuthService(repositories.UserRepo, jsClient),
-		Chat: NewChatService(repositories.RoomRepo, repositories.KeyRepo, repositories.UserRepo, jsClient),
+		Auth: NewAuthService(repositories.UserRepo, repositories.IdentityKeyRepo, repositories.KeyRepo, repositories.TokenRepo,
+			jsClient, signer, refreshTTL),
+		Chat: NewChatService(repositories.RoomRepo, repositories.KeyRepo, repositories.UserRepo, repositories.TokenRepo, jsClient),
 	}
 }
@@ -0,0 +1,88 @@
+package service
+
+import (
+	"CryptoMessenger/internal/auth"
+	"CryptoMessenger/internal/domain"
+	myErrors "CryptoMessenger/internal/errors"
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// idleStream — очередь без событий: Next ждёт, пока не отменят ctx.
+type idleStream struct{}
+
+func (idleStream) Next(ctx context.Context) (domain.Event, error) {
+	<-ctx.Done()
+	return domain.Event{}, ctx.Err()
+}
+
+func (idleStream) Close() {}
+
+func newSubscribeService(tokens *fakeTokenRepo) *ChatService {
+	return &ChatService{
+		tokens: tokens,
+		subscribeEvents: func(string) (eventStream, error) {
+			return idleStream{}, nil
+		},
+		revocationCheck: 10 * time.Millisecond,
+	}
+}
+
+// subscribe открывает поток и ждёт его закрытия.
+func subscribe(t *testing.T, s *ChatService, ctx context.Context) error {
+	t.Helper()
+	done := make(chan error, 1)
+	go func() {
+		done <- s.Subscribe(ctx, "alice-id", 0, func(domain.Event) error { return nil })
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-time.After(5 * time.Second):
+		t.Fatal("stream is still open")
+		return nil
+	}
+}
+
+func TestSubscribeEndsWhenTokenExpires(t *testing.T) {
+	s := newSubscribeService(newFakeTokenRepo())
+	ctx := auth.WithPrincipal(context.Background(), auth.Principal{
+		UserID:         "alice-id",
+		TokenID:        "token-id",
+		TokenExpiresAt: time.Now().Add(50 * time.Millisecond),
+	})
+	if err := subscribe(t, s, ctx); !errors.Is(err, myErrors.ErrUnauthorized) {
+		t.Fatalf("got %v, want ErrUnauthorized", err)
+	}
+}
+
+func TestSubscribeEndsWhenTokenIsRevoked(t *testing.T) {
+	tokens := newFakeTokenRepo()
+	s := newSubscribeService(tokens)
+	ctx := auth.WithPrincipal(context.Background(), auth.Principal{
+		UserID:         "alice-id",
+		TokenID:        "token-id",
+		TokenExpiresAt: time.Now().Add(time.Hour),
+	})
+	time.AfterFunc(50*time.Millisecond, func() {
+		_ = tokens.RevokeAccessToken(context.Background(), "token-id", time.Now().Add(time.Hour))
+	})
+	if err := subscribe(t, s, ctx); !errors.Is(err, myErrors.ErrUnauthorized) {
+		t.Fatalf("got %v, want ErrUnauthorized", err)
+	}
+}
+
+func TestSubscribeEndsWhenClientLeaves(t *testing.T) {
+	s := newSubscribeService(newFakeTokenRepo())
+	ctx, cancel := context.WithTimeout(auth.WithPrincipal(context.Background(), auth.Principal{
+		UserID:         "alice-id",
+		TokenID:        "token-id",
+		TokenExpiresAt: time.Now().Add(time.Hour),
+	}), 50*time.Millisecond)
+	defer cancel()
+	if err := subscribe(t, s, ctx); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+}
@@ -0,0 +1,139 @@
+package service
+
+import (
+	"CryptoMessenger/internal/auth"
+	"CryptoMessenger/internal/domain"
+	myErrors "CryptoMessenger/internal/errors"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"log/slog"
+	"time"
+)
+
+// Токены. Токен доступа (JWT) живёт недолго и проверяется без обращения к базе,
+// кроме списка отозванных. Токен обновления — случайная строка; при каждом обновлении
+// он заменяется новым. Повторное использование уже заменённого токена означает,
+// что его украли, и тогда отзывается всё семейство, выпущенное от того же входа.
+
+const refreshTokenBytes = 32
+
+// IssueTokens выдаёт пару токенов после входа или регистрации; так начинается новое семейство.
+func (s *AuthService) IssueTokens(ctx context.Context, userID string) (domain.TokenPair, error) {
+	return s.issueTokens(ctx, userID, uuid.New().String())
+}
+
+func (s *AuthService) issueTokens(ctx context.Context, userID, familyID string) (domain.TokenPair, error) {
+	access, claims, err := s.signer.GenerateToken(userID)
+	if err != nil {
+		return domain.TokenPair{}, fmt.Errorf("error signing access token: %w", err)
+	}
+
+	raw := make([]byte, refreshTokenBytes)
+	if _, err = rand.Read(raw); err != nil {
+		return domain.TokenPair{}, fmt.Errorf("error generating refresh token: %w", err)
+	}
+	refresh := base64.RawURLEncoding.EncodeToString(raw)
+
+	err = s.tokens.CreateRefreshToken(ctx, domain.RefreshToken{
+		Hash:      hashRefreshToken(refresh),
+		UserID:    userID,
+		FamilyID:  familyID,
+		ExpiresAt: time.Now().Add(s.refreshTTL),
+	})
+	if err != nil {
+		return domain.TokenPair{}, err
+	}
+
+	return domain.TokenPair{
+		AccessToken:     access,
+		AccessExpiresAt: claims.ExpiresAt.Time,
+		RefreshToken:    refresh,
+	}, nil
+}
+
+// Refresh меняет токен обновления на новую пару токенов.
+func (s *AuthService) Refresh(ctx context.Context, refreshToken string) (domain.TokenPair, error) {
+	hash := hashRefreshToken(refreshToken)
+	token, err := s.tokens.FindRefreshToken(ctx, hash)
+	if errors.Is(err, sql.ErrNoRows) {
+		return domain.TokenPair{}, myErrors.ErrInvalidRefreshToken
+	}
+	if err != nil {
+		return domain.TokenPair{}, err
+	}
+	if time.Now().After(token.ExpiresAt) {
+		return domain.TokenPair{}, fmt.Errorf("%w: expired", myErrors.ErrInvalidRefreshToken)
+	}
+
+	fresh := !token.Used
+	if fresh {
+		if fresh, err = s.tokens.MarkRefreshTokenUsed(ctx, hash); err != nil {
+			return domain.TokenPair{}, err
+		}
+	}
+	if !fresh {
+		slog.Warn("refresh token reuse, revoking token family", "user", token.UserID, "family", token.FamilyID)
+		if err = s.tokens.RevokeFamily(ctx, token.FamilyID); err != nil {
+			return domain.TokenPair{}, err
+		}
+		return domain.TokenPair{}, fmt.Errorf("%w: already used", myErrors.ErrInvalidRefreshToken)
+	}
+
+	return s.issueTokens(ctx, token.UserID, token.FamilyID)
+}
+
+// Logout отзывает токен доступа, с которым сделан вызов, и семейство переданного токена обновления.
+func (s *AuthService) Logout(ctx context.Context, refreshToken string) error {
+	principal, ok := auth.PrincipalFromContext(ctx)
+	if !ok {
+		return fmt.Errorf("%w: unauthenticated call", myErrors.ErrPermissionDenied)
+	}
+	if principal.TokenID != "" {
+		if err := s.tokens.RevokeAccessToken(ctx, principal.TokenID, principal.TokenExpiresAt); err != nil {
+			return err
+		}
+	}
+
+	if refreshToken == "" {
+		return nil
+	}
+	token, err := s.tokens.FindRefreshToken(ctx, hashRefreshToken(refreshToken))
+	if errors.Is(err, sql.ErrNoRows) {
+		// Семейство уже отозвано или токен истёк и удалён
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if token.UserID != principal.UserID {
+		return fmt.Errorf("%w: refresh token of another user", myErrors.ErrPermissionDenied)
+	}
+	return s.tokens.RevokeFamily(ctx, token.FamilyID)
+}
+
+// VerifyAccessToken проверяет подпись и срок токена и что он не отозван.
+func (s *AuthService) VerifyAccessToken(ctx context.Context, token string) (*auth.Claims, error) {
+	claims, err := s.signer.ParseToken(token)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", myErrors.ErrUnauthorized, err)
+	}
+	revoked, err := s.tokens.IsAccessTokenRevoked(ctx, claims.ID)
+	if err != nil {
+		return nil, err
+	}
+	if revoked {
+		return nil, fmt.Errorf("%w: token is revoked", myErrors.ErrUnauthorized)
+	}
+	return claims, nil
+}
+
+func hashRefreshToken(token string) []byte {
+	sum := sha256.Sum256([]byte(token))
+	return sum[:]
+}
@@ -0,0 +1,213 @@
+package service
+
+import (
+	"CryptoMessenger/internal/auth"
+	"CryptoMessenger/internal/domain"
+	myErrors "CryptoMessenger/internal/errors"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeTokenRepo — TokenRepo в памяти.
+type fakeTokenRepo struct {
+	mu      sync.Mutex
+	refresh map[string]domain.RefreshToken
+	revoked map[string]time.Time
+}
+
+func newFakeTokenRepo() *fakeTokenRepo {
+	return &fakeTokenRepo{refresh: make(map[string]domain.RefreshToken), revoked: make(map[string]time.Time)}
+}
+
+func (r *fakeTokenRepo) CreateRefreshToken(_ context.Context, token domain.RefreshToken) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.refresh[string(token.Hash)] = token
+	return nil
+}
+
+func (r *fakeTokenRepo) FindRefreshToken(_ context.Context, hash []byte) (domain.RefreshToken, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	token, ok := r.refresh[string(hash)]
+	if !ok {
+		return domain.RefreshToken{}, fmt.Errorf("error getting refresh token: %w", sql.ErrNoRows)
+	}
+	return token, nil
+}
+
+func (r *fakeTokenRepo) MarkRefreshTokenUsed(_ context.Context, hash []byte) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	token, ok := r.refresh[string(hash)]
+	if !ok || token.Used {
+		return false, nil
+	}
+	token.Used = true
+	r.refresh[string(hash)] = token
+	return true, nil
+}
+
+func (r *fakeTokenRepo) RevokeFamily(_ context.Context, familyID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for hash, token := range r.refresh {
+		if token.FamilyID == familyID {
+			delete(r.refresh, hash)
+		}
+	}
+	return nil
+}
+
+func (r *fakeTokenRepo) RevokeAccessToken(_ context.Context, tokenID string, expiresAt time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.revoked[tokenID] = expiresAt
+	return nil
+}
+
+func (r *fakeTokenRepo) IsAccessTokenRevoked(_ context.Context, tokenID string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_, ok := r.revoked[tokenID]
+	return ok, nil
+}
+
+func newTokenService(t *testing.T, refreshTTL time.Duration) *AuthService {
+	t.Helper()
+	signer, err := auth.NewSigner(map[string]string{"k1": "0123456789abcdef0123456789abcdef"}, "k1", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &AuthService{tokens: newFakeTokenRepo(), signer: signer, refreshTTL: refreshTTL}
+}
+
+// principalContext — контекст вызова, сделанного с токеном доступа token.
+func principalContext(t *testing.T, s *AuthService, token string) context.Context {
+	t.Helper()
+	claims, err := s.VerifyAccessToken(context.Background(), token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return auth.WithPrincipal(context.Background(), auth.Principal{
+		UserID:         claims.ClientID,
+		TokenID:        claims.ID,
+		TokenExpiresAt: claims.ExpiresAt.Time,
+	})
+}
+
+func TestRefreshRotatesToken(t *testing.T) {
+	s := newTokenService(t, time.Hour)
+	ctx := context.Background()
+
+	first, err := s.IssueTokens(ctx, "alice-id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := s.Refresh(ctx, first.RefreshToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second.RefreshToken == first.RefreshToken || second.AccessToken == first.AccessToken {
+		t.Fatal("refresh returned the same tokens")
+	}
+	if _, err = s.VerifyAccessToken(ctx, second.AccessToken); err != nil {
+		t.Fatal(err)
+	}
+
+	// Старый токен обновления больше не принимается
+	if _, err = s.Refresh(ctx, first.RefreshToken); !errors.Is(err, myErrors.ErrInvalidRefreshToken) {
+		t.Fatalf("reused refresh token: got %v, want ErrInvalidRefreshToken", err)
+	}
+	// Повторное использование отзывает всё семейство, в том числе выданный взамен токен
+	if _, err = s.Refresh(ctx, second.RefreshToken); !errors.Is(err, myErrors.ErrInvalidRefreshToken) {
+		t.Fatalf("refresh token of a revoked family: got %v, want ErrInvalidRefreshToken", err)
+	}
+
+	// Другие входы того же пользователя не затронуты
+	other, err := s.IssueTokens(ctx, "alice-id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = s.Refresh(ctx, other.RefreshToken); err != nil {
+		t.Fatalf("other family: %v", err)
+	}
+}
+
+func TestRefreshRejectsUnknownAndExpired(t *testing.T) {
+	ctx := context.Background()
+	s := newTokenService(t, time.Hour)
+	if _, err := s.Refresh(ctx, "unknown"); !errors.Is(err, myErrors.ErrInvalidRefreshToken) {
+		t.Errorf("unknown token: got %v, want ErrInvalidRefreshToken", err)
+	}
+
+	s = newTokenService(t, -time.Minute)
+	expired, err := s.IssueTokens(ctx, "alice-id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = s.Refresh(ctx, expired.RefreshToken); !errors.Is(err, myErrors.ErrInvalidRefreshToken) {
+		t.Errorf("expired token: got %v, want ErrInvalidRefreshToken", err)
+	}
+}
+
+func TestLogoutRevokesTokens(t *testing.T) {
+	s := newTokenService(t, time.Hour)
+	ctx := context.Background()
+
+	pair, err := s.IssueTokens(ctx, "alice-id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	untouched, err := s.IssueTokens(ctx, "alice-id")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = s.Logout(principalContext(t, s, pair.AccessToken), pair.RefreshToken); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = s.VerifyAccessToken(ctx, pair.AccessToken); !errors.Is(err, myErrors.ErrUnauthorized) {
+		t.Errorf("revoked access token: got %v, want ErrUnauthorized", err)
+	}
+	if _, err = s.Refresh(ctx, pair.RefreshToken); !errors.Is(err, myErrors.ErrInvalidRefreshToken) {
+		t.Errorf("refresh after logout: got %v, want ErrInvalidRefreshToken", err)
+	}
+
+	// Токены другого входа продолжают работать
+	if _, err = s.VerifyAccessToken(ctx, untouched.AccessToken); err != nil {
+		t.Errorf("other access token: %v", err)
+	}
+	if _, err = s.Refresh(ctx, untouched.RefreshToken); err != nil {
+		t.Errorf("other refresh token: %v", err)
+	}
+}
+
+func TestLogoutRejectsForeignRefreshToken(t *testing.T) {
+	s := newTokenService(t, time.Hour)
+	ctx := context.Background()
+
+	alice, err := s.IssueTokens(ctx, "alice-id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bob, err := s.IssueTokens(ctx, "bob-id")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = s.Logout(principalContext(t, s, alice.AccessToken), bob.RefreshToken); !errors.Is(err, myErrors.ErrPermissionDenied) {
+		t.Fatalf("got %v, want ErrPermissionDenied", err)
+	}
+	if _, err = s.Refresh(ctx, bob.RefreshToken); err != nil {
+		t.Fatalf("bob's refresh token must survive: %v", err)
+	}
+	if err = s.Logout(ctx, ""); !errors.Is(err, myErrors.ErrPermissionDenied) {
+		t.Fatalf("unauthenticated logout: got %v, want ErrPermissionDenied", err)
+	}
+}
@@ -1,15 +1,15 @@
 package grpc
 
 import (
-	"CryptoMessenger/internal/auth"
 	"CryptoMessenger/internal/domain"
 	myErrors "CryptoMessenger/internal/errors"
+	natsjs "CryptoMessenger/internal/infrastructure/nats"
 	"CryptoMessenger/internal/service"
 	pb "CryptoMessenger/proto/chatpb"
 	"context"
 	"database/sql"
 	"errors"
-	"github.com/nats-io/nats.go"
+	"github.com/google/uuid"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 	"google.golang.org/protobuf/types/known/emptypb"
@@ -38,15 +38,17 @@ func (h *ChatHandler) Register(ctx context.Context, req *pb.RegisterRequest) (*p
 		return nil, status.Error(codes.Internal, err.Error())
 	}
 
-	token, err := auth.GenerateToken(userID)
+	tokens, err := h.services.IssueTokens(ctx, userID)
 	if err != nil {
 		return nil, status.Error(codes.Internal, err.Error())
 	}
 	slog.Info("Register response sent")
 
 	return &pb.RegisterResponse{
-		Token:  token,
-		UserID: userID,
+		Token:        tokens.AccessToken,
+		UserID:       userID,
+		RefreshToken: tokens.RefreshToken,
+		ExpiresAt:    timestamppb.New(tokens.AccessExpiresAt),
 	}, nil
 }
 
@@ -63,30 +65,172 @@ func (h *ChatHandler) Login(ctx context.Context, req *pb.LoginRequest) (*pb.Logi
 		return nil, status.Error(codes.Internal, err.Error())
 	}
 
-	token, err := auth.GenerateToken(userID)
+	tokens, err := h.services.IssueTokens(ctx, userID)
 	if err != nil {
 		return nil, status.Error(codes.Internal, err.Error())
 	}
 
 	slog.Info("Login response sent")
 	return &pb.LoginResponse{
-		Token:  token,
-		UserID: userID,
+		Token:        tokens.AccessToken,
+		UserID:       userID,
+		RefreshToken: tokens.RefreshToken,
+		ExpiresAt:    timestamppb.New(tokens.AccessExpiresAt),
 	}, nil
 }
 
+func (h *ChatHandler) Refresh(ctx context.Context, req *pb.RefreshRequest) (*pb.RefreshResponse, error) {
+	tokens, err := h.services.Refresh(ctx, req.GetRefreshToken())
+	if err != nil {
+		if errors.Is(err, myErrors.ErrInvalidRefreshToken) {
+			return nil, status.Error(codes.Unauthenticated, err.Error())
+		}
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return &pb.RefreshResponse{
+		Token:        tokens.AccessToken,
+		RefreshToken: tokens.RefreshToken,
+		ExpiresAt:    timestamppb.New(tokens.AccessExpiresAt),
+	}, nil
+}
+
+func (h *ChatHandler) Logout(ctx context.Context, req *pb.LogoutRequest) (*emptypb.Empty, error) {
+	if err := h.services.Logout(ctx, req.GetRefreshToken()); err != nil {
+		if errors.Is(err, myErrors.ErrPermissionDenied) {
+			return nil, status.Error(codes.PermissionDenied, err.Error())
+		}
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return &emptypb.Empty{}, nil
+}
+
+func (h *ChatHandler) PublishIdentityKey(ctx context.Context, req *pb.IdentityKey) (*emptypb.Empty, error) {
+	clientID, err := GetClientID(ctx)
+	if err != nil {
+		return nil, status.Error(codes.PermissionDenied, err.Error())
+	}
+	if err = h.services.PublishIdentityKey(ctx, clientID, req.PublicKey); err != nil {
+		if errors.Is(err, myErrors.ErrInvalidIdentityKey) {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+		if errors.Is(err, myErrors.ErrIdentityKeyExists) {
+			return nil, status.Error(codes.AlreadyExists, err.Error())
+		}
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return &emptypb.Empty{}, nil
+}
+
+func (h *ChatHandler) GetIdentityKey(ctx context.Context, req *pb.IdentityKeyRequest) (*pb.IdentityKey, error) {
+	key, err := h.services.GetIdentityKey(ctx, req.Username)
+	if err != nil {
+		if errors.Is(err, myErrors.ErrIdentityKeyNotFound) {
+			return nil, status.Error(codes.NotFound, err.Error())
+		}
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return &pb.IdentityKey{
+		Username:  key.Username,
+		PublicKey: key.PublicKey,
+	}, nil
+}
+
+func (h *ChatHandler) UploadPrekeys(ctx context.Context, req *pb.UploadPrekeysRequest) (*pb.PrekeyStatus, error) {
+	clientID, err := GetClientID(ctx)
+	if err != nil {
+		return nil, status.Error(codes.PermissionDenied, err.Error())
+	}
+
+	prekeys := make([]domain.Prekey, 0, len(req.SignedPrekeys)+len(req.OneTimePrekeys))
+	for _, p := range req.SignedPrekeys {
+		prekeys = append(prekeys, prekeyFromProto(p, false))
+	}
+	for _, p := range req.OneTimePrekeys {
+		prekeys = append(prekeys, prekeyFromProto(p, true))
+	}
+
+	counts, err := h.services.UploadPrekeys(ctx, clientID, prekeys)
+	if err != nil {
+		if errors.Is(err, myErrors.ErrInvalidPrekey) {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	resp := &pb.PrekeyStatus{}
+	for _, c := range counts {
+		resp.Counts = append(resp.Counts, &pb.PrekeyCount{
+			KeyAgreement: c.KeyAgreement,
+			DhGroup:      c.DHGroup,
+			OneTimeCount: int32(c.Count),
+		})
+	}
+	return resp, nil
+}
+
+func (h *ChatHandler) GetPrekeyBundle(ctx context.Context, req *pb.PrekeyBundleRequest) (*pb.PrekeyBundle, error) {
+	bundle, err := h.services.GetPrekeyBundle(ctx, req.Username, req.KeyAgreement, req.DhGroup)
+	if err != nil {
+		if errors.Is(err, myErrors.ErrPrekeyNotFound) {
+			return nil, status.Error(codes.NotFound, err.Error())
+		}
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	resp := &pb.PrekeyBundle{
+		Username:     bundle.Username,
+		SignedPrekey: prekeyToProto(bundle.SignedPrekey),
+	}
+	if bundle.OneTimePrekey != nil {
+		resp.OneTimePrekey = prekeyToProto(*bundle.OneTimePrekey)
+	}
+	return resp, nil
+}
+
+func prekeyFromProto(p *pb.Prekey, oneTime bool) domain.Prekey {
+	return domain.Prekey{
+		KeyID:        p.KeyId,
+		KeyAgreement: p.KeyAgreement,
+		DHGroup:      p.DhGroup,
+		PublicKey:    p.PublicKey,
+		Signature:    p.Signature,
+		OneTime:      oneTime,
+	}
+}
+
+func prekeyToProto(p domain.Prekey) *pb.Prekey {
+	return &pb.Prekey{
+		KeyId:        p.KeyID,
+		KeyAgreement: p.KeyAgreement,
+		DhGroup:      p.DHGroup,
+		PublicKey:    p.PublicKey,
+		Signature:    p.Signature,
+	}
+}
+
 func (h *ChatHandler) CreateRoom(ctx context.Context, req *pb.CreateRoomRequest) (*pb.CreateRoomResponse, error) {
 	slog.Info("CreateRoom request received")
+	clientID, err := GetClientID(ctx)
+	if err != nil {
+		return &pb.CreateRoomResponse{}, status.Error(codes.PermissionDenied, err.Error())
+	}
 	roomID, err := h.services.CreateRoom(ctx, domain.RoomConfig{
 		RoomName:    req.RoomName,
+		CreatorID:   clientID,
 		Algorithm:   req.Algorithm,
 		Mode:        req.Mode,
 		Padding:     req.Padding,
 		PrimeHex:    req.Prime,
 		Iv:          req.Iv,
 		RandomDelta: req.RandomDelta,
+		WordSize:    int(req.WordSize),
+		Rounds:      int(req.Rounds),
+		KeyLength:   int(req.KeyLength),
 	})
 	if err != nil {
+		if errors.Is(err, myErrors.ErrInvalidRoom) {
+			return &pb.CreateRoomResponse{}, status.Error(codes.InvalidArgument, err.Error())
+		}
 		return &pb.CreateRoomResponse{}, status.Error(codes.Internal, err.Error())
 	}
 	slog.Info("CreateRoom response sent")
@@ -114,11 +258,27 @@ func (h *ChatHandler) InviteUser(ctx context.Context, req *pb.Invitation) (*empt
 		Mode:         req.Mode,
 		Padding:      req.Padding,
 		Iv:           req.Iv,
+		WordSize:     int(req.WordSize),
+		Rounds:       int(req.Rounds),
+		KeyLength:    int(req.KeyLength),
+		DHGroup:      req.DhGroup,
+		KeyAgreement: req.KeyAgreement,
+		Signature:    req.Signature,
+
+		DoubleRatchet: req.DoubleRatchet,
+
+		SignedPrekeyID:  req.SignedPrekeyId,
+		OneTimePrekeyID: req.OneTimePrekeyId,
+	}
+	if req.FirstMessage != nil {
+		if invitation.FirstMessage, err = chatMessageFromProto(senderID, req.FirstMessage); err != nil {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
 	}
 
 	_, err = h.services.Chat.InviteUser(ctx, invitation)
 	if err != nil {
-		return nil, status.Error(codes.Internal, err.Error())
+		return nil, chatError(err)
 	}
 
 	slog.Info("InviteUser response sent")
@@ -128,16 +288,48 @@ func (h *ChatHandler) InviteUser(ctx context.Context, req *pb.Invitation) (*empt
 
 func (h *ChatHandler) AckEvent(ctx context.Context, req *pb.AckRequest) (*emptypb.Empty, error) {
 	slog.Info("AckEvent request received")
-	if err := h.services.Chat.AckEvent(req.MessageId); err != nil {
+	if err := h.services.Chat.AckEvent(ctx, req.MessageId); err != nil {
+		if errors.Is(err, natsjs.ErrForeignEvent) || errors.Is(err, myErrors.ErrPermissionDenied) {
+			return nil, status.Error(codes.PermissionDenied, err.Error())
+		}
 		return nil, status.Error(codes.NotFound, "message not found")
 	}
 	slog.Info("AckEvent response sent")
 	return &emptypb.Empty{}, nil
 }
 
+// Subscribe держит поток событий пользователя.
+func (h *ChatHandler) Subscribe(req *pb.SubscribeRequest, stream pb.ChatService_SubscribeServer) error {
+	clientID, err := GetClientID(stream.Context())
+	if err != nil {
+		return status.Error(codes.Unauthenticated, err.Error())
+	}
+	slog.Info("Subscribe stream opened", "user", clientID, "last_acked", req.LastAckedSequence)
+
+	err = h.services.Chat.Subscribe(stream.Context(), clientID, req.LastAckedSequence, func(event domain.Event) error {
+		resp, err := eventToProto(event)
+		if err != nil {
+			return status.Error(codes.Internal, err.Error())
+		}
+		return stream.Send(resp)
+	})
+	slog.Info("Subscribe stream closed", "user", clientID)
+	if err != nil {
+		if _, ok := status.FromError(err); ok {
+			return err
+		}
+		return chatError(err)
+	}
+	return nil
+}
+
 func (h *ChatHandler) CloseRoom(ctx context.Context, req *pb.CloseRoomRequest) (*emptypb.Empty, error) {
-	if err := h.services.CloseRoom(ctx, req.RoomId); err != nil {
-		return nil, status.Error(codes.Internal, err.Error())
+	clientID, err := GetClientID(ctx)
+	if err != nil {
+		return nil, status.Error(codes.PermissionDenied, err.Error())
+	}
+	if err = h.services.CloseRoom(ctx, req.RoomId, clientID); err != nil {
+		return nil, chatError(err)
 	}
 	return &emptypb.Empty{}, nil
 }
@@ -148,7 +340,7 @@ func (h *ChatHandler) JoinRoom(ctx context.Context, req *pb.JoinRoomRequest) (*e
 		return nil, status.Error(codes.PermissionDenied, err.Error())
 	}
 	if err = h.services.JoinRoom(ctx, req.RoomId, clientID); err != nil {
-		return nil, status.Error(codes.Internal, err.Error())
+		return nil, chatError(err)
 	}
 	return &emptypb.Empty{}, nil
 }
@@ -159,39 +351,49 @@ func (h *ChatHandler) LeaveRoom(ctx context.Context, req *pb.LeaveRoomRequest) (
 		return nil, status.Error(codes.PermissionDenied, err.Error())
 	}
 	if err = h.services.LeaveRoom(ctx, req.RoomId, clientID); err != nil {
-		return nil, status.Error(codes.Internal, err.Error())
+		return nil, chatError(err)
 	}
 	return &emptypb.Empty{}, nil
 }
 
-func (h *ChatHandler) ReceiveInvitation(ctx context.Context, _ *emptypb.Empty) (*pb.Invitation, error) {
+func (h *ChatHandler) GetRoomConfig(ctx context.Context, req *pb.RoomConfigRequest) (*pb.RoomConfig, error) {
 	clientID, err := GetClientID(ctx)
 	if err != nil {
-		return &pb.Invitation{}, status.Error(codes.PermissionDenied, err.Error())
+		return nil, status.Error(codes.PermissionDenied, err.Error())
 	}
-	invitation, err := h.services.Chat.ReceiveInvitation(ctx, clientID)
+	cfg, err := h.services.GetRoomConfig(ctx, req.RoomId, clientID)
 	if err != nil {
-		if errors.Is(err, nats.ErrMsgNotFound) {
-			return nil, status.Error(codes.NotFound, err.Error())
-		}
-		return nil, err
-	}
-	return &pb.Invitation{
-		SenderName:  invitation.SenderName,
-		RoomId:      invitation.RoomID,
-		Prime:       invitation.Prime,
-		G:           invitation.G,
-		PublicKey:   invitation.PublicKey,
-		RoomName:    invitation.RoomName,
-		Algorithm:   invitation.Algorithm,
-		Mode:        invitation.Mode,
-		Padding:     invitation.Padding,
-		Iv:          invitation.Iv,
-		RandomDelta: invitation.RandomDelta,
-		MessageId:   invitation.MessageID,
+		return nil, chatError(err)
+	}
+	return &pb.RoomConfig{
+		RoomId:      cfg.RoomID,
+		RoomName:    cfg.RoomName,
+		Algorithm:   cfg.Algorithm,
+		Mode:        cfg.Mode,
+		Padding:     cfg.Padding,
+		Iv:          cfg.Iv,
+		RandomDelta: cfg.RandomDelta,
+		WordSize:    int32(cfg.WordSize),
+		Rounds:      int32(cfg.Rounds),
+		KeyLength:   int32(cfg.KeyLength),
 	}, nil
 }
 
+// chatError переводит ошибки сервиса чатов в коды gRPC.
+func chatError(err error) error {
+	switch {
+	case errors.Is(err, myErrors.ErrPermissionDenied):
+		return status.Error(codes.PermissionDenied, err.Error())
+	case errors.Is(err, myErrors.ErrUnauthorized):
+		return status.Error(codes.Unauthenticated, err.Error())
+	case errors.Is(err, myErrors.ErrRoomNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, myErrors.ErrInvalidInvitation):
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	return status.Error(codes.Internal, err.Error())
+}
+
 func (h *ChatHandler) ReactToInvitation(ctx context.Context, reaction *pb.InvitationReaction) (*emptypb.Empty, error) {
 	clientID, err := GetClientID(ctx)
 	if err != nil {
@@ -204,40 +406,83 @@ func (h *ChatHandler) ReactToInvitation(ctx context.Context, reaction *pb.Invita
 		RoomID:       reaction.RoomId,
 		PublicKey:    reaction.PublicKey,
 		Accepted:     reaction.Accepted,
+		Signature:    reaction.Signature,
 	}
 	if err = h.services.Chat.ReactToInvitation(ctx, invitationReaction); err != nil {
-		return nil, status.Error(codes.Internal, err.Error())
+		return nil, chatError(err)
 	}
 	return &emptypb.Empty{}, nil
 }
 
-func (h *ChatHandler) ReceiveInvitationReaction(ctx context.Context, _ *emptypb.Empty) (*pb.InvitationReaction, error) {
-	clientID, err := GetClientID(ctx)
+func (h *ChatHandler) SendMessage(ctx context.Context, req *pb.ChatMessage) (*emptypb.Empty, error) {
+	senderID, err := GetClientID(ctx)
 	if err != nil {
-		return &pb.InvitationReaction{}, status.Error(codes.PermissionDenied, err.Error())
+		return nil, status.Error(codes.PermissionDenied, err.Error())
 	}
-	reaction, err := h.services.Chat.ReceiveInvitationReaction(ctx, clientID)
+
+	chatMessage, err := chatMessageFromProto(senderID, req)
 	if err != nil {
-		if errors.Is(err, nats.ErrMsgNotFound) {
-			return nil, status.Error(codes.NotFound, err.Error())
-		}
-		return nil, err
+		return nil, status.Error(codes.InvalidArgument, err.Error())
 	}
-	return &pb.InvitationReaction{
-		SenderName:   reaction.SenderName,
-		ReceiverName: reaction.ReceiverName,
-		RoomId:       reaction.RoomID,
-		PublicKey:    reaction.PublicKey,
-		MessageId:    reaction.MessageID,
-		Accepted:     reaction.Accepted,
-	}, nil
+
+	if err := h.services.Chat.SendMessage(ctx, chatMessage); err != nil {
+		return nil, chatError(err)
+	}
+	return &emptypb.Empty{}, nil
 }
 
-func (h *ChatHandler) SendMessage(ctx context.Context, req *pb.ChatMessage) (*emptypb.Empty, error) {
+func (h *ChatHandler) ClearChatHistory(ctx context.Context, req *pb.ClearHistoryRequest) (*emptypb.Empty, error) {
 	senderID, err := GetClientID(ctx)
 	if err != nil {
 		return nil, status.Error(codes.PermissionDenied, err.Error())
 	}
+	request := domain.ChatActions{
+		ID:        req.ChatId,
+		UserName:  req.UserName,
+		MessageID: req.MessageId,
+		SenderID:  senderID,
+	}
+	if err = h.services.Chat.ClearChatHistory(ctx, request); err != nil {
+		return nil, chatError(err)
+	}
+	return &emptypb.Empty{}, nil
+}
+
+func (h *ChatHandler) UpdateOrDeleteCipherKey(ctx context.Context, req *pb.UpdateCipherKeyRequest) (*emptypb.Empty, error) {
+	senderID, err := GetClientID(ctx)
+	if err != nil {
+		return nil, status.Error(codes.PermissionDenied, err.Error())
+	}
+
+	if err = service.AuthorizeUser(ctx, req.UserId); err != nil {
+		return nil, chatError(err)
+	}
+
+	action := domain.ChatActions{
+		ID:        req.ChatId,
+		UserName:  req.UserName,
+		PublicKey: req.PublicKey,
+		MessageID: req.MessageId,
+		SenderID:  senderID,
+		Epoch:     int(req.Epoch),
+		Answer:    req.Answer,
+		Signature: req.Signature,
+	}
+	if err = h.services.Chat.UpdateOrDeleteCipherKey(ctx, action); err != nil {
+		return nil, chatError(err)
+	}
+	return &emptypb.Empty{}, nil
+}
+
+func chatMessageFromProto(senderID string, req *pb.ChatMessage) (*domain.ChatMessage, error) {
+	// ID сообщения и файла выбирает отправитель (они входят в дополнительные данные шифра),
+	// а получатель называет по ним файлы, поэтому принимаются только UUID
+	if !isUUID(req.MessageId) {
+		return nil, errors.New("message id must be a UUID")
+	}
+	if chunk := req.GetChunk(); chunk != nil && !isUUID(chunk.FileId) {
+		return nil, errors.New("file id must be a UUID")
+	}
 
 	chatMessage := &domain.ChatMessage{
 		MessageID:    req.MessageId,
@@ -245,6 +490,14 @@ func (h *ChatHandler) SendMessage(ctx context.Context, req *pb.ChatMessage) (*em
 		ReceiverName: req.ReceiverName,
 		ChatID:       req.ChatId,
 		Timestamp:    req.Timestamp.AsTime(),
+		KeyEpoch:     int(req.KeyEpoch),
+	}
+	if req.Ratchet != nil {
+		chatMessage.Ratchet = &domain.RatchetHeader{
+			PublicKey:           req.Ratchet.PublicKey,
+			PreviousChainLength: req.Ratchet.PreviousChainLength,
+			MessageNumber:       req.Ratchet.MessageNumber,
+		}
 	}
 
 	switch payload := req.Payload.(type) {
@@ -261,70 +514,32 @@ func (h *ChatHandler) SendMessage(ctx context.Context, req *pb.ChatMessage) (*em
 			ChunkData:   payload.Chunk.ChunkData,
 		}
 	default:
-		return nil, status.Error(codes.InvalidArgument, "unknown payload type")
+		return nil, errors.New("unknown payload type")
 	}
-
-	if err := h.services.Chat.SendMessage(ctx, chatMessage); err != nil {
-		return nil, status.Error(codes.Internal, err.Error())
-	}
-	return &emptypb.Empty{}, nil
-}
-
-func (h *ChatHandler) ClearChatHistory(ctx context.Context, req *pb.ClearHistoryRequest) (*emptypb.Empty, error) {
-	request := domain.ChatActions{
-		ID:        req.ChatId,
-		UserName:  req.UserName,
-		MessageID: req.MessageId,
-	}
-	if err := h.services.Chat.ClearChatHistory(ctx, request); err != nil {
-		return nil, status.Error(codes.Internal, err.Error())
-	}
-	return &emptypb.Empty{}, nil
+	return chatMessage, nil
 }
 
-func (h *ChatHandler) ReceiveChatHistoryRequest(ctx context.Context, req *pb.ClearHistoryRequest) (*pb.ClearHistoryRequest, error) {
-	request, err := h.services.Chat.ReceiveClearChatHistoryRequest(ctx, req.UserId)
-	if err != nil {
-		if errors.Is(err, nats.ErrMsgNotFound) {
-			return nil, status.Error(codes.NotFound, err.Error())
-		}
-		return nil, status.Error(codes.Internal, err.Error())
-	}
-	slog.Info("without errors", request)
-	return &pb.ClearHistoryRequest{
-		UserId:    request.UserID,
-		ChatId:    request.ID,
-		MessageId: request.MessageID,
-	}, nil
-}
-
-func (h *ChatHandler) UpdateOrDeleteCipherKey(ctx context.Context, req *pb.UpdateCipherKeyRequest) (*emptypb.Empty, error) {
-	action := domain.ChatActions{
-		ID:        req.ChatId,
-		UserID:    req.UserId,
-		PublicKey: req.PublicKey,
-	}
-	if err := h.services.Chat.UpdateOrDeleteCipherKey(ctx, action); err != nil {
-		return nil, status.Error(codes.Internal, err.Error())
-	}
-	return &emptypb.Empty{}, nil
+// isUUID принимает только каноническую запись UUID, без скобок и префикса urn.
+func isUUID(s string) bool {
+	id, err := uuid.Parse(s)
+	return err == nil && id.String() == s
 }
 
-func (h *ChatHandler) ReceiveMessage(ctx context.Context, req *pb.ReceiveMessagesRequest) (*pb.ChatMessage, error) {
-	msg, err := h.services.Chat.ReceiveMessage(ctx, req.UserId, req.ChatId)
-	if err != nil {
-		if errors.Is(err, nats.ErrMsgNotFound) {
-			return nil, status.Error(codes.NotFound, err.Error())
-		}
-		return nil, status.Error(codes.Internal, err.Error())
-	}
-
+func chatMessageToProto(msg domain.ChatMessage) (*pb.ChatMessage, error) {
 	chatMsg := &pb.ChatMessage{
 		MessageId:  msg.MessageID,
 		SenderId:   msg.SenderID,
 		SenderName: msg.SenderName,
 		ChatId:     msg.ChatID,
 		Timestamp:  timestamppb.New(msg.Timestamp),
+		KeyEpoch:   int32(msg.KeyEpoch),
+	}
+	if msg.Ratchet != nil {
+		chatMsg.Ratchet = &pb.RatchetHeader{
+			PublicKey:           msg.Ratchet.PublicKey,
+			PreviousChainLength: msg.Ratchet.PreviousChainLength,
+			MessageNumber:       msg.Ratchet.MessageNumber,
+		}
 	}
 
 	switch {
@@ -345,8 +560,93 @@ func (h *ChatHandler) ReceiveMessage(ctx context.Context, req *pb.ReceiveMessage
 			},
 		}
 	default:
-		return nil, status.Error(codes.InvalidArgument, "unknown message payload")
+		return nil, errors.New("unknown message payload")
 	}
-
 	return chatMsg, nil
 }
+
+func eventToProto(event domain.Event) (*pb.Event, error) {
+	resp := &pb.Event{Sequence: event.Sequence}
+
+	switch {
+	case event.Message != nil:
+		msg, err := chatMessageToProto(*event.Message)
+		if err != nil {
+			return nil, err
+		}
+		resp.Payload = &pb.Event_Message{Message: msg}
+	case event.Invitation != nil:
+		invitation, err := invitationToProto(*event.Invitation)
+		if err != nil {
+			return nil, err
+		}
+		resp.Payload = &pb.Event_Invitation{Invitation: invitation}
+	case event.Reaction != nil:
+		resp.Payload = &pb.Event_Reaction{Reaction: &pb.InvitationReaction{
+			SenderName:   event.Reaction.SenderName,
+			ReceiverName: event.Reaction.ReceiverName,
+			RoomId:       event.Reaction.RoomID,
+			PublicKey:    event.Reaction.PublicKey,
+			MessageId:    event.Reaction.MessageID,
+			Accepted:     event.Reaction.Accepted,
+			Signature:    event.Reaction.Signature,
+		}}
+	case event.ClearHistory != nil:
+		resp.Payload = &pb.Event_ClearHistory{ClearHistory: &pb.ClearHistoryRequest{
+			UserId:    event.ClearHistory.UserID,
+			ChatId:    event.ClearHistory.ID,
+			MessageId: event.ClearHistory.MessageID,
+		}}
+	case event.KeyUpdate != nil:
+		resp.Payload = &pb.Event_KeyUpdate{KeyUpdate: &pb.UpdateCipherKeyRequest{
+			UserId:     event.KeyUpdate.UserID,
+			UserName:   event.KeyUpdate.UserName,
+			ChatId:     event.KeyUpdate.ID,
+			PublicKey:  event.KeyUpdate.PublicKey,
+			MessageId:  event.KeyUpdate.MessageID,
+			Epoch:      int32(event.KeyUpdate.Epoch),
+			Answer:     event.KeyUpdate.Answer,
+			Signature:  event.KeyUpdate.Signature,
+			SenderName: event.KeyUpdate.SenderName,
+		}}
+	default:
+		return nil, errors.New("unknown event payload")
+	}
+	return resp, nil
+}
+
+func invitationToProto(invitation domain.ChatInvitation) (*pb.Invitation, error) {
+	resp := &pb.Invitation{
+		SenderName:   invitation.SenderName,
+		RoomId:       invitation.RoomID,
+		Prime:        invitation.Prime,
+		G:            invitation.G,
+		PublicKey:    invitation.PublicKey,
+		RoomName:     invitation.RoomName,
+		Algorithm:    invitation.Algorithm,
+		Mode:         invitation.Mode,
+		Padding:      invitation.Padding,
+		Iv:           invitation.Iv,
+		RandomDelta:  invitation.RandomDelta,
+		MessageId:    invitation.MessageID,
+		WordSize:     int32(invitation.WordSize),
+		Rounds:       int32(invitation.Rounds),
+		KeyLength:    int32(invitation.KeyLength),
+		DhGroup:      invitation.DHGroup,
+		KeyAgreement: invitation.KeyAgreement,
+		Signature:    invitation.Signature,
+
+		DoubleRatchet: invitation.DoubleRatchet,
+
+		SignedPrekeyId:  invitation.SignedPrekeyID,
+		OneTimePrekeyId: invitation.OneTimePrekeyID,
+	}
+	if invitation.FirstMessage != nil {
+		firstMessage, err := chatMessageToProto(*invitation.FirstMessage)
+		if err != nil {
+			return nil, err
+		}
+		resp.FirstMessage = firstMessage
+	}
+	return resp, nil
+}
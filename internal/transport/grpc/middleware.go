@@ -2,7 +2,10 @@ package grpc
 
 import (
 	"CryptoMessenger/internal/auth"
+	myErrors "CryptoMessenger/internal/errors"
+	pb "CryptoMessenger/proto/chatpb"
 	"context"
+	"errors"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/metadata"
@@ -10,43 +13,154 @@ import (
 	"strings"
 )
 
-func AuthInterceptor() grpc.UnaryServerInterceptor {
+// AccessLevel — кто может вызывать метод.
+type AccessLevel int
+
+const (
+	// Authenticated — любой пользователь с действующим токеном. Так защищены и методы,
+	// которых нет в таблице.
+	Authenticated AccessLevel = iota
+	// Public — без токена.
+	Public
+	// Admin — только пользователи из списка администраторов в конфигурации сервера.
+	Admin
+)
+
+// methodAccess — уровень доступа каждого метода ChatService.
+var methodAccess = map[string]AccessLevel{
+	pb.ChatService_Register_FullMethodName: Public,
+	pb.ChatService_Login_FullMethodName:    Public,
+	pb.ChatService_Refresh_FullMethodName:  Public,
+
+	pb.ChatService_Logout_FullMethodName:                  Authenticated,
+	pb.ChatService_PublishIdentityKey_FullMethodName:      Authenticated,
+	pb.ChatService_GetIdentityKey_FullMethodName:          Authenticated,
+	pb.ChatService_UploadPrekeys_FullMethodName:           Authenticated,
+	pb.ChatService_GetPrekeyBundle_FullMethodName:         Authenticated,
+	pb.ChatService_CreateRoom_FullMethodName:              Authenticated,
+	pb.ChatService_CloseRoom_FullMethodName:               Authenticated,
+	pb.ChatService_JoinRoom_FullMethodName:                Authenticated,
+	pb.ChatService_LeaveRoom_FullMethodName:               Authenticated,
+	pb.ChatService_GetRoomConfig_FullMethodName:           Authenticated,
+	pb.ChatService_SendMessage_FullMethodName:             Authenticated,
+	pb.ChatService_InviteUser_FullMethodName:              Authenticated,
+	pb.ChatService_ReactToInvitation_FullMethodName:       Authenticated,
+	pb.ChatService_ClearChatHistory_FullMethodName:        Authenticated,
+	pb.ChatService_UpdateOrDeleteCipherKey_FullMethodName: Authenticated,
+	pb.ChatService_Subscribe_FullMethodName:               Authenticated,
+	pb.ChatService_AckEvent_FullMethodName:                Authenticated,
+}
+
+// TokenVerifier проверяет токен доступа, включая список отозванных.
+type TokenVerifier interface {
+	VerifyAccessToken(ctx context.Context, token string) (*auth.Claims, error)
+}
+
+// AuthPolicy проверяет вызывающего по таблице methodAccess.
+type AuthPolicy struct {
+	admins   map[string]struct{}
+	verifier TokenVerifier
+}
+
+func NewAuthPolicy(adminIDs []string, verifier TokenVerifier) *AuthPolicy {
+	admins := make(map[string]struct{}, len(adminIDs))
+	for _, id := range adminIDs {
+		admins[id] = struct{}{}
+	}
+	return &AuthPolicy{admins: admins, verifier: verifier}
+}
+
+// authorize возвращает контекст с Principal вызывающего; для публичных методов контекст не меняется.
+func (p *AuthPolicy) authorize(ctx context.Context, fullMethod string) (context.Context, error) {
+	level := methodAccess[fullMethod]
+	if level == Public {
+		return ctx, nil
+	}
+
+	claims, err := p.authenticate(ctx)
+	if err != nil {
+		return nil, err
+	}
+	_, admin := p.admins[claims.ClientID]
+	if level == Admin && !admin {
+		return nil, status.Error(codes.PermissionDenied, "admin access required")
+	}
+	return auth.WithPrincipal(ctx, auth.Principal{
+		UserID:         claims.ClientID,
+		Admin:          admin,
+		TokenID:        claims.ID,
+		TokenExpiresAt: claims.ExpiresAt.Time,
+	}), nil
+}
+
+func AuthInterceptor(policy *AuthPolicy) grpc.UnaryServerInterceptor {
 	return func(
 		ctx context.Context,
 		req interface{},
 		info *grpc.UnaryServerInfo,
 		handler grpc.UnaryHandler,
 	) (interface{}, error) {
-
-		if info.FullMethod == "/chat.ChatService/Register" || info.FullMethod == "/chat.ChatService/Login" {
-			return handler(ctx, req)
+		ctx, err := policy.authorize(ctx, info.FullMethod)
+		if err != nil {
+			return nil, err
 		}
+		return handler(ctx, req)
+	}
+}
 
-		md, ok := metadata.FromIncomingContext(ctx)
-		if !ok {
-			return nil, status.Error(codes.Unauthenticated, "missing metadata")
+func StreamAuthInterceptor(policy *AuthPolicy) grpc.StreamServerInterceptor {
+	return func(
+		srv interface{},
+		stream grpc.ServerStream,
+		info *grpc.StreamServerInfo,
+		handler grpc.StreamHandler,
+	) error {
+		ctx, err := policy.authorize(stream.Context(), info.FullMethod)
+		if err != nil {
+			return err
 		}
+		return handler(srv, &authenticatedStream{ServerStream: stream, ctx: ctx})
+	}
+}
 
-		authHeader := md["authorization"]
-		if len(authHeader) == 0 {
-			return nil, status.Error(codes.Unauthenticated, "authorization header is not provided")
-		}
+// authenticatedStream подменяет контекст потока контекстом с Principal.
+type authenticatedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
 
-		token := strings.TrimPrefix(authHeader[0], "Bearer ")
-		claims, err := auth.ParseToken(token)
-		if err != nil {
-			return nil, status.Errorf(codes.Unauthenticated, "invalid token: %v", err)
-		}
+func (s *authenticatedStream) Context() context.Context {
+	return s.ctx
+}
 
-		ctx = context.WithValue(ctx, "client_id", claims.ClientID)
-		return handler(ctx, req)
+// authenticate проверяет токен из заголовка authorization.
+func (p *AuthPolicy) authenticate(ctx context.Context) (*auth.Claims, error) {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "missing metadata")
+	}
+
+	authHeader := md["authorization"]
+	if len(authHeader) == 0 {
+		return nil, status.Error(codes.Unauthenticated, "authorization header is not provided")
+	}
+
+	token := strings.TrimPrefix(authHeader[0], "Bearer ")
+	claims, err := p.verifier.VerifyAccessToken(ctx, token)
+	if errors.Is(err, myErrors.ErrUnauthorized) {
+		return nil, status.Errorf(codes.Unauthenticated, "invalid token: %v", err)
 	}
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	return claims, nil
 }
 
 func GetClientID(ctx context.Context) (string, error) {
-	clientID, ok := ctx.Value("client_id").(string)
+	principal, ok := auth.PrincipalFromContext(ctx)
 	if !ok {
 		return "", status.Error(codes.Unauthenticated, "missing client id")
 	}
-	return clientID, nil
+	return principal.UserID, nil
 }
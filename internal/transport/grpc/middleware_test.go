@@ -0,0 +1,185 @@
+package grpc
+
+import (
+	"CryptoMessenger/internal/auth"
+	myErrors "CryptoMessenger/internal/errors"
+	pb "CryptoMessenger/proto/chatpb"
+	"context"
+	"fmt"
+	"github.com/golang-jwt/jwt/v5"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"testing"
+	"time"
+)
+
+// fakeVerifier принимает только токены из tokens.
+type fakeVerifier struct {
+	tokens map[string]*auth.Claims
+}
+
+func (v fakeVerifier) VerifyAccessToken(_ context.Context, token string) (*auth.Claims, error) {
+	claims, ok := v.tokens[token]
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown token", myErrors.ErrUnauthorized)
+	}
+	return claims, nil
+}
+
+func testClaims(clientID string) *auth.Claims {
+	return &auth.Claims{
+		ClientID: clientID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        clientID + "-token",
+			ExpiresAt: jwt.NewNumericDate(time.Unix(2000000000, 0)),
+		},
+	}
+}
+
+func newTestPolicy() *AuthPolicy {
+	return NewAuthPolicy([]string{"root-id"}, fakeVerifier{tokens: map[string]*auth.Claims{
+		"alice-token": testClaims("alice-id"),
+		"root-token":  testClaims("root-id"),
+	}})
+}
+
+func withToken(header string) context.Context {
+	return metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", header))
+}
+
+// serverStream — поток, у которого есть только контекст.
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s serverStream) Context() context.Context {
+	return s.ctx
+}
+
+// callUnary и callStream вызывают метод через interceptor и возвращают Principal,
+// который увидел обработчик.
+func callUnary(policy *AuthPolicy, ctx context.Context, method string) (auth.Principal, bool, error) {
+	var principal auth.Principal
+	var ok bool
+	_, err := AuthInterceptor(policy)(ctx, nil, &grpc.UnaryServerInfo{FullMethod: method},
+		func(ctx context.Context, _ interface{}) (interface{}, error) {
+			principal, ok = auth.PrincipalFromContext(ctx)
+			return nil, nil
+		})
+	return principal, ok, err
+}
+
+func callStream(policy *AuthPolicy, ctx context.Context, method string) (auth.Principal, bool, error) {
+	var principal auth.Principal
+	var ok bool
+	err := StreamAuthInterceptor(policy)(nil, serverStream{ctx: ctx}, &grpc.StreamServerInfo{FullMethod: method},
+		func(_ interface{}, stream grpc.ServerStream) error {
+			principal, ok = auth.PrincipalFromContext(stream.Context())
+			return nil
+		})
+	return principal, ok, err
+}
+
+func TestMethodAccessCoversService(t *testing.T) {
+	methods := make(map[string]bool)
+	for _, m := range pb.ChatService_ServiceDesc.Methods {
+		methods["/"+pb.ChatService_ServiceDesc.ServiceName+"/"+m.MethodName] = true
+	}
+	for _, s := range pb.ChatService_ServiceDesc.Streams {
+		methods["/"+pb.ChatService_ServiceDesc.ServiceName+"/"+s.StreamName] = true
+	}
+
+	for method := range methods {
+		if _, ok := methodAccess[method]; !ok {
+			t.Errorf("%s is missing from methodAccess", method)
+		}
+	}
+	for method := range methodAccess {
+		if !methods[method] {
+			t.Errorf("methodAccess lists unknown method %s", method)
+		}
+	}
+}
+
+func TestInterceptorsRejectMissingOrInvalidToken(t *testing.T) {
+	tests := []struct {
+		name string
+		ctx  context.Context
+	}{
+		{"no metadata", context.Background()},
+		{"no authorization header", metadata.NewIncomingContext(context.Background(), metadata.Pairs("other", "value"))},
+		{"unknown token", withToken("Bearer forged-token")},
+		{"empty token", withToken("Bearer ")},
+	}
+	policy := newTestPolicy()
+	calls := map[string]func(*AuthPolicy, context.Context, string) (auth.Principal, bool, error){
+		"unary":  callUnary,
+		"stream": callStream,
+	}
+	for _, tt := range tests {
+		for kind, call := range calls {
+			t.Run(tt.name+"/"+kind, func(t *testing.T) {
+				_, _, err := call(policy, tt.ctx, pb.ChatService_Subscribe_FullMethodName)
+				if status.Code(err) != codes.Unauthenticated {
+					t.Fatalf("got %v, want Unauthenticated", err)
+				}
+			})
+		}
+	}
+}
+
+func TestInterceptorsPassPrincipal(t *testing.T) {
+	policy := newTestPolicy()
+	want := auth.Principal{
+		UserID:         "alice-id",
+		TokenID:        "alice-id-token",
+		TokenExpiresAt: time.Unix(2000000000, 0),
+	}
+	for kind, call := range map[string]func(*AuthPolicy, context.Context, string) (auth.Principal, bool, error){
+		"unary":  callUnary,
+		"stream": callStream,
+	} {
+		principal, ok, err := call(policy, withToken("Bearer alice-token"), pb.ChatService_Subscribe_FullMethodName)
+		if err != nil || !ok {
+			t.Fatalf("%s: ok=%v err=%v", kind, ok, err)
+		}
+		if principal.UserID != want.UserID || principal.Admin || principal.TokenID != want.TokenID ||
+			!principal.TokenExpiresAt.Equal(want.TokenExpiresAt) {
+			t.Errorf("%s: got %+v, want %+v", kind, principal, want)
+		}
+	}
+
+	// Публичные методы вызываются без токена и без Principal
+	_, ok, err := callUnary(policy, context.Background(), pb.ChatService_Login_FullMethodName)
+	if err != nil || ok {
+		t.Errorf("public method: ok=%v err=%v", ok, err)
+	}
+}
+
+func TestAdminAccess(t *testing.T) {
+	// Методов уровня Admin в ChatService пока нет, поэтому добавляем тестовый
+	const method = "/chat.ChatService/AdminOnly"
+	methodAccess[method] = Admin
+	t.Cleanup(func() { delete(methodAccess, method) })
+
+	policy := newTestPolicy()
+	if _, _, err := callUnary(policy, withToken("Bearer alice-token"), method); status.Code(err) != codes.PermissionDenied {
+		t.Errorf("non-admin: got %v, want PermissionDenied", err)
+	}
+	if _, _, err := callStream(policy, context.Background(), method); status.Code(err) != codes.Unauthenticated {
+		t.Errorf("no token: got %v, want Unauthenticated", err)
+	}
+	principal, ok, err := callStream(policy, withToken("Bearer root-token"), method)
+	if err != nil || !ok || !principal.Admin {
+		t.Errorf("admin: principal=%+v ok=%v err=%v", principal, ok, err)
+	}
+
+	// Администратор остаётся администратором и в обычных методах
+	principal, _, err = callUnary(policy, withToken("Bearer root-token"), pb.ChatService_Logout_FullMethodName)
+	if err != nil || !principal.Admin {
+		t.Errorf("admin on authenticated method: principal=%+v err=%v", principal, err)
+	}
+}
@@ -11,13 +11,21 @@ import (
 )
 
 func RunGRPCServer(config serverConfig.ServerConfig, service *service.Service) error {
+	creds, err := serverCredentials(config)
+	if err != nil {
+		return err
+	}
+
 	lis, err := net.Listen(config.Type, config.Address)
 	if err != nil {
 		return fmt.Errorf("failed to listen on %s: %w", config.Address, err)
 	}
 
+	policy := NewAuthPolicy(config.Admins, service)
 	srv := grpc.NewServer(
-		grpc.UnaryInterceptor(AuthInterceptor()))
+		grpc.Creds(creds),
+		grpc.UnaryInterceptor(AuthInterceptor(policy)),
+		grpc.StreamInterceptor(StreamAuthInterceptor(policy)))
 	pb.RegisterChatServiceServer(srv, NewChatHandler(service))
 
 	fmt.Printf("gRPC server listening at %s\n", config.Address)
@@ -0,0 +1,55 @@
+package grpc
+
+import (
+	"CryptoMessenger/internal/config/serverConfig"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"log/slog"
+	"os"
+)
+
+// serverCredentials собирает TLS-настройки сервера из конфигурации.
+func serverCredentials(config serverConfig.ServerConfig) (credentials.TransportCredentials, error) {
+	if config.CertFile == "" && config.KeyFile == "" {
+		if !config.Insecure {
+			return nil, fmt.Errorf("TLS certificate is not configured (set SERVER_TLS_CERT and SERVER_TLS_KEY or SERVER_INSECURE=true)")
+		}
+		slog.Warn("gRPC server runs without TLS: tokens and passwords are sent in the clear")
+		return insecure.NewCredentials(), nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(config.CertFile, config.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load server certificate: %w", err)
+	}
+	tlsConfig := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+
+	if config.ClientCAFile != "" {
+		pool, err := loadCertPool(config.ClientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client CA: %w", err)
+		}
+		tlsConfig.ClientCAs = pool
+		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	return credentials.NewTLS(tlsConfig), nil
+}
+
+func loadCertPool(path string) (*x509.CertPool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("no certificates found in %s", path)
+	}
+	return pool, nil
+}
@@ -13,7 +13,7 @@ func main() {
 	//if err != nil {
 	//	panic(err)
 	//}
-	rc, err := rc6.NewRC6(key)
+	rc, err := rc6.NewRC6(32, 20, 32, key)
 	if err != nil {
 		fmt.Println(err)
 	}
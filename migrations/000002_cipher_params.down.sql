@@ -0,0 +1,4 @@
+ALTER TABLE chats
+    DROP COLUMN IF EXISTS key_length,
+    DROP COLUMN IF EXISTS rounds,
+    DROP COLUMN IF EXISTS word_size;
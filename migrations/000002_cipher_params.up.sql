@@ -0,0 +1,5 @@
+-- 0 — значение по умолчанию для выбранного шифра (для RC5 это 64/12, для RC6 — 32/20)
+ALTER TABLE chats
+    ADD COLUMN IF NOT EXISTS word_size  INT NOT NULL DEFAULT 0,  -- w для RC5/RC6
+    ADD COLUMN IF NOT EXISTS rounds     INT NOT NULL DEFAULT 0,  -- r для RC5/RC6
+    ADD COLUMN IF NOT EXISTS key_length INT NOT NULL DEFAULT 32; -- длина ключа в байтах
@@ -0,0 +1,6 @@
+CREATE TABLE IF NOT EXISTS identity_keys
+(
+    user_id    UUID PRIMARY KEY REFERENCES users (user_id) ON DELETE CASCADE,
+    public_key BYTEA       NOT NULL, -- открытый ключ Ed25519
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
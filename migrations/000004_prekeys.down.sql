@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS room_keys;
+DROP TABLE IF EXISTS prekeys;
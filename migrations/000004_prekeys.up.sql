@@ -0,0 +1,25 @@
+CREATE TABLE IF NOT EXISTS prekeys
+(
+    user_id       UUID        NOT NULL REFERENCES users (user_id) ON DELETE CASCADE,
+    key_id        TEXT        NOT NULL,
+    key_agreement TEXT        NOT NULL,          -- "DH", "X25519", "P-256"
+    dh_group      TEXT        NOT NULL DEFAULT '', -- для ECDH пусто
+    public_key    TEXT        NOT NULL,          -- в hex
+    signature     BYTEA,                         -- подпись Ed25519, только у подписанного предключа
+    one_time      BOOLEAN     NOT NULL,
+    created_at    TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (user_id, key_id)
+);
+
+-- у пользователя один подписанный предключ на схему согласования
+CREATE UNIQUE INDEX IF NOT EXISTS prekeys_signed_idx
+    ON prekeys (user_id, key_agreement, dh_group) WHERE NOT one_time;
+
+CREATE TABLE IF NOT EXISTS room_keys
+(
+    room_id    UUID        NOT NULL REFERENCES chats (chat_id) ON DELETE CASCADE,
+    user_id    UUID        NOT NULL REFERENCES users (user_id) ON DELETE CASCADE,
+    public_key TEXT        NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (room_id, user_id)
+);
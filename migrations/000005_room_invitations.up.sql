@@ -0,0 +1,18 @@
+-- приглашение в комнату ждёт ответа; принять его может только приглашённый
+CREATE TABLE IF NOT EXISTS room_invitations
+(
+    room_id    UUID        NOT NULL REFERENCES chats (chat_id) ON DELETE CASCADE,
+    user_id    UUID        NOT NULL REFERENCES users (user_id) ON DELETE CASCADE,
+    invited_by UUID        NOT NULL REFERENCES users (user_id) ON DELETE CASCADE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (room_id, user_id)
+);
+
+-- Участники комнат раньше не записывались (AddMember не работал), поэтому у комнат,
+-- созданных до этой версии, участников нет, и восстановить их не из чего: такие
+-- комнаты нужно создать заново. Участниками считаются только те, кто уже опубликовал
+-- ключ в room_keys (таблица появилась в 000004).
+INSERT INTO room_participants (room_id, user_id)
+SELECT room_id, user_id
+FROM room_keys
+ON CONFLICT (room_id, user_id) DO NOTHING;
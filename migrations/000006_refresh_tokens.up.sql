@@ -0,0 +1,20 @@
+-- токены обновления хранятся только в виде SHA-256; все токены, полученные
+-- цепочкой обновлений от одного входа, образуют семейство (family_id)
+CREATE TABLE IF NOT EXISTS refresh_tokens
+(
+    token_hash BYTEA PRIMARY KEY,
+    user_id    UUID        NOT NULL REFERENCES users (user_id) ON DELETE CASCADE,
+    family_id  UUID        NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL,
+    used_at    TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS refresh_tokens_family_idx ON refresh_tokens (family_id);
+
+-- отозванные токены доступа; запись нужна только до истечения самого токена
+CREATE TABLE IF NOT EXISTS revoked_tokens
+(
+    token_id   UUID PRIMARY KEY,
+    expires_at TIMESTAMPTZ NOT NULL
+);
@@ -79,6 +79,8 @@ type RegisterResponse struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Token         string                 `protobuf:"bytes,1,opt,name=token,proto3" json:"token,omitempty"`
 	UserID        string                 `protobuf:"bytes,2,opt,name=userID,proto3" json:"userID,omitempty"`
+	RefreshToken  string                 `protobuf:"bytes,3,opt,name=refresh_token,json=refreshToken,proto3" json:"refresh_token,omitempty"`
+	ExpiresAt     *timestamppb.Timestamp `protobuf:"bytes,4,opt,name=expires_at,json=expiresAt,proto3" json:"expires_at,omitempty"` // срок действия token
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -127,29 +129,644 @@ func (x *RegisterResponse) GetUserID() string {
 	return ""
 }
 
+func (x *RegisterResponse) GetRefreshToken() string {
+	if x != nil {
+		return x.RefreshToken
+	}
+	return ""
+}
+
+func (x *RegisterResponse) GetExpiresAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.ExpiresAt
+	}
+	return nil
+}
+
 type LoginRequest struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Username      string                 `protobuf:"bytes,1,opt,name=username,proto3" json:"username,omitempty"`
-	Password      string                 `protobuf:"bytes,2,opt,name=password,proto3" json:"password,omitempty"`
+	Password      string                 `protobuf:"bytes,2,opt,name=password,proto3" json:"password,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *LoginRequest) Reset() {
+	*x = LoginRequest{}
+	mi := &file_chat_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *LoginRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*LoginRequest) ProtoMessage() {}
+
+func (x *LoginRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_chat_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use LoginRequest.ProtoReflect.Descriptor instead.
+func (*LoginRequest) Descriptor() ([]byte, []int) {
+	return file_chat_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *LoginRequest) GetUsername() string {
+	if x != nil {
+		return x.Username
+	}
+	return ""
+}
+
+func (x *LoginRequest) GetPassword() string {
+	if x != nil {
+		return x.Password
+	}
+	return ""
+}
+
+type LoginResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Token         string                 `protobuf:"bytes,1,opt,name=token,proto3" json:"token,omitempty"`
+	UserID        string                 `protobuf:"bytes,2,opt,name=userID,proto3" json:"userID,omitempty"`
+	RefreshToken  string                 `protobuf:"bytes,3,opt,name=refresh_token,json=refreshToken,proto3" json:"refresh_token,omitempty"`
+	ExpiresAt     *timestamppb.Timestamp `protobuf:"bytes,4,opt,name=expires_at,json=expiresAt,proto3" json:"expires_at,omitempty"` // срок действия token
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *LoginResponse) Reset() {
+	*x = LoginResponse{}
+	mi := &file_chat_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *LoginResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*LoginResponse) ProtoMessage() {}
+
+func (x *LoginResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_chat_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use LoginResponse.ProtoReflect.Descriptor instead.
+func (*LoginResponse) Descriptor() ([]byte, []int) {
+	return file_chat_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *LoginResponse) GetToken() string {
+	if x != nil {
+		return x.Token
+	}
+	return ""
+}
+
+func (x *LoginResponse) GetUserID() string {
+	if x != nil {
+		return x.UserID
+	}
+	return ""
+}
+
+func (x *LoginResponse) GetRefreshToken() string {
+	if x != nil {
+		return x.RefreshToken
+	}
+	return ""
+}
+
+func (x *LoginResponse) GetExpiresAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.ExpiresAt
+	}
+	return nil
+}
+
+// RefreshRequest обменивает токен обновления на новую пару токенов; старый токен
+// обновления после этого недействителен.
+type RefreshRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	RefreshToken  string                 `protobuf:"bytes,1,opt,name=refresh_token,json=refreshToken,proto3" json:"refresh_token,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RefreshRequest) Reset() {
+	*x = RefreshRequest{}
+	mi := &file_chat_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RefreshRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RefreshRequest) ProtoMessage() {}
+
+func (x *RefreshRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_chat_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RefreshRequest.ProtoReflect.Descriptor instead.
+func (*RefreshRequest) Descriptor() ([]byte, []int) {
+	return file_chat_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *RefreshRequest) GetRefreshToken() string {
+	if x != nil {
+		return x.RefreshToken
+	}
+	return ""
+}
+
+type RefreshResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Token         string                 `protobuf:"bytes,1,opt,name=token,proto3" json:"token,omitempty"`
+	RefreshToken  string                 `protobuf:"bytes,2,opt,name=refresh_token,json=refreshToken,proto3" json:"refresh_token,omitempty"`
+	ExpiresAt     *timestamppb.Timestamp `protobuf:"bytes,3,opt,name=expires_at,json=expiresAt,proto3" json:"expires_at,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RefreshResponse) Reset() {
+	*x = RefreshResponse{}
+	mi := &file_chat_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RefreshResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RefreshResponse) ProtoMessage() {}
+
+func (x *RefreshResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_chat_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RefreshResponse.ProtoReflect.Descriptor instead.
+func (*RefreshResponse) Descriptor() ([]byte, []int) {
+	return file_chat_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *RefreshResponse) GetToken() string {
+	if x != nil {
+		return x.Token
+	}
+	return ""
+}
+
+func (x *RefreshResponse) GetRefreshToken() string {
+	if x != nil {
+		return x.RefreshToken
+	}
+	return ""
+}
+
+func (x *RefreshResponse) GetExpiresAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.ExpiresAt
+	}
+	return nil
+}
+
+// LogoutRequest отзывает токен доступа, с которым сделан вызов, и цепочку токенов обновления.
+type LogoutRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	RefreshToken  string                 `protobuf:"bytes,1,opt,name=refresh_token,json=refreshToken,proto3" json:"refresh_token,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *LogoutRequest) Reset() {
+	*x = LogoutRequest{}
+	mi := &file_chat_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *LogoutRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*LogoutRequest) ProtoMessage() {}
+
+func (x *LogoutRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_chat_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use LogoutRequest.ProtoReflect.Descriptor instead.
+func (*LogoutRequest) Descriptor() ([]byte, []int) {
+	return file_chat_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *LogoutRequest) GetRefreshToken() string {
+	if x != nil {
+		return x.RefreshToken
+	}
+	return ""
+}
+
+type IdentityKey struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Username      string                 `protobuf:"bytes,1,opt,name=username,proto3" json:"username,omitempty"`                    // при публикации игнорируется: ключ привязывается к владельцу токена
+	PublicKey     []byte                 `protobuf:"bytes,2,opt,name=public_key,json=publicKey,proto3" json:"public_key,omitempty"` // открытый ключ Ed25519, 32 байта
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *IdentityKey) Reset() {
+	*x = IdentityKey{}
+	mi := &file_chat_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *IdentityKey) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*IdentityKey) ProtoMessage() {}
+
+func (x *IdentityKey) ProtoReflect() protoreflect.Message {
+	mi := &file_chat_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use IdentityKey.ProtoReflect.Descriptor instead.
+func (*IdentityKey) Descriptor() ([]byte, []int) {
+	return file_chat_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *IdentityKey) GetUsername() string {
+	if x != nil {
+		return x.Username
+	}
+	return ""
+}
+
+func (x *IdentityKey) GetPublicKey() []byte {
+	if x != nil {
+		return x.PublicKey
+	}
+	return nil
+}
+
+type IdentityKeyRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Username      string                 `protobuf:"bytes,1,opt,name=username,proto3" json:"username,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *IdentityKeyRequest) Reset() {
+	*x = IdentityKeyRequest{}
+	mi := &file_chat_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *IdentityKeyRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*IdentityKeyRequest) ProtoMessage() {}
+
+func (x *IdentityKeyRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_chat_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use IdentityKeyRequest.ProtoReflect.Descriptor instead.
+func (*IdentityKeyRequest) Descriptor() ([]byte, []int) {
+	return file_chat_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *IdentityKeyRequest) GetUsername() string {
+	if x != nil {
+		return x.Username
+	}
+	return ""
+}
+
+type Prekey struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	KeyId         string                 `protobuf:"bytes,1,opt,name=key_id,json=keyId,proto3" json:"key_id,omitempty"`
+	KeyAgreement  string                 `protobuf:"bytes,2,opt,name=key_agreement,json=keyAgreement,proto3" json:"key_agreement,omitempty"` // "DH", "X25519" или "P-256"
+	DhGroup       string                 `protobuf:"bytes,3,opt,name=dh_group,json=dhGroup,proto3" json:"dh_group,omitempty"`                // группа DH; для ECDH пусто
+	PublicKey     string                 `protobuf:"bytes,4,opt,name=public_key,json=publicKey,proto3" json:"public_key,omitempty"`          // в hex, как public_key в приглашении
+	Signature     []byte                 `protobuf:"bytes,5,opt,name=signature,proto3" json:"signature,omitempty"`                           // подпись ключом идентичности; только у подписанного предключа
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Prekey) Reset() {
+	*x = Prekey{}
+	mi := &file_chat_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Prekey) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Prekey) ProtoMessage() {}
+
+func (x *Prekey) ProtoReflect() protoreflect.Message {
+	mi := &file_chat_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Prekey.ProtoReflect.Descriptor instead.
+func (*Prekey) Descriptor() ([]byte, []int) {
+	return file_chat_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *Prekey) GetKeyId() string {
+	if x != nil {
+		return x.KeyId
+	}
+	return ""
+}
+
+func (x *Prekey) GetKeyAgreement() string {
+	if x != nil {
+		return x.KeyAgreement
+	}
+	return ""
+}
+
+func (x *Prekey) GetDhGroup() string {
+	if x != nil {
+		return x.DhGroup
+	}
+	return ""
+}
+
+func (x *Prekey) GetPublicKey() string {
+	if x != nil {
+		return x.PublicKey
+	}
+	return ""
+}
+
+func (x *Prekey) GetSignature() []byte {
+	if x != nil {
+		return x.Signature
+	}
+	return nil
+}
+
+type UploadPrekeysRequest struct {
+	state          protoimpl.MessageState `protogen:"open.v1"`
+	SignedPrekeys  []*Prekey              `protobuf:"bytes,1,rep,name=signed_prekeys,json=signedPrekeys,proto3" json:"signed_prekeys,omitempty"`      // заменяют прежние для той же схемы
+	OneTimePrekeys []*Prekey              `protobuf:"bytes,2,rep,name=one_time_prekeys,json=oneTimePrekeys,proto3" json:"one_time_prekeys,omitempty"` // добавляются к оставшимся
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
+}
+
+func (x *UploadPrekeysRequest) Reset() {
+	*x = UploadPrekeysRequest{}
+	mi := &file_chat_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UploadPrekeysRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UploadPrekeysRequest) ProtoMessage() {}
+
+func (x *UploadPrekeysRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_chat_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UploadPrekeysRequest.ProtoReflect.Descriptor instead.
+func (*UploadPrekeysRequest) Descriptor() ([]byte, []int) {
+	return file_chat_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *UploadPrekeysRequest) GetSignedPrekeys() []*Prekey {
+	if x != nil {
+		return x.SignedPrekeys
+	}
+	return nil
+}
+
+func (x *UploadPrekeysRequest) GetOneTimePrekeys() []*Prekey {
+	if x != nil {
+		return x.OneTimePrekeys
+	}
+	return nil
+}
+
+type PrekeyCount struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	KeyAgreement  string                 `protobuf:"bytes,1,opt,name=key_agreement,json=keyAgreement,proto3" json:"key_agreement,omitempty"`
+	DhGroup       string                 `protobuf:"bytes,2,opt,name=dh_group,json=dhGroup,proto3" json:"dh_group,omitempty"`
+	OneTimeCount  int32                  `protobuf:"varint,3,opt,name=one_time_count,json=oneTimeCount,proto3" json:"one_time_count,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PrekeyCount) Reset() {
+	*x = PrekeyCount{}
+	mi := &file_chat_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PrekeyCount) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PrekeyCount) ProtoMessage() {}
+
+func (x *PrekeyCount) ProtoReflect() protoreflect.Message {
+	mi := &file_chat_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PrekeyCount.ProtoReflect.Descriptor instead.
+func (*PrekeyCount) Descriptor() ([]byte, []int) {
+	return file_chat_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *PrekeyCount) GetKeyAgreement() string {
+	if x != nil {
+		return x.KeyAgreement
+	}
+	return ""
+}
+
+func (x *PrekeyCount) GetDhGroup() string {
+	if x != nil {
+		return x.DhGroup
+	}
+	return ""
+}
+
+func (x *PrekeyCount) GetOneTimeCount() int32 {
+	if x != nil {
+		return x.OneTimeCount
+	}
+	return 0
+}
+
+// PrekeyStatus — сколько одноразовых предключей осталось на сервере по каждой схеме.
+type PrekeyStatus struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Counts        []*PrekeyCount         `protobuf:"bytes,1,rep,name=counts,proto3" json:"counts,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PrekeyStatus) Reset() {
+	*x = PrekeyStatus{}
+	mi := &file_chat_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PrekeyStatus) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PrekeyStatus) ProtoMessage() {}
+
+func (x *PrekeyStatus) ProtoReflect() protoreflect.Message {
+	mi := &file_chat_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PrekeyStatus.ProtoReflect.Descriptor instead.
+func (*PrekeyStatus) Descriptor() ([]byte, []int) {
+	return file_chat_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *PrekeyStatus) GetCounts() []*PrekeyCount {
+	if x != nil {
+		return x.Counts
+	}
+	return nil
+}
+
+type PrekeyBundleRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Username      string                 `protobuf:"bytes,1,opt,name=username,proto3" json:"username,omitempty"`
+	KeyAgreement  string                 `protobuf:"bytes,2,opt,name=key_agreement,json=keyAgreement,proto3" json:"key_agreement,omitempty"`
+	DhGroup       string                 `protobuf:"bytes,3,opt,name=dh_group,json=dhGroup,proto3" json:"dh_group,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *LoginRequest) Reset() {
-	*x = LoginRequest{}
-	mi := &file_chat_proto_msgTypes[2]
+func (x *PrekeyBundleRequest) Reset() {
+	*x = PrekeyBundleRequest{}
+	mi := &file_chat_proto_msgTypes[13]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *LoginRequest) String() string {
+func (x *PrekeyBundleRequest) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*LoginRequest) ProtoMessage() {}
+func (*PrekeyBundleRequest) ProtoMessage() {}
 
-func (x *LoginRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_chat_proto_msgTypes[2]
+func (x *PrekeyBundleRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_chat_proto_msgTypes[13]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -160,48 +777,57 @@ func (x *LoginRequest) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use LoginRequest.ProtoReflect.Descriptor instead.
-func (*LoginRequest) Descriptor() ([]byte, []int) {
-	return file_chat_proto_rawDescGZIP(), []int{2}
+// Deprecated: Use PrekeyBundleRequest.ProtoReflect.Descriptor instead.
+func (*PrekeyBundleRequest) Descriptor() ([]byte, []int) {
+	return file_chat_proto_rawDescGZIP(), []int{13}
 }
 
-func (x *LoginRequest) GetUsername() string {
+func (x *PrekeyBundleRequest) GetUsername() string {
 	if x != nil {
 		return x.Username
 	}
 	return ""
 }
 
-func (x *LoginRequest) GetPassword() string {
+func (x *PrekeyBundleRequest) GetKeyAgreement() string {
 	if x != nil {
-		return x.Password
+		return x.KeyAgreement
 	}
 	return ""
 }
 
-type LoginResponse struct {
+func (x *PrekeyBundleRequest) GetDhGroup() string {
+	if x != nil {
+		return x.DhGroup
+	}
+	return ""
+}
+
+// PrekeyBundle выдаётся один раз: одноразовый предключ при этом удаляется с сервера.
+type PrekeyBundle struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
-	Token         string                 `protobuf:"bytes,1,opt,name=token,proto3" json:"token,omitempty"`
-	UserID        string                 `protobuf:"bytes,2,opt,name=userID,proto3" json:"userID,omitempty"`
+	Username      string                 `protobuf:"bytes,1,opt,name=username,proto3" json:"username,omitempty"`
+	SignedPrekey  *Prekey                `protobuf:"bytes,2,opt,name=signed_prekey,json=signedPrekey,proto3" json:"signed_prekey,omitempty"`
+	OneTimePrekey *Prekey                `protobuf:"bytes,3,opt,name=one_time_prekey,json=oneTimePrekey,proto3" json:"one_time_prekey,omitempty"` // отсутствует, если одноразовые предключи закончились
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *LoginResponse) Reset() {
-	*x = LoginResponse{}
-	mi := &file_chat_proto_msgTypes[3]
+func (x *PrekeyBundle) Reset() {
+	*x = PrekeyBundle{}
+	mi := &file_chat_proto_msgTypes[14]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *LoginResponse) String() string {
+func (x *PrekeyBundle) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*LoginResponse) ProtoMessage() {}
+func (*PrekeyBundle) ProtoMessage() {}
 
-func (x *LoginResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_chat_proto_msgTypes[3]
+func (x *PrekeyBundle) ProtoReflect() protoreflect.Message {
+	mi := &file_chat_proto_msgTypes[14]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -212,23 +838,30 @@ func (x *LoginResponse) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use LoginResponse.ProtoReflect.Descriptor instead.
-func (*LoginResponse) Descriptor() ([]byte, []int) {
-	return file_chat_proto_rawDescGZIP(), []int{3}
+// Deprecated: Use PrekeyBundle.ProtoReflect.Descriptor instead.
+func (*PrekeyBundle) Descriptor() ([]byte, []int) {
+	return file_chat_proto_rawDescGZIP(), []int{14}
 }
 
-func (x *LoginResponse) GetToken() string {
+func (x *PrekeyBundle) GetUsername() string {
 	if x != nil {
-		return x.Token
+		return x.Username
 	}
 	return ""
 }
 
-func (x *LoginResponse) GetUserID() string {
+func (x *PrekeyBundle) GetSignedPrekey() *Prekey {
 	if x != nil {
-		return x.UserID
+		return x.SignedPrekey
 	}
-	return ""
+	return nil
+}
+
+func (x *PrekeyBundle) GetOneTimePrekey() *Prekey {
+	if x != nil {
+		return x.OneTimePrekey
+	}
+	return nil
 }
 
 type CreateRoomRequest struct {
@@ -240,13 +873,16 @@ type CreateRoomRequest struct {
 	Iv            string                 `protobuf:"bytes,5,opt,name=iv,proto3" json:"iv,omitempty"`
 	RandomDelta   string                 `protobuf:"bytes,6,opt,name=randomDelta,proto3" json:"randomDelta,omitempty"`
 	RoomName      string                 `protobuf:"bytes,7,opt,name=room_name,json=roomName,proto3" json:"room_name,omitempty"`
+	WordSize      int32                  `protobuf:"varint,8,opt,name=word_size,json=wordSize,proto3" json:"word_size,omitempty"`     // w для RC5/RC6: 16, 32 или 64; 0 — по умолчанию для шифра
+	Rounds        int32                  `protobuf:"varint,9,opt,name=rounds,proto3" json:"rounds,omitempty"`                         // r для RC5/RC6: 0..255; 0 — по умолчанию для шифра
+	KeyLength     int32                  `protobuf:"varint,10,opt,name=key_length,json=keyLength,proto3" json:"key_length,omitempty"` // длина ключа в байтах
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
 func (x *CreateRoomRequest) Reset() {
 	*x = CreateRoomRequest{}
-	mi := &file_chat_proto_msgTypes[4]
+	mi := &file_chat_proto_msgTypes[15]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -258,7 +894,7 @@ func (x *CreateRoomRequest) String() string {
 func (*CreateRoomRequest) ProtoMessage() {}
 
 func (x *CreateRoomRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_chat_proto_msgTypes[4]
+	mi := &file_chat_proto_msgTypes[15]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -271,7 +907,7 @@ func (x *CreateRoomRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use CreateRoomRequest.ProtoReflect.Descriptor instead.
 func (*CreateRoomRequest) Descriptor() ([]byte, []int) {
-	return file_chat_proto_rawDescGZIP(), []int{4}
+	return file_chat_proto_rawDescGZIP(), []int{15}
 }
 
 func (x *CreateRoomRequest) GetAlgorithm() string {
@@ -323,6 +959,27 @@ func (x *CreateRoomRequest) GetRoomName() string {
 	return ""
 }
 
+func (x *CreateRoomRequest) GetWordSize() int32 {
+	if x != nil {
+		return x.WordSize
+	}
+	return 0
+}
+
+func (x *CreateRoomRequest) GetRounds() int32 {
+	if x != nil {
+		return x.Rounds
+	}
+	return 0
+}
+
+func (x *CreateRoomRequest) GetKeyLength() int32 {
+	if x != nil {
+		return x.KeyLength
+	}
+	return 0
+}
+
 type CreateRoomResponse struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	RoomId        string                 `protobuf:"bytes,1,opt,name=room_id,json=roomId,proto3" json:"room_id,omitempty"`
@@ -332,7 +989,7 @@ type CreateRoomResponse struct {
 
 func (x *CreateRoomResponse) Reset() {
 	*x = CreateRoomResponse{}
-	mi := &file_chat_proto_msgTypes[5]
+	mi := &file_chat_proto_msgTypes[16]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -344,7 +1001,7 @@ func (x *CreateRoomResponse) String() string {
 func (*CreateRoomResponse) ProtoMessage() {}
 
 func (x *CreateRoomResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_chat_proto_msgTypes[5]
+	mi := &file_chat_proto_msgTypes[16]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -357,7 +1014,7 @@ func (x *CreateRoomResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use CreateRoomResponse.ProtoReflect.Descriptor instead.
 func (*CreateRoomResponse) Descriptor() ([]byte, []int) {
-	return file_chat_proto_rawDescGZIP(), []int{5}
+	return file_chat_proto_rawDescGZIP(), []int{16}
 }
 
 func (x *CreateRoomResponse) GetRoomId() string {
@@ -376,7 +1033,7 @@ type CloseRoomRequest struct {
 
 func (x *CloseRoomRequest) Reset() {
 	*x = CloseRoomRequest{}
-	mi := &file_chat_proto_msgTypes[6]
+	mi := &file_chat_proto_msgTypes[17]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -388,7 +1045,7 @@ func (x *CloseRoomRequest) String() string {
 func (*CloseRoomRequest) ProtoMessage() {}
 
 func (x *CloseRoomRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_chat_proto_msgTypes[6]
+	mi := &file_chat_proto_msgTypes[17]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -401,7 +1058,7 @@ func (x *CloseRoomRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use CloseRoomRequest.ProtoReflect.Descriptor instead.
 func (*CloseRoomRequest) Descriptor() ([]byte, []int) {
-	return file_chat_proto_rawDescGZIP(), []int{6}
+	return file_chat_proto_rawDescGZIP(), []int{17}
 }
 
 func (x *CloseRoomRequest) GetRoomId() string {
@@ -420,7 +1077,7 @@ type JoinRoomRequest struct {
 
 func (x *JoinRoomRequest) Reset() {
 	*x = JoinRoomRequest{}
-	mi := &file_chat_proto_msgTypes[7]
+	mi := &file_chat_proto_msgTypes[18]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -432,7 +1089,7 @@ func (x *JoinRoomRequest) String() string {
 func (*JoinRoomRequest) ProtoMessage() {}
 
 func (x *JoinRoomRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_chat_proto_msgTypes[7]
+	mi := &file_chat_proto_msgTypes[18]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -445,7 +1102,7 @@ func (x *JoinRoomRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use JoinRoomRequest.ProtoReflect.Descriptor instead.
 func (*JoinRoomRequest) Descriptor() ([]byte, []int) {
-	return file_chat_proto_rawDescGZIP(), []int{7}
+	return file_chat_proto_rawDescGZIP(), []int{18}
 }
 
 func (x *JoinRoomRequest) GetRoomId() string {
@@ -464,7 +1121,7 @@ type LeaveRoomRequest struct {
 
 func (x *LeaveRoomRequest) Reset() {
 	*x = LeaveRoomRequest{}
-	mi := &file_chat_proto_msgTypes[8]
+	mi := &file_chat_proto_msgTypes[19]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -476,7 +1133,7 @@ func (x *LeaveRoomRequest) String() string {
 func (*LeaveRoomRequest) ProtoMessage() {}
 
 func (x *LeaveRoomRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_chat_proto_msgTypes[8]
+	mi := &file_chat_proto_msgTypes[19]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -489,7 +1146,7 @@ func (x *LeaveRoomRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use LeaveRoomRequest.ProtoReflect.Descriptor instead.
 func (*LeaveRoomRequest) Descriptor() ([]byte, []int) {
-	return file_chat_proto_rawDescGZIP(), []int{8}
+	return file_chat_proto_rawDescGZIP(), []int{19}
 }
 
 func (x *LeaveRoomRequest) GetRoomId() string {
@@ -499,28 +1156,199 @@ func (x *LeaveRoomRequest) GetRoomId() string {
 	return ""
 }
 
-type Invitation struct {
+type RoomConfigRequest struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
-	SenderName    string                 `protobuf:"bytes,1,opt,name=sender_name,json=senderName,proto3" json:"sender_name,omitempty"`
-	ReceiverName  string                 `protobuf:"bytes,2,opt,name=receiver_name,json=receiverName,proto3" json:"receiver_name,omitempty"`
-	RoomId        string                 `protobuf:"bytes,3,opt,name=room_id,json=roomId,proto3" json:"room_id,omitempty"`
-	Prime         string                 `protobuf:"bytes,4,opt,name=prime,proto3" json:"prime,omitempty"`                          // p в hex
-	G             string                 `protobuf:"bytes,5,opt,name=g,proto3" json:"g,omitempty"`                                  // g как строка (int -> string)
-	PublicKey     string                 `protobuf:"bytes,6,opt,name=public_key,json=publicKey,proto3" json:"public_key,omitempty"` // A = g^a mod p в hex
-	RoomName      string                 `protobuf:"bytes,7,opt,name=room_name,json=roomName,proto3" json:"room_name,omitempty"`
-	Algorithm     string                 `protobuf:"bytes,8,opt,name=algorithm,proto3" json:"algorithm,omitempty"`
-	Mode          string                 `protobuf:"bytes,9,opt,name=mode,proto3" json:"mode,omitempty"`
-	Padding       string                 `protobuf:"bytes,10,opt,name=padding,proto3" json:"padding,omitempty"`
-	Iv            string                 `protobuf:"bytes,11,opt,name=iv,proto3" json:"iv,omitempty"`
-	RandomDelta   string                 `protobuf:"bytes,12,opt,name=randomDelta,proto3" json:"randomDelta,omitempty"`
-	MessageId     string                 `protobuf:"bytes,13,opt,name=message_id,json=messageId,proto3" json:"message_id,omitempty"`
+	RoomId        string                 `protobuf:"bytes,1,opt,name=room_id,json=roomId,proto3" json:"room_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RoomConfigRequest) Reset() {
+	*x = RoomConfigRequest{}
+	mi := &file_chat_proto_msgTypes[20]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RoomConfigRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RoomConfigRequest) ProtoMessage() {}
+
+func (x *RoomConfigRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_chat_proto_msgTypes[20]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RoomConfigRequest.ProtoReflect.Descriptor instead.
+func (*RoomConfigRequest) Descriptor() ([]byte, []int) {
+	return file_chat_proto_rawDescGZIP(), []int{20}
+}
+
+func (x *RoomConfigRequest) GetRoomId() string {
+	if x != nil {
+		return x.RoomId
+	}
+	return ""
+}
+
+// RoomConfig — параметры шифра комнаты, сохранённые при её создании.
+type RoomConfig struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	RoomId        string                 `protobuf:"bytes,1,opt,name=room_id,json=roomId,proto3" json:"room_id,omitempty"`
+	RoomName      string                 `protobuf:"bytes,2,opt,name=room_name,json=roomName,proto3" json:"room_name,omitempty"`
+	Algorithm     string                 `protobuf:"bytes,3,opt,name=algorithm,proto3" json:"algorithm,omitempty"`
+	Mode          string                 `protobuf:"bytes,4,opt,name=mode,proto3" json:"mode,omitempty"`
+	Padding       string                 `protobuf:"bytes,5,opt,name=padding,proto3" json:"padding,omitempty"`
+	Iv            string                 `protobuf:"bytes,6,opt,name=iv,proto3" json:"iv,omitempty"`
+	RandomDelta   string                 `protobuf:"bytes,7,opt,name=random_delta,json=randomDelta,proto3" json:"random_delta,omitempty"`
+	WordSize      int32                  `protobuf:"varint,8,opt,name=word_size,json=wordSize,proto3" json:"word_size,omitempty"`
+	Rounds        int32                  `protobuf:"varint,9,opt,name=rounds,proto3" json:"rounds,omitempty"`
+	KeyLength     int32                  `protobuf:"varint,10,opt,name=key_length,json=keyLength,proto3" json:"key_length,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
+func (x *RoomConfig) Reset() {
+	*x = RoomConfig{}
+	mi := &file_chat_proto_msgTypes[21]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RoomConfig) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RoomConfig) ProtoMessage() {}
+
+func (x *RoomConfig) ProtoReflect() protoreflect.Message {
+	mi := &file_chat_proto_msgTypes[21]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RoomConfig.ProtoReflect.Descriptor instead.
+func (*RoomConfig) Descriptor() ([]byte, []int) {
+	return file_chat_proto_rawDescGZIP(), []int{21}
+}
+
+func (x *RoomConfig) GetRoomId() string {
+	if x != nil {
+		return x.RoomId
+	}
+	return ""
+}
+
+func (x *RoomConfig) GetRoomName() string {
+	if x != nil {
+		return x.RoomName
+	}
+	return ""
+}
+
+func (x *RoomConfig) GetAlgorithm() string {
+	if x != nil {
+		return x.Algorithm
+	}
+	return ""
+}
+
+func (x *RoomConfig) GetMode() string {
+	if x != nil {
+		return x.Mode
+	}
+	return ""
+}
+
+func (x *RoomConfig) GetPadding() string {
+	if x != nil {
+		return x.Padding
+	}
+	return ""
+}
+
+func (x *RoomConfig) GetIv() string {
+	if x != nil {
+		return x.Iv
+	}
+	return ""
+}
+
+func (x *RoomConfig) GetRandomDelta() string {
+	if x != nil {
+		return x.RandomDelta
+	}
+	return ""
+}
+
+func (x *RoomConfig) GetWordSize() int32 {
+	if x != nil {
+		return x.WordSize
+	}
+	return 0
+}
+
+func (x *RoomConfig) GetRounds() int32 {
+	if x != nil {
+		return x.Rounds
+	}
+	return 0
+}
+
+func (x *RoomConfig) GetKeyLength() int32 {
+	if x != nil {
+		return x.KeyLength
+	}
+	return 0
+}
+
+type Invitation struct {
+	state           protoimpl.MessageState `protogen:"open.v1"`
+	SenderName      string                 `protobuf:"bytes,1,opt,name=sender_name,json=senderName,proto3" json:"sender_name,omitempty"`
+	ReceiverName    string                 `protobuf:"bytes,2,opt,name=receiver_name,json=receiverName,proto3" json:"receiver_name,omitempty"`
+	RoomId          string                 `protobuf:"bytes,3,opt,name=room_id,json=roomId,proto3" json:"room_id,omitempty"`
+	Prime           string                 `protobuf:"bytes,4,opt,name=prime,proto3" json:"prime,omitempty"`                          // p в hex; устарело, вместо него dh_group
+	G               string                 `protobuf:"bytes,5,opt,name=g,proto3" json:"g,omitempty"`                                  // g в hex; устарело, вместо него dh_group
+	PublicKey       string                 `protobuf:"bytes,6,opt,name=public_key,json=publicKey,proto3" json:"public_key,omitempty"` // A = g^a mod p в hex
+	RoomName        string                 `protobuf:"bytes,7,opt,name=room_name,json=roomName,proto3" json:"room_name,omitempty"`
+	Algorithm       string                 `protobuf:"bytes,8,opt,name=algorithm,proto3" json:"algorithm,omitempty"`
+	Mode            string                 `protobuf:"bytes,9,opt,name=mode,proto3" json:"mode,omitempty"`
+	Padding         string                 `protobuf:"bytes,10,opt,name=padding,proto3" json:"padding,omitempty"`
+	Iv              string                 `protobuf:"bytes,11,opt,name=iv,proto3" json:"iv,omitempty"`
+	RandomDelta     string                 `protobuf:"bytes,12,opt,name=randomDelta,proto3" json:"randomDelta,omitempty"`
+	MessageId       string                 `protobuf:"bytes,13,opt,name=message_id,json=messageId,proto3" json:"message_id,omitempty"`
+	WordSize        int32                  `protobuf:"varint,14,opt,name=word_size,json=wordSize,proto3" json:"word_size,omitempty"`
+	Rounds          int32                  `protobuf:"varint,15,opt,name=rounds,proto3" json:"rounds,omitempty"`
+	KeyLength       int32                  `protobuf:"varint,16,opt,name=key_length,json=keyLength,proto3" json:"key_length,omitempty"`
+	DhGroup         string                 `protobuf:"bytes,17,opt,name=dh_group,json=dhGroup,proto3" json:"dh_group,omitempty"`                        // именованная группа DH (RFC 3526 / RFC 7919), p и g не передаются
+	KeyAgreement    string                 `protobuf:"bytes,18,opt,name=key_agreement,json=keyAgreement,proto3" json:"key_agreement,omitempty"`         // "DH" (по умолчанию), "X25519" или "P-256"
+	Signature       []byte                 `protobuf:"bytes,19,opt,name=signature,proto3" json:"signature,omitempty"`                                   // подпись Ed25519 ключом идентичности отправителя
+	DoubleRatchet   bool                   `protobuf:"varint,20,opt,name=double_ratchet,json=doubleRatchet,proto3" json:"double_ratchet,omitempty"`     // сообщения комнаты шифруются ключами Double Ratchet
+	SignedPrekeyId  string                 `protobuf:"bytes,21,opt,name=signed_prekey_id,json=signedPrekeyId,proto3" json:"signed_prekey_id,omitempty"` // ключ комнаты выведен из предключей получателя
+	OneTimePrekeyId string                 `protobuf:"bytes,22,opt,name=one_time_prekey_id,json=oneTimePrekeyId,proto3" json:"one_time_prekey_id,omitempty"`
+	FirstMessage    *ChatMessage           `protobuf:"bytes,23,opt,name=first_message,json=firstMessage,proto3" json:"first_message,omitempty"` // первое сообщение, зашифрованное ключом из предключей
+	unknownFields   protoimpl.UnknownFields
+	sizeCache       protoimpl.SizeCache
+}
+
 func (x *Invitation) Reset() {
 	*x = Invitation{}
-	mi := &file_chat_proto_msgTypes[9]
+	mi := &file_chat_proto_msgTypes[22]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -532,7 +1360,7 @@ func (x *Invitation) String() string {
 func (*Invitation) ProtoMessage() {}
 
 func (x *Invitation) ProtoReflect() protoreflect.Message {
-	mi := &file_chat_proto_msgTypes[9]
+	mi := &file_chat_proto_msgTypes[22]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -545,7 +1373,7 @@ func (x *Invitation) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use Invitation.ProtoReflect.Descriptor instead.
 func (*Invitation) Descriptor() ([]byte, []int) {
-	return file_chat_proto_rawDescGZIP(), []int{9}
+	return file_chat_proto_rawDescGZIP(), []int{22}
 }
 
 func (x *Invitation) GetSenderName() string {
@@ -639,6 +1467,76 @@ func (x *Invitation) GetMessageId() string {
 	return ""
 }
 
+func (x *Invitation) GetWordSize() int32 {
+	if x != nil {
+		return x.WordSize
+	}
+	return 0
+}
+
+func (x *Invitation) GetRounds() int32 {
+	if x != nil {
+		return x.Rounds
+	}
+	return 0
+}
+
+func (x *Invitation) GetKeyLength() int32 {
+	if x != nil {
+		return x.KeyLength
+	}
+	return 0
+}
+
+func (x *Invitation) GetDhGroup() string {
+	if x != nil {
+		return x.DhGroup
+	}
+	return ""
+}
+
+func (x *Invitation) GetKeyAgreement() string {
+	if x != nil {
+		return x.KeyAgreement
+	}
+	return ""
+}
+
+func (x *Invitation) GetSignature() []byte {
+	if x != nil {
+		return x.Signature
+	}
+	return nil
+}
+
+func (x *Invitation) GetDoubleRatchet() bool {
+	if x != nil {
+		return x.DoubleRatchet
+	}
+	return false
+}
+
+func (x *Invitation) GetSignedPrekeyId() string {
+	if x != nil {
+		return x.SignedPrekeyId
+	}
+	return ""
+}
+
+func (x *Invitation) GetOneTimePrekeyId() string {
+	if x != nil {
+		return x.OneTimePrekeyId
+	}
+	return ""
+}
+
+func (x *Invitation) GetFirstMessage() *ChatMessage {
+	if x != nil {
+		return x.FirstMessage
+	}
+	return nil
+}
+
 type InvitationReaction struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	SenderName    string                 `protobuf:"bytes,1,opt,name=sender_name,json=senderName,proto3" json:"sender_name,omitempty"`
@@ -647,13 +1545,14 @@ type InvitationReaction struct {
 	PublicKey     string                 `protobuf:"bytes,4,opt,name=public_key,json=publicKey,proto3" json:"public_key,omitempty"`
 	Accepted      bool                   `protobuf:"varint,5,opt,name=accepted,proto3" json:"accepted,omitempty"`
 	MessageId     string                 `protobuf:"bytes,6,opt,name=message_id,json=messageId,proto3" json:"message_id,omitempty"`
+	Signature     []byte                 `protobuf:"bytes,7,opt,name=signature,proto3" json:"signature,omitempty"` // подпись Ed25519 ключом идентичности отправителя
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
 func (x *InvitationReaction) Reset() {
 	*x = InvitationReaction{}
-	mi := &file_chat_proto_msgTypes[10]
+	mi := &file_chat_proto_msgTypes[23]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -665,7 +1564,7 @@ func (x *InvitationReaction) String() string {
 func (*InvitationReaction) ProtoMessage() {}
 
 func (x *InvitationReaction) ProtoReflect() protoreflect.Message {
-	mi := &file_chat_proto_msgTypes[10]
+	mi := &file_chat_proto_msgTypes[23]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -678,7 +1577,7 @@ func (x *InvitationReaction) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use InvitationReaction.ProtoReflect.Descriptor instead.
 func (*InvitationReaction) Descriptor() ([]byte, []int) {
-	return file_chat_proto_rawDescGZIP(), []int{10}
+	return file_chat_proto_rawDescGZIP(), []int{23}
 }
 
 func (x *InvitationReaction) GetSenderName() string {
@@ -723,6 +1622,13 @@ func (x *InvitationReaction) GetMessageId() string {
 	return ""
 }
 
+func (x *InvitationReaction) GetSignature() []byte {
+	if x != nil {
+		return x.Signature
+	}
+	return nil
+}
+
 type AckRequest struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	MessageId     string                 `protobuf:"bytes,1,opt,name=message_id,json=messageId,proto3" json:"message_id,omitempty"`
@@ -732,7 +1638,7 @@ type AckRequest struct {
 
 func (x *AckRequest) Reset() {
 	*x = AckRequest{}
-	mi := &file_chat_proto_msgTypes[11]
+	mi := &file_chat_proto_msgTypes[24]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -744,7 +1650,7 @@ func (x *AckRequest) String() string {
 func (*AckRequest) ProtoMessage() {}
 
 func (x *AckRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_chat_proto_msgTypes[11]
+	mi := &file_chat_proto_msgTypes[24]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -757,7 +1663,7 @@ func (x *AckRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use AckRequest.ProtoReflect.Descriptor instead.
 func (*AckRequest) Descriptor() ([]byte, []int) {
-	return file_chat_proto_rawDescGZIP(), []int{11}
+	return file_chat_proto_rawDescGZIP(), []int{24}
 }
 
 func (x *AckRequest) GetMessageId() string {
@@ -767,6 +1673,191 @@ func (x *AckRequest) GetMessageId() string {
 	return ""
 }
 
+type SubscribeRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// События с номером не больше этого клиент уже обработал; после переподключения
+	// сервер подтверждает их сам и не отправляет повторно.
+	LastAckedSequence uint64 `protobuf:"varint,1,opt,name=last_acked_sequence,json=lastAckedSequence,proto3" json:"last_acked_sequence,omitempty"`
+	unknownFields     protoimpl.UnknownFields
+	sizeCache         protoimpl.SizeCache
+}
+
+func (x *SubscribeRequest) Reset() {
+	*x = SubscribeRequest{}
+	mi := &file_chat_proto_msgTypes[25]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SubscribeRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SubscribeRequest) ProtoMessage() {}
+
+func (x *SubscribeRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_chat_proto_msgTypes[25]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SubscribeRequest.ProtoReflect.Descriptor instead.
+func (*SubscribeRequest) Descriptor() ([]byte, []int) {
+	return file_chat_proto_rawDescGZIP(), []int{25}
+}
+
+func (x *SubscribeRequest) GetLastAckedSequence() uint64 {
+	if x != nil {
+		return x.LastAckedSequence
+	}
+	return 0
+}
+
+// Event — событие из очереди пользователя.
+type Event struct {
+	state    protoimpl.MessageState `protogen:"open.v1"`
+	Sequence uint64                 `protobuf:"varint,1,opt,name=sequence,proto3" json:"sequence,omitempty"` // номер в потоке JetStream, растёт в порядке доставки
+	// Types that are valid to be assigned to Payload:
+	//
+	//	*Event_Message
+	//	*Event_Invitation
+	//	*Event_Reaction
+	//	*Event_ClearHistory
+	//	*Event_KeyUpdate
+	Payload       isEvent_Payload `protobuf_oneof:"payload"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Event) Reset() {
+	*x = Event{}
+	mi := &file_chat_proto_msgTypes[26]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Event) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Event) ProtoMessage() {}
+
+func (x *Event) ProtoReflect() protoreflect.Message {
+	mi := &file_chat_proto_msgTypes[26]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Event.ProtoReflect.Descriptor instead.
+func (*Event) Descriptor() ([]byte, []int) {
+	return file_chat_proto_rawDescGZIP(), []int{26}
+}
+
+func (x *Event) GetSequence() uint64 {
+	if x != nil {
+		return x.Sequence
+	}
+	return 0
+}
+
+func (x *Event) GetPayload() isEvent_Payload {
+	if x != nil {
+		return x.Payload
+	}
+	return nil
+}
+
+func (x *Event) GetMessage() *ChatMessage {
+	if x != nil {
+		if x, ok := x.Payload.(*Event_Message); ok {
+			return x.Message
+		}
+	}
+	return nil
+}
+
+func (x *Event) GetInvitation() *Invitation {
+	if x != nil {
+		if x, ok := x.Payload.(*Event_Invitation); ok {
+			return x.Invitation
+		}
+	}
+	return nil
+}
+
+func (x *Event) GetReaction() *InvitationReaction {
+	if x != nil {
+		if x, ok := x.Payload.(*Event_Reaction); ok {
+			return x.Reaction
+		}
+	}
+	return nil
+}
+
+func (x *Event) GetClearHistory() *ClearHistoryRequest {
+	if x != nil {
+		if x, ok := x.Payload.(*Event_ClearHistory); ok {
+			return x.ClearHistory
+		}
+	}
+	return nil
+}
+
+func (x *Event) GetKeyUpdate() *UpdateCipherKeyRequest {
+	if x != nil {
+		if x, ok := x.Payload.(*Event_KeyUpdate); ok {
+			return x.KeyUpdate
+		}
+	}
+	return nil
+}
+
+type isEvent_Payload interface {
+	isEvent_Payload()
+}
+
+type Event_Message struct {
+	Message *ChatMessage `protobuf:"bytes,2,opt,name=message,proto3,oneof"`
+}
+
+type Event_Invitation struct {
+	Invitation *Invitation `protobuf:"bytes,3,opt,name=invitation,proto3,oneof"`
+}
+
+type Event_Reaction struct {
+	Reaction *InvitationReaction `protobuf:"bytes,4,opt,name=reaction,proto3,oneof"`
+}
+
+type Event_ClearHistory struct {
+	ClearHistory *ClearHistoryRequest `protobuf:"bytes,5,opt,name=clear_history,json=clearHistory,proto3,oneof"`
+}
+
+type Event_KeyUpdate struct {
+	KeyUpdate *UpdateCipherKeyRequest `protobuf:"bytes,6,opt,name=key_update,json=keyUpdate,proto3,oneof"`
+}
+
+func (*Event_Message) isEvent_Payload() {}
+
+func (*Event_Invitation) isEvent_Payload() {}
+
+func (*Event_Reaction) isEvent_Payload() {}
+
+func (*Event_ClearHistory) isEvent_Payload() {}
+
+func (*Event_KeyUpdate) isEvent_Payload() {}
+
 type ChatMessage struct {
 	state        protoimpl.MessageState `protogen:"open.v1"`
 	MessageId    string                 `protobuf:"bytes,1,opt,name=message_id,json=messageId,proto3" json:"message_id,omitempty"`
@@ -781,13 +1872,15 @@ type ChatMessage struct {
 	//	*ChatMessage_Text
 	//	*ChatMessage_Chunk
 	Payload       isChatMessage_Payload `protobuf_oneof:"payload"`
+	KeyEpoch      int32                 `protobuf:"varint,10,opt,name=key_epoch,json=keyEpoch,proto3" json:"key_epoch,omitempty"` // эпоха ключа комнаты, которым зашифровано сообщение
+	Ratchet       *RatchetHeader        `protobuf:"bytes,11,opt,name=ratchet,proto3" json:"ratchet,omitempty"`                    // заголовок Double Ratchet, если он включён в комнате
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
 func (x *ChatMessage) Reset() {
 	*x = ChatMessage{}
-	mi := &file_chat_proto_msgTypes[12]
+	mi := &file_chat_proto_msgTypes[27]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -799,7 +1892,7 @@ func (x *ChatMessage) String() string {
 func (*ChatMessage) ProtoMessage() {}
 
 func (x *ChatMessage) ProtoReflect() protoreflect.Message {
-	mi := &file_chat_proto_msgTypes[12]
+	mi := &file_chat_proto_msgTypes[27]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -812,7 +1905,7 @@ func (x *ChatMessage) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use ChatMessage.ProtoReflect.Descriptor instead.
 func (*ChatMessage) Descriptor() ([]byte, []int) {
-	return file_chat_proto_rawDescGZIP(), []int{12}
+	return file_chat_proto_rawDescGZIP(), []int{27}
 }
 
 func (x *ChatMessage) GetMessageId() string {
@@ -889,6 +1982,20 @@ func (x *ChatMessage) GetChunk() *FileChunk {
 	return nil
 }
 
+func (x *ChatMessage) GetKeyEpoch() int32 {
+	if x != nil {
+		return x.KeyEpoch
+	}
+	return 0
+}
+
+func (x *ChatMessage) GetRatchet() *RatchetHeader {
+	if x != nil {
+		return x.Ratchet
+	}
+	return nil
+}
+
 type isChatMessage_Payload interface {
 	isChatMessage_Payload()
 }
@@ -898,7 +2005,7 @@ type ChatMessage_Text struct {
 }
 
 type ChatMessage_Chunk struct {
-	// FileHeader file = 9;
+	//    FileHeader file = 9;
 	Chunk *FileChunk `protobuf:"bytes,9,opt,name=chunk,proto3,oneof"`
 }
 
@@ -906,29 +2013,30 @@ func (*ChatMessage_Text) isChatMessage_Payload() {}
 
 func (*ChatMessage_Chunk) isChatMessage_Payload() {}
 
-type ReceiveMessagesRequest struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	UserId        string                 `protobuf:"bytes,1,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`
-	ChatId        string                 `protobuf:"bytes,2,opt,name=chat_id,json=chatId,proto3" json:"chat_id,omitempty"`
-	unknownFields protoimpl.UnknownFields
-	sizeCache     protoimpl.SizeCache
+type RatchetHeader struct {
+	state               protoimpl.MessageState `protogen:"open.v1"`
+	PublicKey           []byte                 `protobuf:"bytes,1,opt,name=public_key,json=publicKey,proto3" json:"public_key,omitempty"`
+	PreviousChainLength uint32                 `protobuf:"varint,2,opt,name=previous_chain_length,json=previousChainLength,proto3" json:"previous_chain_length,omitempty"`
+	MessageNumber       uint32                 `protobuf:"varint,3,opt,name=message_number,json=messageNumber,proto3" json:"message_number,omitempty"`
+	unknownFields       protoimpl.UnknownFields
+	sizeCache           protoimpl.SizeCache
 }
 
-func (x *ReceiveMessagesRequest) Reset() {
-	*x = ReceiveMessagesRequest{}
-	mi := &file_chat_proto_msgTypes[13]
+func (x *RatchetHeader) Reset() {
+	*x = RatchetHeader{}
+	mi := &file_chat_proto_msgTypes[28]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *ReceiveMessagesRequest) String() string {
+func (x *RatchetHeader) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*ReceiveMessagesRequest) ProtoMessage() {}
+func (*RatchetHeader) ProtoMessage() {}
 
-func (x *ReceiveMessagesRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_chat_proto_msgTypes[13]
+func (x *RatchetHeader) ProtoReflect() protoreflect.Message {
+	mi := &file_chat_proto_msgTypes[28]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -939,23 +2047,30 @@ func (x *ReceiveMessagesRequest) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use ReceiveMessagesRequest.ProtoReflect.Descriptor instead.
-func (*ReceiveMessagesRequest) Descriptor() ([]byte, []int) {
-	return file_chat_proto_rawDescGZIP(), []int{13}
+// Deprecated: Use RatchetHeader.ProtoReflect.Descriptor instead.
+func (*RatchetHeader) Descriptor() ([]byte, []int) {
+	return file_chat_proto_rawDescGZIP(), []int{28}
 }
 
-func (x *ReceiveMessagesRequest) GetUserId() string {
+func (x *RatchetHeader) GetPublicKey() []byte {
 	if x != nil {
-		return x.UserId
+		return x.PublicKey
 	}
-	return ""
+	return nil
 }
 
-func (x *ReceiveMessagesRequest) GetChatId() string {
+func (x *RatchetHeader) GetPreviousChainLength() uint32 {
 	if x != nil {
-		return x.ChatId
+		return x.PreviousChainLength
 	}
-	return ""
+	return 0
+}
+
+func (x *RatchetHeader) GetMessageNumber() uint32 {
+	if x != nil {
+		return x.MessageNumber
+	}
+	return 0
 }
 
 type TextPayload struct {
@@ -967,7 +2082,7 @@ type TextPayload struct {
 
 func (x *TextPayload) Reset() {
 	*x = TextPayload{}
-	mi := &file_chat_proto_msgTypes[14]
+	mi := &file_chat_proto_msgTypes[29]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -979,7 +2094,7 @@ func (x *TextPayload) String() string {
 func (*TextPayload) ProtoMessage() {}
 
 func (x *TextPayload) ProtoReflect() protoreflect.Message {
-	mi := &file_chat_proto_msgTypes[14]
+	mi := &file_chat_proto_msgTypes[29]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -992,7 +2107,7 @@ func (x *TextPayload) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use TextPayload.ProtoReflect.Descriptor instead.
 func (*TextPayload) Descriptor() ([]byte, []int) {
-	return file_chat_proto_rawDescGZIP(), []int{14}
+	return file_chat_proto_rawDescGZIP(), []int{29}
 }
 
 func (x *TextPayload) GetContent() string {
@@ -1015,7 +2130,7 @@ type FileChunk struct {
 
 func (x *FileChunk) Reset() {
 	*x = FileChunk{}
-	mi := &file_chat_proto_msgTypes[15]
+	mi := &file_chat_proto_msgTypes[30]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -1027,7 +2142,7 @@ func (x *FileChunk) String() string {
 func (*FileChunk) ProtoMessage() {}
 
 func (x *FileChunk) ProtoReflect() protoreflect.Message {
-	mi := &file_chat_proto_msgTypes[15]
+	mi := &file_chat_proto_msgTypes[30]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1040,7 +2155,7 @@ func (x *FileChunk) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use FileChunk.ProtoReflect.Descriptor instead.
 func (*FileChunk) Descriptor() ([]byte, []int) {
-	return file_chat_proto_rawDescGZIP(), []int{15}
+	return file_chat_proto_rawDescGZIP(), []int{30}
 }
 
 func (x *FileChunk) GetFileId() string {
@@ -1090,7 +2205,7 @@ type ClearHistoryRequest struct {
 
 func (x *ClearHistoryRequest) Reset() {
 	*x = ClearHistoryRequest{}
-	mi := &file_chat_proto_msgTypes[16]
+	mi := &file_chat_proto_msgTypes[31]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -1102,7 +2217,7 @@ func (x *ClearHistoryRequest) String() string {
 func (*ClearHistoryRequest) ProtoMessage() {}
 
 func (x *ClearHistoryRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_chat_proto_msgTypes[16]
+	mi := &file_chat_proto_msgTypes[31]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1115,7 +2230,7 @@ func (x *ClearHistoryRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use ClearHistoryRequest.ProtoReflect.Descriptor instead.
 func (*ClearHistoryRequest) Descriptor() ([]byte, []int) {
-	return file_chat_proto_rawDescGZIP(), []int{16}
+	return file_chat_proto_rawDescGZIP(), []int{31}
 }
 
 func (x *ClearHistoryRequest) GetUserId() string {
@@ -1153,13 +2268,17 @@ type UpdateCipherKeyRequest struct {
 	ChatId        string                 `protobuf:"bytes,3,opt,name=chat_id,json=chatId,proto3" json:"chat_id,omitempty"`
 	PublicKey     string                 `protobuf:"bytes,4,opt,name=public_key,json=publicKey,proto3" json:"public_key,omitempty"` //if "" - delete room
 	MessageId     string                 `protobuf:"bytes,5,opt,name=message_id,json=messageId,proto3" json:"message_id,omitempty"`
+	Epoch         int32                  `protobuf:"varint,6,opt,name=epoch,proto3" json:"epoch,omitempty"`        // номер новой эпохи ключа
+	Answer        bool                   `protobuf:"varint,7,opt,name=answer,proto3" json:"answer,omitempty"`      // ответ на предложение сменить ключ
+	Signature     []byte                 `protobuf:"bytes,8,opt,name=signature,proto3" json:"signature,omitempty"` // подпись Ed25519 ключом идентичности отправителя
+	SenderName    string                 `protobuf:"bytes,9,opt,name=sender_name,json=senderName,proto3" json:"sender_name,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
 func (x *UpdateCipherKeyRequest) Reset() {
 	*x = UpdateCipherKeyRequest{}
-	mi := &file_chat_proto_msgTypes[17]
+	mi := &file_chat_proto_msgTypes[32]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -1171,7 +2290,7 @@ func (x *UpdateCipherKeyRequest) String() string {
 func (*UpdateCipherKeyRequest) ProtoMessage() {}
 
 func (x *UpdateCipherKeyRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_chat_proto_msgTypes[17]
+	mi := &file_chat_proto_msgTypes[32]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1184,7 +2303,7 @@ func (x *UpdateCipherKeyRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use UpdateCipherKeyRequest.ProtoReflect.Descriptor instead.
 func (*UpdateCipherKeyRequest) Descriptor() ([]byte, []int) {
-	return file_chat_proto_rawDescGZIP(), []int{17}
+	return file_chat_proto_rawDescGZIP(), []int{32}
 }
 
 func (x *UpdateCipherKeyRequest) GetUserId() string {
@@ -1222,6 +2341,34 @@ func (x *UpdateCipherKeyRequest) GetMessageId() string {
 	return ""
 }
 
+func (x *UpdateCipherKeyRequest) GetEpoch() int32 {
+	if x != nil {
+		return x.Epoch
+	}
+	return 0
+}
+
+func (x *UpdateCipherKeyRequest) GetAnswer() bool {
+	if x != nil {
+		return x.Answer
+	}
+	return false
+}
+
+func (x *UpdateCipherKeyRequest) GetSignature() []byte {
+	if x != nil {
+		return x.Signature
+	}
+	return nil
+}
+
+func (x *UpdateCipherKeyRequest) GetSenderName() string {
+	if x != nil {
+		return x.SenderName
+	}
+	return ""
+}
+
 var File_chat_proto protoreflect.FileDescriptor
 
 const file_chat_proto_rawDesc = "" +
@@ -1230,16 +2377,61 @@ const file_chat_proto_rawDesc = "" +
 	"chat.proto\x12\x04chat\x1a\x1bgoogle/protobuf/empty.proto\x1a\x1fgoogle/protobuf/timestamp.proto\"I\n" +
 	"\x0fRegisterRequest\x12\x1a\n" +
 	"\busername\x18\x01 \x01(\tR\busername\x12\x1a\n" +
-	"\bpassword\x18\x02 \x01(\tR\bpassword\"@\n" +
+	"\bpassword\x18\x02 \x01(\tR\bpassword\"\xa0\x01\n" +
 	"\x10RegisterResponse\x12\x14\n" +
 	"\x05token\x18\x01 \x01(\tR\x05token\x12\x16\n" +
-	"\x06userID\x18\x02 \x01(\tR\x06userID\"F\n" +
+	"\x06userID\x18\x02 \x01(\tR\x06userID\x12#\n" +
+	"\rrefresh_token\x18\x03 \x01(\tR\frefreshToken\x129\n" +
+	"\n" +
+	"expires_at\x18\x04 \x01(\v2\x1a.google.protobuf.TimestampR\texpiresAt\"F\n" +
 	"\fLoginRequest\x12\x1a\n" +
 	"\busername\x18\x01 \x01(\tR\busername\x12\x1a\n" +
-	"\bpassword\x18\x02 \x01(\tR\bpassword\"=\n" +
+	"\bpassword\x18\x02 \x01(\tR\bpassword\"\x9d\x01\n" +
 	"\rLoginResponse\x12\x14\n" +
 	"\x05token\x18\x01 \x01(\tR\x05token\x12\x16\n" +
-	"\x06userID\x18\x02 \x01(\tR\x06userID\"\xc4\x01\n" +
+	"\x06userID\x18\x02 \x01(\tR\x06userID\x12#\n" +
+	"\rrefresh_token\x18\x03 \x01(\tR\frefreshToken\x129\n" +
+	"\n" +
+	"expires_at\x18\x04 \x01(\v2\x1a.google.protobuf.TimestampR\texpiresAt\"5\n" +
+	"\x0eRefreshRequest\x12#\n" +
+	"\rrefresh_token\x18\x01 \x01(\tR\frefreshToken\"\x87\x01\n" +
+	"\x0fRefreshResponse\x12\x14\n" +
+	"\x05token\x18\x01 \x01(\tR\x05token\x12#\n" +
+	"\rrefresh_token\x18\x02 \x01(\tR\frefreshToken\x129\n" +
+	"\n" +
+	"expires_at\x18\x03 \x01(\v2\x1a.google.protobuf.TimestampR\texpiresAt\"4\n" +
+	"\rLogoutRequest\x12#\n" +
+	"\rrefresh_token\x18\x01 \x01(\tR\frefreshToken\"H\n" +
+	"\vIdentityKey\x12\x1a\n" +
+	"\busername\x18\x01 \x01(\tR\busername\x12\x1d\n" +
+	"\n" +
+	"public_key\x18\x02 \x01(\fR\tpublicKey\"0\n" +
+	"\x12IdentityKeyRequest\x12\x1a\n" +
+	"\busername\x18\x01 \x01(\tR\busername\"\x9c\x01\n" +
+	"\x06Prekey\x12\x15\n" +
+	"\x06key_id\x18\x01 \x01(\tR\x05keyId\x12#\n" +
+	"\rkey_agreement\x18\x02 \x01(\tR\fkeyAgreement\x12\x19\n" +
+	"\bdh_group\x18\x03 \x01(\tR\adhGroup\x12\x1d\n" +
+	"\n" +
+	"public_key\x18\x04 \x01(\tR\tpublicKey\x12\x1c\n" +
+	"\tsignature\x18\x05 \x01(\fR\tsignature\"\x83\x01\n" +
+	"\x14UploadPrekeysRequest\x123\n" +
+	"\x0esigned_prekeys\x18\x01 \x03(\v2\f.chat.PrekeyR\rsignedPrekeys\x126\n" +
+	"\x10one_time_prekeys\x18\x02 \x03(\v2\f.chat.PrekeyR\x0eoneTimePrekeys\"s\n" +
+	"\vPrekeyCount\x12#\n" +
+	"\rkey_agreement\x18\x01 \x01(\tR\fkeyAgreement\x12\x19\n" +
+	"\bdh_group\x18\x02 \x01(\tR\adhGroup\x12$\n" +
+	"\x0eone_time_count\x18\x03 \x01(\x05R\foneTimeCount\"9\n" +
+	"\fPrekeyStatus\x12)\n" +
+	"\x06counts\x18\x01 \x03(\v2\x11.chat.PrekeyCountR\x06counts\"q\n" +
+	"\x13PrekeyBundleRequest\x12\x1a\n" +
+	"\busername\x18\x01 \x01(\tR\busername\x12#\n" +
+	"\rkey_agreement\x18\x02 \x01(\tR\fkeyAgreement\x12\x19\n" +
+	"\bdh_group\x18\x03 \x01(\tR\adhGroup\"\x93\x01\n" +
+	"\fPrekeyBundle\x12\x1a\n" +
+	"\busername\x18\x01 \x01(\tR\busername\x121\n" +
+	"\rsigned_prekey\x18\x02 \x01(\v2\f.chat.PrekeyR\fsignedPrekey\x124\n" +
+	"\x0fone_time_prekey\x18\x03 \x01(\v2\f.chat.PrekeyR\roneTimePrekey\"\x98\x02\n" +
 	"\x11CreateRoomRequest\x12\x1c\n" +
 	"\talgorithm\x18\x01 \x01(\tR\talgorithm\x12\x12\n" +
 	"\x04mode\x18\x02 \x01(\tR\x04mode\x12\x18\n" +
@@ -1247,7 +2439,12 @@ const file_chat_proto_rawDesc = "" +
 	"\x05prime\x18\x04 \x01(\tR\x05prime\x12\x0e\n" +
 	"\x02iv\x18\x05 \x01(\tR\x02iv\x12 \n" +
 	"\vrandomDelta\x18\x06 \x01(\tR\vrandomDelta\x12\x1b\n" +
-	"\troom_name\x18\a \x01(\tR\broomName\"-\n" +
+	"\troom_name\x18\a \x01(\tR\broomName\x12\x1b\n" +
+	"\tword_size\x18\b \x01(\x05R\bwordSize\x12\x16\n" +
+	"\x06rounds\x18\t \x01(\x05R\x06rounds\x12\x1d\n" +
+	"\n" +
+	"key_length\x18\n" +
+	" \x01(\x05R\tkeyLength\"-\n" +
 	"\x12CreateRoomResponse\x12\x17\n" +
 	"\aroom_id\x18\x01 \x01(\tR\x06roomId\"+\n" +
 	"\x10CloseRoomRequest\x12\x17\n" +
@@ -1255,7 +2452,23 @@ const file_chat_proto_rawDesc = "" +
 	"\x0fJoinRoomRequest\x12\x17\n" +
 	"\aroom_id\x18\x01 \x01(\tR\x06roomId\"+\n" +
 	"\x10LeaveRoomRequest\x12\x17\n" +
-	"\aroom_id\x18\x01 \x01(\tR\x06roomId\"\xe8\x02\n" +
+	"\aroom_id\x18\x01 \x01(\tR\x06roomId\",\n" +
+	"\x11RoomConfigRequest\x12\x17\n" +
+	"\aroom_id\x18\x01 \x01(\tR\x06roomId\"\x95\x02\n" +
+	"\n" +
+	"RoomConfig\x12\x17\n" +
+	"\aroom_id\x18\x01 \x01(\tR\x06roomId\x12\x1b\n" +
+	"\troom_name\x18\x02 \x01(\tR\broomName\x12\x1c\n" +
+	"\talgorithm\x18\x03 \x01(\tR\talgorithm\x12\x12\n" +
+	"\x04mode\x18\x04 \x01(\tR\x04mode\x12\x18\n" +
+	"\apadding\x18\x05 \x01(\tR\apadding\x12\x0e\n" +
+	"\x02iv\x18\x06 \x01(\tR\x02iv\x12!\n" +
+	"\frandom_delta\x18\a \x01(\tR\vrandomDelta\x12\x1b\n" +
+	"\tword_size\x18\b \x01(\x05R\bwordSize\x12\x16\n" +
+	"\x06rounds\x18\t \x01(\x05R\x06rounds\x12\x1d\n" +
+	"\n" +
+	"key_length\x18\n" +
+	" \x01(\x05R\tkeyLength\"\xd0\x05\n" +
 	"\n" +
 	"Invitation\x12\x1f\n" +
 	"\vsender_name\x18\x01 \x01(\tR\n" +
@@ -1274,7 +2487,18 @@ const file_chat_proto_rawDesc = "" +
 	"\x02iv\x18\v \x01(\tR\x02iv\x12 \n" +
 	"\vrandomDelta\x18\f \x01(\tR\vrandomDelta\x12\x1d\n" +
 	"\n" +
-	"message_id\x18\r \x01(\tR\tmessageId\"\xcd\x01\n" +
+	"message_id\x18\r \x01(\tR\tmessageId\x12\x1b\n" +
+	"\tword_size\x18\x0e \x01(\x05R\bwordSize\x12\x16\n" +
+	"\x06rounds\x18\x0f \x01(\x05R\x06rounds\x12\x1d\n" +
+	"\n" +
+	"key_length\x18\x10 \x01(\x05R\tkeyLength\x12\x19\n" +
+	"\bdh_group\x18\x11 \x01(\tR\adhGroup\x12#\n" +
+	"\rkey_agreement\x18\x12 \x01(\tR\fkeyAgreement\x12\x1c\n" +
+	"\tsignature\x18\x13 \x01(\fR\tsignature\x12%\n" +
+	"\x0edouble_ratchet\x18\x14 \x01(\bR\rdoubleRatchet\x12(\n" +
+	"\x10signed_prekey_id\x18\x15 \x01(\tR\x0esignedPrekeyId\x12+\n" +
+	"\x12one_time_prekey_id\x18\x16 \x01(\tR\x0foneTimePrekeyId\x126\n" +
+	"\rfirst_message\x18\x17 \x01(\v2\x11.chat.ChatMessageR\ffirstMessage\"\xeb\x01\n" +
 	"\x12InvitationReaction\x12\x1f\n" +
 	"\vsender_name\x18\x01 \x01(\tR\n" +
 	"senderName\x12#\n" +
@@ -1284,11 +2508,25 @@ const file_chat_proto_rawDesc = "" +
 	"public_key\x18\x04 \x01(\tR\tpublicKey\x12\x1a\n" +
 	"\baccepted\x18\x05 \x01(\bR\baccepted\x12\x1d\n" +
 	"\n" +
-	"message_id\x18\x06 \x01(\tR\tmessageId\"+\n" +
+	"message_id\x18\x06 \x01(\tR\tmessageId\x12\x1c\n" +
+	"\tsignature\x18\a \x01(\fR\tsignature\"+\n" +
 	"\n" +
 	"AckRequest\x12\x1d\n" +
 	"\n" +
-	"message_id\x18\x01 \x01(\tR\tmessageId\"\xe0\x02\n" +
+	"message_id\x18\x01 \x01(\tR\tmessageId\"B\n" +
+	"\x10SubscribeRequest\x12.\n" +
+	"\x13last_acked_sequence\x18\x01 \x01(\x04R\x11lastAckedSequence\"\xca\x02\n" +
+	"\x05Event\x12\x1a\n" +
+	"\bsequence\x18\x01 \x01(\x04R\bsequence\x12-\n" +
+	"\amessage\x18\x02 \x01(\v2\x11.chat.ChatMessageH\x00R\amessage\x122\n" +
+	"\n" +
+	"invitation\x18\x03 \x01(\v2\x10.chat.InvitationH\x00R\n" +
+	"invitation\x126\n" +
+	"\breaction\x18\x04 \x01(\v2\x18.chat.InvitationReactionH\x00R\breaction\x12@\n" +
+	"\rclear_history\x18\x05 \x01(\v2\x19.chat.ClearHistoryRequestH\x00R\fclearHistory\x12=\n" +
+	"\n" +
+	"key_update\x18\x06 \x01(\v2\x1c.chat.UpdateCipherKeyRequestH\x00R\tkeyUpdateB\t\n" +
+	"\apayload\"\xac\x03\n" +
 	"\vChatMessage\x12\x1d\n" +
 	"\n" +
 	"message_id\x18\x01 \x01(\tR\tmessageId\x12\x1b\n" +
@@ -1301,11 +2539,16 @@ const file_chat_proto_rawDesc = "" +
 	"\achat_id\x18\x06 \x01(\tR\x06chatId\x128\n" +
 	"\ttimestamp\x18\a \x01(\v2\x1a.google.protobuf.TimestampR\ttimestamp\x12'\n" +
 	"\x04text\x18\b \x01(\v2\x11.chat.TextPayloadH\x00R\x04text\x12'\n" +
-	"\x05chunk\x18\t \x01(\v2\x0f.chat.FileChunkH\x00R\x05chunkB\t\n" +
-	"\apayload\"J\n" +
-	"\x16ReceiveMessagesRequest\x12\x17\n" +
-	"\auser_id\x18\x01 \x01(\tR\x06userId\x12\x17\n" +
-	"\achat_id\x18\x02 \x01(\tR\x06chatId\"'\n" +
+	"\x05chunk\x18\t \x01(\v2\x0f.chat.FileChunkH\x00R\x05chunk\x12\x1b\n" +
+	"\tkey_epoch\x18\n" +
+	" \x01(\x05R\bkeyEpoch\x12-\n" +
+	"\aratchet\x18\v \x01(\v2\x13.chat.RatchetHeaderR\aratchetB\t\n" +
+	"\apayload\"\x89\x01\n" +
+	"\rRatchetHeader\x12\x1d\n" +
+	"\n" +
+	"public_key\x18\x01 \x01(\fR\tpublicKey\x122\n" +
+	"\x15previous_chain_length\x18\x02 \x01(\rR\x13previousChainLength\x12%\n" +
+	"\x0emessage_number\x18\x03 \x01(\rR\rmessageNumber\"'\n" +
 	"\vTextPayload\x12\x18\n" +
 	"\acontent\x18\x01 \x01(\tR\acontent\"\xa3\x01\n" +
 	"\tFileChunk\x12\x17\n" +
@@ -1321,7 +2564,7 @@ const file_chat_proto_rawDesc = "" +
 	"\tuser_name\x18\x02 \x01(\tR\buserName\x12\x17\n" +
 	"\achat_id\x18\x03 \x01(\tR\x06chatId\x12\x1d\n" +
 	"\n" +
-	"message_id\x18\x04 \x01(\tR\tmessageId\"\xa5\x01\n" +
+	"message_id\x18\x04 \x01(\tR\tmessageId\"\x92\x02\n" +
 	"\x16UpdateCipherKeyRequest\x12\x17\n" +
 	"\auser_id\x18\x01 \x01(\tR\x06userId\x12\x1b\n" +
 	"\tuser_name\x18\x02 \x01(\tR\buserName\x12\x17\n" +
@@ -1329,25 +2572,34 @@ const file_chat_proto_rawDesc = "" +
 	"\n" +
 	"public_key\x18\x04 \x01(\tR\tpublicKey\x12\x1d\n" +
 	"\n" +
-	"message_id\x18\x05 \x01(\tR\tmessageId2\x9b\b\n" +
+	"message_id\x18\x05 \x01(\tR\tmessageId\x12\x14\n" +
+	"\x05epoch\x18\x06 \x01(\x05R\x05epoch\x12\x16\n" +
+	"\x06answer\x18\a \x01(\bR\x06answer\x12\x1c\n" +
+	"\tsignature\x18\b \x01(\fR\tsignature\x12\x1f\n" +
+	"\vsender_name\x18\t \x01(\tR\n" +
+	"senderName2\xd9\t\n" +
 	"\vChatService\x129\n" +
 	"\bRegister\x12\x15.chat.RegisterRequest\x1a\x16.chat.RegisterResponse\x120\n" +
-	"\x05Login\x12\x12.chat.LoginRequest\x1a\x13.chat.LoginResponse\x12?\n" +
+	"\x05Login\x12\x12.chat.LoginRequest\x1a\x13.chat.LoginResponse\x126\n" +
+	"\aRefresh\x12\x14.chat.RefreshRequest\x1a\x15.chat.RefreshResponse\x125\n" +
+	"\x06Logout\x12\x13.chat.LogoutRequest\x1a\x16.google.protobuf.Empty\x12?\n" +
+	"\x12PublishIdentityKey\x12\x11.chat.IdentityKey\x1a\x16.google.protobuf.Empty\x12=\n" +
+	"\x0eGetIdentityKey\x12\x18.chat.IdentityKeyRequest\x1a\x11.chat.IdentityKey\x12?\n" +
+	"\rUploadPrekeys\x12\x1a.chat.UploadPrekeysRequest\x1a\x12.chat.PrekeyStatus\x12@\n" +
+	"\x0fGetPrekeyBundle\x12\x19.chat.PrekeyBundleRequest\x1a\x12.chat.PrekeyBundle\x12?\n" +
 	"\n" +
 	"CreateRoom\x12\x17.chat.CreateRoomRequest\x1a\x18.chat.CreateRoomResponse\x12;\n" +
 	"\tCloseRoom\x12\x16.chat.CloseRoomRequest\x1a\x16.google.protobuf.Empty\x129\n" +
 	"\bJoinRoom\x12\x15.chat.JoinRoomRequest\x1a\x16.google.protobuf.Empty\x12;\n" +
-	"\tLeaveRoom\x12\x16.chat.LeaveRoomRequest\x1a\x16.google.protobuf.Empty\x128\n" +
-	"\vSendMessage\x12\x11.chat.ChatMessage\x1a\x16.google.protobuf.Empty\x12A\n" +
-	"\x0eReceiveMessage\x12\x1c.chat.ReceiveMessagesRequest\x1a\x11.chat.ChatMessage\x126\n" +
+	"\tLeaveRoom\x12\x16.chat.LeaveRoomRequest\x1a\x16.google.protobuf.Empty\x12:\n" +
+	"\rGetRoomConfig\x12\x17.chat.RoomConfigRequest\x1a\x10.chat.RoomConfig\x128\n" +
+	"\vSendMessage\x12\x11.chat.ChatMessage\x1a\x16.google.protobuf.Empty\x126\n" +
 	"\n" +
-	"InviteUser\x12\x10.chat.Invitation\x1a\x16.google.protobuf.Empty\x12=\n" +
-	"\x11ReceiveInvitation\x12\x16.google.protobuf.Empty\x1a\x10.chat.Invitation\x12E\n" +
-	"\x11ReactToInvitation\x12\x18.chat.InvitationReaction\x1a\x16.google.protobuf.Empty\x12M\n" +
-	"\x19ReceiveInvitationReaction\x12\x16.google.protobuf.Empty\x1a\x18.chat.InvitationReaction\x12E\n" +
-	"\x10ClearChatHistory\x12\x19.chat.ClearHistoryRequest\x1a\x16.google.protobuf.Empty\x12Q\n" +
-	"\x19ReceiveChatHistoryRequest\x12\x19.chat.ClearHistoryRequest\x1a\x19.chat.ClearHistoryRequest\x12O\n" +
-	"\x17UpdateOrDeleteCipherKey\x12\x1c.chat.UpdateCipherKeyRequest\x1a\x16.google.protobuf.Empty\x124\n" +
+	"InviteUser\x12\x10.chat.Invitation\x1a\x16.google.protobuf.Empty\x12E\n" +
+	"\x11ReactToInvitation\x12\x18.chat.InvitationReaction\x1a\x16.google.protobuf.Empty\x12E\n" +
+	"\x10ClearChatHistory\x12\x19.chat.ClearHistoryRequest\x1a\x16.google.protobuf.Empty\x12O\n" +
+	"\x17UpdateOrDeleteCipherKey\x12\x1c.chat.UpdateCipherKeyRequest\x1a\x16.google.protobuf.Empty\x122\n" +
+	"\tSubscribe\x12\x16.chat.SubscribeRequest\x1a\v.chat.Event0\x01\x124\n" +
 	"\bAckEvent\x12\x10.chat.AckRequest\x1a\x16.google.protobuf.EmptyB\x15Z\x13proto/chatpb;chatpbb\x06proto3"
 
 var (
@@ -1362,70 +2614,108 @@ func file_chat_proto_rawDescGZIP() []byte {
 	return file_chat_proto_rawDescData
 }
 
-var file_chat_proto_msgTypes = make([]protoimpl.MessageInfo, 18)
+var file_chat_proto_msgTypes = make([]protoimpl.MessageInfo, 33)
 var file_chat_proto_goTypes = []any{
 	(*RegisterRequest)(nil),        // 0: chat.RegisterRequest
 	(*RegisterResponse)(nil),       // 1: chat.RegisterResponse
 	(*LoginRequest)(nil),           // 2: chat.LoginRequest
 	(*LoginResponse)(nil),          // 3: chat.LoginResponse
-	(*CreateRoomRequest)(nil),      // 4: chat.CreateRoomRequest
-	(*CreateRoomResponse)(nil),     // 5: chat.CreateRoomResponse
-	(*CloseRoomRequest)(nil),       // 6: chat.CloseRoomRequest
-	(*JoinRoomRequest)(nil),        // 7: chat.JoinRoomRequest
-	(*LeaveRoomRequest)(nil),       // 8: chat.LeaveRoomRequest
-	(*Invitation)(nil),             // 9: chat.Invitation
-	(*InvitationReaction)(nil),     // 10: chat.InvitationReaction
-	(*AckRequest)(nil),             // 11: chat.AckRequest
-	(*ChatMessage)(nil),            // 12: chat.ChatMessage
-	(*ReceiveMessagesRequest)(nil), // 13: chat.ReceiveMessagesRequest
-	(*TextPayload)(nil),            // 14: chat.TextPayload
-	(*FileChunk)(nil),              // 15: chat.FileChunk
-	(*ClearHistoryRequest)(nil),    // 16: chat.ClearHistoryRequest
-	(*UpdateCipherKeyRequest)(nil), // 17: chat.UpdateCipherKeyRequest
-	(*timestamppb.Timestamp)(nil),  // 18: google.protobuf.Timestamp
-	(*emptypb.Empty)(nil),          // 19: google.protobuf.Empty
+	(*RefreshRequest)(nil),         // 4: chat.RefreshRequest
+	(*RefreshResponse)(nil),        // 5: chat.RefreshResponse
+	(*LogoutRequest)(nil),          // 6: chat.LogoutRequest
+	(*IdentityKey)(nil),            // 7: chat.IdentityKey
+	(*IdentityKeyRequest)(nil),     // 8: chat.IdentityKeyRequest
+	(*Prekey)(nil),                 // 9: chat.Prekey
+	(*UploadPrekeysRequest)(nil),   // 10: chat.UploadPrekeysRequest
+	(*PrekeyCount)(nil),            // 11: chat.PrekeyCount
+	(*PrekeyStatus)(nil),           // 12: chat.PrekeyStatus
+	(*PrekeyBundleRequest)(nil),    // 13: chat.PrekeyBundleRequest
+	(*PrekeyBundle)(nil),           // 14: chat.PrekeyBundle
+	(*CreateRoomRequest)(nil),      // 15: chat.CreateRoomRequest
+	(*CreateRoomResponse)(nil),     // 16: chat.CreateRoomResponse
+	(*CloseRoomRequest)(nil),       // 17: chat.CloseRoomRequest
+	(*JoinRoomRequest)(nil),        // 18: chat.JoinRoomRequest
+	(*LeaveRoomRequest)(nil),       // 19: chat.LeaveRoomRequest
+	(*RoomConfigRequest)(nil),      // 20: chat.RoomConfigRequest
+	(*RoomConfig)(nil),             // 21: chat.RoomConfig
+	(*Invitation)(nil),             // 22: chat.Invitation
+	(*InvitationReaction)(nil),     // 23: chat.InvitationReaction
+	(*AckRequest)(nil),             // 24: chat.AckRequest
+	(*SubscribeRequest)(nil),       // 25: chat.SubscribeRequest
+	(*Event)(nil),                  // 26: chat.Event
+	(*ChatMessage)(nil),            // 27: chat.ChatMessage
+	(*RatchetHeader)(nil),          // 28: chat.RatchetHeader
+	(*TextPayload)(nil),            // 29: chat.TextPayload
+	(*FileChunk)(nil),              // 30: chat.FileChunk
+	(*ClearHistoryRequest)(nil),    // 31: chat.ClearHistoryRequest
+	(*UpdateCipherKeyRequest)(nil), // 32: chat.UpdateCipherKeyRequest
+	(*timestamppb.Timestamp)(nil),  // 33: google.protobuf.Timestamp
+	(*emptypb.Empty)(nil),          // 34: google.protobuf.Empty
 }
 var file_chat_proto_depIdxs = []int32{
-	18, // 0: chat.ChatMessage.timestamp:type_name -> google.protobuf.Timestamp
-	14, // 1: chat.ChatMessage.text:type_name -> chat.TextPayload
-	15, // 2: chat.ChatMessage.chunk:type_name -> chat.FileChunk
-	0,  // 3: chat.ChatService.Register:input_type -> chat.RegisterRequest
-	2,  // 4: chat.ChatService.Login:input_type -> chat.LoginRequest
-	4,  // 5: chat.ChatService.CreateRoom:input_type -> chat.CreateRoomRequest
-	6,  // 6: chat.ChatService.CloseRoom:input_type -> chat.CloseRoomRequest
-	7,  // 7: chat.ChatService.JoinRoom:input_type -> chat.JoinRoomRequest
-	8,  // 8: chat.ChatService.LeaveRoom:input_type -> chat.LeaveRoomRequest
-	12, // 9: chat.ChatService.SendMessage:input_type -> chat.ChatMessage
-	13, // 10: chat.ChatService.ReceiveMessage:input_type -> chat.ReceiveMessagesRequest
-	9,  // 11: chat.ChatService.InviteUser:input_type -> chat.Invitation
-	19, // 12: chat.ChatService.ReceiveInvitation:input_type -> google.protobuf.Empty
-	10, // 13: chat.ChatService.ReactToInvitation:input_type -> chat.InvitationReaction
-	19, // 14: chat.ChatService.ReceiveInvitationReaction:input_type -> google.protobuf.Empty
-	16, // 15: chat.ChatService.ClearChatHistory:input_type -> chat.ClearHistoryRequest
-	16, // 16: chat.ChatService.ReceiveChatHistoryRequest:input_type -> chat.ClearHistoryRequest
-	17, // 17: chat.ChatService.UpdateOrDeleteCipherKey:input_type -> chat.UpdateCipherKeyRequest
-	11, // 18: chat.ChatService.AckEvent:input_type -> chat.AckRequest
-	1,  // 19: chat.ChatService.Register:output_type -> chat.RegisterResponse
-	3,  // 20: chat.ChatService.Login:output_type -> chat.LoginResponse
-	5,  // 21: chat.ChatService.CreateRoom:output_type -> chat.CreateRoomResponse
-	19, // 22: chat.ChatService.CloseRoom:output_type -> google.protobuf.Empty
-	19, // 23: chat.ChatService.JoinRoom:output_type -> google.protobuf.Empty
-	19, // 24: chat.ChatService.LeaveRoom:output_type -> google.protobuf.Empty
-	19, // 25: chat.ChatService.SendMessage:output_type -> google.protobuf.Empty
-	12, // 26: chat.ChatService.ReceiveMessage:output_type -> chat.ChatMessage
-	19, // 27: chat.ChatService.InviteUser:output_type -> google.protobuf.Empty
-	9,  // 28: chat.ChatService.ReceiveInvitation:output_type -> chat.Invitation
-	19, // 29: chat.ChatService.ReactToInvitation:output_type -> google.protobuf.Empty
-	10, // 30: chat.ChatService.ReceiveInvitationReaction:output_type -> chat.InvitationReaction
-	19, // 31: chat.ChatService.ClearChatHistory:output_type -> google.protobuf.Empty
-	16, // 32: chat.ChatService.ReceiveChatHistoryRequest:output_type -> chat.ClearHistoryRequest
-	19, // 33: chat.ChatService.UpdateOrDeleteCipherKey:output_type -> google.protobuf.Empty
-	19, // 34: chat.ChatService.AckEvent:output_type -> google.protobuf.Empty
-	19, // [19:35] is the sub-list for method output_type
-	3,  // [3:19] is the sub-list for method input_type
-	3,  // [3:3] is the sub-list for extension type_name
-	3,  // [3:3] is the sub-list for extension extendee
-	0,  // [0:3] is the sub-list for field type_name
+	33, // 0: chat.RegisterResponse.expires_at:type_name -> google.protobuf.Timestamp
+	33, // 1: chat.LoginResponse.expires_at:type_name -> google.protobuf.Timestamp
+	33, // 2: chat.RefreshResponse.expires_at:type_name -> google.protobuf.Timestamp
+	9,  // 3: chat.UploadPrekeysRequest.signed_prekeys:type_name -> chat.Prekey
+	9,  // 4: chat.UploadPrekeysRequest.one_time_prekeys:type_name -> chat.Prekey
+	11, // 5: chat.PrekeyStatus.counts:type_name -> chat.PrekeyCount
+	9,  // 6: chat.PrekeyBundle.signed_prekey:type_name -> chat.Prekey
+	9,  // 7: chat.PrekeyBundle.one_time_prekey:type_name -> chat.Prekey
+	27, // 8: chat.Invitation.first_message:type_name -> chat.ChatMessage
+	27, // 9: chat.Event.message:type_name -> chat.ChatMessage
+	22, // 10: chat.Event.invitation:type_name -> chat.Invitation
+	23, // 11: chat.Event.reaction:type_name -> chat.InvitationReaction
+	31, // 12: chat.Event.clear_history:type_name -> chat.ClearHistoryRequest
+	32, // 13: chat.Event.key_update:type_name -> chat.UpdateCipherKeyRequest
+	33, // 14: chat.ChatMessage.timestamp:type_name -> google.protobuf.Timestamp
+	29, // 15: chat.ChatMessage.text:type_name -> chat.TextPayload
+	30, // 16: chat.ChatMessage.chunk:type_name -> chat.FileChunk
+	28, // 17: chat.ChatMessage.ratchet:type_name -> chat.RatchetHeader
+	0,  // 18: chat.ChatService.Register:input_type -> chat.RegisterRequest
+	2,  // 19: chat.ChatService.Login:input_type -> chat.LoginRequest
+	4,  // 20: chat.ChatService.Refresh:input_type -> chat.RefreshRequest
+	6,  // 21: chat.ChatService.Logout:input_type -> chat.LogoutRequest
+	7,  // 22: chat.ChatService.PublishIdentityKey:input_type -> chat.IdentityKey
+	8,  // 23: chat.ChatService.GetIdentityKey:input_type -> chat.IdentityKeyRequest
+	10, // 24: chat.ChatService.UploadPrekeys:input_type -> chat.UploadPrekeysRequest
+	13, // 25: chat.ChatService.GetPrekeyBundle:input_type -> chat.PrekeyBundleRequest
+	15, // 26: chat.ChatService.CreateRoom:input_type -> chat.CreateRoomRequest
+	17, // 27: chat.ChatService.CloseRoom:input_type -> chat.CloseRoomRequest
+	18, // 28: chat.ChatService.JoinRoom:input_type -> chat.JoinRoomRequest
+	19, // 29: chat.ChatService.LeaveRoom:input_type -> chat.LeaveRoomRequest
+	20, // 30: chat.ChatService.GetRoomConfig:input_type -> chat.RoomConfigRequest
+	27, // 31: chat.ChatService.SendMessage:input_type -> chat.ChatMessage
+	22, // 32: chat.ChatService.InviteUser:input_type -> chat.Invitation
+	23, // 33: chat.ChatService.ReactToInvitation:input_type -> chat.InvitationReaction
+	31, // 34: chat.ChatService.ClearChatHistory:input_type -> chat.ClearHistoryRequest
+	32, // 35: chat.ChatService.UpdateOrDeleteCipherKey:input_type -> chat.UpdateCipherKeyRequest
+	25, // 36: chat.ChatService.Subscribe:input_type -> chat.SubscribeRequest
+	24, // 37: chat.ChatService.AckEvent:input_type -> chat.AckRequest
+	1,  // 38: chat.ChatService.Register:output_type -> chat.RegisterResponse
+	3,  // 39: chat.ChatService.Login:output_type -> chat.LoginResponse
+	5,  // 40: chat.ChatService.Refresh:output_type -> chat.RefreshResponse
+	34, // 41: chat.ChatService.Logout:output_type -> google.protobuf.Empty
+	34, // 42: chat.ChatService.PublishIdentityKey:output_type -> google.protobuf.Empty
+	7,  // 43: chat.ChatService.GetIdentityKey:output_type -> chat.IdentityKey
+	12, // 44: chat.ChatService.UploadPrekeys:output_type -> chat.PrekeyStatus
+	14, // 45: chat.ChatService.GetPrekeyBundle:output_type -> chat.PrekeyBundle
+	16, // 46: chat.ChatService.CreateRoom:output_type -> chat.CreateRoomResponse
+	34, // 47: chat.ChatService.CloseRoom:output_type -> google.protobuf.Empty
+	34, // 48: chat.ChatService.JoinRoom:output_type -> google.protobuf.Empty
+	34, // 49: chat.ChatService.LeaveRoom:output_type -> google.protobuf.Empty
+	21, // 50: chat.ChatService.GetRoomConfig:output_type -> chat.RoomConfig
+	34, // 51: chat.ChatService.SendMessage:output_type -> google.protobuf.Empty
+	34, // 52: chat.ChatService.InviteUser:output_type -> google.protobuf.Empty
+	34, // 53: chat.ChatService.ReactToInvitation:output_type -> google.protobuf.Empty
+	34, // 54: chat.ChatService.ClearChatHistory:output_type -> google.protobuf.Empty
+	34, // 55: chat.ChatService.UpdateOrDeleteCipherKey:output_type -> google.protobuf.Empty
+	26, // 56: chat.ChatService.Subscribe:output_type -> chat.Event
+	34, // 57: chat.ChatService.AckEvent:output_type -> google.protobuf.Empty
+	38, // [38:58] is the sub-list for method output_type
+	18, // [18:38] is the sub-list for method input_type
+	18, // [18:18] is the sub-list for extension type_name
+	18, // [18:18] is the sub-list for extension extendee
+	0,  // [0:18] is the sub-list for field type_name
 }
 
 func init() { file_chat_proto_init() }
@@ -1433,7 +2723,14 @@ func file_chat_proto_init() {
 	if File_chat_proto != nil {
 		return
 	}
-	file_chat_proto_msgTypes[12].OneofWrappers = []any{
+	file_chat_proto_msgTypes[26].OneofWrappers = []any{
+		(*Event_Message)(nil),
+		(*Event_Invitation)(nil),
+		(*Event_Reaction)(nil),
+		(*Event_ClearHistory)(nil),
+		(*Event_KeyUpdate)(nil),
+	}
+	file_chat_proto_msgTypes[27].OneofWrappers = []any{
 		(*ChatMessage_Text)(nil),
 		(*ChatMessage_Chunk)(nil),
 	}
@@ -1443,7 +2740,7 @@ func file_chat_proto_init() {
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_chat_proto_rawDesc), len(file_chat_proto_rawDesc)),
 			NumEnums:      0,
-			NumMessages:   18,
+			NumMessages:   33,
 			NumExtensions: 0,
 			NumServices:   1,
 		},
@@ -20,22 +20,26 @@ import (
 const _ = grpc.SupportPackageIsVersion9
 
 const (
-	ChatService_Register_FullMethodName                  = "/chat.ChatService/Register"
-	ChatService_Login_FullMethodName                     = "/chat.ChatService/Login"
-	ChatService_CreateRoom_FullMethodName                = "/chat.ChatService/CreateRoom"
-	ChatService_CloseRoom_FullMethodName                 = "/chat.ChatService/CloseRoom"
-	ChatService_JoinRoom_FullMethodName                  = "/chat.ChatService/JoinRoom"
-	ChatService_LeaveRoom_FullMethodName                 = "/chat.ChatService/LeaveRoom"
-	ChatService_SendMessage_FullMethodName               = "/chat.ChatService/SendMessage"
-	ChatService_ReceiveMessage_FullMethodName            = "/chat.ChatService/ReceiveMessage"
-	ChatService_InviteUser_FullMethodName                = "/chat.ChatService/InviteUser"
-	ChatService_ReceiveInvitation_FullMethodName         = "/chat.ChatService/ReceiveInvitation"
-	ChatService_ReactToInvitation_FullMethodName         = "/chat.ChatService/ReactToInvitation"
-	ChatService_ReceiveInvitationReaction_FullMethodName = "/chat.ChatService/ReceiveInvitationReaction"
-	ChatService_ClearChatHistory_FullMethodName          = "/chat.ChatService/ClearChatHistory"
-	ChatService_ReceiveChatHistoryRequest_FullMethodName = "/chat.ChatService/ReceiveChatHistoryRequest"
-	ChatService_UpdateOrDeleteCipherKey_FullMethodName   = "/chat.ChatService/UpdateOrDeleteCipherKey"
-	ChatService_AckEvent_FullMethodName                  = "/chat.ChatService/AckEvent"
+	ChatService_Register_FullMethodName                = "/chat.ChatService/Register"
+	ChatService_Login_FullMethodName                   = "/chat.ChatService/Login"
+	ChatService_Refresh_FullMethodName                 = "/chat.ChatService/Refresh"
+	ChatService_Logout_FullMethodName                  = "/chat.ChatService/Logout"
+	ChatService_PublishIdentityKey_FullMethodName      = "/chat.ChatService/PublishIdentityKey"
+	ChatService_GetIdentityKey_FullMethodName          = "/chat.ChatService/GetIdentityKey"
+	ChatService_UploadPrekeys_FullMethodName           = "/chat.ChatService/UploadPrekeys"
+	ChatService_GetPrekeyBundle_FullMethodName         = "/chat.ChatService/GetPrekeyBundle"
+	ChatService_CreateRoom_FullMethodName              = "/chat.ChatService/CreateRoom"
+	ChatService_CloseRoom_FullMethodName               = "/chat.ChatService/CloseRoom"
+	ChatService_JoinRoom_FullMethodName                = "/chat.ChatService/JoinRoom"
+	ChatService_LeaveRoom_FullMethodName               = "/chat.ChatService/LeaveRoom"
+	ChatService_GetRoomConfig_FullMethodName           = "/chat.ChatService/GetRoomConfig"
+	ChatService_SendMessage_FullMethodName             = "/chat.ChatService/SendMessage"
+	ChatService_InviteUser_FullMethodName              = "/chat.ChatService/InviteUser"
+	ChatService_ReactToInvitation_FullMethodName       = "/chat.ChatService/ReactToInvitation"
+	ChatService_ClearChatHistory_FullMethodName        = "/chat.ChatService/ClearChatHistory"
+	ChatService_UpdateOrDeleteCipherKey_FullMethodName = "/chat.ChatService/UpdateOrDeleteCipherKey"
+	ChatService_Subscribe_FullMethodName               = "/chat.ChatService/Subscribe"
+	ChatService_AckEvent_FullMethodName                = "/chat.ChatService/AckEvent"
 )
 
 // ChatServiceClient is the client API for ChatService service.
@@ -44,19 +48,24 @@ const (
 type ChatServiceClient interface {
 	Register(ctx context.Context, in *RegisterRequest, opts ...grpc.CallOption) (*RegisterResponse, error)
 	Login(ctx context.Context, in *LoginRequest, opts ...grpc.CallOption) (*LoginResponse, error)
+	Refresh(ctx context.Context, in *RefreshRequest, opts ...grpc.CallOption) (*RefreshResponse, error)
+	Logout(ctx context.Context, in *LogoutRequest, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	PublishIdentityKey(ctx context.Context, in *IdentityKey, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	GetIdentityKey(ctx context.Context, in *IdentityKeyRequest, opts ...grpc.CallOption) (*IdentityKey, error)
+	UploadPrekeys(ctx context.Context, in *UploadPrekeysRequest, opts ...grpc.CallOption) (*PrekeyStatus, error)
+	GetPrekeyBundle(ctx context.Context, in *PrekeyBundleRequest, opts ...grpc.CallOption) (*PrekeyBundle, error)
 	CreateRoom(ctx context.Context, in *CreateRoomRequest, opts ...grpc.CallOption) (*CreateRoomResponse, error)
 	CloseRoom(ctx context.Context, in *CloseRoomRequest, opts ...grpc.CallOption) (*emptypb.Empty, error)
 	JoinRoom(ctx context.Context, in *JoinRoomRequest, opts ...grpc.CallOption) (*emptypb.Empty, error)
 	LeaveRoom(ctx context.Context, in *LeaveRoomRequest, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	GetRoomConfig(ctx context.Context, in *RoomConfigRequest, opts ...grpc.CallOption) (*RoomConfig, error)
 	SendMessage(ctx context.Context, in *ChatMessage, opts ...grpc.CallOption) (*emptypb.Empty, error)
-	ReceiveMessage(ctx context.Context, in *ReceiveMessagesRequest, opts ...grpc.CallOption) (*ChatMessage, error)
 	InviteUser(ctx context.Context, in *Invitation, opts ...grpc.CallOption) (*emptypb.Empty, error)
-	ReceiveInvitation(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*Invitation, error)
 	ReactToInvitation(ctx context.Context, in *InvitationReaction, opts ...grpc.CallOption) (*emptypb.Empty, error)
-	ReceiveInvitationReaction(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*InvitationReaction, error)
 	ClearChatHistory(ctx context.Context, in *ClearHistoryRequest, opts ...grpc.CallOption) (*emptypb.Empty, error)
-	ReceiveChatHistoryRequest(ctx context.Context, in *ClearHistoryRequest, opts ...grpc.CallOption) (*ClearHistoryRequest, error)
 	UpdateOrDeleteCipherKey(ctx context.Context, in *UpdateCipherKeyRequest, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	// Subscribe доставляет все события пользователя; каждое подтверждается через AckEvent.
+	Subscribe(ctx context.Context, in *SubscribeRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Event], error)
 	AckEvent(ctx context.Context, in *AckRequest, opts ...grpc.CallOption) (*emptypb.Empty, error)
 }
 
@@ -88,120 +97,150 @@ func (c *chatServiceClient) Login(ctx context.Context, in *LoginRequest, opts ..
 	return out, nil
 }
 
-func (c *chatServiceClient) CreateRoom(ctx context.Context, in *CreateRoomRequest, opts ...grpc.CallOption) (*CreateRoomResponse, error) {
+func (c *chatServiceClient) Refresh(ctx context.Context, in *RefreshRequest, opts ...grpc.CallOption) (*RefreshResponse, error) {
 	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
-	out := new(CreateRoomResponse)
-	err := c.cc.Invoke(ctx, ChatService_CreateRoom_FullMethodName, in, out, cOpts...)
+	out := new(RefreshResponse)
+	err := c.cc.Invoke(ctx, ChatService_Refresh_FullMethodName, in, out, cOpts...)
 	if err != nil {
 		return nil, err
 	}
 	return out, nil
 }
 
-func (c *chatServiceClient) CloseRoom(ctx context.Context, in *CloseRoomRequest, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+func (c *chatServiceClient) Logout(ctx context.Context, in *LogoutRequest, opts ...grpc.CallOption) (*emptypb.Empty, error) {
 	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
 	out := new(emptypb.Empty)
-	err := c.cc.Invoke(ctx, ChatService_CloseRoom_FullMethodName, in, out, cOpts...)
+	err := c.cc.Invoke(ctx, ChatService_Logout_FullMethodName, in, out, cOpts...)
 	if err != nil {
 		return nil, err
 	}
 	return out, nil
 }
 
-func (c *chatServiceClient) JoinRoom(ctx context.Context, in *JoinRoomRequest, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+func (c *chatServiceClient) PublishIdentityKey(ctx context.Context, in *IdentityKey, opts ...grpc.CallOption) (*emptypb.Empty, error) {
 	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
 	out := new(emptypb.Empty)
-	err := c.cc.Invoke(ctx, ChatService_JoinRoom_FullMethodName, in, out, cOpts...)
+	err := c.cc.Invoke(ctx, ChatService_PublishIdentityKey_FullMethodName, in, out, cOpts...)
 	if err != nil {
 		return nil, err
 	}
 	return out, nil
 }
 
-func (c *chatServiceClient) LeaveRoom(ctx context.Context, in *LeaveRoomRequest, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+func (c *chatServiceClient) GetIdentityKey(ctx context.Context, in *IdentityKeyRequest, opts ...grpc.CallOption) (*IdentityKey, error) {
 	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
-	out := new(emptypb.Empty)
-	err := c.cc.Invoke(ctx, ChatService_LeaveRoom_FullMethodName, in, out, cOpts...)
+	out := new(IdentityKey)
+	err := c.cc.Invoke(ctx, ChatService_GetIdentityKey_FullMethodName, in, out, cOpts...)
 	if err != nil {
 		return nil, err
 	}
 	return out, nil
 }
 
-func (c *chatServiceClient) SendMessage(ctx context.Context, in *ChatMessage, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+func (c *chatServiceClient) UploadPrekeys(ctx context.Context, in *UploadPrekeysRequest, opts ...grpc.CallOption) (*PrekeyStatus, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(PrekeyStatus)
+	err := c.cc.Invoke(ctx, ChatService_UploadPrekeys_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *chatServiceClient) GetPrekeyBundle(ctx context.Context, in *PrekeyBundleRequest, opts ...grpc.CallOption) (*PrekeyBundle, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(PrekeyBundle)
+	err := c.cc.Invoke(ctx, ChatService_GetPrekeyBundle_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *chatServiceClient) CreateRoom(ctx context.Context, in *CreateRoomRequest, opts ...grpc.CallOption) (*CreateRoomResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(CreateRoomResponse)
+	err := c.cc.Invoke(ctx, ChatService_CreateRoom_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *chatServiceClient) CloseRoom(ctx context.Context, in *CloseRoomRequest, opts ...grpc.CallOption) (*emptypb.Empty, error) {
 	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
 	out := new(emptypb.Empty)
-	err := c.cc.Invoke(ctx, ChatService_SendMessage_FullMethodName, in, out, cOpts...)
+	err := c.cc.Invoke(ctx, ChatService_CloseRoom_FullMethodName, in, out, cOpts...)
 	if err != nil {
 		return nil, err
 	}
 	return out, nil
 }
 
-func (c *chatServiceClient) ReceiveMessage(ctx context.Context, in *ReceiveMessagesRequest, opts ...grpc.CallOption) (*ChatMessage, error) {
+func (c *chatServiceClient) JoinRoom(ctx context.Context, in *JoinRoomRequest, opts ...grpc.CallOption) (*emptypb.Empty, error) {
 	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
-	out := new(ChatMessage)
-	err := c.cc.Invoke(ctx, ChatService_ReceiveMessage_FullMethodName, in, out, cOpts...)
+	out := new(emptypb.Empty)
+	err := c.cc.Invoke(ctx, ChatService_JoinRoom_FullMethodName, in, out, cOpts...)
 	if err != nil {
 		return nil, err
 	}
 	return out, nil
 }
 
-func (c *chatServiceClient) InviteUser(ctx context.Context, in *Invitation, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+func (c *chatServiceClient) LeaveRoom(ctx context.Context, in *LeaveRoomRequest, opts ...grpc.CallOption) (*emptypb.Empty, error) {
 	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
 	out := new(emptypb.Empty)
-	err := c.cc.Invoke(ctx, ChatService_InviteUser_FullMethodName, in, out, cOpts...)
+	err := c.cc.Invoke(ctx, ChatService_LeaveRoom_FullMethodName, in, out, cOpts...)
 	if err != nil {
 		return nil, err
 	}
 	return out, nil
 }
 
-func (c *chatServiceClient) ReceiveInvitation(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*Invitation, error) {
+func (c *chatServiceClient) GetRoomConfig(ctx context.Context, in *RoomConfigRequest, opts ...grpc.CallOption) (*RoomConfig, error) {
 	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
-	out := new(Invitation)
-	err := c.cc.Invoke(ctx, ChatService_ReceiveInvitation_FullMethodName, in, out, cOpts...)
+	out := new(RoomConfig)
+	err := c.cc.Invoke(ctx, ChatService_GetRoomConfig_FullMethodName, in, out, cOpts...)
 	if err != nil {
 		return nil, err
 	}
 	return out, nil
 }
 
-func (c *chatServiceClient) ReactToInvitation(ctx context.Context, in *InvitationReaction, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+func (c *chatServiceClient) SendMessage(ctx context.Context, in *ChatMessage, opts ...grpc.CallOption) (*emptypb.Empty, error) {
 	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
 	out := new(emptypb.Empty)
-	err := c.cc.Invoke(ctx, ChatService_ReactToInvitation_FullMethodName, in, out, cOpts...)
+	err := c.cc.Invoke(ctx, ChatService_SendMessage_FullMethodName, in, out, cOpts...)
 	if err != nil {
 		return nil, err
 	}
 	return out, nil
 }
 
-func (c *chatServiceClient) ReceiveInvitationReaction(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*InvitationReaction, error) {
+func (c *chatServiceClient) InviteUser(ctx context.Context, in *Invitation, opts ...grpc.CallOption) (*emptypb.Empty, error) {
 	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
-	out := new(InvitationReaction)
-	err := c.cc.Invoke(ctx, ChatService_ReceiveInvitationReaction_FullMethodName, in, out, cOpts...)
+	out := new(emptypb.Empty)
+	err := c.cc.Invoke(ctx, ChatService_InviteUser_FullMethodName, in, out, cOpts...)
 	if err != nil {
 		return nil, err
 	}
 	return out, nil
 }
 
-func (c *chatServiceClient) ClearChatHistory(ctx context.Context, in *ClearHistoryRequest, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+func (c *chatServiceClient) ReactToInvitation(ctx context.Context, in *InvitationReaction, opts ...grpc.CallOption) (*emptypb.Empty, error) {
 	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
 	out := new(emptypb.Empty)
-	err := c.cc.Invoke(ctx, ChatService_ClearChatHistory_FullMethodName, in, out, cOpts...)
+	err := c.cc.Invoke(ctx, ChatService_ReactToInvitation_FullMethodName, in, out, cOpts...)
 	if err != nil {
 		return nil, err
 	}
 	return out, nil
 }
 
-func (c *chatServiceClient) ReceiveChatHistoryRequest(ctx context.Context, in *ClearHistoryRequest, opts ...grpc.CallOption) (*ClearHistoryRequest, error) {
+func (c *chatServiceClient) ClearChatHistory(ctx context.Context, in *ClearHistoryRequest, opts ...grpc.CallOption) (*emptypb.Empty, error) {
 	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
-	out := new(ClearHistoryRequest)
-	err := c.cc.Invoke(ctx, ChatService_ReceiveChatHistoryRequest_FullMethodName, in, out, cOpts...)
+	out := new(emptypb.Empty)
+	err := c.cc.Invoke(ctx, ChatService_ClearChatHistory_FullMethodName, in, out, cOpts...)
 	if err != nil {
 		return nil, err
 	}
@@ -218,6 +257,25 @@ func (c *chatServiceClient) UpdateOrDeleteCipherKey(ctx context.Context, in *Upd
 	return out, nil
 }
 
+func (c *chatServiceClient) Subscribe(ctx context.Context, in *SubscribeRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Event], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &ChatService_ServiceDesc.Streams[0], ChatService_Subscribe_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[SubscribeRequest, Event]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type ChatService_SubscribeClient = grpc.ServerStreamingClient[Event]
+
 func (c *chatServiceClient) AckEvent(ctx context.Context, in *AckRequest, opts ...grpc.CallOption) (*emptypb.Empty, error) {
 	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
 	out := new(emptypb.Empty)
@@ -234,19 +292,24 @@ func (c *chatServiceClient) AckEvent(ctx context.Context, in *AckRequest, opts .
 type ChatServiceServer interface {
 	Register(context.Context, *RegisterRequest) (*RegisterResponse, error)
 	Login(context.Context, *LoginRequest) (*LoginResponse, error)
+	Refresh(context.Context, *RefreshRequest) (*RefreshResponse, error)
+	Logout(context.Context, *LogoutRequest) (*emptypb.Empty, error)
+	PublishIdentityKey(context.Context, *IdentityKey) (*emptypb.Empty, error)
+	GetIdentityKey(context.Context, *IdentityKeyRequest) (*IdentityKey, error)
+	UploadPrekeys(context.Context, *UploadPrekeysRequest) (*PrekeyStatus, error)
+	GetPrekeyBundle(context.Context, *PrekeyBundleRequest) (*PrekeyBundle, error)
 	CreateRoom(context.Context, *CreateRoomRequest) (*CreateRoomResponse, error)
 	CloseRoom(context.Context, *CloseRoomRequest) (*emptypb.Empty, error)
 	JoinRoom(context.Context, *JoinRoomRequest) (*emptypb.Empty, error)
 	LeaveRoom(context.Context, *LeaveRoomRequest) (*emptypb.Empty, error)
+	GetRoomConfig(context.Context, *RoomConfigRequest) (*RoomConfig, error)
 	SendMessage(context.Context, *ChatMessage) (*emptypb.Empty, error)
-	ReceiveMessage(context.Context, *ReceiveMessagesRequest) (*ChatMessage, error)
 	InviteUser(context.Context, *Invitation) (*emptypb.Empty, error)
-	ReceiveInvitation(context.Context, *emptypb.Empty) (*Invitation, error)
 	ReactToInvitation(context.Context, *InvitationReaction) (*emptypb.Empty, error)
-	ReceiveInvitationReaction(context.Context, *emptypb.Empty) (*InvitationReaction, error)
 	ClearChatHistory(context.Context, *ClearHistoryRequest) (*emptypb.Empty, error)
-	ReceiveChatHistoryRequest(context.Context, *ClearHistoryRequest) (*ClearHistoryRequest, error)
 	UpdateOrDeleteCipherKey(context.Context, *UpdateCipherKeyRequest) (*emptypb.Empty, error)
+	// Subscribe доставляет все события пользователя; каждое подтверждается через AckEvent.
+	Subscribe(*SubscribeRequest, grpc.ServerStreamingServer[Event]) error
 	AckEvent(context.Context, *AckRequest) (*emptypb.Empty, error)
 	mustEmbedUnimplementedChatServiceServer()
 }
@@ -264,6 +327,24 @@ func (UnimplementedChatServiceServer) Register(context.Context, *RegisterRequest
 func (UnimplementedChatServiceServer) Login(context.Context, *LoginRequest) (*LoginResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method Login not implemented")
 }
+func (UnimplementedChatServiceServer) Refresh(context.Context, *RefreshRequest) (*RefreshResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Refresh not implemented")
+}
+func (UnimplementedChatServiceServer) Logout(context.Context, *LogoutRequest) (*emptypb.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Logout not implemented")
+}
+func (UnimplementedChatServiceServer) PublishIdentityKey(context.Context, *IdentityKey) (*emptypb.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method PublishIdentityKey not implemented")
+}
+func (UnimplementedChatServiceServer) GetIdentityKey(context.Context, *IdentityKeyRequest) (*IdentityKey, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetIdentityKey not implemented")
+}
+func (UnimplementedChatServiceServer) UploadPrekeys(context.Context, *UploadPrekeysRequest) (*PrekeyStatus, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UploadPrekeys not implemented")
+}
+func (UnimplementedChatServiceServer) GetPrekeyBundle(context.Context, *PrekeyBundleRequest) (*PrekeyBundle, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetPrekeyBundle not implemented")
+}
 func (UnimplementedChatServiceServer) CreateRoom(context.Context, *CreateRoomRequest) (*CreateRoomResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method CreateRoom not implemented")
 }
@@ -276,33 +357,27 @@ func (UnimplementedChatServiceServer) JoinRoom(context.Context, *JoinRoomRequest
 func (UnimplementedChatServiceServer) LeaveRoom(context.Context, *LeaveRoomRequest) (*emptypb.Empty, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method LeaveRoom not implemented")
 }
+func (UnimplementedChatServiceServer) GetRoomConfig(context.Context, *RoomConfigRequest) (*RoomConfig, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetRoomConfig not implemented")
+}
 func (UnimplementedChatServiceServer) SendMessage(context.Context, *ChatMessage) (*emptypb.Empty, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method SendMessage not implemented")
 }
-func (UnimplementedChatServiceServer) ReceiveMessage(context.Context, *ReceiveMessagesRequest) (*ChatMessage, error) {
-	return nil, status.Errorf(codes.Unimplemented, "method ReceiveMessage not implemented")
-}
 func (UnimplementedChatServiceServer) InviteUser(context.Context, *Invitation) (*emptypb.Empty, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method InviteUser not implemented")
 }
-func (UnimplementedChatServiceServer) ReceiveInvitation(context.Context, *emptypb.Empty) (*Invitation, error) {
-	return nil, status.Errorf(codes.Unimplemented, "method ReceiveInvitation not implemented")
-}
 func (UnimplementedChatServiceServer) ReactToInvitation(context.Context, *InvitationReaction) (*emptypb.Empty, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method ReactToInvitation not implemented")
 }
-func (UnimplementedChatServiceServer) ReceiveInvitationReaction(context.Context, *emptypb.Empty) (*InvitationReaction, error) {
-	return nil, status.Errorf(codes.Unimplemented, "method ReceiveInvitationReaction not implemented")
-}
 func (UnimplementedChatServiceServer) ClearChatHistory(context.Context, *ClearHistoryRequest) (*emptypb.Empty, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method ClearChatHistory not implemented")
 }
-func (UnimplementedChatServiceServer) ReceiveChatHistoryRequest(context.Context, *ClearHistoryRequest) (*ClearHistoryRequest, error) {
-	return nil, status.Errorf(codes.Unimplemented, "method ReceiveChatHistoryRequest not implemented")
-}
 func (UnimplementedChatServiceServer) UpdateOrDeleteCipherKey(context.Context, *UpdateCipherKeyRequest) (*emptypb.Empty, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method UpdateOrDeleteCipherKey not implemented")
 }
+func (UnimplementedChatServiceServer) Subscribe(*SubscribeRequest, grpc.ServerStreamingServer[Event]) error {
+	return status.Errorf(codes.Unimplemented, "method Subscribe not implemented")
+}
 func (UnimplementedChatServiceServer) AckEvent(context.Context, *AckRequest) (*emptypb.Empty, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method AckEvent not implemented")
 }
@@ -363,218 +438,272 @@ func _ChatService_Login_Handler(srv interface{}, ctx context.Context, dec func(i
 	return interceptor(ctx, in, info, handler)
 }
 
-func _ChatService_CreateRoom_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(CreateRoomRequest)
+func _ChatService_Refresh_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RefreshRequest)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
 	if interceptor == nil {
-		return srv.(ChatServiceServer).CreateRoom(ctx, in)
+		return srv.(ChatServiceServer).Refresh(ctx, in)
 	}
 	info := &grpc.UnaryServerInfo{
 		Server:     srv,
-		FullMethod: ChatService_CreateRoom_FullMethodName,
+		FullMethod: ChatService_Refresh_FullMethodName,
 	}
 	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(ChatServiceServer).CreateRoom(ctx, req.(*CreateRoomRequest))
+		return srv.(ChatServiceServer).Refresh(ctx, req.(*RefreshRequest))
 	}
 	return interceptor(ctx, in, info, handler)
 }
 
-func _ChatService_CloseRoom_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(CloseRoomRequest)
+func _ChatService_Logout_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(LogoutRequest)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
 	if interceptor == nil {
-		return srv.(ChatServiceServer).CloseRoom(ctx, in)
+		return srv.(ChatServiceServer).Logout(ctx, in)
 	}
 	info := &grpc.UnaryServerInfo{
 		Server:     srv,
-		FullMethod: ChatService_CloseRoom_FullMethodName,
+		FullMethod: ChatService_Logout_FullMethodName,
 	}
 	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(ChatServiceServer).CloseRoom(ctx, req.(*CloseRoomRequest))
+		return srv.(ChatServiceServer).Logout(ctx, req.(*LogoutRequest))
 	}
 	return interceptor(ctx, in, info, handler)
 }
 
-func _ChatService_JoinRoom_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(JoinRoomRequest)
+func _ChatService_PublishIdentityKey_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(IdentityKey)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
 	if interceptor == nil {
-		return srv.(ChatServiceServer).JoinRoom(ctx, in)
+		return srv.(ChatServiceServer).PublishIdentityKey(ctx, in)
 	}
 	info := &grpc.UnaryServerInfo{
 		Server:     srv,
-		FullMethod: ChatService_JoinRoom_FullMethodName,
+		FullMethod: ChatService_PublishIdentityKey_FullMethodName,
 	}
 	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(ChatServiceServer).JoinRoom(ctx, req.(*JoinRoomRequest))
+		return srv.(ChatServiceServer).PublishIdentityKey(ctx, req.(*IdentityKey))
 	}
 	return interceptor(ctx, in, info, handler)
 }
 
-func _ChatService_LeaveRoom_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(LeaveRoomRequest)
+func _ChatService_GetIdentityKey_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(IdentityKeyRequest)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
 	if interceptor == nil {
-		return srv.(ChatServiceServer).LeaveRoom(ctx, in)
+		return srv.(ChatServiceServer).GetIdentityKey(ctx, in)
 	}
 	info := &grpc.UnaryServerInfo{
 		Server:     srv,
-		FullMethod: ChatService_LeaveRoom_FullMethodName,
+		FullMethod: ChatService_GetIdentityKey_FullMethodName,
 	}
 	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(ChatServiceServer).LeaveRoom(ctx, req.(*LeaveRoomRequest))
+		return srv.(ChatServiceServer).GetIdentityKey(ctx, req.(*IdentityKeyRequest))
 	}
 	return interceptor(ctx, in, info, handler)
 }
 
-func _ChatService_SendMessage_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(ChatMessage)
+func _ChatService_UploadPrekeys_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UploadPrekeysRequest)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
 	if interceptor == nil {
-		return srv.(ChatServiceServer).SendMessage(ctx, in)
+		return srv.(ChatServiceServer).UploadPrekeys(ctx, in)
 	}
 	info := &grpc.UnaryServerInfo{
 		Server:     srv,
-		FullMethod: ChatService_SendMessage_FullMethodName,
+		FullMethod: ChatService_UploadPrekeys_FullMethodName,
 	}
 	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(ChatServiceServer).SendMessage(ctx, req.(*ChatMessage))
+		return srv.(ChatServiceServer).UploadPrekeys(ctx, req.(*UploadPrekeysRequest))
 	}
 	return interceptor(ctx, in, info, handler)
 }
 
-func _ChatService_ReceiveMessage_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(ReceiveMessagesRequest)
+func _ChatService_GetPrekeyBundle_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PrekeyBundleRequest)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
 	if interceptor == nil {
-		return srv.(ChatServiceServer).ReceiveMessage(ctx, in)
+		return srv.(ChatServiceServer).GetPrekeyBundle(ctx, in)
 	}
 	info := &grpc.UnaryServerInfo{
 		Server:     srv,
-		FullMethod: ChatService_ReceiveMessage_FullMethodName,
+		FullMethod: ChatService_GetPrekeyBundle_FullMethodName,
 	}
 	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(ChatServiceServer).ReceiveMessage(ctx, req.(*ReceiveMessagesRequest))
+		return srv.(ChatServiceServer).GetPrekeyBundle(ctx, req.(*PrekeyBundleRequest))
 	}
 	return interceptor(ctx, in, info, handler)
 }
 
-func _ChatService_InviteUser_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(Invitation)
+func _ChatService_CreateRoom_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateRoomRequest)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
 	if interceptor == nil {
-		return srv.(ChatServiceServer).InviteUser(ctx, in)
+		return srv.(ChatServiceServer).CreateRoom(ctx, in)
 	}
 	info := &grpc.UnaryServerInfo{
 		Server:     srv,
-		FullMethod: ChatService_InviteUser_FullMethodName,
+		FullMethod: ChatService_CreateRoom_FullMethodName,
 	}
 	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(ChatServiceServer).InviteUser(ctx, req.(*Invitation))
+		return srv.(ChatServiceServer).CreateRoom(ctx, req.(*CreateRoomRequest))
 	}
 	return interceptor(ctx, in, info, handler)
 }
 
-func _ChatService_ReceiveInvitation_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(emptypb.Empty)
+func _ChatService_CloseRoom_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CloseRoomRequest)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
 	if interceptor == nil {
-		return srv.(ChatServiceServer).ReceiveInvitation(ctx, in)
+		return srv.(ChatServiceServer).CloseRoom(ctx, in)
 	}
 	info := &grpc.UnaryServerInfo{
 		Server:     srv,
-		FullMethod: ChatService_ReceiveInvitation_FullMethodName,
+		FullMethod: ChatService_CloseRoom_FullMethodName,
 	}
 	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(ChatServiceServer).ReceiveInvitation(ctx, req.(*emptypb.Empty))
+		return srv.(ChatServiceServer).CloseRoom(ctx, req.(*CloseRoomRequest))
 	}
 	return interceptor(ctx, in, info, handler)
 }
 
-func _ChatService_ReactToInvitation_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(InvitationReaction)
+func _ChatService_JoinRoom_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(JoinRoomRequest)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
 	if interceptor == nil {
-		return srv.(ChatServiceServer).ReactToInvitation(ctx, in)
+		return srv.(ChatServiceServer).JoinRoom(ctx, in)
 	}
 	info := &grpc.UnaryServerInfo{
 		Server:     srv,
-		FullMethod: ChatService_ReactToInvitation_FullMethodName,
+		FullMethod: ChatService_JoinRoom_FullMethodName,
 	}
 	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(ChatServiceServer).ReactToInvitation(ctx, req.(*InvitationReaction))
+		return srv.(ChatServiceServer).JoinRoom(ctx, req.(*JoinRoomRequest))
 	}
 	return interceptor(ctx, in, info, handler)
 }
 
-func _ChatService_ReceiveInvitationReaction_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(emptypb.Empty)
+func _ChatService_LeaveRoom_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(LeaveRoomRequest)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
 	if interceptor == nil {
-		return srv.(ChatServiceServer).ReceiveInvitationReaction(ctx, in)
+		return srv.(ChatServiceServer).LeaveRoom(ctx, in)
 	}
 	info := &grpc.UnaryServerInfo{
 		Server:     srv,
-		FullMethod: ChatService_ReceiveInvitationReaction_FullMethodName,
+		FullMethod: ChatService_LeaveRoom_FullMethodName,
 	}
 	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(ChatServiceServer).ReceiveInvitationReaction(ctx, req.(*emptypb.Empty))
+		return srv.(ChatServiceServer).LeaveRoom(ctx, req.(*LeaveRoomRequest))
 	}
 	return interceptor(ctx, in, info, handler)
 }
 
-func _ChatService_ClearChatHistory_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(ClearHistoryRequest)
+func _ChatService_GetRoomConfig_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RoomConfigRequest)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
 	if interceptor == nil {
-		return srv.(ChatServiceServer).ClearChatHistory(ctx, in)
+		return srv.(ChatServiceServer).GetRoomConfig(ctx, in)
 	}
 	info := &grpc.UnaryServerInfo{
 		Server:     srv,
-		FullMethod: ChatService_ClearChatHistory_FullMethodName,
+		FullMethod: ChatService_GetRoomConfig_FullMethodName,
 	}
 	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(ChatServiceServer).ClearChatHistory(ctx, req.(*ClearHistoryRequest))
+		return srv.(ChatServiceServer).GetRoomConfig(ctx, req.(*RoomConfigRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ChatService_SendMessage_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ChatMessage)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ChatServiceServer).SendMessage(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ChatService_SendMessage_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ChatServiceServer).SendMessage(ctx, req.(*ChatMessage))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ChatService_InviteUser_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Invitation)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ChatServiceServer).InviteUser(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ChatService_InviteUser_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ChatServiceServer).InviteUser(ctx, req.(*Invitation))
 	}
 	return interceptor(ctx, in, info, handler)
 }
 
-func _ChatService_ReceiveChatHistoryRequest_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+func _ChatService_ReactToInvitation_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(InvitationReaction)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ChatServiceServer).ReactToInvitation(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ChatService_ReactToInvitation_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ChatServiceServer).ReactToInvitation(ctx, req.(*InvitationReaction))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ChatService_ClearChatHistory_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(ClearHistoryRequest)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
 	if interceptor == nil {
-		return srv.(ChatServiceServer).ReceiveChatHistoryRequest(ctx, in)
+		return srv.(ChatServiceServer).ClearChatHistory(ctx, in)
 	}
 	info := &grpc.UnaryServerInfo{
 		Server:     srv,
-		FullMethod: ChatService_ReceiveChatHistoryRequest_FullMethodName,
+		FullMethod: ChatService_ClearChatHistory_FullMethodName,
 	}
 	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(ChatServiceServer).ReceiveChatHistoryRequest(ctx, req.(*ClearHistoryRequest))
+		return srv.(ChatServiceServer).ClearChatHistory(ctx, req.(*ClearHistoryRequest))
 	}
 	return interceptor(ctx, in, info, handler)
 }
@@ -597,6 +726,17 @@ func _ChatService_UpdateOrDeleteCipherKey_Handler(srv interface{}, ctx context.C
 	return interceptor(ctx, in, info, handler)
 }
 
+func _ChatService_Subscribe_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(SubscribeRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(ChatServiceServer).Subscribe(m, &grpc.GenericServerStream[SubscribeRequest, Event]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type ChatService_SubscribeServer = grpc.ServerStreamingServer[Event]
+
 func _ChatService_AckEvent_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(AckRequest)
 	if err := dec(in); err != nil {
@@ -630,6 +770,30 @@ var ChatService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "Login",
 			Handler:    _ChatService_Login_Handler,
 		},
+		{
+			MethodName: "Refresh",
+			Handler:    _ChatService_Refresh_Handler,
+		},
+		{
+			MethodName: "Logout",
+			Handler:    _ChatService_Logout_Handler,
+		},
+		{
+			MethodName: "PublishIdentityKey",
+			Handler:    _ChatService_PublishIdentityKey_Handler,
+		},
+		{
+			MethodName: "GetIdentityKey",
+			Handler:    _ChatService_GetIdentityKey_Handler,
+		},
+		{
+			MethodName: "UploadPrekeys",
+			Handler:    _ChatService_UploadPrekeys_Handler,
+		},
+		{
+			MethodName: "GetPrekeyBundle",
+			Handler:    _ChatService_GetPrekeyBundle_Handler,
+		},
 		{
 			MethodName: "CreateRoom",
 			Handler:    _ChatService_CreateRoom_Handler,
@@ -647,37 +811,25 @@ var ChatService_ServiceDesc = grpc.ServiceDesc{
 			Handler:    _ChatService_LeaveRoom_Handler,
 		},
 		{
-			MethodName: "SendMessage",
-			Handler:    _ChatService_SendMessage_Handler,
+			MethodName: "GetRoomConfig",
+			Handler:    _ChatService_GetRoomConfig_Handler,
 		},
 		{
-			MethodName: "ReceiveMessage",
-			Handler:    _ChatService_ReceiveMessage_Handler,
+			MethodName: "SendMessage",
+			Handler:    _ChatService_SendMessage_Handler,
 		},
 		{
 			MethodName: "InviteUser",
 			Handler:    _ChatService_InviteUser_Handler,
 		},
-		{
-			MethodName: "ReceiveInvitation",
-			Handler:    _ChatService_ReceiveInvitation_Handler,
-		},
 		{
 			MethodName: "ReactToInvitation",
 			Handler:    _ChatService_ReactToInvitation_Handler,
 		},
-		{
-			MethodName: "ReceiveInvitationReaction",
-			Handler:    _ChatService_ReceiveInvitationReaction_Handler,
-		},
 		{
 			MethodName: "ClearChatHistory",
 			Handler:    _ChatService_ClearChatHistory_Handler,
 		},
-		{
-			MethodName: "ReceiveChatHistoryRequest",
-			Handler:    _ChatService_ReceiveChatHistoryRequest_Handler,
-		},
 		{
 			MethodName: "UpdateOrDeleteCipherKey",
 			Handler:    _ChatService_UpdateOrDeleteCipherKey_Handler,
@@ -687,6 +839,12 @@ var ChatService_ServiceDesc = grpc.ServiceDesc{
 			Handler:    _ChatService_AckEvent_Handler,
 		},
 	},
-	Streams:  []grpc.StreamDesc{},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Subscribe",
+			Handler:       _ChatService_Subscribe_Handler,
+			ServerStreams: true,
+		},
+	},
 	Metadata: "chat.proto",
 }